@@ -0,0 +1,213 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+//	Personal access tokens: long-lived tokens of scripts and integrations
+// ----------------------------------------------------------------------------
+
+// PersonalAccessTokenPrefix starts all personal access tokens, which are sent
+// as Bearer tokens like JWT
+const PersonalAccessTokenPrefix = "alun_pat_"
+
+// Scopes of the personal access tokens. A write scope also grants the read
+// scope of the same service
+const (
+	ScopeMemoRead          = "memo:read"
+	ScopeMemoWrite         = "memo:write"
+	ScopeNotificationRead  = "notification:read"
+	ScopeNotificationWrite = "notification:write"
+)
+
+// KnownScopes lists the scopes which can be granted to a personal access
+// token
+var KnownScopes = []string{ScopeMemoRead, ScopeMemoWrite, ScopeNotificationRead, ScopeNotificationWrite}
+
+// accessTokenCacheDuration is how long a service may accept a deleted
+// personal access token verified by another service
+const accessTokenCacheDuration = 30 * time.Second
+
+// errAccessTokenUnverifiable is returned when no verifier is registered
+var errAccessTokenUnverifiable = errors.New("Personal access tokens are not supported")
+
+// IsKnownScope is true if scope can be granted
+func IsKnownScope(scope string) bool {
+	for _, knownScope := range KnownScopes {
+		if scope == knownScope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPersonalAccessToken is true if the claims come from a personal access
+// token, which is restricted to its scopes
+func (c JwtClaims) IsPersonalAccessToken() bool {
+	return c.Scopes != nil
+}
+
+// HasScope is true if the claims grant scope. A login JWT grants all scopes
+func (c JwtClaims) HasScope(scope string) bool {
+	if !c.IsPersonalAccessToken() {
+		return true
+	}
+
+	writeScope := strings.TrimSuffix(scope, ":read") + ":write"
+	for _, granted := range c.Scopes {
+		if granted == scope || granted == writeScope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope restricts an AccessChecker to the personal access tokens with
+// scope. Personal access tokens are rejected by the endpoints which do not
+// require a scope
+func RequireScope(scope string, canAccess AccessChecker) AccessChecker {
+	return func(r *http.Request, claims JwtClaims) Access {
+		if !claims.HasScope(scope) {
+			return Access{}
+		}
+		return Access{IsAllowed: canAccess(r, claims).IsAllowed, IsScoped: true}
+	}
+}
+
+// PersonalAccessTokenVerifier finds the claims of a personal access token
+type PersonalAccessTokenVerifier interface {
+	VerifyPersonalAccessToken(token string) (*JwtClaims, error)
+}
+
+var accessTokenVerifier PersonalAccessTokenVerifier
+
+// SetPersonalAccessTokenVerifier registers how the personal access tokens are
+// verified. In monolithic mode, the user package registers itself when loaded.
+// Without verifier, all personal access tokens are rejected
+func SetPersonalAccessTokenVerifier(verifier PersonalAccessTokenVerifier) {
+	accessTokenVerifier = verifier
+}
+
+// decodePersonalAccessToken verifies a personal access token with the
+// registered verifier
+func decodePersonalAccessToken(token string) (*JwtClaims, *ServiceMessage) {
+	if accessTokenVerifier == nil {
+		coreLogger.Warn("[PAT] %v", errAccessTokenUnverifiable)
+		return nil, isTokenInvalid
+	}
+
+	claims, err := accessTokenVerifier.VerifyPersonalAccessToken(token)
+	if err != nil || claims == nil {
+		return nil, isTokenInvalid
+	}
+	if claims.Scopes == nil {
+		claims.Scopes = []string{}
+	}
+
+	return claims, isAuthorized
+}
+
+// AccessTokenVerification is the request of the internal verification
+// endpoint of the user service
+type AccessTokenVerification struct {
+	Token string `json:"token"`
+}
+
+// httpAccessTokenVerifier verifies the personal access tokens with the
+// internal endpoint of the user service. Valid tokens are cached to avoid a
+// call per request
+type httpAccessTokenVerifier struct {
+	url    string
+	secret string
+	client *http.Client
+	mutex  sync.Mutex
+	cache  map[string]cachedAccessToken
+}
+
+type cachedAccessToken struct {
+	claims   JwtClaims
+	cachedAt time.Time
+}
+
+// NewHTTPAccessTokenVerifier verifies the personal access tokens with the
+// internal endpoint of the user service at url, authenticated with the shared
+// secret of the revocations
+func NewHTTPAccessTokenVerifier(url string, secret string) PersonalAccessTokenVerifier {
+	return &httpAccessTokenVerifier{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 5 * time.Second},
+		cache:  make(map[string]cachedAccessToken),
+	}
+}
+
+func (v *httpAccessTokenVerifier) VerifyPersonalAccessToken(token string) (*JwtClaims, error) {
+	h := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(h[:])
+	now := time.Now()
+
+	v.mutex.Lock()
+	cached, isCached := v.cache[key]
+	v.mutex.Unlock()
+	if isCached && now.Sub(cached.cachedAt) < accessTokenCacheDuration {
+		claims := cached.claims
+		return &claims, nil
+	}
+
+	claims, err := v.verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mutex.Lock()
+	for cachedKey, cachedToken := range v.cache {
+		if now.Sub(cachedToken.cachedAt) >= accessTokenCacheDuration {
+			delete(v.cache, cachedKey)
+		}
+	}
+	v.cache[key] = cachedAccessToken{claims: *claims, cachedAt: now}
+	v.mutex.Unlock()
+
+	return claims, nil
+}
+
+func (v *httpAccessTokenVerifier) verify(token string) (*JwtClaims, error) {
+	body, err := json.Marshal(AccessTokenVerification{Token: token})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, v.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(RevocationSecretHeader, v.secret)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("User service answered %s", resp.Status)
+	}
+
+	var claims JwtClaims
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
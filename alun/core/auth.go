@@ -1,8 +1,11 @@
 package core
 
 import (
+	"crypto/rsa"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
@@ -12,19 +15,78 @@ import (
 // ----------------------------------------------------------------------------
 
 // CheckPublic always returns true to provided public access
-var CheckPublic AccessChecker = func(r *http.Request, claims JwtClaims) bool {
-	return true
+var CheckPublic AccessChecker = func(r *http.Request, claims JwtClaims) Access {
+	return Access{IsAllowed: true}
 }
 
 // CheckIfLogged is the AuthChecher ensuring the request has a properly
 // logged-in user
-var CheckIfLogged AccessChecker = func(r *http.Request, claims JwtClaims) bool {
-	return claims.UserID != ""
+var CheckIfLogged AccessChecker = func(r *http.Request, claims JwtClaims) Access {
+	return Access{IsAllowed: claims.UserID != ""}
 }
 
 // CheckIfAdmin simply checks if the JWT has admin privilege
-var CheckIfAdmin AccessChecker = func(r *http.Request, claims JwtClaims) bool {
-	return claims.IsAdmin
+var CheckIfAdmin AccessChecker = func(r *http.Request, claims JwtClaims) Access {
+	return Access{IsAllowed: claims.IsAdmin}
+}
+
+// All passes if all the checkers pass, and is scoped if one of them is.
+// Checkers are evaluated in order and the first failure stops the evaluation
+func All(checkers ...AccessChecker) AccessChecker {
+	return func(r *http.Request, claims JwtClaims) Access {
+		result := Access{IsAllowed: true}
+		for _, canAccess := range checkers {
+			access := canAccess(r, claims)
+			if !access.IsAllowed {
+				return Access{}
+			}
+			result.IsScoped = result.IsScoped || access.IsScoped
+		}
+		return result
+	}
+}
+
+// Any passes if at least one checker passes. Checkers are evaluated in order
+// and the first scoped success stops the evaluation, otherwise the first
+// success is returned
+func Any(checkers ...AccessChecker) AccessChecker {
+	return func(r *http.Request, claims JwtClaims) Access {
+		var result Access
+		for _, canAccess := range checkers {
+			access := canAccess(r, claims)
+			if access.IsAllowed && access.IsScoped {
+				return access
+			}
+			if access.IsAllowed && !result.IsAllowed {
+				result = access
+			}
+		}
+		return result
+	}
+}
+
+// Not passes if the checker fails. It is never scoped as a failing scoped
+// checker does not grant its scope
+func Not(canAccess AccessChecker) AccessChecker {
+	return func(r *http.Request, claims JwtClaims) Access {
+		return Access{IsAllowed: !canAccess(r, claims).IsAllowed}
+	}
+}
+
+// HasPermission passes if the roles of the user grant the permission. Admins
+// have all the permissions
+func HasPermission(permission string) AccessChecker {
+	return func(r *http.Request, claims JwtClaims) Access {
+		return Access{IsAllowed: claims.HasPermission(permission)}
+	}
+}
+
+// IsOwnerOf passes if the route variable is the ID of the user, such as
+// "userId" in "detail/{userId}"
+func IsOwnerOf(varName string) AccessChecker {
+	return func(r *http.Request, claims JwtClaims) Access {
+		return Access{IsAllowed: claims.UserID != "" && GetVar(r, varName) == claims.UserID}
+	}
 }
 
 // DoIfAccess ensures that the provided accessChecker passes before proceeding
@@ -46,10 +108,14 @@ func DoIfAccess(canAccess AccessChecker, authenticatedHandler AuthenticatedHandl
 			return
 		}
 
-		if canAccess(r, claims) {
-			authenticatedHandler(w, r, claims)
-		} else {
+		// Personal access tokens only pass the checkers requiring a scope
+		access := canAccess(r, claims)
+		if claims.IsPersonalAccessToken() && !access.IsScoped {
+			isScopeMissing.Write(w, r)
+		} else if !access.IsAllowed {
 			isNotAuthorized.Write(w, r)
+		} else {
+			authenticatedHandler(w, r, claims)
 		}
 
 	})
@@ -63,14 +129,18 @@ func DoIfAccess(canAccess AccessChecker, authenticatedHandler AuthenticatedHandl
 // List of claims is based on https://tools.ietf.org/html/rfc7519 found through
 // https://auth0.com/docs/tokens/jwt-claims.
 //
-// HMAC is chosen over RSA to protect against manipulation:
-// https://security.stackexchange.com/a/220190
+// RSA is chosen over HMAC so that only the user service holds the signing key
+// while other services verify tokens with the public keys. The "kid" header
+// tells which key signed the token, as several keys are active during a key
+// rotation. The algorithm is enforced when decoding to protect against
+// manipulation: https://security.stackexchange.com/a/220190
 //
 // Generate Token	: https://godoc.org/github.com/dgrijalva/jwt-go#example-New--Hmac
 // Custom claims	: https://godoc.org/github.com/dgrijalva/jwt-go#NewWithClaims
-func BuildJWT(claims JwtClaims) (string, error) {
-	newToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return newToken.SignedString([]byte(jwtSecretKey))
+func BuildJWT(claims JwtClaims, kid string, key *rsa.PrivateKey) (string, error) {
+	newToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	newToken.Header["kid"] = kid
+	return newToken.SignedString(key)
 }
 
 // DecodeJWT extracts the claims from a JWT if it is valid.
@@ -80,6 +150,9 @@ func BuildJWT(claims JwtClaims) (string, error) {
 // 11-Apr-2020: Make authentication check 100% stateless for a microservice-ready
 // architecture by removing the check in the database of the JWT status: a pure JWT
 // is 100% stateless.
+// Revoked tokens are rejected from an in-memory list of revocations, refreshed
+// from the user service, so that each request does not hit the database.
+// Personal access tokens are not JWT and are verified by the user service.
 //
 // Returns:
 // - JwtClaims 	: if token is present and valid
@@ -100,21 +173,35 @@ func DecodeJWT(r *http.Request) (*JwtClaims, *ServiceMessage) {
 
 	// Get the header value and strip "Bearer " out
 	tokenString := authHeader[7:]
+	if strings.HasPrefix(tokenString, PersonalAccessTokenPrefix) {
+		return decodePersonalAccessToken(tokenString)
+	}
 
 	// Parse token. Make sure hashing method is the correct one
 	token, err := jwt.ParseWithClaims(tokenString, &JwtClaims{}, func(token *jwt.Token) (interface{}, error) {
 		// Don't forget to validate the alg is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if token.Method != jwt.SigningMethodRS256 {
 			return nil, fmt.Errorf("[JWT decode] Unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(jwtSecretKey), nil
+		kid, _ := token.Header["kid"].(string)
+		if jwtPublicKeys == nil || kid == "" {
+			return nil, errUnknownKeyID
+		}
+		return jwtPublicKeys.PublicKey(kid)
 	})
 
 	// Decipher claims
 	if claims, ok := token.Claims.(*JwtClaims); ok {
 
-		// Check token validity
+		// Check token validity. Tokens without ID cannot be revoked and are
+		// issued before revocation was available
 		if token.Valid {
+			if claims.Id == "" {
+				return claims, isTokenInvalidated
+			}
+			if revokedMsg := checkRevocation(claims.Id, time.Now()); revokedMsg != nil {
+				return claims, revokedMsg
+			}
 			return claims, isAuthorized
 		}
 
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+//	Users directory: users information shared between services
+// ----------------------------------------------------------------------------
+
+// UserContact is the information of an user that other services can use to
+// reach this user
+type UserContact struct {
+	ID       string `json:"id"`
+	Email    string `json:"email"`
+	Username string `json:"username,omitempty"`
+	Timezone string `json:"timezone,omitempty"` // IANA name, UTC if empty
+}
+
+// UserDirectory finds the contact of users from another service than the user
+// service. Unknown users are not part of the returned contacts
+type UserDirectory interface {
+	FindUserContacts(userIDs []string) ([]UserContact, error)
+	// FindUserContactsByUsernames ignores the case of the usernames. Several
+	// users may share a username
+	FindUserContactsByUsernames(usernames []string) ([]UserContact, error)
+}
+
+var userDirectory UserDirectory
+
+// SetUserDirectory registers the directory of the users. In monolithic mode,
+// the user package registers itself when loaded
+func SetUserDirectory(directory UserDirectory) {
+	userDirectory = directory
+}
+
+// GetUserDirectory returns the registered users directory, nil if none is
+// available
+func GetUserDirectory() UserDirectory {
+	return userDirectory
+}
+
+// UserContactsRequest is the request of the internal contacts endpoint of the
+// user service. Users are found by ID, or by username if no ID is provided
+type UserContactsRequest struct {
+	UserIDs   []string `json:"userIds,omitempty"`
+	Usernames []string `json:"usernames,omitempty"`
+}
+
+// httpUserDirectory finds the contact of users with the internal endpoint of
+// the user service
+type httpUserDirectory struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewHTTPUserDirectory finds the contact of users with the internal endpoint of
+// the user service at url, authenticated with the shared secret of the
+// revocations
+func NewHTTPUserDirectory(url string, secret string) UserDirectory {
+	return &httpUserDirectory{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (d *httpUserDirectory) FindUserContacts(userIDs []string) ([]UserContact, error) {
+	return d.find(UserContactsRequest{UserIDs: userIDs})
+}
+
+func (d *httpUserDirectory) FindUserContactsByUsernames(usernames []string) ([]UserContact, error) {
+	return d.find(UserContactsRequest{Usernames: usernames})
+}
+
+func (d *httpUserDirectory) find(contactsReq UserContactsRequest) ([]UserContact, error) {
+	if len(contactsReq.UserIDs) == 0 && len(contactsReq.Usernames) == 0 {
+		return []UserContact{}, nil
+	}
+
+	body, err := json.Marshal(contactsReq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(RevocationSecretHeader, d.secret)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("User service answered %s", resp.Status)
+	}
+
+	contacts := make([]UserContact, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&contacts); err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+//	JSON Web Keys: public keys verifying the JWT signed by the user service
+// ----------------------------------------------------------------------------
+
+// DefaultJWKSRefreshInterval is how often a service fetches the public keys
+// from the user service. Unknown key IDs trigger a refresh earlier
+const DefaultJWKSRefreshInterval = 10 * time.Minute
+
+// jwksUnknownKeyInterval limits the refreshes triggered by unknown key IDs,
+// which may be sent by anyone
+const jwksUnknownKeyInterval = 30 * time.Second
+
+// errUnknownKeyID is returned when no public key has the key ID of a token
+var errUnknownKeyID = errors.New("Unknown key ID")
+
+// JSONWebKey is the public part of a RSA signing key, as defined by
+// https://tools.ietf.org/html/rfc7517
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JSONWebKeySet is the list of public keys published by the user service
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKey publishes a RSA public key
+func NewJSONWebKey(kid string, key *rsa.PublicKey) JSONWebKey {
+	return JSONWebKey{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// PublicKey decodes a RSA public key
+func (k JSONWebKey) PublicKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, fmt.Errorf("Key %s has unsupported type %s", k.Kid, k.Kty)
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("Key %s has an invalid exponent", k.Kid)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// PublicKeyProvider finds the public key of a key ID to verify a JWT
+type PublicKeyProvider interface {
+	PublicKey(kid string) (*rsa.PublicKey, error)
+}
+
+var jwtPublicKeys PublicKeyProvider
+
+// SetJWTPublicKeys registers the public keys verifying the JWT. In monolithic
+// mode, the user package registers its signing keys when loaded. Without
+// public keys, all tokens are rejected
+func SetJWTPublicKeys(provider PublicKeyProvider) {
+	jwtPublicKeys = provider
+}
+
+// jwksKeyProvider caches the public keys of the JWKS endpoint of the user
+// service. The endpoint is fetched without holding the mutex, by one request
+// at a time, so that the known keys are available during a refresh
+type jwksKeyProvider struct {
+	url         string
+	interval    time.Duration
+	client      *http.Client
+	mutex       sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	nextSync    time.Time
+	lastRefresh time.Time
+	refreshing  chan struct{} // closed when the running refresh is done
+}
+
+// NewJWKSKeyProvider fetches the public keys from the JWKS endpoint at url
+// and refreshes them every interval
+func NewJWKSKeyProvider(url string, interval time.Duration) PublicKeyProvider {
+	return &jwksKeyProvider{
+		url:      url,
+		interval: interval,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		keys:     make(map[string]*rsa.PublicKey),
+	}
+}
+
+func (p *jwksKeyProvider) PublicKey(kid string) (*rsa.PublicKey, error) {
+	now := time.Now()
+
+	p.mutex.Lock()
+	key, isKnown := p.keys[kid]
+	refreshing := p.refreshing
+	isStale := !now.Before(p.nextSync)
+	isDue := refreshing == nil && (isStale || (!isKnown && now.Sub(p.lastRefresh) >= jwksUnknownKeyInterval))
+	if isDue {
+		p.lastRefresh = now
+		p.nextSync = now.Add(p.interval)
+		refreshing = make(chan struct{})
+		p.refreshing = refreshing
+	}
+	p.mutex.Unlock()
+
+	if isDue {
+		p.refresh(refreshing)
+	}
+
+	// An unknown key may be in the key set being fetched
+	if !isKnown && refreshing != nil {
+		<-refreshing
+
+		p.mutex.Lock()
+		key, isKnown = p.keys[kid]
+		p.mutex.Unlock()
+	}
+
+	if !isKnown {
+		return nil, errUnknownKeyID
+	}
+
+	return key, nil
+}
+
+// refresh replaces the keys with the fetched ones and closes done. A failure
+// is logged and the previous keys are kept until the next refresh. The mutex
+// must not be held
+func (p *jwksKeyProvider) refresh(done chan struct{}) {
+	keys, err := p.fetch()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err != nil {
+		coreLogger.Warn("[JWKS] Refresh failed, keeping %d keys: %v", len(p.keys), err)
+	} else {
+		p.keys = keys
+	}
+	p.refreshing = nil
+	close(done)
+}
+
+func (p *jwksKeyProvider) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := p.client.Get(p.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("User service answered %s", resp.Status)
+	}
+
+	var keySet JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&keySet); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range keySet.Keys {
+		if jwk.Alg != "RS256" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			coreLogger.Warn("[JWKS] Key ignored: %v", err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
@@ -16,10 +16,14 @@ import (
 // JwtClaims extends standard claims for our User model.
 //
 // By including the IsAdmin and UserID fields, authorization check can be
-// based on those values
+// based on those values. Permissions are granted by the roles of the user
+// when the token is issued. Scopes are only defined for personal access tokens
 type JwtClaims struct {
-	IsAdmin bool   `json:"isAdmin"`
-	UserID  string `json:"userId"`
+	IsAdmin     bool     `json:"isAdmin"`
+	UserID      string   `json:"userId"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -35,7 +39,15 @@ type AuthenticatedHandler func(w http.ResponseWriter, r *http.Request, claims Jw
 // assumes that the JWT is properly formed, hence the jwtClaims argument.
 // An AccessChecker's check success often leads to some AuthenticatedHandler to
 // proceed.
-type AccessChecker func(r *http.Request, jwtClaims JwtClaims) bool
+type AccessChecker func(r *http.Request, jwtClaims JwtClaims) Access
+
+// Access is the result of an AccessChecker. IsScoped tells that the checker
+// granting the access requires a scope, which personal access tokens must
+// pass
+type Access struct {
+	IsAllowed bool
+	IsScoped  bool
+}
 
 // ----------------------------------------------------------------------------
 //	Types: Basic data model
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+//	Notifications: events of any service displayed to an user
+// ----------------------------------------------------------------------------
+
+// Notification types produced by the services
+const (
+	NotificationBoardInvite     = "board.invite"
+	NotificationItemAssigned    = "item.assigned"
+	NotificationItemMentioned   = "item.mentioned"
+	NotificationMemoMentioned   = "memo.mentioned"
+	NotificationPasswordUpdated = "user.passwordUpdated"
+)
+
+// NotificationSecretHeader authenticates the services sending notifications
+// to the notification service in microservice mode
+const NotificationSecretHeader = "X-Alun-Notification-Secret"
+
+// Notification is an event for a single user. Data references the entities
+// of the event, such as a "boardId", for the client to build a link
+type Notification struct {
+	UserID  string            `json:"userId"`
+	Type    string            `json:"type"`
+	Title   string            `json:"title"`
+	Message string            `json:"message,omitempty"`
+	Data    map[string]string `json:"data,omitempty"`
+}
+
+// NotificationTransport delivers notifications to the notification service
+type NotificationTransport interface {
+	Send(notifications []Notification) error
+}
+
+var notificationTransport NotificationTransport
+
+// SetNotificationTransport registers how notifications are delivered. In
+// monolithic mode, the notification package registers itself when loaded
+func SetNotificationTransport(transport NotificationTransport) {
+	notificationTransport = transport
+}
+
+// GetNotificationTransport returns the registered transport, nil if none is
+// available
+func GetNotificationTransport() NotificationTransport {
+	return notificationTransport
+}
+
+// Notify sends notifications with the registered transport. Notifications are
+// dropped if no transport is available
+func Notify(notifications ...Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	if notificationTransport == nil {
+		coreLogger.Verbose("[Notification] No transport, %d notifications dropped", len(notifications))
+		return nil
+	}
+
+	return notificationTransport.Send(notifications)
+}
+
+// NotifyAsync sends notifications without blocking the caller, as the HTTP
+// transport can take seconds. Failures are only logged
+func NotifyAsync(notifications ...Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+
+	go func() {
+		if err := Notify(notifications...); err != nil {
+			coreLogger.Warn("[Notification] Cannot send %d notifications: %v", len(notifications), err)
+		}
+	}()
+}
+
+// httpNotificationTransport posts the notifications to the notification
+// service
+type httpNotificationTransport struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewHTTPNotificationTransport sends notifications to the internal endpoint
+// of the notification service at url, authenticated with a shared secret
+func NewHTTPNotificationTransport(url string, secret string) NotificationTransport {
+	return httpNotificationTransport{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (t httpNotificationTransport) Send(notifications []Notification) error {
+	body, err := json.Marshal(notifications)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(NotificationSecretHeader, t.secret)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Notification service answered %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package core
+
+// ----------------------------------------------------------------------------
+//	Permissions: granted by the roles of the users
+// ----------------------------------------------------------------------------
+
+// Permissions of the roles. Admins have all the permissions
+const (
+	PermissionUsersRead    = "users.read"    // list users and the audit trail
+	PermissionUsersManage  = "users.manage"  // manage the accounts of other users
+	PermissionRolesManage  = "roles.manage"  // define roles and assign them
+	PermissionQuotasManage = "quotas.manage" // override the memo quotas
+)
+
+// KnownPermissions lists the permissions which can be granted to a role
+var KnownPermissions = []string{
+	PermissionUsersRead,
+	PermissionUsersManage,
+	PermissionRolesManage,
+	PermissionQuotasManage,
+}
+
+// IsKnownPermission is true if permission can be granted
+func IsKnownPermission(permission string) bool {
+	for _, knownPermission := range KnownPermissions {
+		if permission == knownPermission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission is true if the claims grant permission
+func (c JwtClaims) HasPermission(permission string) bool {
+	if c.IsAdmin {
+		return true
+	}
+
+	for _, granted := range c.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
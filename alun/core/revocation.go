@@ -0,0 +1,211 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+//	Token revocation: JWT rejected before their expiration
+// ----------------------------------------------------------------------------
+
+// Revocation reasons, rejected with a different service message
+const (
+	TokenRevokedLogout      = "logout"
+	TokenRevokedInvalidated = "invalidated"
+)
+
+// RevocationSecretHeader authenticates the services fetching the revoked
+// tokens from the user service in microservice mode
+const RevocationSecretHeader = "X-Alun-Revocation-Secret"
+
+// DefaultRevocationRefreshInterval is how long a service may accept a revoked
+// token issued by another service
+const DefaultRevocationRefreshInterval = 30 * time.Second
+
+// TokenRevocation revokes a JWT by its "jti" claim. A revocation is useless
+// once the token is expired
+type TokenRevocation struct {
+	ID        string    `json:"jti" bson:"jti"`
+	Reason    string    `json:"reason" bson:"reason"`
+	RevokedAt time.Time `json:"revokedAt" bson:"revokedAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+// RevocationSource lists the revocations since a given time, included, and
+// the time of the list which is the next "since"
+type RevocationSource interface {
+	FindRevocations(since time.Time) ([]TokenRevocation, time.Time, error)
+}
+
+// revocationCache keeps in memory the revocations of tokens which are not
+// expired. The cache is refreshed at most every interval, when checking a
+// token. The source is fetched without holding the mutex so that only the
+// request triggering the refresh waits for it
+type revocationCache struct {
+	source       RevocationSource
+	interval     time.Duration
+	mutex        sync.Mutex
+	revoked      map[string]TokenRevocation
+	syncedAt     time.Time // time of the source list
+	refreshErr   error
+	nextSync     time.Time // local time of the next refresh
+	isRefreshing bool
+}
+
+var tokenRevocations *revocationCache
+
+// SetTokenRevocationSource registers where the revoked tokens are listed. In
+// monolithic mode, the user package registers itself when loaded
+func SetTokenRevocationSource(source RevocationSource, interval time.Duration) {
+	tokenRevocations = newRevocationCache(source, interval)
+}
+
+func newRevocationCache(source RevocationSource, interval time.Duration) *revocationCache {
+	return &revocationCache{
+		source:   source,
+		interval: interval,
+		revoked:  make(map[string]TokenRevocation),
+	}
+}
+
+// AddTokenRevocations adds revocations to the cache without waiting for the
+// next refresh, so that the service revoking a token rejects it immediately
+func AddTokenRevocations(revocations ...TokenRevocation) {
+	if tokenRevocations != nil {
+		tokenRevocations.mutex.Lock()
+		defer tokenRevocations.mutex.Unlock()
+
+		tokenRevocations.add(revocations)
+	}
+}
+
+// checkRevocation rejects a revoked token. Tokens are accepted if no source
+// is registered
+func checkRevocation(tokenID string, now time.Time) *ServiceMessage {
+	if tokenRevocations == nil {
+		return nil
+	}
+
+	return tokenRevocations.check(tokenID, now)
+}
+
+func (c *revocationCache) add(revocations []TokenRevocation) {
+	for _, revocation := range revocations {
+		c.revoked[revocation.ID] = revocation
+	}
+}
+
+// refresh fetches the new revocations and drops the expired ones. A failure
+// is logged and the cache is kept until the next refresh. The mutex must not
+// be held, other checks use the current cache meanwhile
+func (c *revocationCache) refresh(since time.Time, now time.Time) {
+	revocations, syncedAt, err := c.source.FindRevocations(since)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.isRefreshing = false
+	if err != nil {
+		if c.refreshErr == nil {
+			coreLogger.Warn("[Revocation] Refresh failed, keeping %d revocations: %v", len(c.revoked), err)
+		}
+		c.refreshErr = err
+		return
+	}
+
+	c.refreshErr = nil
+	c.syncedAt = syncedAt
+	c.add(revocations)
+
+	for tokenID, revocation := range c.revoked {
+		if revocation.ExpiresAt.Before(now) {
+			delete(c.revoked, tokenID)
+		}
+	}
+}
+
+func (c *revocationCache) check(tokenID string, now time.Time) *ServiceMessage {
+	c.mutex.Lock()
+	isDue := !now.Before(c.nextSync) && !c.isRefreshing
+	if isDue {
+		c.nextSync = now.Add(c.interval)
+		c.isRefreshing = true
+	}
+	since := c.syncedAt
+	c.mutex.Unlock()
+
+	if isDue {
+		c.refresh(since, now)
+	}
+
+	c.mutex.Lock()
+	revocation, isRevoked := c.revoked[tokenID]
+	c.mutex.Unlock()
+
+	switch {
+	case !isRevoked:
+		return nil
+	case revocation.Reason == TokenRevokedLogout:
+		return isTokenLogout
+	default:
+		return isTokenInvalidated
+	}
+}
+
+// httpRevocationSource fetches the revocations from the internal endpoint of
+// the user service
+type httpRevocationSource struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// RevocationList is the answer of the internal revocations endpoint of the
+// user service
+type RevocationList struct {
+	Revocations []TokenRevocation `json:"revocations"`
+	SyncedAt    time.Time         `json:"syncedAt"`
+}
+
+// NewHTTPRevocationSource fetches the revocations from the internal endpoint
+// of the user service at url, authenticated with a shared secret
+func NewHTTPRevocationSource(url string, secret string) RevocationSource {
+	return httpRevocationSource{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (s httpRevocationSource) FindRevocations(since time.Time) ([]TokenRevocation, time.Time, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, since, err
+	}
+	query := url.Values{}
+	query.Set("since", since.Format(time.RFC3339Nano))
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set(RevocationSecretHeader, s.secret)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, since, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, since, fmt.Errorf("User service answered %s", resp.Status)
+	}
+
+	var list RevocationList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, since, err
+	}
+
+	return list.Revocations, list.SyncedAt, nil
+}
@@ -76,3 +76,11 @@ var isUnknownError = &ServiceMessage{
 	HTTPStatus: http.StatusForbidden,
 	Message:    "Unknown error during Authorization check",
 }
+
+// isScopeMissing is returned when a personal access token does not grant the
+// scope of an endpoint
+var isScopeMissing = &ServiceMessage{
+	Code:       10110,
+	HTTPStatus: http.StatusForbidden,
+	Message:    "Token scope does not grant access",
+}
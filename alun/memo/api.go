@@ -9,28 +9,68 @@ import (
 // MemoAPI for Memo
 var MemoAPI *core.API
 
-// isAdminOrOwnUser checks access for user modification: only self data
-// are allowed
-var isAdminOrOwnUser = func(r *http.Request, jwtClaims core.JwtClaims) bool {
-	if jwtClaims.IsAdmin {
-		return true
-	}
-
-	userID := core.GetVar(r, "userId")
-	return userID == jwtClaims.UserID
-}
+// Access checkers of the endpoints. Personal access tokens need a memo scope,
+// reading or writing
+var (
+	checkMemoRead  = core.RequireScope(core.ScopeMemoRead, core.CheckIfLogged)
+	checkMemoWrite = core.RequireScope(core.ScopeMemoWrite, core.CheckIfLogged)
+	// Quotas are managed with a permission and readable by their user
+	checkQuotasManage = core.HasPermission(core.PermissionQuotasManage)
+	checkQuotasRead   = core.Any(checkQuotasManage, core.IsOwnerOf("userId"))
+)
 
 func initAPI() {
 	apiRoot := "memos"
 	MemoAPI = core.NewAPI(apiRoot, memoLogger)
 	MemoAPI.AddMiddleware(core.AddJSONHeaders)
 
-	MemoAPI.AddProtectedEndpoint("boards", http.MethodGet, core.APIv1, core.CheckIfLogged, handleListBoards)
-	MemoAPI.AddProtectedEndpoint("boards", http.MethodPost, core.APIv1, core.CheckIfLogged, handleCreateBoard)
-	MemoAPI.AddProtectedEndpoint("boards/{boardId}", http.MethodGet, core.APIv1, core.CheckIfLogged, handleGetBoard)
-	MemoAPI.AddProtectedEndpoint("boards/{boardId}", http.MethodPut, core.APIv1, core.CheckIfLogged, handleUpdateBoard)
-	MemoAPI.AddProtectedEndpoint("boards/{boardId}", http.MethodDelete, core.APIv1, core.CheckIfLogged, handleDeleteBoard)
-	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos", http.MethodPost, core.APIv1, core.CheckIfLogged, handleCreateMemo)
-	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}", http.MethodPut, core.APIv1, core.CheckIfLogged, handleUpdateMemo)
-	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}", http.MethodDelete, core.APIv1, core.CheckIfLogged, handleDeleteMemo)
+	MemoAPI.AddProtectedEndpoint("boards", http.MethodGet, core.APIv1, checkMemoRead, handleListBoards)
+	MemoAPI.AddProtectedEndpoint("boards", http.MethodPost, core.APIv1, checkMemoWrite, handleCreateBoard)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}", http.MethodGet, core.APIv1, checkMemoRead, handleGetBoard)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}", http.MethodPut, core.APIv1, checkMemoWrite, handleUpdateBoard)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}", http.MethodDelete, core.APIv1, checkMemoWrite, handleDeleteBoard)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/watch", http.MethodPut, core.APIv1, checkMemoWrite, handleWatchBoard)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/watch", http.MethodDelete, core.APIv1, checkMemoWrite, handleUnwatchBoard)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/completions", http.MethodGet, core.APIv1, checkMemoRead, handleGetCompletions)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/time", http.MethodGet, core.APIv1, checkMemoRead, handleGetTimeTotals)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/time/export", http.MethodGet, core.APIv1, checkMemoRead, handleExportTimeEntries)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/time/{entryId}", http.MethodDelete, core.APIv1, checkMemoWrite, handleDeleteTimeEntry)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/columns", http.MethodPost, core.APIv1, checkMemoWrite, handleCreateColumn)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/columns", http.MethodPut, core.APIv1, checkMemoWrite, handleReorderColumns)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/columns/{columnId}", http.MethodPut, core.APIv1, checkMemoWrite, handleRenameColumn)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/columns/{columnId}", http.MethodDelete, core.APIv1, checkMemoWrite, handleDeleteColumn)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/fields", http.MethodPost, core.APIv1, checkMemoWrite, handleCreateField)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/fields/{fieldId}", http.MethodPut, core.APIv1, checkMemoWrite, handleUpdateField)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/fields/{fieldId}", http.MethodDelete, core.APIv1, checkMemoWrite, handleDeleteField)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos", http.MethodPost, core.APIv1, checkMemoWrite, handleCreateMemo)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}", http.MethodPut, core.APIv1, checkMemoWrite, handleUpdateMemo)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}", http.MethodDelete, core.APIv1, checkMemoWrite, handleDeleteMemo)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/move", http.MethodPut, core.APIv1, checkMemoWrite, handleMoveMemo)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/markdown", http.MethodGet, core.APIv1, checkMemoRead, handleExportMemoMarkdown)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/quickadd", http.MethodPost, core.APIv1, checkMemoWrite, handleQuickAddItem)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/attachments", http.MethodGet, core.APIv1, checkMemoRead, handleListAttachments)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/attachments", http.MethodPost, core.APIv1, checkMemoWrite, handleCreateAttachment)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/attachments/{attachmentId}", http.MethodGet, core.APIv1, checkMemoRead, handleGetAttachment)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/attachments/{attachmentId}", http.MethodDelete, core.APIv1, checkMemoWrite, handleDeleteAttachment)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/items", http.MethodPost, core.APIv1, checkMemoWrite, handleCreateItem)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/items/{itemPath}", http.MethodPost, core.APIv1, checkMemoWrite, handleCreateChildItem)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/items/{itemPath}", http.MethodPut, core.APIv1, checkMemoWrite, handleUpdateItem)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/items/{itemPath}", http.MethodDelete, core.APIv1, checkMemoWrite, handleDeleteItem)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/items/{itemPath}/timer", http.MethodPost, core.APIv1, checkMemoWrite, handleStartTimer)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/items/{itemPath}/timer", http.MethodDelete, core.APIv1, checkMemoWrite, handleStopTimer)
+	MemoAPI.AddProtectedEndpoint("boards/{boardId}/memos/{memoId}/items/{itemPath}/time", http.MethodPost, core.APIv1, checkMemoWrite, handleCreateTimeEntry)
+	MemoAPI.AddProtectedEndpoint("timer", http.MethodGet, core.APIv1, checkMemoRead, handleGetRunningTimer)
+	MemoAPI.AddProtectedEndpoint("assignments", http.MethodGet, core.APIv1, checkMemoRead, handleListAssignedItems)
+	MemoAPI.AddProtectedEndpoint("smartlists", http.MethodGet, core.APIv1, checkMemoRead, handleListSmartLists)
+	MemoAPI.AddProtectedEndpoint("smartlists", http.MethodPost, core.APIv1, checkMemoWrite, handleCreateSmartList)
+	MemoAPI.AddProtectedEndpoint("smartlists/{smartListId}", http.MethodPut, core.APIv1, checkMemoWrite, handleUpdateSmartList)
+	MemoAPI.AddProtectedEndpoint("smartlists/{smartListId}", http.MethodDelete, core.APIv1, checkMemoWrite, handleDeleteSmartList)
+	MemoAPI.AddProtectedEndpoint("smartlists/{smartListId}/items", http.MethodGet, core.APIv1, checkMemoRead, handleGetSmartListItems)
+	MemoAPI.AddPublicEndpoint("watches/unsubscribe", http.MethodPost, core.APIv1, handleUnsubscribeWatch)
+	MemoAPI.AddProtectedEndpoint("search", http.MethodGet, core.APIv1, checkMemoRead, handleSearch)
+	MemoAPI.AddProtectedEndpoint("sync", http.MethodGet, core.APIv1, checkMemoRead, handleGetChanges)
+	MemoAPI.AddProtectedEndpoint("quotas/{userId}", http.MethodGet, core.APIv1, checkQuotasManage, handleGetQuotaOverride)
+	MemoAPI.AddProtectedEndpoint("quotas/{userId}", http.MethodPut, core.APIv1, checkQuotasManage, handleUpdateQuotaOverride)
+	MemoAPI.AddProtectedEndpoint("quotas/{userId}", http.MethodDelete, core.APIv1, checkQuotasManage, handleDeleteQuotaOverride)
+	MemoAPI.AddProtectedEndpoint("quotas/{userId}/usage", http.MethodGet, core.APIv1, checkQuotasRead, handleGetQuotaUsage)
 }
@@ -7,7 +7,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Al-un/alun-api/alun/core"
 	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func TestE2EMemo(t *testing.T) {
@@ -115,14 +117,16 @@ func TestE2EMemo(t *testing.T) {
 		json.NewDecoder(rr.Body).Decode(&newMemo)
 
 		testutils.Equals(t, testutils.CallFromTestFile, memo1.BasicInfo, newMemo.BasicInfo)
-		testutils.Equals(t, testutils.CallFromTestFile, memo1.Items, newMemo.Items)
+		testutils.Assert(t, testutils.CallFromTestFile, areItemsArrayEquals(memo1.Items, newMemo.Items),
+			"Items are not equals: \ngot:\n%+v\nexpected:\n%+v",
+			newMemo.Items, memo1.Items)
 		testutils.Assert(t, testutils.CallFromTestFile, !newMemo.CreatedAt.IsZero(), "CreatedAt is empty")
 		testutils.Assert(t, testutils.CallFromTestFile, newMemo.UpdatedAt.IsZero(), "UpdatedAt is not empty %v", memo1.UpdatedAt)
 
 		// Check in DB
 		memoFromDb, _ := findMemoByID(board1.ID.Hex(), newMemo.ID.Hex())
 		testutils.Equals(t, testutils.CallFromTestFile, memo1.BasicInfo, memoFromDb.BasicInfo)
-		testutils.Equals(t, testutils.CallFromTestFile, memo1.Items, memoFromDb.Items)
+		testutils.Equals(t, testutils.CallFromTestFile, newMemo.Items, memoFromDb.Items)
 
 		// Save ID for teardown
 		memo1.ID = memoFromDb.ID
@@ -230,7 +234,6 @@ func TestE2EMemo(t *testing.T) {
 
 		testutils.Equals(t, testutils.CallFromTestFile, memo1.BasicInfo, newMemo.BasicInfo)
 		testutils.Equals(t, testutils.CallFromTestFile, memo1.CreatedAt, newMemo.CreatedAt)
-		testutils.Equals(t, testutils.CallFromTestFile, memo1ItemsSet2, newMemo.Items)
 		testutils.Assert(t, testutils.CallFromTestFile, areItemsArrayEquals(memo1ItemsSet2, newMemo.Items),
 			"Items are not equals: \ngot:\n%+v\nexpected:\n%+v",
 			newMemo.Items, memo1ItemsSet2)
@@ -240,7 +243,7 @@ func TestE2EMemo(t *testing.T) {
 		memoFromDb, _ := findMemoByID(board1.ID.Hex(), memo1.ID.Hex())
 		testutils.Equals(t, testutils.CallFromTestFile, memo1.BasicInfo, memoFromDb.BasicInfo)
 		testutils.Equals(t, testutils.CallFromTestFile, newMemo.UpdatedAt, memoFromDb.UpdatedAt)
-		testutils.Equals(t, testutils.CallFromTestFile, memo1ItemsSet2, memoFromDb.Items)
+		testutils.Equals(t, testutils.CallFromTestFile, newMemo.Items, memoFromDb.Items)
 
 		memo1.Items = memo1ItemsSet2
 		memo1.UpdatedAt = memoFromDb.UpdatedAt
@@ -270,10 +273,11 @@ func TestE2EMemo(t *testing.T) {
 
 		// Compare memos
 		memos := []Memo{memo1, memo2}
-		testutils.Assert(t, testutils.CallFromTestFile, len(b.Memos) == len(memos),
+		memosByColumn := boardMemos(b)
+		testutils.Assert(t, testutils.CallFromTestFile, len(memosByColumn) == len(memos),
 			"Memos counts are not equals: got %d, expected %d",
-			len(b.Memos), len(memos))
-		for idx, m := range b.Memos {
+			len(memosByColumn), len(memos))
+		for idx, m := range memosByColumn {
 			testutils.Assert(t, testutils.CallFromTestFile, m.equals(memos[idx]),
 				"Memos are not equals: \ngot:\n%+v\nexpected:\n%+v",
 				m, memos[idx])
@@ -318,10 +322,11 @@ func TestE2EMemo(t *testing.T) {
 		json.NewDecoder(rr.Body).Decode(&b)
 
 		memos := []Memo{memo2}
-		testutils.Assert(t, testutils.CallFromTestFile, len(b.Memos) == len(memos),
+		memosByColumn := boardMemos(b)
+		testutils.Assert(t, testutils.CallFromTestFile, len(memosByColumn) == len(memos),
 			"Memos counts are not equals: got %d, expected %d",
-			len(b.Memos), len(memos))
-		for idx, m := range b.Memos {
+			len(memosByColumn), len(memos))
+		for idx, m := range memosByColumn {
 			testutils.Assert(t, testutils.CallFromTestFile, m.equals(memos[idx]),
 				"Memos are not equals: \ngot:\n%+v\nexpected:\n%+v",
 				m, memos[idx])
@@ -353,3 +358,99 @@ func TestE2EMemo(t *testing.T) {
 		apiTester.TestPath(t, testInfo)
 	})
 }
+
+func TestE2EBoardMembers(t *testing.T) {
+	t.Parallel()
+
+	user, token := setupUser(t)
+	owner := primitive.NewObjectID()
+	sharedBoard, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Shared with me"},
+		Members:       []primitive.ObjectID{user.ID},
+		TrackedEntity: trackedBy(owner),
+	})
+	otherBoard, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Not shared"},
+		TrackedEntity: trackedBy(owner),
+	})
+	otherMemo, _ := createMemo(otherBoard.ID.Hex(), Memo{
+		BasicInfo: BasicInfo{Title: "Not shared"},
+		Items:     []Item{{Text: "Secret"}},
+	})
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(sharedBoard.ID.Hex())
+		deleteBoard(otherBoard.ID.Hex())
+	})
+
+	updateBoard := func(t *testing.T, board *Board, payload Board, expectedStatus int) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s", board.ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            payload,
+			ExpectedHTTPStatus: expectedStatus,
+			AuthToken:          token,
+		})
+	}
+
+	t.Run("OutsiderCannotUpdate", func(t *testing.T) {
+		updateBoard(t, otherBoard, Board{
+			BasicInfo: BasicInfo{Title: "Mine"},
+			Members:   []primitive.ObjectID{user.ID},
+		}, http.StatusNotFound)
+	})
+
+	t.Run("MemberCannotChangeMembers", func(t *testing.T) {
+		updateBoard(t, sharedBoard, Board{
+			BasicInfo: BasicInfo{Title: "Shared with me"},
+			Members:   []primitive.ObjectID{},
+		}, http.StatusForbidden)
+	})
+
+	t.Run("OmittedMembersAreKept", func(t *testing.T) {
+		updateBoard(t, sharedBoard, Board{BasicInfo: BasicInfo{Title: "Renamed"}}, http.StatusOK)
+
+		board, _ := findBoardByID(sharedBoard.ID.Hex())
+		testutils.Equals(t, testutils.CallFromTestFile, "Renamed", board.Title)
+		testutils.Equals(t, testutils.CallFromTestFile, []primitive.ObjectID{user.ID}, board.Members)
+	})
+
+	t.Run("OutsiderCannotAccessBoardAndMemos", func(t *testing.T) {
+		otherBoardPath := fmt.Sprintf("boards/%s", otherBoard.ID.Hex())
+		otherMemoPath := fmt.Sprintf("%s/memos/%s", otherBoardPath, otherMemo.ID.Hex())
+		requests := []testutils.APITestInfo{
+			{Path: otherBoardPath, Method: http.MethodGet},
+			{Path: otherBoardPath, Method: http.MethodDelete},
+			{Path: fmt.Sprintf("%s/memos", otherBoardPath), Method: http.MethodPost, Payload: Memo{BasicInfo: BasicInfo{Title: "Mine"}}},
+			{Path: otherMemoPath, Method: http.MethodPut, Payload: Memo{BasicInfo: BasicInfo{Title: "Mine"}}},
+			{Path: otherMemoPath, Method: http.MethodDelete},
+		}
+		for _, testInfo := range requests {
+			testInfo.ExpectedHTTPStatus = http.StatusNotFound
+			testInfo.AuthToken = token
+			rr := apiTester.TestPath(t, testInfo)
+
+			var msg core.ServiceMessage
+			json.NewDecoder(rr.Body).Decode(&msg)
+			testutils.Equals(t, testutils.CallFromTestFile, boardNotFound.Code, msg.Code)
+		}
+
+		memo, _ := findMemoByID(otherBoard.ID.Hex(), otherMemo.ID.Hex())
+		testutils.Equals(t, testutils.CallFromTestFile, "Not shared", memo.Title)
+		isBoardIDInDb, _ := isBoardIDExist(otherBoard.ID.Hex())
+		testutils.Assert(t, testutils.CallFromTestFile, isBoardIDInDb, "Board ID %s deleted by an outsider", otherBoard.ID)
+	})
+
+	t.Run("MemberCannotDeleteBoard", func(t *testing.T) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s", sharedBoard.ID.Hex()),
+			Method:             http.MethodDelete,
+			ExpectedHTTPStatus: http.StatusForbidden,
+			AuthToken:          token,
+		})
+
+		isBoardIDInDb, _ := isBoardIDExist(sharedBoard.ID.Hex())
+		testutils.Assert(t, testutils.CallFromTestFile, isBoardIDInDb, "Board ID %s deleted by a member", sharedBoard.ID)
+	})
+}
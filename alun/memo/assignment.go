@@ -0,0 +1,136 @@
+package memo
+
+import (
+	"fmt"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ----------------------------------------------------------------------------
+//	Types
+// ----------------------------------------------------------------------------
+
+// assignmentEmailData is the content of the memo_item-assigned email
+type assignmentEmailData struct {
+	Assigner   string
+	BoardTitle string
+	MemoTitle  string
+	Items      []Item
+}
+
+// ----------------------------------------------------------------------------
+//	Assignments
+// ----------------------------------------------------------------------------
+
+// newAssignments lists the items of a memo whose assignee has changed, grouped
+// by new assignee. Self-assignments are not listed as nobody has to be
+// notified
+func newAssignments(before *Memo, after Memo, assignerID primitive.ObjectID) map[primitive.ObjectID][]Item {
+	previousItems := make(map[primitive.ObjectID]Item)
+	if before != nil {
+		previousItems = flattenItems(before.Items)
+	}
+
+	assignments := make(map[primitive.ObjectID][]Item)
+	walkItems(after.Items, func(item *Item, parentID primitive.ObjectID) {
+		if item.AssigneeID.IsZero() || item.AssigneeID == assignerID {
+			return
+		}
+		if previous, ok := previousItems[item.ID]; ok && previous.AssigneeID == item.AssigneeID {
+			return
+		}
+
+		assigned := *item
+		assigned.Children = nil
+		assignments[item.AssigneeID] = append(assignments[item.AssigneeID], assigned)
+	})
+
+	return assignments
+}
+
+// validateAssignees checks that the new assignees of a memo can access the
+// board. Unchanged assignees are not checked so that removing a member from a
+// board does not prevent from updating the memos
+func validateAssignees(board *Board, before *Memo, after Memo) *core.ServiceMessage {
+	for assigneeID := range newAssignments(before, after, primitive.NilObjectID) {
+		if !board.hasAccess(assigneeID) {
+			return itemAssigneeInvalid
+		}
+	}
+
+	return nil
+}
+
+// filterMemosByAssignee keeps the memos having at least one item assigned to
+// an user, with the assigned items only
+func (b *Board) filterMemosByAssignee(assigneeID primitive.ObjectID) {
+	b.filterMemoItems(func(item Item) bool {
+		return item.AssigneeID == assigneeID
+	})
+}
+
+// ----------------------------------------------------------------------------
+//	Notifications
+// ----------------------------------------------------------------------------
+
+// notifyAssignees notifies the new assignees in-app and sends them an email.
+// Emails are resolved with the users directory and are not sent if no
+// directory is available.
+//
+// The memo is already saved so failures are only logged
+func notifyAssignees(board *Board, memo Memo, assignments map[primitive.ObjectID][]Item, assignerID primitive.ObjectID) {
+	if len(assignments) == 0 {
+		return
+	}
+	sendNotifications(newAssignmentNotifications(board, memo, assignments))
+	go sendAssignmentEmails(board.Title, memo.Title, assignments, assignerID)
+}
+
+// sendAssignmentEmails emails the assignees, in the background as SMTP can be
+// slow
+func sendAssignmentEmails(boardTitle string, memoTitle string, assignments map[primitive.ObjectID][]Item, assignerID primitive.ObjectID) {
+	directory := core.GetUserDirectory()
+	if directory == nil {
+		memoLogger.Info("[Assignment] No users directory, %d assignment emails skipped", len(assignments))
+		return
+	}
+
+	userIDs := []string{assignerID.Hex()}
+	for assigneeID := range assignments {
+		userIDs = append(userIDs, assigneeID.Hex())
+	}
+
+	contacts, err := directory.FindUserContacts(userIDs)
+	if err != nil {
+		memoLogger.Warn("[Assignment] Cannot load contacts of %v: %v", userIDs, err)
+		return
+	}
+
+	assigner := "Someone"
+	for _, contact := range contacts {
+		if contact.ID == assignerID.Hex() && contact.Username != "" {
+			assigner = contact.Username
+		}
+	}
+
+	for _, contact := range contacts {
+		assigneeID, _ := primitive.ObjectIDFromHex(contact.ID)
+		items, ok := assignments[assigneeID]
+		if !ok || contact.Email == "" {
+			continue
+		}
+
+		data := assignmentEmailData{
+			Assigner:   assigner,
+			BoardTitle: boardTitle,
+			MemoTitle:  memoTitle,
+			Items:      items,
+		}
+		subject := fmt.Sprintf("Items assigned to you in %s", memoTitle)
+		if err := alunEmail.SendNoReplyEmail([]string{contact.Email}, subject, utils.EmailTemplateMemoItemAssigned, data); err != nil {
+			memoLogger.Warn("[Assignment] Cannot notify user %s: %v", contact.ID, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package memo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNewAssignments(t *testing.T) {
+	assigner, member := primitive.NewObjectID(), primitive.NewObjectID()
+
+	before := Memo{Items: newItemsTree()}
+	before.Items[1].AssigneeID = member
+	before.prepareItems()
+
+	after := before
+	after.Items = newItemsTree()
+	after.Items[0].ID = before.Items[0].ID
+	after.Items[1].ID = before.Items[1].ID
+	after.Items[1].AssigneeID = member               // Unchanged
+	after.Items[0].Children[0].AssigneeID = member   // New assignment
+	after.Items[0].Children[1].AssigneeID = assigner // Self-assignment
+	after.prepareItems()
+
+	assignments := newAssignments(&before, after, assigner)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(assignments))
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(assignments[member]))
+	testutils.Equals(t, testutils.CallFromTestFile, "Clothes", assignments[member][0].Text)
+
+	board := Board{TrackedEntity: trackedBy(assigner)}
+	testutils.Equals(t, testutils.CallFromTestFile, itemAssigneeInvalid, validateAssignees(&board, &before, after))
+
+	board.Members = []primitive.ObjectID{member}
+	testutils.Assert(t, testutils.CallFromTestFile, validateAssignees(&board, &before, after) == nil, "Member can be assigned")
+}
+
+func TestFilterMemosByAssignee(t *testing.T) {
+	member := primitive.NewObjectID()
+	board := Board{Memos: []Memo{
+		{BasicInfo: BasicInfo{Title: "Holidays"}, Items: newItemsTree()},
+		{BasicInfo: BasicInfo{Title: "Groceries"}, Items: []Item{{Text: "Milk"}}},
+	}}
+	board.Memos[0].Items[0].Children[1].Children[1].AssigneeID = member
+
+	board.filterMemosByAssignee(member)
+
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(board.Memos))
+	items := board.Memos[0].Items
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(items))
+	testutils.Equals(t, testutils.CallFromTestFile, "Pack", items[0].Text)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(items[0].Children))
+	testutils.Equals(t, testutils.CallFromTestFile, "Toiletries", items[0].Children[0].Text)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(items[0].Children[0].Children))
+	testutils.Equals(t, testutils.CallFromTestFile, "Soap", items[0].Children[0].Children[0].Text)
+}
+
+func TestE2EAssignment(t *testing.T) {
+	t.Parallel()
+
+	var testInfo testutils.APITestInfo
+	user, token := setupUser(t)
+	member := primitive.NewObjectID()
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Shared board"},
+		Members:       []primitive.ObjectID{member},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	memo, _ := createMemo(board.ID.Hex(), Memo{
+		BasicInfo:     BasicInfo{Title: "Chores"},
+		Items:         []Item{{Text: "Dishes"}, {Text: "Laundry"}},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	itemsPath := fmt.Sprintf("boards/%s/memos/%s/items", board.ID.Hex(), memo.ID.Hex())
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+	})
+
+	t.Run("AssignOutsiderIsRejected", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("%s/%s", itemsPath, memo.Items[0].ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            Item{Text: "Dishes", AssigneeID: primitive.NewObjectID()},
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("AssignMembers", func(t *testing.T) {
+		assignees := []primitive.ObjectID{user.ID, member}
+		for idx, item := range memo.Items {
+			testInfo = testutils.APITestInfo{
+				Path:               fmt.Sprintf("%s/%s", itemsPath, item.ID.Hex()),
+				Method:             http.MethodPut,
+				Payload:            Item{Text: item.Text, AssigneeID: assignees[idx]},
+				ExpectedHTTPStatus: http.StatusOK,
+				AuthToken:          token,
+			}
+			apiTester.TestPath(t, testInfo)
+		}
+	})
+
+	t.Run("ListMyAssignedItems", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               "assignments",
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var assignedItems []LocatedItem
+		json.NewDecoder(rr.Body).Decode(&assignedItems)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(assignedItems))
+		testutils.Equals(t, testutils.CallFromTestFile, "Dishes", assignedItems[0].Item.Text)
+		testutils.Equals(t, testutils.CallFromTestFile, memo.ID, assignedItems[0].MemoID)
+	})
+
+	t.Run("FilterBoardByAssignee", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s?assignee=%s", board.ID.Hex(), member.Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var filteredBoard Board
+		json.NewDecoder(rr.Body).Decode(&filteredBoard)
+		memos := boardMemos(filteredBoard)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(memos))
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(memos[0].Items))
+		testutils.Equals(t, testutils.CallFromTestFile, "Laundry", memos[0].Items[0].Text)
+	})
+}
@@ -0,0 +1,69 @@
+package memo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// attachmentMaxBytes is the size limit of a single attachment. The content
+	// is stored with the attachment so it must stay below the MongoDB 16MB
+	// document limit
+	attachmentMaxBytes = 8 * 1024 * 1024
+	// attachmentDefaultContentType applies when the upload has no content type
+	attachmentDefaultContentType = "application/octet-stream"
+)
+
+// Attachment is a file attached to a memo. Its size counts in the attachment
+// bytes quota of the board owner, which is saved so that the attachments of
+// an user can be summed up without the boards
+type Attachment struct {
+	ID                 primitive.ObjectID `json:"id" bson:"_id"`
+	BoardID            primitive.ObjectID `json:"boardId" bson:"boardId"`
+	MemoID             primitive.ObjectID `json:"memoId" bson:"memoId"`
+	OwnerID            primitive.ObjectID `json:"-" bson:"ownerId"`
+	Name               string             `json:"name" bson:"name"`
+	ContentType        string             `json:"contentType" bson:"contentType"`
+	Size               int64              `json:"size" bson:"size"`
+	Content            []byte             `json:"-" bson:"content,omitempty"`
+	core.TrackedEntity `bson:",inline"`
+}
+
+// validate checks an uploaded attachment and sets its size
+func (a *Attachment) validate() error {
+	a.Name = strings.TrimSpace(a.Name)
+	a.ContentType = strings.TrimSpace(a.ContentType)
+	a.Size = int64(len(a.Content))
+
+	if a.Name == "" {
+		return errors.New("Name is required")
+	}
+	if a.Size == 0 {
+		return errors.New("Content is empty")
+	}
+	if a.ContentType == "" {
+		a.ContentType = attachmentDefaultContentType
+	}
+
+	return nil
+}
+
+// newAttachmentInvalid details why an attachment is invalid
+func newAttachmentInvalid(err error) *core.ServiceMessage {
+	msg := *attachmentInvalid
+	msg.Message = fmt.Sprintf("%s: %v", attachmentInvalid.Message, err)
+
+	return &msg
+}
+
+// newAttachmentTooLarge details the size limit of an attachment
+func newAttachmentTooLarge() *core.ServiceMessage {
+	msg := *attachmentTooLarge
+	msg.Message = fmt.Sprintf("%s: %d bytes maximum", attachmentTooLarge.Message, attachmentMaxBytes)
+
+	return &msg
+}
@@ -0,0 +1,22 @@
+package memo
+
+import (
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+)
+
+func TestAttachmentValidate(t *testing.T) {
+	attachment := Attachment{Name: "  notes.txt ", Content: []byte("Notes")}
+	err := attachment.validate()
+	testutils.Assert(t, testutils.CallFromTestFile, err == nil, "Attachment should be valid: %v", err)
+	testutils.Equals(t, testutils.CallFromTestFile, "notes.txt", attachment.Name)
+	testutils.Equals(t, testutils.CallFromTestFile, int64(5), attachment.Size)
+	testutils.Equals(t, testutils.CallFromTestFile, attachmentDefaultContentType, attachment.ContentType)
+
+	err = (&Attachment{Content: []byte("Notes")}).validate()
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Attachment without name should be invalid")
+
+	err = (&Attachment{Name: "empty.txt"}).validate()
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Empty attachment should be invalid")
+}
@@ -0,0 +1,108 @@
+package memo
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// columnDefaultTitle is the title of the column created for boards
+	// without column
+	columnDefaultTitle = "To do"
+)
+
+// ColumnOrder is the new order of all the columns of a board
+type ColumnOrder struct {
+	ColumnIDs []primitive.ObjectID `json:"columnIds"`
+}
+
+// MemoMove is the destination of a memo moved to another column or to another
+// position of its column
+type MemoMove struct {
+	ColumnID primitive.ObjectID `json:"columnId"`
+	Position int                `json:"position"`
+}
+
+// prepareColumns generates an ID for each column which does not have one yet.
+// A board has at least one column
+func (b *Board) prepareColumns() {
+	if len(b.Columns) == 0 {
+		b.Columns = []Column{{Title: columnDefaultTitle}}
+	}
+
+	for idx := range b.Columns {
+		if b.Columns[idx].ID.IsZero() {
+			b.Columns[idx].ID = primitive.NewObjectID()
+		}
+	}
+}
+
+// findColumn returns the index of a column in the board, -1 if not found
+func (b *Board) findColumn(columnID primitive.ObjectID) int {
+	for idx, column := range b.Columns {
+		if column.ID == columnID {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// groupMemosByColumn moves the board memos into their column, ordered by
+// position. Memos of an unknown column fall back into the first column
+func (b *Board) groupMemosByColumn() {
+	if len(b.Columns) == 0 {
+		return
+	}
+
+	for idx := range b.Columns {
+		b.Columns[idx].Memos = make([]Memo, 0)
+	}
+
+	// Memos are loaded by position so appending keeps the order
+	for _, memo := range b.Memos {
+		columnIdx := b.findColumn(memo.ColumnID)
+		if columnIdx < 0 {
+			columnIdx = 0
+		}
+		b.Columns[columnIdx].Memos = append(b.Columns[columnIdx].Memos, memo)
+	}
+
+	b.Memos = nil
+}
+
+// reorderColumns returns the board columns in the new order. The new order
+// must contain each column exactly once
+func (b *Board) reorderColumns(columnIDs []primitive.ObjectID) ([]Column, error) {
+	if len(columnIDs) != len(b.Columns) {
+		return nil, errors.New("all columns must be ordered")
+	}
+
+	reordered := make([]Column, 0, len(columnIDs))
+	seen := make(map[primitive.ObjectID]bool)
+	for _, columnID := range columnIDs {
+		columnIdx := b.findColumn(columnID)
+		if columnIdx < 0 || seen[columnID] {
+			return nil, errors.New("column order is invalid")
+		}
+
+		seen[columnID] = true
+		reordered = append(reordered, b.Columns[columnIdx])
+	}
+
+	return reordered, nil
+}
+
+// clampPosition keeps a position within the bounds of a column of size
+// memosCount
+func clampPosition(position int, memosCount int) int {
+	if position < 0 {
+		return 0
+	}
+	if position > memosCount {
+		return memosCount
+	}
+
+	return position
+}
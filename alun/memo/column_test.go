@@ -0,0 +1,334 @@
+package memo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGroupMemosByColumn(t *testing.T) {
+	board := Board{Columns: []Column{{Title: "To do"}, {Title: "Done"}}}
+	board.prepareColumns()
+	todo, done := board.Columns[0].ID, board.Columns[1].ID
+
+	board.Memos = []Memo{
+		{ID: primitive.NewObjectID(), ColumnID: done, Position: 0},
+		{ID: primitive.NewObjectID(), ColumnID: todo, Position: 0},
+		{ID: primitive.NewObjectID(), ColumnID: primitive.NewObjectID(), Position: 0},
+		{ID: primitive.NewObjectID(), ColumnID: todo, Position: 1},
+	}
+	memos := board.Memos
+
+	board.groupMemosByColumn()
+
+	testutils.Equals(t, testutils.CallFromTestFile, []Memo(nil), board.Memos)
+	testutils.Equals(t, testutils.CallFromTestFile, []Memo{memos[1], memos[2], memos[3]}, board.Columns[0].Memos)
+	testutils.Equals(t, testutils.CallFromTestFile, []Memo{memos[0]}, board.Columns[1].Memos)
+}
+
+func TestReorderColumns(t *testing.T) {
+	board := Board{Columns: []Column{{Title: "To do"}, {Title: "Doing"}, {Title: "Done"}}}
+	board.prepareColumns()
+	todo, doing, done := board.Columns[0].ID, board.Columns[1].ID, board.Columns[2].ID
+
+	reordered, err := board.reorderColumns([]primitive.ObjectID{done, todo, doing})
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, "Done", reordered[0].Title)
+	testutils.Equals(t, testutils.CallFromTestFile, "Doing", reordered[2].Title)
+
+	_, err = board.reorderColumns([]primitive.ObjectID{done, todo})
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Missing column should be rejected")
+
+	_, err = board.reorderColumns([]primitive.ObjectID{done, todo, todo})
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Duplicated column should be rejected")
+}
+
+func TestE2EColumns(t *testing.T) {
+	t.Parallel()
+
+	var testInfo testutils.APITestInfo
+	user, token := setupUser(t)
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Kanban board"},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	todo := board.Columns[0]
+	var doing, done Column
+	memos := make([]Memo, 3)
+	otherBoard, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Not shared"},
+		TrackedEntity: trackedBy(primitive.NewObjectID()),
+	})
+	otherMemo, _ := createMemo(otherBoard.ID.Hex(), Memo{BasicInfo: BasicInfo{Title: "Not shared"}})
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+		deleteBoard(otherBoard.ID.Hex())
+	})
+
+	loadBoard := func(t *testing.T) Board {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s", board.ID.Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var b Board
+		json.NewDecoder(rr.Body).Decode(&b)
+		return b
+	}
+
+	columnMemoIDs := func(column Column) []primitive.ObjectID {
+		ids := make([]primitive.ObjectID, 0)
+		for _, memo := range column.Memos {
+			ids = append(ids, memo.ID)
+		}
+		return ids
+	}
+
+	t.Run("NewBoardHasDefaultColumn", func(t *testing.T) {
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(board.Columns))
+		testutils.Equals(t, testutils.CallFromTestFile, columnDefaultTitle, todo.Title)
+	})
+
+	t.Run("CreateColumns", func(t *testing.T) {
+		for _, title := range []string{"Doing", "Done"} {
+			testInfo = testutils.APITestInfo{
+				Path:               fmt.Sprintf("boards/%s/columns", board.ID.Hex()),
+				Method:             http.MethodPost,
+				Payload:            Column{Title: title},
+				ExpectedHTTPStatus: http.StatusOK,
+				AuthToken:          token,
+			}
+			rr := apiTester.TestPath(t, testInfo)
+			json.NewDecoder(rr.Body).Decode(board)
+		}
+
+		testutils.Equals(t, testutils.CallFromTestFile, 3, len(board.Columns))
+		doing, done = board.Columns[1], board.Columns[2]
+		testutils.Equals(t, testutils.CallFromTestFile, "Done", done.Title)
+	})
+
+	t.Run("CreateColumnWithoutTitle", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/columns", board.ID.Hex()),
+			Method:             http.MethodPost,
+			Payload:            Column{Title: " "},
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("RenameColumn", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/columns/%s", board.ID.Hex(), doing.ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            Column{Title: "In progress"},
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+		json.NewDecoder(rr.Body).Decode(board)
+
+		testutils.Equals(t, testutils.CallFromTestFile, "In progress", board.Columns[1].Title)
+	})
+
+	t.Run("ReorderColumns", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/columns", board.ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            ColumnOrder{ColumnIDs: []primitive.ObjectID{done.ID, todo.ID}},
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		testInfo.Payload = ColumnOrder{ColumnIDs: []primitive.ObjectID{todo.ID, done.ID, doing.ID}}
+		testInfo.ExpectedHTTPStatus = http.StatusOK
+		rr := apiTester.TestPath(t, testInfo)
+		json.NewDecoder(rr.Body).Decode(board)
+
+		testutils.Equals(t, testutils.CallFromTestFile, done.ID, board.Columns[1].ID)
+		testutils.Equals(t, testutils.CallFromTestFile, doing.ID, board.Columns[2].ID)
+	})
+
+	t.Run("CreateMemosInColumns", func(t *testing.T) {
+		columnIDs := []primitive.ObjectID{primitive.NilObjectID, primitive.NilObjectID, doing.ID}
+		for idx := range memos {
+			testInfo = testutils.APITestInfo{
+				Path:               fmt.Sprintf("boards/%s/memos", board.ID.Hex()),
+				Method:             http.MethodPost,
+				Payload:            Memo{BasicInfo: BasicInfo{Title: fmt.Sprintf("Memo %d", idx)}, ColumnID: columnIDs[idx]},
+				ExpectedHTTPStatus: http.StatusOK,
+				AuthToken:          token,
+			}
+			rr := apiTester.TestPath(t, testInfo)
+			json.NewDecoder(rr.Body).Decode(&memos[idx])
+		}
+
+		// Memos without column go to the first one
+		testutils.Equals(t, testutils.CallFromTestFile, todo.ID, memos[1].ColumnID)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, memos[1].Position)
+		testutils.Equals(t, testutils.CallFromTestFile, doing.ID, memos[2].ColumnID)
+		testutils.Equals(t, testutils.CallFromTestFile, 0, memos[2].Position)
+
+		b := loadBoard(t)
+		testutils.Equals(t, testutils.CallFromTestFile, []primitive.ObjectID{memos[0].ID, memos[1].ID}, columnMemoIDs(b.Columns[0]))
+		testutils.Equals(t, testutils.CallFromTestFile, 0, len(b.Columns[1].Memos))
+		testutils.Equals(t, testutils.CallFromTestFile, []primitive.ObjectID{memos[2].ID}, columnMemoIDs(b.Columns[2]))
+	})
+
+	t.Run("MoveMemo", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/memos/%s/move", board.ID.Hex(), memos[1].ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            MemoMove{ColumnID: doing.ID, Position: 0},
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var movedMemo Memo
+		json.NewDecoder(rr.Body).Decode(&movedMemo)
+		testutils.Equals(t, testutils.CallFromTestFile, doing.ID, movedMemo.ColumnID)
+		testutils.Equals(t, testutils.CallFromTestFile, 0, movedMemo.Position)
+
+		b := loadBoard(t)
+		testutils.Equals(t, testutils.CallFromTestFile, []primitive.ObjectID{memos[0].ID}, columnMemoIDs(b.Columns[0]))
+		testutils.Equals(t, testutils.CallFromTestFile, []primitive.ObjectID{memos[1].ID, memos[2].ID}, columnMemoIDs(b.Columns[2]))
+		testutils.Equals(t, testutils.CallFromTestFile, 1, b.Columns[2].Memos[1].Position)
+	})
+
+	t.Run("MoveMemoToUnknownColumn", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/memos/%s/move", board.ID.Hex(), memos[1].ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            MemoMove{ColumnID: primitive.NewObjectID()},
+			ExpectedHTTPStatus: http.StatusNotFound,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("DeleteColumnWithFallback", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/columns/%s?fallback=%s", board.ID.Hex(), doing.ID.Hex(), doing.ID.Hex()),
+			Method:             http.MethodDelete,
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		testInfo.Path = fmt.Sprintf("boards/%s/columns/%s?fallback=%s", board.ID.Hex(), doing.ID.Hex(), todo.ID.Hex())
+		testInfo.ExpectedHTTPStatus = http.StatusOK
+		apiTester.TestPath(t, testInfo)
+
+		b := loadBoard(t)
+		testutils.Equals(t, testutils.CallFromTestFile, 2, len(b.Columns))
+		testutils.Equals(t, testutils.CallFromTestFile,
+			[]primitive.ObjectID{memos[0].ID, memos[1].ID, memos[2].ID}, columnMemoIDs(b.Columns[0]))
+	})
+
+	t.Run("LastColumnCannotBeDeleted", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/columns/%s", board.ID.Hex(), done.ID.Hex()),
+			Method:             http.MethodDelete,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		testInfo.Path = fmt.Sprintf("boards/%s/columns/%s", board.ID.Hex(), todo.ID.Hex())
+		testInfo.ExpectedHTTPStatus = http.StatusBadRequest
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("OutsiderCannotChangeColumns", func(t *testing.T) {
+		otherColumnID := otherBoard.Columns[0].ID.Hex()
+		requests := []testutils.APITestInfo{
+			{Path: fmt.Sprintf("boards/%s/columns", otherBoard.ID.Hex()), Method: http.MethodPost, Payload: Column{Title: "Mine"}},
+			{Path: fmt.Sprintf("boards/%s/columns/%s", otherBoard.ID.Hex(), otherColumnID), Method: http.MethodPut, Payload: Column{Title: "Mine"}},
+			{Path: fmt.Sprintf("boards/%s/columns", otherBoard.ID.Hex()), Method: http.MethodPut, Payload: ColumnOrder{}},
+			{Path: fmt.Sprintf("boards/%s/columns/%s", otherBoard.ID.Hex(), otherColumnID), Method: http.MethodDelete},
+			{Path: fmt.Sprintf("boards/%s/memos/%s/move", otherBoard.ID.Hex(), otherMemo.ID.Hex()), Method: http.MethodPut, Payload: MemoMove{ColumnID: otherBoard.Columns[0].ID}},
+		}
+		for _, testInfo := range requests {
+			testInfo.ExpectedHTTPStatus = http.StatusNotFound
+			testInfo.AuthToken = token
+			apiTester.TestPath(t, testInfo)
+		}
+
+		b, _ := findBoardByID(otherBoard.ID.Hex())
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(b.Columns))
+	})
+}
+
+func TestE2EMemoPositionsAreUnique(t *testing.T) {
+	t.Parallel()
+
+	user, _ := setupUser(t)
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Busy board"},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	columnID := board.Columns[0].ID
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+	})
+
+	columnPositions := func(t *testing.T) []int {
+		memos, errMsg := findMemosByBoardID(board.ID)
+		testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Cannot load memos: %v", errMsg)
+
+		positions := make([]int, len(memos))
+		for idx, memo := range memos {
+			positions[idx] = memo.Position
+		}
+		sort.Ints(positions)
+		return positions
+	}
+
+	t.Run("ConcurrentCreations", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				createMemo(board.ID.Hex(), Memo{
+					BasicInfo:     BasicInfo{Title: fmt.Sprintf("Memo %d", i)},
+					TrackedEntity: trackedBy(user.ID),
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		testutils.Equals(t, testutils.CallFromTestFile, []int{0, 1, 2, 3, 4}, columnPositions(t))
+	})
+
+	t.Run("DuplicatedPositionsAreRenumbered", func(t *testing.T) {
+		dbMemoCollection.UpdateMany(context.TODO(), bson.M{"boardId": board.ID}, bson.M{"$set": bson.M{"position": 1}})
+
+		written := Memo{}
+		renumberedMemos, errMsg := normalizeMemoPositions(board.ID, columnID, &written)
+		testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Cannot renumber memos: %v", errMsg)
+		testutils.Equals(t, testutils.CallFromTestFile, 4, len(renumberedMemos))
+		testutils.Equals(t, testutils.CallFromTestFile, []int{0, 1, 2, 3, 4}, columnPositions(t))
+
+		renumberedMemos, _ = normalizeMemoPositions(board.ID, columnID, nil)
+		testutils.Equals(t, testutils.CallFromTestFile, 0, len(renumberedMemos))
+	})
+}
@@ -0,0 +1,291 @@
+package memo
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	completionCreated   = "created"
+	completionCompleted = "completed"
+	completionReopened  = "reopened"
+	completionDeleted   = "deleted"
+
+	// completionDayFormat is the day of a completion history bucket, in the
+	// timezone of the user
+	completionDayFormat = "2006-01-02"
+	// completionDefaultDays is the length of a completion history without
+	// explicit start
+	completionDefaultDays = 30
+	// completionMaxDays limits the length of a completion history
+	completionMaxDays = 366
+)
+
+// CompletionEvent is a single step of an item life which impacts the number
+// of remaining items of a board
+type CompletionEvent struct {
+	ID         primitive.ObjectID `json:"-" bson:"_id"`
+	BoardID    primitive.ObjectID `json:"boardId" bson:"boardId"`
+	MemoID     primitive.ObjectID `json:"memoId" bson:"memoId"`
+	ItemID     primitive.ObjectID `json:"itemId" bson:"itemId"`
+	Type       string             `json:"type" bson:"type"`
+	IsFinished bool               `json:"isFinished" bson:"isFinished"` // Item state after the event, or before deletion
+	UserID     primitive.ObjectID `json:"userId" bson:"userId"`
+	At         time.Time          `json:"at" bson:"at"`
+}
+
+// itemCompletionStart is what the recorded events of an item tell about its
+// state when the completion history started
+type itemCompletionStart struct {
+	ItemID          primitive.ObjectID `bson:"_id"`
+	BoardID         primitive.ObjectID `bson:"boardId"`
+	MemoID          primitive.ObjectID `bson:"memoId"`
+	HasCreated      bool               `bson:"hasCreated"`
+	FirstType       string             `bson:"firstType"`
+	FirstIsFinished bool               `bson:"firstIsFinished"`
+	FirstAt         time.Time          `bson:"firstAt"`
+}
+
+// CompletionDay sums up the completion events of a single day
+type CompletionDay struct {
+	Date      string `json:"date"`
+	Created   int    `json:"created"`
+	Completed int    `json:"completed"`
+	Reopened  int    `json:"reopened"`
+	Deleted   int    `json:"deleted"`
+	Remaining int    `json:"remaining"` // Unfinished items at the end of the day
+}
+
+// CompletionHistory is the completion history of a board over a period
+type CompletionHistory struct {
+	BoardID primitive.ObjectID `json:"boardId"`
+	Events  []CompletionEvent  `json:"events"`
+	Days    []CompletionDay    `json:"days"`
+}
+
+// trackCompletion sets the completion fields of the items of a memo being saved
+// by userID. Fields sent by the client are ignored:
+//   - a newly finished item is completed now by userID
+//   - an item which was already finished keeps its completion
+//   - an unfinished item has no completion
+func (m *Memo) trackCompletion(previous *Memo, userID primitive.ObjectID, now time.Time) {
+	previousItems := make(map[primitive.ObjectID]Item)
+	if previous != nil {
+		previousItems = flattenItems(previous.Items)
+	}
+
+	walkItems(m.Items, func(item *Item, parentID primitive.ObjectID) {
+		previousItem, exists := previousItems[item.ID]
+
+		switch {
+		case !item.IsFinished:
+			item.CompletedAt = time.Time{}
+			item.CompletedBy = primitive.NilObjectID
+		case exists && previousItem.IsFinished:
+			item.CompletedAt = previousItem.CompletedAt
+			item.CompletedBy = previousItem.CompletedBy
+		default:
+			item.CompletedAt = now
+			item.CompletedBy = userID
+		}
+	})
+}
+
+// buildCompletionEvents compares the items of a memo before and after a save
+// and lists the events changing the board completion. Nested items count as
+// any other item. A nil after memo means that the memo is deleted
+func buildCompletionEvents(boardID primitive.ObjectID, before *Memo, after *Memo,
+	userID primitive.ObjectID, now time.Time) []CompletionEvent {
+
+	events := make([]CompletionEvent, 0)
+	newEvent := func(memoID primitive.ObjectID, item Item, eventType string) CompletionEvent {
+		return CompletionEvent{
+			BoardID:    boardID,
+			MemoID:     memoID,
+			ItemID:     item.ID,
+			Type:       eventType,
+			IsFinished: item.IsFinished,
+			UserID:     userID,
+			At:         now,
+		}
+	}
+
+	previousItems := make(map[primitive.ObjectID]Item)
+	if before != nil {
+		previousItems = flattenItems(before.Items)
+	}
+
+	if after != nil {
+		walkItems(after.Items, func(item *Item, parentID primitive.ObjectID) {
+			previousItem, exists := previousItems[item.ID]
+			delete(previousItems, item.ID)
+
+			if !exists {
+				events = append(events, newEvent(after.ID, Item{ID: item.ID}, completionCreated))
+				if item.IsFinished {
+					events = append(events, newEvent(after.ID, *item, completionCompleted))
+				}
+			} else if item.IsFinished && !previousItem.IsFinished {
+				events = append(events, newEvent(after.ID, *item, completionCompleted))
+			} else if !item.IsFinished && previousItem.IsFinished {
+				events = append(events, newEvent(after.ID, *item, completionReopened))
+			}
+		})
+	}
+
+	// Keep the original order for the deleted items
+	if before != nil {
+		walkItems(before.Items, func(item *Item, parentID primitive.ObjectID) {
+			if _, isDeleted := previousItems[item.ID]; isDeleted {
+				events = append(events, newEvent(before.ID, *item, completionDeleted))
+			}
+		})
+	}
+
+	return events
+}
+
+// backfillCompletionEvents returns the events missing for an item created
+// before the completion history: its creation and, if it was finished, its
+// completion. The start is nil if no event is recorded for the item, whose
+// current state is then its initial state. Backfilled events happen before the
+// recorded ones
+func backfillCompletionEvents(boardID primitive.ObjectID, memoID primitive.ObjectID, item Item,
+	createdBy primitive.ObjectID, start *itemCompletionStart) []CompletionEvent {
+
+	if start != nil && start.HasCreated {
+		return nil
+	}
+
+	createdAt := item.ID.Timestamp()
+	isFinished := item.IsFinished
+	if start != nil {
+		if !createdAt.Before(start.FirstAt) {
+			createdAt = start.FirstAt.Add(-time.Millisecond)
+		}
+		switch start.FirstType {
+		case completionCompleted:
+			isFinished = false
+		case completionReopened:
+			isFinished = true
+		case completionDeleted:
+			isFinished = start.FirstIsFinished
+		}
+	}
+
+	events := []CompletionEvent{{
+		BoardID: boardID,
+		MemoID:  memoID,
+		ItemID:  item.ID,
+		Type:    completionCreated,
+		UserID:  createdBy,
+		At:      createdAt,
+	}}
+	if isFinished {
+		completedAt := item.CompletedAt
+		if completedAt.Before(createdAt) || (start != nil && !completedAt.Before(start.FirstAt)) {
+			completedAt = createdAt
+		}
+		events = append(events, CompletionEvent{
+			BoardID:    boardID,
+			MemoID:     memoID,
+			ItemID:     item.ID,
+			Type:       completionCompleted,
+			IsFinished: true,
+			UserID:     item.CompletedBy,
+			At:         completedAt,
+		})
+	}
+
+	return events
+}
+
+// buildCompletionDays buckets the events per day between from and to, both
+// included, in the timezone of from and to. Events must be sorted by time and
+// start from the board creation so that the remaining items count is correct
+func buildCompletionDays(events []CompletionEvent, from time.Time, to time.Time) []CompletionDay {
+	days := make([]CompletionDay, 0)
+	from = truncateToDay(from)
+	to = truncateToDay(to)
+
+	remaining := 0
+	eventIdx := 0
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		nextDay := day.AddDate(0, 0, 1)
+		bucket := CompletionDay{Date: day.Format(completionDayFormat)}
+
+		for ; eventIdx < len(events) && events[eventIdx].At.Before(nextDay); eventIdx++ {
+			event := events[eventIdx]
+			isInDay := !event.At.Before(day)
+
+			switch event.Type {
+			case completionCreated:
+				remaining++
+				if isInDay {
+					bucket.Created++
+				}
+			case completionCompleted:
+				remaining--
+				if isInDay {
+					bucket.Completed++
+				}
+			case completionReopened:
+				remaining++
+				if isInDay {
+					bucket.Reopened++
+				}
+			case completionDeleted:
+				if !event.IsFinished {
+					remaining--
+				}
+				if isInDay {
+					bucket.Deleted++
+				}
+			}
+		}
+
+		bucket.Remaining = remaining
+		days = append(days, bucket)
+	}
+
+	return days
+}
+
+// parseCompletionRange parses the "from" and "to" days of a completion history
+// in the timezone of now. Without "to", the history ends today and without
+// "from", it covers the completionDefaultDays days before "to"
+func parseCompletionRange(fromParam string, toParam string, now time.Time) (time.Time, time.Time, error) {
+	to := truncateToDay(now)
+	if toParam != "" {
+		parsed, err := time.ParseInLocation(completionDayFormat, toParam, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, 1-completionDefaultDays)
+	if fromParam != "" {
+		parsed, err := time.ParseInLocation(completionDayFormat, fromParam, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("completion history cannot end before it starts")
+	}
+	if to.Sub(from) >= completionMaxDays*24*time.Hour {
+		return time.Time{}, time.Time{}, errors.New("completion history is too long")
+	}
+
+	return from, to, nil
+}
+
+// truncateToDay returns the beginning of the day of t in the location of t
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,217 @@
+package memo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTrackCompletion(t *testing.T) {
+	userID := primitive.NewObjectID()
+	yesterday := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	now := yesterday.AddDate(0, 0, 1)
+
+	previous := Memo{Items: []Item{
+		{ID: primitive.NewObjectID(), IsFinished: true, CompletedAt: yesterday, CompletedBy: userID},
+		{ID: primitive.NewObjectID(), IsFinished: true, CompletedAt: yesterday, CompletedBy: userID},
+		{ID: primitive.NewObjectID()},
+	}}
+	memo := Memo{Items: []Item{
+		{ID: previous.Items[0].ID, IsFinished: true},                            // still finished
+		{ID: previous.Items[1].ID, CompletedAt: now},                            // reopened
+		{ID: previous.Items[2].ID, IsFinished: true},                            // completed
+		{ID: primitive.NewObjectID(), IsFinished: true, CompletedAt: yesterday}, // created finished
+	}}
+
+	memo.trackCompletion(&previous, userID, now)
+
+	testutils.Equals(t, testutils.CallFromTestFile, yesterday, memo.Items[0].CompletedAt)
+	testutils.Equals(t, testutils.CallFromTestFile, time.Time{}, memo.Items[1].CompletedAt)
+	testutils.Equals(t, testutils.CallFromTestFile, primitive.NilObjectID, memo.Items[1].CompletedBy)
+	testutils.Equals(t, testutils.CallFromTestFile, now, memo.Items[2].CompletedAt)
+	testutils.Equals(t, testutils.CallFromTestFile, userID, memo.Items[2].CompletedBy)
+	testutils.Equals(t, testutils.CallFromTestFile, now, memo.Items[3].CompletedAt)
+}
+
+func TestBuildCompletionHistory(t *testing.T) {
+	boardID := primitive.NewObjectID()
+	userID := primitive.NewObjectID()
+	day1 := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	day3 := day1.AddDate(0, 0, 2)
+
+	// Day 1: three items are created, one of them is already finished
+	memo := Memo{ID: primitive.NewObjectID(), Items: []Item{
+		{ID: primitive.NewObjectID()},
+		{ID: primitive.NewObjectID()},
+		{ID: primitive.NewObjectID(), IsFinished: true},
+	}}
+	events := buildCompletionEvents(boardID, nil, &memo, userID, day1)
+	testutils.Equals(t, testutils.CallFromTestFile, 4, len(events))
+
+	// Day 3: first item is completed, finished item is reopened and second
+	// item is deleted
+	updated := Memo{ID: memo.ID, Items: []Item{
+		{ID: memo.Items[0].ID, IsFinished: true},
+		{ID: memo.Items[2].ID},
+	}}
+	dayEvents := buildCompletionEvents(boardID, &memo, &updated, userID, day3)
+	testutils.Equals(t, testutils.CallFromTestFile, 3, len(dayEvents))
+	testutils.Equals(t, testutils.CallFromTestFile, completionCompleted, dayEvents[0].Type)
+	testutils.Equals(t, testutils.CallFromTestFile, completionReopened, dayEvents[1].Type)
+	testutils.Equals(t, testutils.CallFromTestFile, completionDeleted, dayEvents[2].Type)
+	events = append(events, dayEvents...)
+
+	days := buildCompletionDays(events, day1.AddDate(0, 0, 1), day3)
+	testutils.Equals(t, testutils.CallFromTestFile, []CompletionDay{
+		{Date: "2020-03-02", Remaining: 2},
+		{Date: "2020-03-03", Completed: 1, Reopened: 1, Deleted: 1, Remaining: 1},
+	}, days)
+}
+
+func TestBackfillCompletionEvents(t *testing.T) {
+	boardID, memoID, userID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	historyStart := time.Now().Add(time.Hour)
+
+	// Finished before the history, then reopened
+	reopened := Item{ID: primitive.NewObjectID()}
+	events := backfillCompletionEvents(boardID, memoID, reopened, userID, &itemCompletionStart{
+		FirstType: completionReopened,
+		FirstAt:   historyStart,
+	})
+	testutils.Equals(t, testutils.CallFromTestFile, 2, len(events))
+	testutils.Equals(t, testutils.CallFromTestFile, completionCompleted, events[1].Type)
+	events = append(events, CompletionEvent{Type: completionReopened, At: historyStart})
+
+	// Unfinished before the history, then completed
+	completed := Item{ID: primitive.NewObjectID(), IsFinished: true}
+	backfilled := backfillCompletionEvents(boardID, memoID, completed, userID, &itemCompletionStart{
+		FirstType: completionCompleted,
+		FirstAt:   historyStart,
+	})
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(backfilled))
+	events = append(backfilled, events...)
+	events = append(events, CompletionEvent{Type: completionCompleted, At: historyStart})
+
+	// Untouched since the history started
+	untouched := Item{ID: primitive.NewObjectID()}
+	events = append(backfillCompletionEvents(boardID, memoID, untouched, userID, nil), events...)
+
+	testutils.Equals(t, testutils.CallFromTestFile, 0,
+		len(backfillCompletionEvents(boardID, memoID, untouched, userID, &itemCompletionStart{HasCreated: true})))
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	days := buildCompletionDays(events, historyStart, historyStart)
+	testutils.Equals(t, testutils.CallFromTestFile, 2, days[0].Remaining)
+}
+
+func TestParseCompletionRange(t *testing.T) {
+	now := time.Date(2020, 3, 31, 18, 0, 0, 0, time.UTC)
+
+	from, to, err := parseCompletionRange("", "", now)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC), from)
+	testutils.Equals(t, testutils.CallFromTestFile, time.Date(2020, 3, 31, 0, 0, 0, 0, time.UTC), to)
+
+	_, _, err = parseCompletionRange("2020-03-10", "2020-03-01", now)
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Range ending before its start is invalid")
+
+	_, _, err = parseCompletionRange("2018-01-01", "", now)
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Range is too long")
+
+	_, _, err = parseCompletionRange("pouet", "", now)
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Day format is invalid")
+}
+
+func TestE2ECompletion(t *testing.T) {
+	t.Parallel()
+
+	var testInfo testutils.APITestInfo
+	user, token := setupUser(t)
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Completion board"},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	memo := Memo{
+		BasicInfo: BasicInfo{Title: "Completion memo"},
+		Items:     []Item{{Text: "Item 1"}, {Text: "Item 2"}},
+	}
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+	})
+
+	t.Run("CompletionIsSetByServer", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/memos", board.ID.Hex()),
+			Method:             http.MethodPost,
+			Payload:            memo,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+		json.NewDecoder(rr.Body).Decode(&memo)
+
+		memo.Items[0].IsFinished = true
+		memo.Items[1].CompletedBy = primitive.NewObjectID()
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/memos/%s", board.ID.Hex(), memo.ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            memo,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr = apiTester.TestPath(t, testInfo)
+		json.NewDecoder(rr.Body).Decode(&memo)
+
+		testutils.Equals(t, testutils.CallFromTestFile, user.ID, memo.Items[0].CompletedBy)
+		testutils.Assert(t, testutils.CallFromTestFile, !memo.Items[0].CompletedAt.IsZero(), "Completion date is set")
+		testutils.Equals(t, testutils.CallFromTestFile, primitive.NilObjectID, memo.Items[1].CompletedBy)
+	})
+
+	t.Run("CompletionHistory", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/completions", board.ID.Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var history CompletionHistory
+		json.NewDecoder(rr.Body).Decode(&history)
+
+		testutils.Equals(t, testutils.CallFromTestFile, 3, len(history.Events))
+		testutils.Equals(t, testutils.CallFromTestFile, completionDefaultDays, len(history.Days))
+		today := history.Days[len(history.Days)-1]
+		testutils.Equals(t, testutils.CallFromTestFile, 2, today.Created)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, today.Completed)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, today.Remaining)
+	})
+
+	t.Run("InvalidRange", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/completions?from=2020-03-10&to=2020-03-01", board.ID.Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("UnknownBoard", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/completions", primitive.NewObjectID().Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusNotFound,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+}
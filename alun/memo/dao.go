@@ -2,8 +2,7 @@ package memo
 
 import (
 	"context"
-	"fmt"
-	"net/http"
+	"time"
 
 	"github.com/Al-un/alun-api/alun/core"
 	"github.com/Al-un/alun-api/alun/utils"
@@ -15,9 +14,11 @@ import (
 
 // ---------- Variable and init -----------------------------------------------
 var (
-	dbMemoCollectionName string
-	dbMemoCollection     *mongo.Collection
-	returnOpt            options.ReturnDocument = 1
+	dbBoardCollectionName string
+	dbMemoCollectionName  string
+	dbBoardCollection     *mongo.Collection
+	dbMemoCollection      *mongo.Collection
+	returnOpt             options.ReturnDocument = 1
 )
 
 // Init the connection with MongoDB upon app initialisation
@@ -27,30 +28,62 @@ func initDao() {
 		memoLogger.Fatal(1, "%v", err)
 	}
 
-	// Initialisation: collections name
-	dbMemoCollectionName = "al_memos"
+	// Initialisation: collections name. Boards keep the historical collection
+	// name to avoid moving them
+	dbBoardCollectionName = "al_memos"
+	dbMemoCollectionName = "al_memos_memos"
 
 	// Initialisation: collections instances
+	dbBoardCollection = memoMongoDb.Collection(dbBoardCollectionName)
 	dbMemoCollection = memoMongoDb.Collection(dbMemoCollectionName)
+	initIndexes()
+	initSearchIndexes()
+	initSyncDao(memoMongoDb)
+	initQuotaDao(memoMongoDb)
+	initCompletionDao(memoMongoDb)
+	initSmartListDao(memoMongoDb)
+	initWatchDao(memoMongoDb)
+	initTimeEntryDao(memoMongoDb)
+	initAttachmentDao(memoMongoDb)
+	initMigrationDao(memoMongoDb)
+
+	// Initialisation: data migration
+	migrateEmbeddedMemos()
+	migrateDefaultColumns()
+	runMigrationOnce("completionEvents", migrateCompletionEvents)
+	runMigrationOnce("changeFeed", migrateChangeFeed)
 
 	memoLogger.Debug("[MongoDB] Memo initialisation!")
 }
 
-// ---------- CRUD ------------------------------------------------------------
-func findBoardsByUserID(userID string) ([]Board, *core.ServiceMessage) {
-	id, _ := primitive.ObjectIDFromHex(userID)
-	filter := bson.M{
-		core.TrackedCreatedBy: id,
+// initIndexes ensures that boards can be listed by owner and memos by board
+func initIndexes() {
+	boardIndex := mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: core.TrackedCreatedBy, Value: 1}},
 	}
-	options := &options.FindOptions{
-		Projection: bson.M{
-			"memos": 0,
+	if _, err := dbBoardCollection.Indexes().CreateOne(context.TODO(), boardIndex); err != nil {
+		memoLogger.Warn("[MongoDB] Board index creation failed: %v", err)
+	}
+
+	memoIndex := mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "boardId", Value: 1},
+			primitive.E{Key: "_id", Value: 1},
 		},
 	}
+	if _, err := dbMemoCollection.Indexes().CreateOne(context.TODO(), memoIndex); err != nil {
+		memoLogger.Warn("[MongoDB] Memo index creation failed: %v", err)
+	}
+}
+
+// ---------- CRUD ------------------------------------------------------------
+func findBoardsByUserID(userID string) ([]Board, *core.ServiceMessage) {
+	id, _ := primitive.ObjectIDFromHex(userID)
+	filter := boardAccessFilter(id)
 
 	boards := make([]Board, 0)
 
-	cur, err := dbMemoCollection.Find(context.TODO(), filter, options)
+	cur, err := dbBoardCollection.Find(context.TODO(), filter)
 	if err != nil {
 		return boards, core.NewServiceErrorMessage(err)
 	}
@@ -70,34 +103,57 @@ func findBoardByID(boardID string) (*Board, *core.ServiceMessage) {
 
 	var board Board
 
-	if err := dbMemoCollection.FindOne(context.TODO(), filter).Decode(&board); err != nil {
+	if err := dbBoardCollection.FindOne(context.TODO(), filter).Decode(&board); err != nil {
 		return nil, core.NewServiceErrorMessage(err)
 	}
 
+	memos, errMsg := findMemosByBoardID(id)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	board.Memos = memos
+
 	return &board, nil
 }
+
+// findMemosByBoardID loads the memos of a board by position in their column
+func findMemosByBoardID(boardID primitive.ObjectID) ([]Memo, *core.ServiceMessage) {
+	filter := bson.M{"boardId": boardID}
+	options := &options.FindOptions{
+		Sort: bson.D{
+			primitive.E{Key: "position", Value: 1},
+			primitive.E{Key: "_id", Value: 1},
+		},
+	}
+
+	memos := make([]Memo, 0)
+
+	cur, err := dbMemoCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	if err = cur.All(context.TODO(), &memos); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return memos, nil
+}
+
 func findMemoByID(boardID string, memoID string) (*Memo, *core.ServiceMessage) {
 	bID, _ := primitive.ObjectIDFromHex(boardID)
 	mID, _ := primitive.ObjectIDFromHex(memoID)
 	filter := bson.M{
-		"_id":       bID,
-		"memos._id": mID,
-	}
-	options := &options.FindOneOptions{
-		Projection: bson.M{
-			"memos.$": 1,
-		},
+		"_id":     mID,
+		"boardId": bID,
 	}
 
-	var board Board
+	var memo Memo
 
-	if err := dbMemoCollection.FindOne(context.TODO(), filter, options).Decode(&board); err != nil {
+	if err := dbMemoCollection.FindOne(context.TODO(), filter).Decode(&memo); err != nil {
 		return nil, core.NewServiceErrorMessage(err)
 	}
 
-	var memo Memo
-	memo = board.Memos[0]
-
 	return &memo, nil
 }
 
@@ -149,7 +205,7 @@ func isBoardIDExist(boardID string) (bool, *core.ServiceMessage) {
 	id, _ := primitive.ObjectIDFromHex(boardID)
 	filter := bson.M{"_id": id}
 
-	count, err := dbMemoCollection.CountDocuments(context.TODO(), filter)
+	count, err := dbBoardCollection.CountDocuments(context.TODO(), filter)
 	if err != nil {
 		return false, core.NewServiceErrorMessage(err)
 	}
@@ -171,106 +227,265 @@ func isBoardIDExist(boardID string) (bool, *core.ServiceMessage) {
 
 func createBoard(toCreateBoard Board) (*Board, *core.ServiceMessage) {
 	toCreateBoard.ID = primitive.NewObjectID()
+	toCreateBoard.prepareColumns()
+	if err := toCreateBoard.prepareFields(); err != nil {
+		return nil, newFieldError(fieldInvalid, err)
+	}
+
+	if errMsg := reserveBoardQuota(toCreateBoard.CreatedBy); errMsg != nil {
+		return nil, errMsg
+	}
 
-	insertResult, err := dbMemoCollection.InsertOne(context.TODO(), toCreateBoard)
+	insertResult, err := dbBoardCollection.InsertOne(context.TODO(), toCreateBoard)
 	if err != nil {
+		releaseQuota(boardsUsageKey(toCreateBoard.CreatedBy))
 		return nil, core.NewServiceErrorMessage(err)
 	}
 
 	var newBoard Board
 	filter := bson.M{"_id": insertResult.InsertedID}
-	if err := dbMemoCollection.FindOne(context.TODO(), filter).Decode(&newBoard); err != nil {
+	if err := dbBoardCollection.FindOne(context.TODO(), filter).Decode(&newBoard); err != nil {
 		return nil, core.NewServiceErrorMessage(err)
 	}
 
+	recordChanges(newBoard.recipients(), newBoardChange(newBoard, changeOpCreated))
+	sendNotifications(newBoardInvites(newBoard, nil, newBoard.CreatedBy))
+
 	return &newBoard, nil
 }
 
 func createMemo(boardID string, toCreateMemo Memo) (*Memo, *core.ServiceMessage) {
 	memoLogger.Verbose("Creating %s with items %v", toCreateMemo.Title, toCreateMemo.Items)
 
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+
+	// Ensure the board exists and fetch who has to be notified
+	board, errMsg := findBoardColumns(bID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	recipients := board.recipients()
+
+	// New memos are appended to their column, the first one by default
+	if toCreateMemo.ColumnID.IsZero() {
+		toCreateMemo.ColumnID = board.Columns[0].ID
+	} else if board.findColumn(toCreateMemo.ColumnID) < 0 {
+		return nil, columnNotFound
+	}
+	toCreateMemo.Position, errMsg = countColumnMemos(bID, toCreateMemo.ColumnID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
 	toCreateMemo.ID = primitive.NewObjectID()
+	toCreateMemo.BoardID = bID
+	toCreateMemo.prepareItems()
+	if errMsg := validateAssignees(board, nil, toCreateMemo); errMsg != nil {
+		return nil, errMsg
+	}
+	if err := validateFieldValues(board, nil, &toCreateMemo); err != nil {
+		return nil, newFieldError(fieldValueInvalid, err)
+	}
+	if errMsg := toCreateMemo.prepareMentions(board, nil); errMsg != nil {
+		return nil, errMsg
+	}
+	toCreateMemo.trackCompletion(nil, toCreateMemo.CreatedBy, toCreateMemo.CreatedAt)
 
-	bID, _ := primitive.ObjectIDFromHex(boardID)
-	filter := bson.M{
-		"_id": bID,
+	if errMsg := reserveMemoQuota(bID); errMsg != nil {
+		return nil, errMsg
 	}
-	update := bson.M{
-		"$push": bson.M{
-			"memos": toCreateMemo,
-		},
+	itemsCount := int64(countItems(toCreateMemo.Items))
+	if errMsg := reserveItemsQuota(bID, toCreateMemo.ID, 0, itemsCount); errMsg != nil {
+		releaseQuota(memosUsageKey(bID))
+		return nil, errMsg
 	}
-	if err := dbMemoCollection.FindOneAndUpdate(context.TODO(), filter, update).Err(); err != nil {
+	if _, err := dbMemoCollection.InsertOne(context.TODO(), toCreateMemo); err != nil {
+		releaseQuota(memosUsageKey(bID))
+		deleteQuotaUsage(itemsUsageKey(bID, toCreateMemo.ID))
 		return nil, core.NewServiceErrorMessage(err)
 	}
 
+	// Concurrent creations may have counted the same position
+	renumberedMemos, errMsg := normalizeMemoPositions(bID, toCreateMemo.ColumnID, &toCreateMemo)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	changes := diffItems(bID, Memo{}, toCreateMemo)
+	changes = append([]Change{newMemoChange(bID, toCreateMemo, changeOpCreated)}, changes...)
+	for _, renumberedMemo := range renumberedMemos {
+		changes = append(changes, newMemoChange(bID, renumberedMemo, changeOpUpdated))
+	}
+	recordChanges(recipients, changes...)
+	completionEvents := buildCompletionEvents(bID, nil, &toCreateMemo, toCreateMemo.CreatedBy, toCreateMemo.CreatedAt)
+	recordCompletionEvents(completionEvents)
+	notifyAssignees(board, toCreateMemo, newAssignments(nil, toCreateMemo, toCreateMemo.CreatedBy), toCreateMemo.CreatedBy)
+	sendNotifications(newMentionNotifications(board, nil, toCreateMemo, toCreateMemo.CreatedBy))
+	watchEvents := []watchEvent{{Type: watchEventMemoCreated, MemoID: toCreateMemo.ID, Text: toCreateMemo.Title}}
+	notifyWatchers(board, toCreateMemo.CreatedBy, append(watchEvents, newCompletedItemEvents(toCreateMemo, completionEvents)...)...)
+
 	return &toCreateMemo, nil
 }
 
 func updateBoard(boardID string, toUpdateBoard Board) (*Board, *core.ServiceMessage) {
 	id, _ := primitive.ObjectIDFromHex(boardID)
+
+	// Removed members must know that they lost the board
+	previousRecipients, errMsg := findBoardRecipients(id)
+	if errMsg != nil && errMsg.Error != mongo.ErrNoDocuments {
+		return nil, errMsg
+	}
+
 	filter := bson.M{
 		"_id": id,
 	}
 	options := &options.FindOneAndUpdateOptions{
 		ReturnDocument: &returnOpt,
 	}
-	update := bson.M{
-		"$set": bson.M{
-			"title":       toUpdateBoard.Title,
-			"description": toUpdateBoard.Description,
-			"access":      toUpdateBoard.Access,
-			"updatedBy":   toUpdateBoard.UpdatedBy,
-			"updatedAt":   toUpdateBoard.UpdatedAt,
-		},
+	toSet := bson.M{
+		"title":       toUpdateBoard.Title,
+		"description": toUpdateBoard.Description,
+		"access":      toUpdateBoard.Access,
+		"updatedBy":   toUpdateBoard.UpdatedBy,
+		"updatedAt":   toUpdateBoard.UpdatedAt,
 	}
+	// Members are only replaced when they are sent
+	if toUpdateBoard.Members != nil {
+		toSet["members"] = toUpdateBoard.Members
+	}
+	update := bson.M{"$set": toSet}
 
 	var updatedBoard Board
-	if err := dbMemoCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&updatedBoard); err != nil {
+	if err := dbBoardCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&updatedBoard); err != nil {
 		return nil, core.NewServiceErrorMessage(err)
 	}
 
+	// Added members have never received the board content
+	previous := make(map[primitive.ObjectID]bool)
+	for _, recipient := range previousRecipients {
+		previous[recipient] = true
+	}
+	keptRecipients, addedRecipients := make([]primitive.ObjectID, 0), make([]primitive.ObjectID, 0)
+	for _, recipient := range updatedBoard.recipients() {
+		if previous[recipient] {
+			keptRecipients = append(keptRecipients, recipient)
+		} else {
+			addedRecipients = append(addedRecipients, recipient)
+		}
+	}
+	recordChanges(keptRecipients, newBoardChange(updatedBoard, changeOpUpdated))
+	if len(addedRecipients) > 0 {
+		memos, errMsg := findMemosByBoardID(id)
+		if errMsg != nil {
+			memoLogger.Warn("[Sync] Cannot load the memos of board %s for its new members: %v", boardID, errMsg.Error)
+		} else {
+			recordChanges(addedRecipients, newBoardSnapshot(updatedBoard, memos)...)
+		}
+	}
+
+	removedRecipients := make([]primitive.ObjectID, 0)
+	for _, recipient := range previousRecipients {
+		if !updatedBoard.hasAccess(recipient) {
+			removedRecipients = append(removedRecipients, recipient)
+		}
+	}
+	recordChanges(removedRecipients, newBoardChange(Board{ID: id}, changeOpDeleted))
+	sendNotifications(newBoardInvites(updatedBoard, previousRecipients, toUpdateBoard.UpdatedBy))
+	notifyWatchers(&updatedBoard, toUpdateBoard.UpdatedBy, watchEvent{Type: watchEventBoardUpdated})
+
 	return &updatedBoard, nil
 }
 
 func updateMemo(boardID string, memoID string, toUpdateMemo Memo) (*Memo, *core.ServiceMessage) {
+	previousMemo, errMsg := findMemoByID(boardID, memoID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
 	bID, _ := primitive.ObjectIDFromHex(boardID)
+	board, errMsg := findBoardColumns(bID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	toUpdateMemo.matchItems(previousMemo)
+	toUpdateMemo.prepareItems()
+	toUpdateMemo.trackCompletion(previousMemo, toUpdateMemo.UpdatedBy, toUpdateMemo.UpdatedAt)
+	if errMsg := validateAssignees(board, previousMemo, toUpdateMemo); errMsg != nil {
+		return nil, errMsg
+	}
+	if err := validateFieldValues(board, previousMemo, &toUpdateMemo); err != nil {
+		return nil, newFieldError(fieldValueInvalid, err)
+	}
+	if errMsg := toUpdateMemo.prepareMentions(board, previousMemo); errMsg != nil {
+		return nil, errMsg
+	}
+
 	mID, _ := primitive.ObjectIDFromHex(memoID)
 	filter := bson.M{
-		"_id":       bID,
-		"memos._id": mID,
+		"_id":     mID,
+		"boardId": bID,
 	}
 	options := &options.FindOneAndUpdateOptions{
 		ReturnDocument: &returnOpt,
 	}
 	update := bson.M{
 		"$set": bson.M{
-			"memos.$.title":       toUpdateMemo.Title,
-			"memos.$.description": toUpdateMemo.Description,
-			"memos.$.items":       toUpdateMemo.Items,
-			"memos.$.updatedBy":   toUpdateMemo.UpdatedBy,
-			"memos.$.updatedAt":   toUpdateMemo.UpdatedAt,
+			"title":       toUpdateMemo.Title,
+			"description": toUpdateMemo.Description,
+			"items":       toUpdateMemo.Items,
+			"mentions":    toUpdateMemo.Mentions,
+			"updatedBy":   toUpdateMemo.UpdatedBy,
+			"updatedAt":   toUpdateMemo.UpdatedAt,
 		},
 	}
 
-	if err := dbMemoCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Err(); err != nil {
+	previousCount, itemsCount := int64(countItems(previousMemo.Items)), int64(countItems(toUpdateMemo.Items))
+	if errMsg := reserveItemsQuota(bID, mID, previousCount, itemsCount); errMsg != nil {
+		return nil, errMsg
+	}
+
+	var updatedMemo Memo
+	if err := dbMemoCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&updatedMemo); err != nil {
+		if err == mongo.ErrNoDocuments {
+			deleteQuotaUsage(itemsUsageKey(bID, mID)) // Memo deleted concurrently
+		} else {
+			releaseItemsQuota(bID, mID, previousCount, itemsCount)
+		}
 		return nil, core.NewServiceErrorMessage(err)
 	}
 
-	updatedMemo, err := findMemoByID(boardID, memoID)
-	if err != nil {
-		return nil, err
+	changes := diffItems(bID, *previousMemo, updatedMemo)
+	changes = append([]Change{newMemoChange(bID, updatedMemo, changeOpUpdated)}, changes...)
+	recordChanges(board.recipients(), changes...)
+	completionEvents := buildCompletionEvents(bID, previousMemo, &updatedMemo, toUpdateMemo.UpdatedBy, toUpdateMemo.UpdatedAt)
+	recordCompletionEvents(completionEvents)
+	if removedIDs := removedItemIDs(*previousMemo, updatedMemo); len(removedIDs) > 0 {
+		stopTimersOfDeleted(bson.M{"itemId": bson.M{"$in": removedIDs}}, toUpdateMemo.UpdatedAt)
 	}
+	notifyAssignees(board, updatedMemo, newAssignments(previousMemo, updatedMemo, toUpdateMemo.UpdatedBy), toUpdateMemo.UpdatedBy)
+	sendNotifications(newMentionNotifications(board, previousMemo, updatedMemo, toUpdateMemo.UpdatedBy))
+	notifyWatchers(board, toUpdateMemo.UpdatedBy, newCompletedItemEvents(updatedMemo, completionEvents)...)
 
-	return updatedMemo, nil
+	return &updatedMemo, nil
 }
 
 func deleteBoard(boardID string) (int64, int64, *core.ServiceMessage) {
 	id, _ := primitive.ObjectIDFromHex(boardID)
 
+	// Recipients and owner are loaded beforehand as the board will not exist
+	// anymore
+	recipients, errMsg := findBoardRecipients(id)
+	if errMsg != nil && errMsg.Error != mongo.ErrNoDocuments {
+		return -1, -1, errMsg
+	}
+	ownerID, errMsg := findBoardOwnerID(id)
+	if errMsg != nil && errMsg.Error != mongo.ErrNoDocuments {
+		return -1, -1, errMsg
+	}
+
 	// Delete board
 	filter := bson.M{"_id": id}
-	deletedBoard, err := dbMemoCollection.DeleteMany(context.TODO(), filter, nil)
+	deletedBoard, err := dbBoardCollection.DeleteMany(context.TODO(), filter, nil)
 	if err != nil {
 		return -1, -1, core.NewServiceErrorMessage(err)
 	}
@@ -282,34 +497,82 @@ func deleteBoard(boardID string) (int64, int64, *core.ServiceMessage) {
 		return -1, -1, core.NewServiceErrorMessage(err)
 	}
 
-	return deletedBoard.DeletedCount, deletedMemos.DeletedCount, nil
-}
+	// Delete completion history
+	if errMsg := deleteCompletionEventsByBoardID(id); errMsg != nil {
+		return -1, -1, errMsg
+	}
 
-func deleteMemo(boardID string, memoID string) (int64, *core.ServiceMessage) {
-	_, err := findMemoByID(boardID, memoID)
-	if err != nil {
-		if err.HTTPStatus == http.StatusNotFound {
-			return -1, nil
-		}
+	// Delete watches
+	if errMsg := deleteWatchesByBoardID(id); errMsg != nil {
+		return -1, -1, errMsg
+	}
+
+	// Delete time entries
+	if errMsg := deleteTimeEntriesByBoardID(id); errMsg != nil {
+		return -1, -1, errMsg
+	}
 
-		return -1, err
+	// Delete attachments
+	if errMsg := deleteAttachmentsByBoardID(id); errMsg != nil {
+		return -1, -1, errMsg
 	}
 
+	if deletedBoard.DeletedCount > 0 {
+		recordChanges(recipients, newBoardChange(Board{ID: id}, changeOpDeleted))
+		releaseQuota(boardsUsageKey(ownerID))
+	}
+	deleteQuotaUsage(memosUsageKey(id))
+	deleteItemsQuotaUsages(id)
+
+	return deletedBoard.DeletedCount, deletedMemos.DeletedCount, nil
+}
+
+// deleteMemo deletes a memo of a board. The memo is loaded beforehand so that
+// its unfinished items are removed from the completion history
+func deleteMemo(boardID string, memoID string, deletedBy string) (int64, *core.ServiceMessage) {
 	bID, _ := primitive.ObjectIDFromHex(boardID)
 	mID, _ := primitive.ObjectIDFromHex(memoID)
 	filter := bson.M{
-		"_id": bID,
+		"_id":     mID,
+		"boardId": bID,
 	}
-	update := bson.M{
-		"$pull": bson.M{
-			"memos": bson.M{"_id": mID},
-		},
+
+	previousMemo, errMsg := findMemoByID(boardID, memoID)
+	if errMsg != nil {
+		if errMsg.Error == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return -1, errMsg
 	}
 
-	if err := dbMemoCollection.FindOneAndUpdate(context.TODO(), filter, update).Err(); err != nil {
-		fmt.Println(err)
+	deleted, err := dbMemoCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
 		return -1, core.NewServiceErrorMessage(err)
 	}
 
-	return 1, nil
+	if deleted.DeletedCount > 0 {
+		releaseQuota(memosUsageKey(bID))
+		deleteQuotaUsage(itemsUsageKey(bID, mID))
+		if errMsg := deleteAttachmentsByMemoID(bID, mID); errMsg != nil {
+			return -1, errMsg
+		}
+
+		recipients, errMsg := findBoardRecipients(bID)
+		if errMsg != nil {
+			return -1, errMsg
+		}
+		recordChanges(recipients, newMemoChange(bID, Memo{ID: mID}, changeOpDeleted))
+
+		// Close the gap in the column
+		if errMsg := shiftMemoPositions(bID, previousMemo.ColumnID, previousMemo.Position+1, -1, mID); errMsg != nil {
+			return -1, errMsg
+		}
+
+		userID, _ := primitive.ObjectIDFromHex(deletedBy)
+		now := time.Now()
+		recordCompletionEvents(buildCompletionEvents(bID, previousMemo, nil, userID, now))
+		stopTimersOfDeleted(bson.M{"memoId": mID}, now)
+	}
+
+	return deleted.DeletedCount, nil
 }
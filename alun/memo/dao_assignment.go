@@ -0,0 +1,66 @@
+package memo
+
+import (
+	"context"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// assigneeFilter matches the memos having an item, at any depth, assigned to
+// an user
+func assigneeFilter(assigneeID primitive.ObjectID) bson.A {
+	filters := bson.A{}
+	itemsPath := "items"
+	for depth := 1; depth <= itemsMaxDepth; depth++ {
+		filters = append(filters, bson.M{itemsPath + ".assigneeId": assigneeID})
+		itemsPath += ".children"
+	}
+
+	return filters
+}
+
+// findAssignedItems lists the items assigned to an user in all the boards the
+// user can access
+func findAssignedItems(userID string) ([]LocatedItem, *core.ServiceMessage) {
+	assignedItems := make([]LocatedItem, 0)
+	uID, _ := primitive.ObjectIDFromHex(userID)
+
+	boards, errMsg := findBoardsByUserID(userID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	if len(boards) == 0 {
+		return assignedItems, nil
+	}
+
+	boardsByID := make(map[primitive.ObjectID]Board)
+	boardIDs := make(bson.A, 0, len(boards))
+	for _, board := range boards {
+		boardsByID[board.ID] = board
+		boardIDs = append(boardIDs, board.ID)
+	}
+
+	filter := bson.M{
+		"boardId": bson.M{"$in": boardIDs},
+		"$or":     assigneeFilter(uID),
+	}
+	cur, err := dbMemoCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	var memos []Memo
+	if err := cur.All(context.TODO(), &memos); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	for _, memo := range memos {
+		assignedItems = append(assignedItems, locateItems(boardsByID[memo.BoardID], memo, func(item Item) bool {
+			return item.AssigneeID == uID
+		})...)
+	}
+
+	return assignedItems, nil
+}
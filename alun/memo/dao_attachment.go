@@ -0,0 +1,180 @@
+package memo
+
+import (
+	"context"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbAttachmentCollectionName string
+	dbAttachmentCollection     *mongo.Collection
+)
+
+// initAttachmentDao loads the attachments collection. Attachments are listed
+// per memo and summed up per owner
+func initAttachmentDao(memoMongoDb *mongo.Database) {
+	dbAttachmentCollectionName = "al_memos_attachments"
+	dbAttachmentCollection = memoMongoDb.Collection(dbAttachmentCollectionName)
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "boardId", Value: 1},
+				primitive.E{Key: "memoId", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{primitive.E{Key: "ownerId", Value: 1}},
+		},
+	}
+	if _, err := dbAttachmentCollection.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		memoLogger.Warn("[MongoDB] Attachment indexes creation failed: %v", err)
+	}
+}
+
+// ---------- CRUD ------------------------------------------------------------
+
+// createAttachment saves an attachment of an existing memo, unless the
+// attachment bytes limit of the board owner is reached
+func createAttachment(toCreateAttachment Attachment) (*Attachment, *core.ServiceMessage) {
+	memoFilter := bson.M{"_id": toCreateAttachment.MemoID, "boardId": toCreateAttachment.BoardID}
+	memoCount, err := dbMemoCollection.CountDocuments(context.TODO(), memoFilter)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if memoCount == 0 {
+		return nil, memoNotFound
+	}
+
+	ownerID, errMsg := findBoardOwnerID(toCreateAttachment.BoardID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	toCreateAttachment.ID = primitive.NewObjectID()
+	toCreateAttachment.OwnerID = ownerID
+
+	if errMsg := reserveAttachmentBytesQuota(ownerID, toCreateAttachment.Size); errMsg != nil {
+		return nil, errMsg
+	}
+	if _, err := dbAttachmentCollection.InsertOne(context.TODO(), toCreateAttachment); err != nil {
+		releaseQuotaAmount(attachmentBytesUsageKey(ownerID), toCreateAttachment.Size)
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	toCreateAttachment.Content = nil
+	return &toCreateAttachment, nil
+}
+
+// findAttachmentsByMemoID lists the attachments of a memo, without content
+func findAttachmentsByMemoID(boardID primitive.ObjectID, memoID primitive.ObjectID) ([]Attachment, *core.ServiceMessage) {
+	filter := bson.M{"boardId": boardID, "memoId": memoID}
+	options := &options.FindOptions{
+		Projection: bson.M{"content": 0},
+		Sort:       bson.M{core.TrackedCreatedAt: 1},
+	}
+
+	cur, err := dbAttachmentCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	defer cur.Close(context.TODO())
+
+	attachments := make([]Attachment, 0)
+	if err := cur.All(context.TODO(), &attachments); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return attachments, nil
+}
+
+// findAttachmentByID loads an attachment of a memo with its content
+func findAttachmentByID(boardID primitive.ObjectID, memoID primitive.ObjectID, attachmentID primitive.ObjectID) (*Attachment, *core.ServiceMessage) {
+	filter := bson.M{"_id": attachmentID, "boardId": boardID, "memoId": memoID}
+
+	var attachment Attachment
+	if err := dbAttachmentCollection.FindOne(context.TODO(), filter).Decode(&attachment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, attachmentNotFound
+		}
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &attachment, nil
+}
+
+// deleteAttachments deletes the attachments matching filter one by one so
+// that only the size of the attachments actually deleted is released from
+// the quota of their owner
+func deleteAttachments(filter bson.M) (int64, *core.ServiceMessage) {
+	options := &options.FindOptions{
+		Projection: bson.M{"_id": 1, "ownerId": 1, "size": 1},
+	}
+	cur, err := dbAttachmentCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return -1, core.NewServiceErrorMessage(err)
+	}
+	defer cur.Close(context.TODO())
+
+	var toDeleteAttachments []Attachment
+	if err := cur.All(context.TODO(), &toDeleteAttachments); err != nil {
+		return -1, core.NewServiceErrorMessage(err)
+	}
+
+	var deletedCount int64
+	for _, attachment := range toDeleteAttachments {
+		deleted, err := dbAttachmentCollection.DeleteOne(context.TODO(), bson.M{"_id": attachment.ID})
+		if err != nil {
+			return -1, core.NewServiceErrorMessage(err)
+		}
+		if deleted.DeletedCount > 0 {
+			releaseQuotaAmount(attachmentBytesUsageKey(attachment.OwnerID), attachment.Size)
+			deletedCount++
+		}
+	}
+
+	return deletedCount, nil
+}
+
+func deleteAttachment(boardID primitive.ObjectID, memoID primitive.ObjectID, attachmentID primitive.ObjectID) (int64, *core.ServiceMessage) {
+	return deleteAttachments(bson.M{"_id": attachmentID, "boardId": boardID, "memoId": memoID})
+}
+
+func deleteAttachmentsByMemoID(boardID primitive.ObjectID, memoID primitive.ObjectID) *core.ServiceMessage {
+	_, errMsg := deleteAttachments(bson.M{"boardId": boardID, "memoId": memoID})
+	return errMsg
+}
+
+func deleteAttachmentsByBoardID(boardID primitive.ObjectID) *core.ServiceMessage {
+	_, errMsg := deleteAttachments(bson.M{"boardId": boardID})
+	return errMsg
+}
+
+// sumAttachmentBytes is the total size of the attachments owned by an user
+func sumAttachmentBytes(ownerID primitive.ObjectID) (int64, error) {
+	pipeline := []bson.M{
+		{"$match": bson.M{"ownerId": ownerID}},
+		{"$group": bson.M{"_id": nil, "total": bson.M{"$sum": "$size"}}},
+	}
+	cur, err := dbAttachmentCollection.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(context.TODO())
+
+	var result struct {
+		Total int64 `bson:"total"`
+	}
+	if cur.Next(context.TODO()) {
+		if err := cur.Decode(&result); err != nil {
+			return 0, err
+		}
+	}
+
+	return result.Total, cur.Err()
+}
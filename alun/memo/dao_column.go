@@ -0,0 +1,342 @@
+package memo
+
+import (
+	"context"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Columns ---------------------------------------------------------
+
+// findBoardColumns loads a board without its memos
+func findBoardColumns(boardID primitive.ObjectID) (*Board, *core.ServiceMessage) {
+	var board Board
+	if err := dbBoardCollection.FindOne(context.TODO(), bson.M{"_id": boardID}).Decode(&board); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, boardNotFound
+		}
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &board, nil
+}
+
+// updateBoardDefinition applies an update of the board columns or fields and
+// records the board change. Filter must at least match the board ID and
+// notFound is returned when the filter does not match
+func updateBoardDefinition(filter bson.M, update bson.M, tracking core.TrackedEntity, notFound *core.ServiceMessage) (*Board, *core.ServiceMessage) {
+	options := &options.FindOneAndUpdateOptions{
+		ReturnDocument: &returnOpt,
+	}
+	if update["$set"] == nil {
+		update["$set"] = bson.M{}
+	}
+	update["$set"].(bson.M)[core.TrackedUpdatedBy] = tracking.UpdatedBy
+	update["$set"].(bson.M)[core.TrackedUpdatedAt] = tracking.UpdatedAt
+
+	var updatedBoard Board
+	if err := dbBoardCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&updatedBoard); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, notFound
+		}
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	recordChanges(updatedBoard.recipients(), newBoardChange(updatedBoard, changeOpUpdated))
+
+	return &updatedBoard, nil
+}
+
+// createColumn appends a column at the end of a board
+func createColumn(boardID string, column Column, tracking core.TrackedEntity) (*Board, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	if _, errMsg := findBoardColumns(bID); errMsg != nil {
+		return nil, errMsg
+	}
+
+	column.ID = primitive.NewObjectID()
+	column.Memos = nil
+	update := bson.M{
+		"$push": bson.M{"columns": column},
+	}
+
+	return updateBoardDefinition(bson.M{"_id": bID}, update, tracking, columnNotFound)
+}
+
+func renameColumn(boardID string, columnID string, title string, tracking core.TrackedEntity) (*Board, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	cID, _ := primitive.ObjectIDFromHex(columnID)
+	filter := bson.M{
+		"_id":         bID,
+		"columns._id": cID,
+	}
+	update := bson.M{
+		"$set": bson.M{"columns.$.title": title},
+	}
+
+	return updateBoardDefinition(filter, update, tracking, columnNotFound)
+}
+
+// reorderBoardColumns replaces the columns order of a board
+func reorderBoardColumns(boardID string, order ColumnOrder, tracking core.TrackedEntity) (*Board, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	board, errMsg := findBoardColumns(bID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	reordered, err := board.reorderColumns(order.ColumnIDs)
+	if err != nil {
+		return nil, columnOrderInvalid
+	}
+
+	update := bson.M{
+		"$set": bson.M{"columns": reordered},
+	}
+
+	return updateBoardDefinition(bson.M{"_id": bID}, update, tracking, columnNotFound)
+}
+
+// deleteColumn removes a column from a board. Its memos are moved, in the same
+// order, at the end of the fallback column. Without fallback, the first other
+// column is used
+func deleteColumn(boardID string, columnID string, fallbackID string, tracking core.TrackedEntity) (*Board, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	cID, _ := primitive.ObjectIDFromHex(columnID)
+	board, errMsg := findBoardColumns(bID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	if board.findColumn(cID) < 0 {
+		return nil, columnNotFound
+	}
+	if len(board.Columns) == 1 {
+		return nil, columnLastDeletion
+	}
+
+	var fID primitive.ObjectID
+	if fallbackID == "" {
+		fID = board.Columns[0].ID
+		if fID == cID {
+			fID = board.Columns[1].ID
+		}
+	} else {
+		fID, _ = primitive.ObjectIDFromHex(fallbackID)
+		if fID == cID || board.findColumn(fID) < 0 {
+			return nil, columnFallbackInvalid
+		}
+	}
+
+	// Positions are contiguous so shifting keeps the moved memos order
+	fallbackCount, err := dbMemoCollection.CountDocuments(context.TODO(), bson.M{"boardId": bID, "columnId": fID})
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	filter := bson.M{"boardId": bID, "columnId": cID}
+	update := bson.M{
+		"$set": bson.M{"columnId": fID},
+		"$inc": bson.M{"position": fallbackCount},
+	}
+	if _, err := dbMemoCollection.UpdateMany(context.TODO(), filter, update); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	update = bson.M{
+		"$pull": bson.M{"columns": bson.M{"_id": cID}},
+	}
+	updatedBoard, errMsg := updateBoardDefinition(bson.M{"_id": bID}, update, tracking, columnNotFound)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	// Moved memos have changed
+	filter = bson.M{"boardId": bID, "columnId": fID, "position": bson.M{"$gte": fallbackCount}}
+	cur, err := dbMemoCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	defer cur.Close(context.TODO())
+
+	changes := make([]Change, 0)
+	for cur.Next(context.TODO()) {
+		var memo Memo
+		if err := cur.Decode(&memo); err != nil {
+			return nil, core.NewServiceErrorMessage(err)
+		}
+		changes = append(changes, newMemoChange(bID, memo, changeOpUpdated))
+	}
+	recordChanges(updatedBoard.recipients(), changes...)
+
+	return updatedBoard, nil
+}
+
+// ---------- Memo positions --------------------------------------------------
+
+// countColumnMemos counts the memos of a column, which is also the position
+// of a memo appended to the column
+func countColumnMemos(boardID primitive.ObjectID, columnID primitive.ObjectID) (int, *core.ServiceMessage) {
+	count, err := dbMemoCollection.CountDocuments(context.TODO(), bson.M{"boardId": boardID, "columnId": columnID})
+	if err != nil {
+		return -1, core.NewServiceErrorMessage(err)
+	}
+
+	return int(count), nil
+}
+
+// shiftMemoPositions moves by delta the memos of a column from a position.
+// The memo being moved, if any, is excluded
+func shiftMemoPositions(boardID primitive.ObjectID, columnID primitive.ObjectID, fromPosition int,
+	delta int, excludedMemoID primitive.ObjectID) *core.ServiceMessage {
+
+	filter := bson.M{
+		"boardId":  boardID,
+		"columnId": columnID,
+		"position": bson.M{"$gte": fromPosition},
+		"_id":      bson.M{"$ne": excludedMemoID},
+	}
+	update := bson.M{
+		"$inc": bson.M{"position": delta},
+	}
+
+	if _, err := dbMemoCollection.UpdateMany(context.TODO(), filter, update); err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	return nil
+}
+
+// normalizeMemoPositions renumbers the memos of a column from zero, by
+// position then by creation. Positions are counted before being written so
+// concurrent creations or moves can give the same position to several memos:
+// renumbering after the write removes the duplicates and the gaps. The
+// written memo, if any, gets its new position and the other renumbered memos
+// are returned
+func normalizeMemoPositions(boardID primitive.ObjectID, columnID primitive.ObjectID, written *Memo) ([]Memo, *core.ServiceMessage) {
+	filter := bson.M{"boardId": boardID, "columnId": columnID}
+	options := &options.FindOptions{
+		Sort: bson.D{
+			primitive.E{Key: "position", Value: 1},
+			primitive.E{Key: "_id", Value: 1},
+		},
+	}
+
+	cur, err := dbMemoCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	defer cur.Close(context.TODO())
+
+	var memos []Memo
+	if err := cur.All(context.TODO(), &memos); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	renumberedMemos := make([]Memo, 0)
+	for position, memo := range memos {
+		if memo.Position == position {
+			continue
+		}
+
+		// A concurrent renumbering has already moved the memo
+		memoFilter := bson.M{"_id": memo.ID, "position": memo.Position}
+		update := bson.M{"$set": bson.M{"position": position}}
+		res, err := dbMemoCollection.UpdateOne(context.TODO(), memoFilter, update)
+		if err != nil {
+			return nil, core.NewServiceErrorMessage(err)
+		}
+		if res.ModifiedCount == 0 {
+			continue
+		}
+
+		memo.Position = position
+		if written != nil && memo.ID == written.ID {
+			written.Position = position
+		} else {
+			renumberedMemos = append(renumberedMemos, memo)
+		}
+	}
+
+	return renumberedMemos, nil
+}
+
+// moveMemo moves a memo to a position of a column, which can be its current
+// column. Only the moved memo, and the memos renumbered after a concurrent
+// write, generate a change: clients are expected to shift the other memos
+// positions the same way
+func moveMemo(boardID string, memoID string, move MemoMove, tracking core.TrackedEntity) (*Memo, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	board, errMsg := findBoardColumns(bID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	if board.findColumn(move.ColumnID) < 0 {
+		return nil, columnNotFound
+	}
+
+	memo, errMsg := findMemoByID(boardID, memoID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	// Remove from the source column
+	if errMsg := shiftMemoPositions(bID, memo.ColumnID, memo.Position+1, -1, memo.ID); errMsg != nil {
+		return nil, errMsg
+	}
+
+	// Make room in the target column
+	targetCount, errMsg := countColumnMemos(bID, move.ColumnID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	if move.ColumnID == memo.ColumnID {
+		targetCount--
+	}
+	position := clampPosition(move.Position, targetCount)
+	if errMsg := shiftMemoPositions(bID, move.ColumnID, position, 1, memo.ID); errMsg != nil {
+		return nil, errMsg
+	}
+
+	filter := bson.M{"_id": memo.ID, "boardId": bID}
+	update := bson.M{
+		"$set": bson.M{
+			"columnId":            move.ColumnID,
+			"position":            position,
+			core.TrackedUpdatedBy: tracking.UpdatedBy,
+			core.TrackedUpdatedAt: tracking.UpdatedAt,
+		},
+	}
+	options := &options.FindOneAndUpdateOptions{
+		ReturnDocument: &returnOpt,
+	}
+
+	var movedMemo Memo
+	if err := dbMemoCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&movedMemo); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	// Concurrent moves may have counted the same position
+	renumberedMemos, errMsg := normalizeMemoPositions(bID, move.ColumnID, &movedMemo)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	if move.ColumnID != memo.ColumnID {
+		sourceMemos, errMsg := normalizeMemoPositions(bID, memo.ColumnID, nil)
+		if errMsg != nil {
+			return nil, errMsg
+		}
+		renumberedMemos = append(renumberedMemos, sourceMemos...)
+	}
+
+	changes := []Change{newMemoChange(bID, movedMemo, changeOpUpdated)}
+	for _, renumberedMemo := range renumberedMemos {
+		changes = append(changes, newMemoChange(bID, renumberedMemo, changeOpUpdated))
+	}
+	recordChanges(board.recipients(), changes...)
+
+	return &movedMemo, nil
+}
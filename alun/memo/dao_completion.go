@@ -0,0 +1,114 @@
+package memo
+
+import (
+	"context"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbCompletionCollectionName string
+	dbCompletionCollection     *mongo.Collection
+)
+
+// initCompletionDao loads the completion events collection and ensures that
+// events can be listed per board chronologically
+func initCompletionDao(memoMongoDb *mongo.Database) {
+	dbCompletionCollectionName = "al_memos_completions"
+	dbCompletionCollection = memoMongoDb.Collection(dbCompletionCollectionName)
+
+	index := mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "boardId", Value: 1},
+			primitive.E{Key: "at", Value: 1},
+		},
+	}
+	if _, err := dbCompletionCollection.Indexes().CreateOne(context.TODO(), index); err != nil {
+		memoLogger.Warn("[MongoDB] Completion index creation failed: %v", err)
+	}
+}
+
+// ---------- Completion events -----------------------------------------------
+
+// recordCompletionEvents saves the events of a memo write. Like the change
+// feed, a failure does not cancel the write and is only logged
+func recordCompletionEvents(events []CompletionEvent) {
+	if err := insertCompletionEvents(events); err != nil {
+		memoLogger.Warn("[Completion] Recording %d events failed: %v", len(events), err)
+	}
+}
+
+func insertCompletionEvents(events []CompletionEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(events))
+	for idx := range events {
+		events[idx].ID = primitive.NewObjectID()
+		documents[idx] = events[idx]
+	}
+	_, err := dbCompletionCollection.InsertMany(context.TODO(), documents)
+
+	return err
+}
+
+// findCompletionHistory builds the completion history of a board between from
+// and to. All the events until to are loaded to compute the remaining items
+func findCompletionHistory(boardID primitive.ObjectID, from time.Time, to time.Time) (*CompletionHistory, *core.ServiceMessage) {
+	nextDay := truncateToDay(to).AddDate(0, 0, 1)
+	filter := bson.M{
+		"boardId": boardID,
+		"at":      bson.M{"$lt": nextDay},
+	}
+	options := &options.FindOptions{
+		Sort: bson.D{
+			primitive.E{Key: "at", Value: 1},
+			primitive.E{Key: "_id", Value: 1},
+		},
+	}
+
+	cur, err := dbCompletionCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	defer cur.Close(context.TODO())
+
+	events := make([]CompletionEvent, 0)
+	for cur.Next(context.TODO()) {
+		var event CompletionEvent
+		if err := cur.Decode(&event); err != nil {
+			return nil, core.NewServiceErrorMessage(err)
+		}
+		events = append(events, event)
+	}
+
+	history := &CompletionHistory{
+		BoardID: boardID,
+		Events:  make([]CompletionEvent, 0),
+		Days:    buildCompletionDays(events, from, to),
+	}
+	fromDay := truncateToDay(from)
+	for _, event := range events {
+		if !event.At.Before(fromDay) {
+			history.Events = append(history.Events, event)
+		}
+	}
+
+	return history, nil
+}
+
+// deleteCompletionEventsByBoardID removes the history of a deleted board
+func deleteCompletionEventsByBoardID(boardID primitive.ObjectID) *core.ServiceMessage {
+	if _, err := dbCompletionCollection.DeleteMany(context.TODO(), bson.M{"boardId": boardID}); err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	return nil
+}
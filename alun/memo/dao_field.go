@@ -0,0 +1,79 @@
+package memo
+
+import (
+	"errors"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// createField appends a custom field to a board
+func createField(boardID string, field Field, tracking core.TrackedEntity) (*Board, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	if _, errMsg := findBoardColumns(bID); errMsg != nil {
+		return nil, errMsg
+	}
+
+	field.ID = primitive.NewObjectID()
+	if err := field.prepare(); err != nil {
+		return nil, newFieldError(fieldInvalid, err)
+	}
+	update := bson.M{
+		"$push": bson.M{"fields": field},
+	}
+
+	return updateBoardDefinition(bson.M{"_id": bID}, update, tracking, boardNotFound)
+}
+
+// updateField changes the name and the options of a custom field. The type of
+// a field cannot be changed. Values of removed options are dropped when their
+// item is updated
+func updateField(boardID string, fieldID string, field Field, tracking core.TrackedEntity) (*Board, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	fID, _ := primitive.ObjectIDFromHex(fieldID)
+	board, errMsg := findBoardColumns(bID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	existing := board.findField(fID)
+	if existing == nil {
+		return nil, fieldNotFound
+	}
+	if field.Type != "" && field.Type != existing.Type {
+		return nil, newFieldError(fieldInvalid, errors.New("Field type cannot be changed"))
+	}
+
+	field.ID = fID
+	field.Type = existing.Type
+	if err := field.prepare(); err != nil {
+		return nil, newFieldError(fieldInvalid, err)
+	}
+
+	filter := bson.M{
+		"_id":        bID,
+		"fields._id": fID,
+	}
+	update := bson.M{
+		"$set": bson.M{"fields.$": field},
+	}
+
+	return updateBoardDefinition(filter, update, tracking, fieldNotFound)
+}
+
+// deleteField removes a custom field from a board. Values of the field are
+// dropped when their item is updated
+func deleteField(boardID string, fieldID string, tracking core.TrackedEntity) (*Board, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	fID, _ := primitive.ObjectIDFromHex(fieldID)
+	filter := bson.M{
+		"_id":        bID,
+		"fields._id": fID,
+	}
+	update := bson.M{
+		"$pull": bson.M{"fields": bson.M{"_id": fID}},
+	}
+
+	return updateBoardDefinition(filter, update, tracking, fieldNotFound)
+}
@@ -0,0 +1,383 @@
+package memo
+
+import (
+	"context"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+
+// migrationStaleAfter is how long a started migration is considered running
+// by the other instances. An interrupted migration is resumed afterwards
+const migrationStaleAfter = time.Hour
+
+var (
+	dbMigrationCollectionName string
+	dbMigrationCollection     *mongo.Collection
+)
+
+// migrationRecord tracks the migrations which cannot run concurrently or are
+// too costly to check at each startup
+type migrationRecord struct {
+	Name      string     `bson:"_id"`
+	StartedAt time.Time  `bson:"startedAt"`
+	DoneAt    *time.Time `bson:"doneAt,omitempty"`
+}
+
+func initMigrationDao(memoMongoDb *mongo.Database) {
+	dbMigrationCollectionName = "al_memos_migrations"
+	dbMigrationCollection = memoMongoDb.Collection(dbMigrationCollectionName)
+}
+
+// runMigrationOnce runs a migration unless it is done or started by another
+// instance. The migration is claimed by inserting its record, so only one
+// instance runs it, and a migration started too long ago is claimed again
+func runMigrationOnce(name string, migrate func()) {
+	now := time.Now()
+	_, err := dbMigrationCollection.InsertOne(context.TODO(), migrationRecord{Name: name, StartedAt: now})
+	if isDuplicateKeyError(err) {
+		filter := bson.M{
+			"_id":       name,
+			"doneAt":    bson.M{"$exists": false},
+			"startedAt": bson.M{"$lt": now.Add(-migrationStaleAfter)},
+		}
+		update := bson.M{"$set": bson.M{"startedAt": now}}
+		res, updateErr := dbMigrationCollection.UpdateOne(context.TODO(), filter, update)
+		if updateErr != nil || res.MatchedCount == 0 {
+			err = updateErr
+			if err == nil {
+				return
+			}
+		} else {
+			err = nil
+		}
+	}
+	if err != nil {
+		memoLogger.Warn("[Migration] Cannot claim %s: %v", name, err)
+		return
+	}
+
+	migrate()
+
+	update := bson.M{"$set": bson.M{"doneAt": time.Now()}}
+	if _, err := dbMigrationCollection.UpdateOne(context.TODO(), bson.M{"_id": name}, update); err != nil {
+		memoLogger.Warn("[Migration] Cannot mark %s as done: %v", name, err)
+	}
+}
+
+// ---------- Migrations ------------------------------------------------------
+
+// migrateEmbeddedMemos moves memos which are still embedded in their board
+// document into the memos collection.
+//
+// Memos are upserted by ID before being removed from the board so the migration
+// can safely be re-run if it is interrupted
+func migrateEmbeddedMemos() {
+	filter := bson.M{"memos": bson.M{"$exists": true}}
+	options := &options.FindOptions{
+		Projection: bson.M{"_id": 1, "memos": 1},
+	}
+
+	cur, err := dbBoardCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		memoLogger.Warn("[Migration] Cannot load boards with embedded memos: %v", err)
+		return
+	}
+	defer cur.Close(context.TODO())
+
+	migratedBoards, migratedMemos := 0, 0
+	for cur.Next(context.TODO()) {
+		var board struct {
+			ID    primitive.ObjectID `bson:"_id"`
+			Memos []Memo             `bson:"memos"`
+		}
+		if err := cur.Decode(&board); err != nil {
+			memoLogger.Warn("[Migration] Cannot decode board: %v", err)
+			continue
+		}
+
+		if err := migrateBoardMemos(board.ID, board.Memos); err != nil {
+			memoLogger.Warn("[Migration] Board %s not migrated: %v", board.ID.Hex(), err)
+			continue
+		}
+
+		migratedBoards++
+		migratedMemos += len(board.Memos)
+	}
+
+	if migratedBoards > 0 {
+		memoLogger.Info("[Migration] %d memo(s) of %d board(s) moved to %s",
+			migratedMemos, migratedBoards, dbMemoCollectionName)
+	}
+}
+
+func migrateBoardMemos(boardID primitive.ObjectID, memos []Memo) error {
+	upsert := true
+	replaceOptions := &options.ReplaceOptions{Upsert: &upsert}
+
+	for _, memo := range memos {
+		memo.BoardID = boardID
+		memo.prepareItems()
+
+		filter := bson.M{"_id": memo.ID}
+		if _, err := dbMemoCollection.ReplaceOne(context.TODO(), filter, memo, replaceOptions); err != nil {
+			return err
+		}
+	}
+
+	filter := bson.M{"_id": boardID}
+	update := bson.M{
+		"$unset": bson.M{"memos": 1},
+	}
+	_, err := dbBoardCollection.UpdateOne(context.TODO(), filter, update)
+
+	return err
+}
+
+// migrateDefaultColumns adds a default column to the boards created before
+// columns existed. Their memos are put in this column by creation order.
+//
+// The default column reuses the board ID and memos without column are appended
+// after the already migrated ones so the migration can safely be re-run if it
+// is interrupted
+func migrateDefaultColumns() {
+	filter := bson.M{"columns": bson.M{"$exists": false}}
+	options := &options.FindOptions{
+		Projection: bson.M{"_id": 1},
+	}
+
+	cur, err := dbBoardCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		memoLogger.Warn("[Migration] Cannot load boards without columns: %v", err)
+		return
+	}
+	defer cur.Close(context.TODO())
+
+	migratedBoards := 0
+	for cur.Next(context.TODO()) {
+		var board Board
+		if err := cur.Decode(&board); err != nil {
+			memoLogger.Warn("[Migration] Cannot decode board: %v", err)
+			continue
+		}
+
+		if err := migrateBoardColumns(board); err != nil {
+			memoLogger.Warn("[Migration] Board %s columns not migrated: %v", board.ID.Hex(), err)
+			continue
+		}
+
+		migratedBoards++
+	}
+
+	if migratedBoards > 0 {
+		memoLogger.Info("[Migration] Default column added to %d board(s)", migratedBoards)
+	}
+}
+
+func migrateBoardColumns(board Board) error {
+	columnID := board.ID
+	board.Columns = []Column{{ID: columnID, Title: columnDefaultTitle}}
+
+	migratedCount, err := dbMemoCollection.CountDocuments(context.TODO(), bson.M{"boardId": board.ID, "columnId": columnID})
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"boardId": board.ID, "columnId": bson.M{"$exists": false}}
+	findOptions := &options.FindOptions{
+		Projection: bson.M{"_id": 1},
+		Sort:       bson.M{"_id": 1},
+	}
+	cur, err := dbMemoCollection.Find(context.TODO(), filter, findOptions)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.TODO())
+
+	position := int(migratedCount)
+	for cur.Next(context.TODO()) {
+		var memo Memo
+		if err := cur.Decode(&memo); err != nil {
+			return err
+		}
+
+		update := bson.M{
+			"$set": bson.M{"columnId": columnID, "position": position},
+		}
+		if _, err := dbMemoCollection.UpdateOne(context.TODO(), bson.M{"_id": memo.ID}, update); err != nil {
+			return err
+		}
+		position++
+	}
+
+	update := bson.M{
+		"$set": bson.M{"columns": board.Columns},
+	}
+	_, err = dbBoardCollection.UpdateOne(context.TODO(), bson.M{"_id": board.ID}, update)
+
+	return err
+}
+
+// migrateCompletionEvents records the creation, and the completion if finished,
+// of the items created before the completion history so that the remaining
+// items of the existing boards are not negative.
+//
+// It scans all the memos so it runs once, with runMigrationOnce: concurrent
+// runs would both record the creation of the same items. Items with a
+// recorded creation are skipped so the migration can safely be resumed if it
+// is interrupted
+func migrateCompletionEvents() {
+	starts, err := findItemCompletionStarts()
+	if err != nil {
+		memoLogger.Warn("[Migration] Cannot load completion events: %v", err)
+		return
+	}
+
+	options := &options.FindOptions{
+		Projection: bson.M{"_id": 1, "boardId": 1, "items": 1, core.TrackedCreatedBy: 1},
+	}
+	cur, err := dbMemoCollection.Find(context.TODO(), bson.M{}, options)
+	if err != nil {
+		memoLogger.Warn("[Migration] Cannot load memos: %v", err)
+		return
+	}
+	defer cur.Close(context.TODO())
+
+	migratedItems := 0
+	for cur.Next(context.TODO()) {
+		var memo Memo
+		if err := cur.Decode(&memo); err != nil {
+			memoLogger.Warn("[Migration] Cannot decode memo: %v", err)
+			continue
+		}
+
+		events := make([]CompletionEvent, 0)
+		walkItems(memo.Items, func(item *Item, parentID primitive.ObjectID) {
+			start := starts[item.ID]
+			delete(starts, item.ID)
+			if start != nil && start.HasCreated {
+				return
+			}
+
+			events = append(events, backfillCompletionEvents(memo.BoardID, memo.ID, *item, memo.CreatedBy, start)...)
+			migratedItems++
+		})
+		if err := insertCompletionEvents(events); err != nil {
+			memoLogger.Warn("[Migration] Memo %s completion not migrated: %v", memo.ID.Hex(), err)
+		}
+	}
+
+	// Items deleted since the completion history started
+	events := make([]CompletionEvent, 0)
+	for _, start := range starts {
+		if !start.HasCreated {
+			events = append(events, backfillCompletionEvents(start.BoardID, start.MemoID,
+				Item{ID: start.ItemID}, primitive.NilObjectID, start)...)
+			migratedItems++
+		}
+	}
+	if err := insertCompletionEvents(events); err != nil {
+		memoLogger.Warn("[Migration] Deleted items completion not migrated: %v", err)
+	}
+
+	if migratedItems > 0 {
+		memoLogger.Info("[Migration] Completion history of %d item(s) backfilled in %s",
+			migratedItems, dbCompletionCollectionName)
+	}
+}
+
+// migrateChangeFeed records the creation of the boards created before the
+// change feed, with their memos and items, in the feed of their recipients so
+// that a client starting without cursor receives them.
+//
+// It scans all the boards so it runs once, with runMigrationOnce. Recipients
+// whose feed already has the board creation are skipped so the migration can
+// safely be resumed if it is interrupted
+func migrateChangeFeed() {
+	cur, err := dbBoardCollection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		memoLogger.Warn("[Migration] Cannot load boards: %v", err)
+		return
+	}
+	defer cur.Close(context.TODO())
+
+	migratedBoards := 0
+	for cur.Next(context.TODO()) {
+		var board Board
+		if err := cur.Decode(&board); err != nil {
+			memoLogger.Warn("[Migration] Cannot decode board: %v", err)
+			continue
+		}
+
+		migrated, err := migrateBoardChanges(board)
+		if err != nil {
+			memoLogger.Warn("[Migration] Board %s changes not migrated: %v", board.ID.Hex(), err)
+			continue
+		}
+		if migrated {
+			migratedBoards++
+		}
+	}
+
+	if migratedBoards > 0 {
+		memoLogger.Info("[Migration] Creation of %d board(s) backfilled in %s",
+			migratedBoards, dbChangeCollectionName)
+	}
+}
+
+// migrateBoardChanges records the creation of a board in the feed of the
+// recipients who do not have it yet
+func migrateBoardChanges(board Board) (bool, error) {
+	recipients, err := findRecipientsWithoutBoard(board)
+	if err != nil || len(recipients) == 0 {
+		return false, err
+	}
+
+	memos, errMsg := findMemosByBoardID(board.ID)
+	if errMsg != nil {
+		return false, errMsg.Error
+	}
+	recordChanges(recipients, newBoardSnapshot(board, memos)...)
+
+	return true, nil
+}
+
+// findItemCompletionStarts summarizes the recorded events of each item
+func findItemCompletionStarts() (map[primitive.ObjectID]*itemCompletionStart, error) {
+	sortStage := bson.D{primitive.E{Key: "$sort", Value: bson.D{
+		primitive.E{Key: "at", Value: 1},
+		primitive.E{Key: "_id", Value: 1},
+	}}}
+	groupStage := bson.D{primitive.E{Key: "$group", Value: bson.M{
+		"_id":             "$itemId",
+		"boardId":         bson.M{"$first": "$boardId"},
+		"memoId":          bson.M{"$first": "$memoId"},
+		"hasCreated":      bson.M{"$max": bson.M{"$eq": bson.A{"$type", completionCreated}}},
+		"firstType":       bson.M{"$first": "$type"},
+		"firstIsFinished": bson.M{"$first": "$isFinished"},
+		"firstAt":         bson.M{"$first": "$at"},
+	}}}
+
+	cur, err := dbCompletionCollection.Aggregate(context.TODO(), mongo.Pipeline{sortStage, groupStage})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	starts := make(map[primitive.ObjectID]*itemCompletionStart)
+	for cur.Next(context.TODO()) {
+		var start itemCompletionStart
+		if err := cur.Decode(&start); err != nil {
+			return nil, err
+		}
+		starts[start.ItemID] = &start
+	}
+
+	return starts, nil
+}
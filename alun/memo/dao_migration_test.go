@@ -0,0 +1,125 @@
+package memo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMigrateEmbeddedMemos(t *testing.T) {
+	t.Parallel()
+
+	boardID := primitive.NewObjectID()
+	memoID := primitive.NewObjectID()
+	legacyBoard := bson.M{
+		"_id":    boardID,
+		"title":  "Legacy board",
+		"access": accessPrivate,
+		"memos": []bson.M{
+			{"_id": memoID, "title": "Legacy memo", "items": []bson.M{{"text": "Legacy item"}}},
+		},
+	}
+
+	t.Cleanup(func() {
+		deleteBoard(boardID.Hex())
+	})
+
+	_, err := dbBoardCollection.InsertOne(context.TODO(), legacyBoard)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+
+	migrateEmbeddedMemos()
+
+	// Memos are now in their own collection
+	board, errMsg := findBoardByID(boardID.Hex())
+	testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Cannot load migrated board: %v", errMsg)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(board.Memos))
+	testutils.Equals(t, testutils.CallFromTestFile, memoID, board.Memos[0].ID)
+	testutils.Equals(t, testutils.CallFromTestFile, "Legacy item", board.Memos[0].Items[0].Text)
+	testutils.Assert(t, testutils.CallFromTestFile, !board.Memos[0].Items[0].ID.IsZero(),
+		"Migrated items should have an ID")
+
+	// Embedded memos are removed from the board
+	count, _ := dbBoardCollection.CountDocuments(context.TODO(),
+		bson.M{"_id": boardID, "memos": bson.M{"$exists": true}})
+	testutils.Equals(t, testutils.CallFromTestFile, int64(0), count)
+}
+
+func TestMigrateDefaultColumns(t *testing.T) {
+	t.Parallel()
+
+	boardID := primitive.NewObjectID()
+	memoIDs := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+	legacyBoard := bson.M{"_id": boardID, "title": "Board without column", "access": accessPrivate}
+
+	t.Cleanup(func() {
+		deleteBoard(boardID.Hex())
+	})
+
+	_, err := dbBoardCollection.InsertOne(context.TODO(), legacyBoard)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	for _, memoID := range memoIDs {
+		_, err = dbMemoCollection.InsertOne(context.TODO(), bson.M{"_id": memoID, "boardId": boardID, "title": "Memo"})
+		testutils.Ok(t, testutils.CallFromTestFile, err)
+	}
+
+	migrateDefaultColumns()
+
+	board, errMsg := findBoardByID(boardID.Hex())
+	testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Cannot load migrated board: %v", errMsg)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(board.Columns))
+	testutils.Equals(t, testutils.CallFromTestFile, columnDefaultTitle, board.Columns[0].Title)
+	for idx, memo := range board.Memos {
+		testutils.Equals(t, testutils.CallFromTestFile, memoIDs[idx], memo.ID)
+		testutils.Equals(t, testutils.CallFromTestFile, board.Columns[0].ID, memo.ColumnID)
+		testutils.Equals(t, testutils.CallFromTestFile, idx, memo.Position)
+	}
+}
+
+func TestMigrateBoardChanges(t *testing.T) {
+	t.Parallel()
+
+	userID := primitive.NewObjectID()
+	board := Board{
+		ID:            primitive.NewObjectID(),
+		BasicInfo:     BasicInfo{Title: "Board before the change feed"},
+		TrackedEntity: trackedBy(userID),
+	}
+	memo := Memo{
+		ID:        primitive.NewObjectID(),
+		BasicInfo: BasicInfo{Title: "Memo before the change feed"},
+		BoardID:   board.ID,
+		Items:     []Item{{Text: "Item before the change feed"}},
+	}
+	memo.prepareItems()
+
+	t.Cleanup(func() {
+		deleteBoard(board.ID.Hex())
+		deleteChangesByUserID(userID)
+	})
+
+	_, err := dbBoardCollection.InsertOne(context.TODO(), board)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	_, err = dbMemoCollection.InsertOne(context.TODO(), memo)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+
+	migrated, err := migrateBoardChanges(board)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Assert(t, testutils.CallFromTestFile, migrated, "Board creation should be backfilled")
+
+	// board + memo + item
+	feed, _ := findChanges(userID.Hex(), 0, syncDefaultLimit)
+	testutils.Equals(t, testutils.CallFromTestFile, 3, len(feed.Changes))
+	testutils.Equals(t, testutils.CallFromTestFile, board.ID, feed.Changes[0].EntityID)
+	testutils.Equals(t, testutils.CallFromTestFile, memo.ID, feed.Changes[1].EntityID)
+	testutils.Equals(t, testutils.CallFromTestFile, memo.Items[0].ID, feed.Changes[2].EntityID)
+
+	// Re-running the migration does not duplicate the changes
+	migrated, err = migrateBoardChanges(board)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Assert(t, testutils.CallFromTestFile, !migrated, "Board creation is already in the feed")
+	feed, _ = findChanges(userID.Hex(), 0, syncDefaultLimit)
+	testutils.Equals(t, testutils.CallFromTestFile, 3, len(feed.Changes))
+}
@@ -0,0 +1,486 @@
+package memo
+
+import (
+	"context"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbQuotaCollectionName      string
+	dbQuotaCollection          *mongo.Collection
+	dbQuotaUsageCollectionName string
+	dbQuotaUsageCollection     *mongo.Collection
+)
+
+// initQuotaDao loads the overrides, by user ID, and the usage counters, by
+// usage key
+func initQuotaDao(memoMongoDb *mongo.Database) {
+	dbQuotaCollectionName = "al_memos_quotas"
+	dbQuotaCollection = memoMongoDb.Collection(dbQuotaCollectionName)
+	dbQuotaUsageCollectionName = "al_memos_quota_usages"
+	dbQuotaUsageCollection = memoMongoDb.Collection(dbQuotaUsageCollectionName)
+
+	loadDefaultQuota()
+}
+
+// ---------- Overrides -------------------------------------------------------
+
+func findQuotaOverride(userID primitive.ObjectID) (*QuotaOverride, *core.ServiceMessage) {
+	var override QuotaOverride
+	filter := bson.M{"_id": userID}
+
+	if err := dbQuotaCollection.FindOne(context.TODO(), filter).Decode(&override); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return &QuotaOverride{UserID: userID}, nil
+		}
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &override, nil
+}
+
+// findQuotaLimits returns the limits applicable to an user
+func findQuotaLimits(userID primitive.ObjectID) (QuotaLimits, *core.ServiceMessage) {
+	override, errMsg := findQuotaOverride(userID)
+	if errMsg != nil {
+		return QuotaLimits{}, errMsg
+	}
+
+	return override.apply(defaultQuota), nil
+}
+
+// upsertQuotaOverride replaces the overridden limits of an user. Omitted limits
+// are removed and the creation tracking is kept
+func upsertQuotaOverride(override QuotaOverride) (*QuotaOverride, *core.ServiceMessage) {
+	toSet := bson.M{
+		core.TrackedUpdatedBy: override.UpdatedBy,
+		core.TrackedUpdatedAt: override.UpdatedAt,
+	}
+	toUnset := bson.M{}
+	limits := map[string]*int64{
+		"boards":          override.Boards,
+		"memosPerBoard":   override.MemosPerBoard,
+		"itemsPerMemo":    override.ItemsPerMemo,
+		"attachmentBytes": override.AttachmentBytes,
+	}
+	for field, limit := range limits {
+		if limit != nil {
+			toSet[field] = *limit
+		} else {
+			toUnset[field] = ""
+		}
+	}
+
+	update := bson.M{
+		"$set": toSet,
+		"$setOnInsert": bson.M{
+			core.TrackedCreatedBy: override.CreatedBy,
+			core.TrackedCreatedAt: override.CreatedAt,
+		},
+	}
+	if len(toUnset) > 0 {
+		update["$unset"] = toUnset
+	}
+
+	filter := bson.M{"_id": override.UserID}
+	upsert := true
+	options := &options.FindOneAndUpdateOptions{
+		ReturnDocument: &returnOpt,
+		Upsert:         &upsert,
+	}
+
+	var savedOverride QuotaOverride
+	if err := dbQuotaCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&savedOverride); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &savedOverride, nil
+}
+
+func deleteQuotaOverride(userID primitive.ObjectID) (int64, *core.ServiceMessage) {
+	deleted, err := dbQuotaCollection.DeleteOne(context.TODO(), bson.M{"_id": userID})
+	if err != nil {
+		return -1, core.NewServiceErrorMessage(err)
+	}
+
+	return deleted.DeletedCount, nil
+}
+
+// ---------- Usage -----------------------------------------------------------
+
+// findBoardOwnerID returns the creator of a board, whose quota applies to
+// the board content
+func findBoardOwnerID(boardID primitive.ObjectID) (primitive.ObjectID, *core.ServiceMessage) {
+	filter := bson.M{"_id": boardID}
+	options := &options.FindOneOptions{
+		Projection: bson.M{core.TrackedCreatedBy: 1},
+	}
+
+	var board Board
+	if err := dbBoardCollection.FindOne(context.TODO(), filter, options).Decode(&board); err != nil {
+		return primitive.NilObjectID, core.NewServiceErrorMessage(err)
+	}
+
+	return board.CreatedBy, nil
+}
+
+// reserveQuota counts a new resource in the usage counter of key, unless the
+// limit is reached
+func reserveQuota(key string, limit int64, countExisting func() (int64, error)) (bool, error) {
+	return reserveQuotaAmount(key, limit, 1, countExisting)
+}
+
+// reserveQuotaAmount adds amount to the usage counter of key, unless it goes
+// beyond the limit. The check and the count are a single update so that
+// concurrent creations cannot exceed the limit. A missing counter is first
+// initialized with the existing resources
+func reserveQuotaAmount(key string, limit int64, amount int64, countExisting func() (int64, error)) (bool, error) {
+	if limit != quotaUnlimited && amount > limit {
+		return false, nil
+	}
+
+	filter := bson.M{"_id": key}
+	if limit != quotaUnlimited {
+		filter["count"] = bson.M{"$lte": limit - amount}
+	}
+	update := bson.M{"$inc": bson.M{"count": amount}}
+
+	res, err := dbQuotaUsageCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	if res.MatchedCount > 0 {
+		return true, nil
+	}
+
+	// The limit is reached, unless the counter does not exist yet
+	isCreated, err := createQuotaUsage(key, countExisting)
+	if err != nil || !isCreated {
+		return false, err
+	}
+
+	res, err = dbQuotaUsageCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// createQuotaUsage initializes a usage counter with the existing resources.
+// It is false if the counter already exists, and true if it is created,
+// concurrently or not
+func createQuotaUsage(key string, countExisting func() (int64, error)) (bool, error) {
+	count, err := countExisting()
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"_id": key}
+	update := bson.M{"$setOnInsert": bson.M{"count": count}}
+	upsert := true
+	res, err := dbQuotaUsageCollection.UpdateOne(context.TODO(), filter, update, &options.UpdateOptions{Upsert: &upsert})
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	return res.UpsertedCount > 0, nil
+}
+
+// releaseQuota uncounts a deleted resource, or a resource which creation
+// failed
+func releaseQuota(key string) {
+	releaseQuotaAmount(key, 1)
+}
+
+// releaseQuotaAmount removes amount from the usage counter of key. A failure
+// is only logged as the resources are already deleted
+func releaseQuotaAmount(key string, amount int64) {
+	filter := bson.M{"_id": key, "count": bson.M{"$gte": amount}}
+	update := bson.M{"$inc": bson.M{"count": -amount}}
+	if _, err := dbQuotaUsageCollection.UpdateOne(context.TODO(), filter, update); err != nil {
+		memoLogger.Warn("[Quota] Releasing %s failed: %v", key, err)
+	}
+}
+
+// deleteQuotaUsage removes the usage counter of deleted resources
+func deleteQuotaUsage(key string) {
+	if _, err := dbQuotaUsageCollection.DeleteOne(context.TODO(), bson.M{"_id": key}); err != nil {
+		memoLogger.Warn("[Quota] Deleting %s failed: %v", key, err)
+	}
+}
+
+// deleteItemsQuotaUsages removes the items counters of the memos of a deleted
+// board
+func deleteItemsQuotaUsages(boardID primitive.ObjectID) {
+	filter := bson.M{"_id": primitive.Regex{Pattern: "^" + itemsUsagePrefix(boardID)}}
+	if _, err := dbQuotaUsageCollection.DeleteMany(context.TODO(), filter); err != nil {
+		memoLogger.Warn("[Quota] Deleting items counters of board %s failed: %v", boardID.Hex(), err)
+	}
+}
+
+// boardsUsageKey counts the boards created by an user
+func boardsUsageKey(userID primitive.ObjectID) string {
+	return "boards:" + userID.Hex()
+}
+
+// memosUsageKey counts the memos of a board
+func memosUsageKey(boardID primitive.ObjectID) string {
+	return "memos:" + boardID.Hex()
+}
+
+// itemsUsageKey counts the items, at any depth, of a memo. The board is part
+// of the key so that the counters of a deleted board are deleted together
+func itemsUsageKey(boardID primitive.ObjectID, memoID primitive.ObjectID) string {
+	return itemsUsagePrefix(boardID) + memoID.Hex()
+}
+
+func itemsUsagePrefix(boardID primitive.ObjectID) string {
+	return "items:" + boardID.Hex() + ":"
+}
+
+// attachmentBytesUsageKey sums the attachments size of the boards created by
+// an user
+func attachmentBytesUsageKey(userID primitive.ObjectID) string {
+	return "attachmentBytes:" + userID.Hex()
+}
+
+// reserveBoardQuota counts a new board of an user, unless the boards limit is
+// reached
+func reserveBoardQuota(userID primitive.ObjectID) *core.ServiceMessage {
+	limits, errMsg := findQuotaLimits(userID)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	isReserved, err := reserveQuota(boardsUsageKey(userID), limits.Boards, func() (int64, error) {
+		return dbBoardCollection.CountDocuments(context.TODO(), bson.M{core.TrackedCreatedBy: userID})
+	})
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if !isReserved {
+		return newQuotaExceeded(quotaResourceBoards, limits.Boards)
+	}
+
+	return nil
+}
+
+// reserveMemoQuota counts a new memo of a board, unless the memos limit of the
+// board owner is reached
+func reserveMemoQuota(boardID primitive.ObjectID) *core.ServiceMessage {
+	ownerID, errMsg := findBoardOwnerID(boardID)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	limits, errMsg := findQuotaLimits(ownerID)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	isReserved, err := reserveQuota(memosUsageKey(boardID), limits.MemosPerBoard, func() (int64, error) {
+		return dbMemoCollection.CountDocuments(context.TODO(), bson.M{"boardId": boardID})
+	})
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if !isReserved {
+		return newQuotaExceeded(quotaResourceMemosPerBoard, limits.MemosPerBoard)
+	}
+
+	return nil
+}
+
+// reserveItemsQuota moves the items counter of a memo from previousCount to
+// newCount, unless newCount exceeds the items limit of the board owner.
+//
+// The counter only moves if it is still at previousCount so that concurrent
+// updates of the same memo cannot both be counted from the same items: the
+// second one is rejected and has to reload the memo. As the counter may have
+// drifted from the stored items, it is reset from them before retrying once
+func reserveItemsQuota(boardID primitive.ObjectID, memoID primitive.ObjectID, previousCount int64, newCount int64) *core.ServiceMessage {
+	ownerID, errMsg := findBoardOwnerID(boardID)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	limits, errMsg := findQuotaLimits(ownerID)
+	if errMsg != nil {
+		return errMsg
+	}
+	if (QuotaUsage{Limit: limits.ItemsPerMemo}).isExceededBy(newCount) {
+		return newQuotaExceeded(quotaResourceItemsPerMemo, limits.ItemsPerMemo)
+	}
+
+	key := itemsUsageKey(boardID, memoID)
+	filter := bson.M{"_id": key, "count": previousCount}
+	update := bson.M{"$inc": bson.M{"count": newCount - previousCount}}
+
+	res, err := dbQuotaUsageCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	// The counter does not exist yet or does not match the stored items
+	storedCount, err := countStoredItems(boardID, memoID)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	resetUpdate := bson.M{"$set": bson.M{"count": storedCount}}
+	upsert := true
+	if _, err := dbQuotaUsageCollection.UpdateOne(context.TODO(), bson.M{"_id": key}, resetUpdate, &options.UpdateOptions{Upsert: &upsert}); err != nil && !isDuplicateKeyError(err) {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	// The memo has been changed concurrently if the stored items are not the
+	// previous ones anymore
+	res, err = dbQuotaUsageCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if res.MatchedCount == 0 {
+		return memoItemsConflict
+	}
+
+	return nil
+}
+
+// countStoredItems counts the items, at any depth, of a stored memo. A memo
+// which is not created yet has no items
+func countStoredItems(boardID primitive.ObjectID, memoID primitive.ObjectID) (int64, error) {
+	filter := bson.M{"_id": memoID, "boardId": boardID}
+	options := &options.FindOneOptions{
+		Projection: bson.M{"items": 1},
+	}
+
+	var memo Memo
+	if err := dbMemoCollection.FindOne(context.TODO(), filter, options).Decode(&memo); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return int64(countItems(memo.Items)), nil
+}
+
+// releaseItemsQuota moves back the items counter of a memo whose update
+// failed. A failure is only logged as the memo is not updated
+func releaseItemsQuota(boardID primitive.ObjectID, memoID primitive.ObjectID, previousCount int64, newCount int64) {
+	key := itemsUsageKey(boardID, memoID)
+	filter := bson.M{"_id": key, "count": newCount}
+	update := bson.M{"$inc": bson.M{"count": previousCount - newCount}}
+	if _, err := dbQuotaUsageCollection.UpdateOne(context.TODO(), filter, update); err != nil {
+		memoLogger.Warn("[Quota] Releasing %s failed: %v", key, err)
+	}
+}
+
+// reserveAttachmentBytesQuota counts the size of a new attachment in the
+// attachment bytes of the board owner, unless the limit is reached
+func reserveAttachmentBytesQuota(ownerID primitive.ObjectID, size int64) *core.ServiceMessage {
+	limits, errMsg := findQuotaLimits(ownerID)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	isReserved, err := reserveQuotaAmount(attachmentBytesUsageKey(ownerID), limits.AttachmentBytes, size, func() (int64, error) {
+		return sumAttachmentBytes(ownerID)
+	})
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if !isReserved {
+		return newQuotaExceeded(quotaResourceAttachmentBytes, limits.AttachmentBytes)
+	}
+
+	return nil
+}
+
+// findQuotaUsage computes the consumption of an user against its limits
+func findQuotaUsage(userID string) (*UserQuotaUsage, *core.ServiceMessage) {
+	id, _ := primitive.ObjectIDFromHex(userID)
+	limits, errMsg := findQuotaLimits(id)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	boardIDs, errMsg := findBoardIDs(bson.M{core.TrackedCreatedBy: id})
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	attachmentBytes, err := sumAttachmentBytes(id)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	usage := &UserQuotaUsage{
+		UserID:          id,
+		Limits:          limits,
+		Boards:          QuotaUsage{Used: int64(len(boardIDs)), Limit: limits.Boards},
+		AttachmentBytes: QuotaUsage{Used: attachmentBytes, Limit: limits.AttachmentBytes},
+		PerBoard:        make([]BoardQuotaUsage, 0),
+	}
+
+	// Count memos and biggest memo per board. Nested items are counted like
+	// the quota checks do
+	filter := bson.M{"boardId": bson.M{"$in": boardIDs}}
+	options := &options.FindOptions{
+		Projection: bson.M{"boardId": 1, "items": 1},
+	}
+	cur, err := dbMemoCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	defer cur.Close(context.TODO())
+
+	usageByBoard := make(map[primitive.ObjectID]BoardQuotaUsage)
+	for cur.Next(context.TODO()) {
+		var memo Memo
+		if err := cur.Decode(&memo); err != nil {
+			return nil, core.NewServiceErrorMessage(err)
+		}
+
+		boardUsage, exists := usageByBoard[memo.BoardID]
+		if !exists {
+			boardUsage = BoardQuotaUsage{
+				BoardID:      memo.BoardID,
+				Memos:        QuotaUsage{Limit: limits.MemosPerBoard},
+				MaxMemoItems: QuotaUsage{Limit: limits.ItemsPerMemo},
+			}
+		}
+		boardUsage.Memos.Used++
+		if itemsCount := int64(countItems(memo.Items)); itemsCount > boardUsage.MaxMemoItems.Used {
+			boardUsage.MaxMemoItems.Used = itemsCount
+		}
+		usageByBoard[memo.BoardID] = boardUsage
+	}
+
+	// Keep empty boards in the usage
+	for _, boardID := range boardIDs {
+		boardUsage, exists := usageByBoard[boardID]
+		if !exists {
+			boardUsage = BoardQuotaUsage{
+				BoardID:      boardID,
+				Memos:        QuotaUsage{Limit: limits.MemosPerBoard},
+				MaxMemoItems: QuotaUsage{Limit: limits.ItemsPerMemo},
+			}
+		}
+		usage.PerBoard = append(usage.PerBoard, boardUsage)
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,215 @@
+package memo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// initSearchIndexes creates the text indexes used by the mongoSearcher. A
+// collection can only have one text index so all searchable fields are in it
+func initSearchIndexes() {
+	boardIndex := mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "title", Value: "text"},
+			primitive.E{Key: "description", Value: "text"},
+		},
+		Options: options.Index().
+			SetName("board_search").
+			SetWeights(bson.M{"title": searchWeightTitle, "description": searchWeightDescription}),
+	}
+	if _, err := dbBoardCollection.Indexes().CreateOne(context.TODO(), boardIndex); err != nil {
+		memoLogger.Warn("[MongoDB] Board text index creation failed: %v", err)
+	}
+
+	// Nested items text is indexed up to the maximum depth. The depth is part
+	// of the index name so that a depth change replaces the index
+	memoKeys := bson.D{
+		primitive.E{Key: "title", Value: "text"},
+		primitive.E{Key: "description", Value: "text"},
+	}
+	memoWeights := bson.M{
+		"title":       searchWeightTitle,
+		"description": searchWeightDescription,
+	}
+	itemsPath := "items"
+	for depth := 1; depth <= itemsMaxDepth; depth++ {
+		memoKeys = append(memoKeys, primitive.E{Key: itemsPath + ".text", Value: "text"})
+		memoWeights[itemsPath+".text"] = searchWeightItem
+		itemsPath += ".children"
+	}
+
+	memoIndexName := fmt.Sprintf("memo_search_d%d", itemsMaxDepth)
+	dropOutdatedSearchIndexes(memoIndexName)
+
+	memoIndex := mongo.IndexModel{
+		Keys: memoKeys,
+		Options: options.Index().
+			SetName(memoIndexName).
+			SetWeights(memoWeights),
+	}
+	if _, err := dbMemoCollection.Indexes().CreateOne(context.TODO(), memoIndex); err != nil {
+		memoLogger.Warn("[MongoDB] Memo text index creation failed: %v", err)
+	}
+}
+
+// dropOutdatedSearchIndexes removes the memo text indexes other than the
+// current one as a collection can only have a single text index
+func dropOutdatedSearchIndexes(currentName string) {
+	cur, err := dbMemoCollection.Indexes().List(context.TODO())
+	if err != nil {
+		memoLogger.Warn("[MongoDB] Cannot list memo indexes: %v", err)
+		return
+	}
+	defer cur.Close(context.TODO())
+
+	for cur.Next(context.TODO()) {
+		var index struct {
+			Name string `bson:"name"`
+		}
+		if err := cur.Decode(&index); err != nil {
+			continue
+		}
+
+		if strings.HasPrefix(index.Name, "memo_search") && index.Name != currentName {
+			if _, err := dbMemoCollection.Indexes().DropOne(context.TODO(), index.Name); err != nil {
+				memoLogger.Warn("[MongoDB] Cannot drop memo text index %s: %v", index.Name, err)
+			}
+		}
+	}
+}
+
+// boardAccessFilter is the filter of the boards an user can access: the
+// boards created by the user or shared with the user
+func boardAccessFilter(userID primitive.ObjectID) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{core.TrackedCreatedBy: userID},
+		bson.M{"members": userID},
+	}}
+}
+
+// findAccessibleBoardIDs lists the ID of all the boards an user can access
+func findAccessibleBoardIDs(userID string) ([]primitive.ObjectID, *core.ServiceMessage) {
+	id, _ := primitive.ObjectIDFromHex(userID)
+	return findBoardIDs(boardAccessFilter(id))
+}
+
+// isBoardAccessible checks if an user can access a board
+func isBoardAccessible(boardID string, userID string) (bool, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	uID, _ := primitive.ObjectIDFromHex(userID)
+
+	filter := boardAccessFilter(uID)
+	filter["_id"] = bID
+
+	count, err := dbBoardCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return false, core.NewServiceErrorMessage(err)
+	}
+
+	return count > 0, nil
+}
+
+// findBoardIDs lists the ID of the boards matching the filter
+func findBoardIDs(filter bson.M) ([]primitive.ObjectID, *core.ServiceMessage) {
+	options := &options.FindOptions{
+		Projection: bson.M{"_id": 1},
+	}
+
+	boardIDs := make([]primitive.ObjectID, 0)
+
+	cur, err := dbBoardCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	defer cur.Close(context.TODO())
+
+	for cur.Next(context.TODO()) {
+		var board Board
+		if err := cur.Decode(&board); err != nil {
+			return nil, core.NewServiceErrorMessage(err)
+		}
+		boardIDs = append(boardIDs, board.ID)
+	}
+
+	return boardIDs, nil
+}
+
+// findSearchableDocuments loads all the boards and memos of the provided
+// boards
+func findSearchableDocuments(boardIDs []primitive.ObjectID) ([]Board, []Memo, error) {
+	boards := make([]Board, 0)
+	memos := make([]Memo, 0)
+
+	cur, err := dbBoardCollection.Find(context.TODO(), bson.M{"_id": bson.M{"$in": boardIDs}})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cur.All(context.TODO(), &boards); err != nil {
+		return nil, nil, err
+	}
+
+	cur, err = dbMemoCollection.Find(context.TODO(), bson.M{"boardId": bson.M{"$in": boardIDs}})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cur.All(context.TODO(), &memos); err != nil {
+		return nil, nil, err
+	}
+
+	return boards, memos, nil
+}
+
+// findTextScoredDocuments loads the boards and memos of the provided boards
+// which match the query with the text indexes, by decreasing text score
+func findTextScoredDocuments(boardIDs []primitive.ObjectID, query string) ([]scoredBoard, []scoredMemo, error) {
+	boards := make([]scoredBoard, 0)
+	memos := make([]scoredMemo, 0)
+
+	textScore := bson.M{"$meta": "textScore"}
+	options := &options.FindOptions{
+		Projection: bson.M{"textScore": textScore},
+		Sort:       bson.M{"textScore": textScore},
+	}
+
+	boardFilter := bson.M{"_id": bson.M{"$in": boardIDs}, "$text": bson.M{"$search": query}}
+	cur, err := dbBoardCollection.Find(context.TODO(), boardFilter, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cur.All(context.TODO(), &boards); err != nil {
+		return nil, nil, err
+	}
+
+	memoFilter := bson.M{"boardId": bson.M{"$in": boardIDs}, "$text": bson.M{"$search": query}}
+	cur, err = dbMemoCollection.Find(context.TODO(), memoFilter, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cur.All(context.TODO(), &memos); err != nil {
+		return nil, nil, err
+	}
+
+	return boards, memos, nil
+}
+
+// searchBoards looks for the query in all the boards an user can access
+func searchBoards(userID string, query string, page int, limit int) (SearchResults, *core.ServiceMessage) {
+	boardIDs, errMsg := findAccessibleBoardIDs(userID)
+	if errMsg != nil {
+		return SearchResults{}, errMsg
+	}
+
+	results, err := memoSearcher.search(boardIDs, query)
+	if err != nil {
+		return SearchResults{}, core.NewServiceErrorMessage(err)
+	}
+
+	return paginateSearchResults(results, page, limit), nil
+}
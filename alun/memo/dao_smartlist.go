@@ -0,0 +1,167 @@
+package memo
+
+import (
+	"context"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbSmartListCollectionName string
+	dbSmartListCollection     *mongo.Collection
+)
+
+// initSmartListDao loads the smart lists collection and ensures that smart
+// lists can be listed per user
+func initSmartListDao(memoMongoDb *mongo.Database) {
+	dbSmartListCollectionName = "al_memos_smartlists"
+	dbSmartListCollection = memoMongoDb.Collection(dbSmartListCollectionName)
+
+	index := mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: core.TrackedCreatedBy, Value: 1}},
+	}
+	if _, err := dbSmartListCollection.Indexes().CreateOne(context.TODO(), index); err != nil {
+		memoLogger.Warn("[MongoDB] Smart list index creation failed: %v", err)
+	}
+}
+
+// ---------- CRUD ------------------------------------------------------------
+
+// smartListFilter matches a smart list of an user. Smart lists are private so
+// other users cannot find them
+func smartListFilter(smartListID string, userID string) bson.M {
+	slID, _ := primitive.ObjectIDFromHex(smartListID)
+	uID, _ := primitive.ObjectIDFromHex(userID)
+
+	return bson.M{
+		"_id":                 slID,
+		core.TrackedCreatedBy: uID,
+	}
+}
+
+func findSmartListsByUserID(userID string) ([]SmartList, *core.ServiceMessage) {
+	uID, _ := primitive.ObjectIDFromHex(userID)
+	filter := bson.M{core.TrackedCreatedBy: uID}
+	options := &options.FindOptions{
+		Sort: bson.M{"name": 1},
+	}
+
+	smartLists := make([]SmartList, 0)
+
+	cur, err := dbSmartListCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if err := cur.All(context.TODO(), &smartLists); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return smartLists, nil
+}
+
+func findSmartListByID(smartListID string, userID string) (*SmartList, *core.ServiceMessage) {
+	var smartList SmartList
+	if err := dbSmartListCollection.FindOne(context.TODO(), smartListFilter(smartListID, userID)).Decode(&smartList); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, smartListNotFound
+		}
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &smartList, nil
+}
+
+func createSmartList(toCreateSmartList SmartList) (*SmartList, *core.ServiceMessage) {
+	toCreateSmartList.ID = primitive.NewObjectID()
+
+	if _, err := dbSmartListCollection.InsertOne(context.TODO(), toCreateSmartList); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &toCreateSmartList, nil
+}
+
+func updateSmartList(smartListID string, userID string, toUpdateSmartList SmartList) (*SmartList, *core.ServiceMessage) {
+	options := &options.FindOneAndUpdateOptions{
+		ReturnDocument: &returnOpt,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"name":                toUpdateSmartList.Name,
+			"query":               toUpdateSmartList.Query,
+			core.TrackedUpdatedBy: toUpdateSmartList.UpdatedBy,
+			core.TrackedUpdatedAt: toUpdateSmartList.UpdatedAt,
+		},
+	}
+
+	var updatedSmartList SmartList
+	if err := dbSmartListCollection.FindOneAndUpdate(context.TODO(), smartListFilter(smartListID, userID), update, options).Decode(&updatedSmartList); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, smartListNotFound
+		}
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &updatedSmartList, nil
+}
+
+func deleteSmartList(smartListID string, userID string) (int64, *core.ServiceMessage) {
+	deleted, err := dbSmartListCollection.DeleteOne(context.TODO(), smartListFilter(smartListID, userID))
+	if err != nil {
+		return -1, core.NewServiceErrorMessage(err)
+	}
+
+	return deleted.DeletedCount, nil
+}
+
+// ---------- Evaluation ------------------------------------------------------
+
+// findSmartListItems evaluates a smart list over the boards the user can
+// access, with the same access rules as findBoardsByUserID
+func findSmartListItems(smartList SmartList, userID string, now time.Time) ([]LocatedItem, *core.ServiceMessage) {
+	uID, _ := primitive.ObjectIDFromHex(userID)
+	query := smartList.Query
+
+	boardFilter := boardAccessFilter(uID)
+	if len(query.BoardIDs) > 0 {
+		boardFilter["_id"] = bson.M{"$in": query.BoardIDs}
+	}
+
+	boards := make([]Board, 0)
+	cur, err := dbBoardCollection.Find(context.TODO(), boardFilter)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if err := cur.All(context.TODO(), &boards); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if len(boards) == 0 {
+		return make([]LocatedItem, 0), nil
+	}
+
+	boardIDs := make(bson.A, 0, len(boards))
+	for _, board := range boards {
+		boardIDs = append(boardIDs, board.ID)
+	}
+	memoFilter := bson.M{"boardId": bson.M{"$in": boardIDs}}
+	if query.Assignee != "" {
+		memoFilter["$or"] = assigneeFilter(query.assigneeID(uID))
+	}
+
+	memos := make([]Memo, 0)
+	cur, err = dbMemoCollection.Find(context.TODO(), memoFilter)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if err := cur.All(context.TODO(), &memos); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return query.evaluate(boards, memos, uID, now), nil
+}
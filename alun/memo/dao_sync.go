@@ -0,0 +1,214 @@
+package memo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbChangeCollectionName  string
+	dbCounterCollectionName string
+	dbChangeCollection      *mongo.Collection
+	dbCounterCollection     *mongo.Collection
+)
+
+const (
+	// syncGapTimeout is how long a missing sequence is waited for before being
+	// considered as lost (the write allocating it failed)
+	syncGapTimeout = 10 * time.Second
+)
+
+// initSyncDao loads the change feed collections and ensures the feed index
+func initSyncDao(memoMongoDb *mongo.Database) {
+	dbChangeCollectionName = "al_memos_changes"
+	dbCounterCollectionName = "al_memos_counters"
+
+	dbChangeCollection = memoMongoDb.Collection(dbChangeCollectionName)
+	dbCounterCollection = memoMongoDb.Collection(dbCounterCollectionName)
+
+	index := mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "userId", Value: 1},
+			primitive.E{Key: "sequence", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := dbChangeCollection.Indexes().CreateOne(context.TODO(), index); err != nil {
+		memoLogger.Warn("[MongoDB] Change feed index creation failed: %v", err)
+	}
+}
+
+// ---------- Change feed -----------------------------------------------------
+
+// nextChangeSequence increments and returns the change sequence of an user.
+//
+// Each user has its own sequence so that a gap in the feed can only come from
+// a pending or a failed write
+func nextChangeSequence(userID primitive.ObjectID) (int64, error) {
+	filter := bson.M{"_id": fmt.Sprintf("changes:%s", userID.Hex())}
+	update := bson.M{"$inc": bson.M{"seq": int64(1)}}
+	upsert := true
+	options := &options.FindOneAndUpdateOptions{
+		ReturnDocument: &returnOpt,
+		Upsert:         &upsert,
+	}
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	if err := dbCounterCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&counter); err != nil {
+		return -1, err
+	}
+
+	return counter.Seq, nil
+}
+
+// recordChanges saves the changes in the feed of each recipient.
+//
+// The change is already applied when recording it so a failure is only logged
+// and not sent back to the client
+func recordChanges(recipients []primitive.ObjectID, changes ...Change) {
+	for _, userID := range recipients {
+		for _, change := range changes {
+			seq, err := nextChangeSequence(userID)
+			if err != nil {
+				memoLogger.Warn("[Sync] Cannot allocate sequence for user %s: %v", userID.Hex(), err)
+				continue
+			}
+
+			change.ID = primitive.NewObjectID()
+			change.UserID = userID
+			change.Sequence = seq
+
+			if _, err := dbChangeCollection.InsertOne(context.TODO(), change); err != nil {
+				memoLogger.Warn("[Sync] Cannot record change %s:%s for user %s: %v",
+					change.EntityType, change.Operation, userID.Hex(), err)
+			}
+		}
+	}
+}
+
+// findBoardRecipients returns the users who have to be notified of a change
+// in the board
+func findBoardRecipients(boardID primitive.ObjectID) ([]primitive.ObjectID, *core.ServiceMessage) {
+	board, errMsg := findBoardMembers(boardID)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	return board.recipients(), nil
+}
+
+// findBoardMembers only loads the creator and the members of a board
+func findBoardMembers(boardID primitive.ObjectID) (*Board, *core.ServiceMessage) {
+	filter := bson.M{"_id": boardID}
+	options := &options.FindOneOptions{
+		Projection: bson.M{core.TrackedCreatedBy: 1, "members": 1},
+	}
+
+	var board Board
+	if err := dbBoardCollection.FindOne(context.TODO(), filter, options).Decode(&board); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &board, nil
+}
+
+// findRecipientsWithoutBoard lists the recipients of a board whose feed does
+// not have the board creation
+func findRecipientsWithoutBoard(board Board) ([]primitive.ObjectID, error) {
+	recipients := board.recipients()
+	filter := bson.M{
+		"userId":     bson.M{"$in": recipients},
+		"entityType": changeEntityBoard,
+		"entityId":   board.ID,
+		"operation":  changeOpCreated,
+	}
+	withBoard, err := dbChangeCollection.Distinct(context.TODO(), "userId", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	received := make(map[primitive.ObjectID]bool)
+	for _, userID := range withBoard {
+		if id, ok := userID.(primitive.ObjectID); ok {
+			received[id] = true
+		}
+	}
+
+	missing := make([]primitive.ObjectID, 0)
+	for _, recipient := range recipients {
+		if !received[recipient] {
+			missing = append(missing, recipient)
+		}
+	}
+
+	return missing, nil
+}
+
+// findChanges loads the changes of an user after the provided sequence.
+//
+// The page stops at the first gap in the sequence unless the change following
+// the gap is older than syncGapTimeout: the missing change may still be written
+// and must not be skipped by the returned cursor
+func findChanges(userID string, after int64, limit int) (ChangeFeed, *core.ServiceMessage) {
+	feed := ChangeFeed{
+		Changes: make([]Change, 0),
+		Cursor:  formatSyncCursor(after),
+	}
+
+	id, _ := primitive.ObjectIDFromHex(userID)
+	filter := bson.M{
+		"userId":   id,
+		"sequence": bson.M{"$gt": after},
+	}
+	queryLimit := int64(limit + 1)
+	options := &options.FindOptions{
+		Sort:  bson.M{"sequence": 1},
+		Limit: &queryLimit,
+	}
+
+	cur, err := dbChangeCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return feed, core.NewServiceErrorMessage(err)
+	}
+	defer cur.Close(context.TODO())
+
+	expected := after + 1
+	for cur.Next(context.TODO()) {
+		var next Change
+		if err := cur.Decode(&next); err != nil {
+			return feed, core.NewServiceErrorMessage(err)
+		}
+
+		if len(feed.Changes) == limit {
+			feed.HasMore = true
+			break
+		}
+
+		if next.Sequence != expected && time.Since(next.ID.Timestamp()) < syncGapTimeout {
+			feed.HasMore = true
+			break
+		}
+
+		feed.Changes = append(feed.Changes, next)
+		feed.Cursor = formatSyncCursor(next.Sequence)
+		expected = next.Sequence + 1
+	}
+
+	return feed, nil
+}
+
+// deleteChangesByUserID is for tests cleanup only
+func deleteChangesByUserID(userID primitive.ObjectID) {
+	dbChangeCollection.DeleteMany(context.TODO(), bson.M{"userId": userID})
+	dbCounterCollection.DeleteOne(context.TODO(), bson.M{"_id": fmt.Sprintf("changes:%s", userID.Hex())})
+}
@@ -0,0 +1,195 @@
+package memo
+
+import (
+	"context"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoDuplicateKeyCode is the error code of an unique index violation
+const mongoDuplicateKeyCode = 11000
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbTimeEntryCollectionName string
+	dbTimeEntryCollection     *mongo.Collection
+)
+
+// initTimeEntryDao loads the time entries collection. Entries are listed per
+// board over a period and an unique partial index guarantees that an user
+// has at most one running timer, even with concurrent requests
+func initTimeEntryDao(memoMongoDb *mongo.Database) {
+	dbTimeEntryCollectionName = "al_memos_timeentries"
+	dbTimeEntryCollection = memoMongoDb.Collection(dbTimeEntryCollectionName)
+
+	isUnique := true
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "boardId", Value: 1},
+				primitive.E{Key: "startedAt", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{primitive.E{Key: core.TrackedCreatedBy, Value: 1}},
+			Options: &options.IndexOptions{
+				Unique:                  &isUnique,
+				PartialFilterExpression: bson.M{"isRunning": true},
+			},
+		},
+	}
+	if _, err := dbTimeEntryCollection.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		memoLogger.Warn("[MongoDB] Time entry indexes creation failed: %v", err)
+	}
+}
+
+// isDuplicateKeyError tells if a write failed because of an unique index
+func isDuplicateKeyError(err error) bool {
+	if writeException, ok := err.(mongo.WriteException); ok {
+		for _, writeError := range writeException.WriteErrors {
+			if writeError.Code == mongoDuplicateKeyCode {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ---------- CRUD ------------------------------------------------------------
+
+// createTimeEntry saves a manual entry or starts a timer. Starting a timer
+// while another one is running fails with timerAlreadyRunning
+func createTimeEntry(toCreateEntry TimeEntry) (*TimeEntry, *core.ServiceMessage) {
+	toCreateEntry.ID = primitive.NewObjectID()
+
+	if _, err := dbTimeEntryCollection.InsertOne(context.TODO(), toCreateEntry); err != nil {
+		if isDuplicateKeyError(err) {
+			return nil, timerAlreadyRunning
+		}
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &toCreateEntry, nil
+}
+
+// findRunningTimer loads the running timer of an user, nil if none is running
+func findRunningTimer(userID string) (*TimeEntry, *core.ServiceMessage) {
+	uID, _ := primitive.ObjectIDFromHex(userID)
+	filter := bson.M{core.TrackedCreatedBy: uID, "isRunning": true}
+
+	var entry TimeEntry
+	if err := dbTimeEntryCollection.FindOne(context.TODO(), filter).Decode(&entry); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &entry, nil
+}
+
+// stopTimer stops the running timer of an user on an item
+func stopTimer(itemID primitive.ObjectID, userID string, stoppedAt time.Time) (*TimeEntry, *core.ServiceMessage) {
+	uID, _ := primitive.ObjectIDFromHex(userID)
+	filter := bson.M{
+		"itemId":              itemID,
+		"isRunning":           true,
+		core.TrackedCreatedBy: uID,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"isRunning":           false,
+			"stoppedAt":           stoppedAt,
+			core.TrackedUpdatedBy: uID,
+			core.TrackedUpdatedAt: stoppedAt,
+		},
+	}
+	options := &options.FindOneAndUpdateOptions{
+		ReturnDocument: &returnOpt,
+	}
+
+	var entry TimeEntry
+	if err := dbTimeEntryCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&entry); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, timerNotRunning
+		}
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &entry, nil
+}
+
+// stopTimersOfDeleted stops the running timers of deleted items or memos, as
+// they cannot be stopped from their item anymore. Entries are kept for the
+// board totals
+func stopTimersOfDeleted(filter bson.M, stoppedAt time.Time) {
+	filter["isRunning"] = true
+	update := bson.M{
+		"$set": bson.M{
+			"isRunning":           false,
+			"stoppedAt":           stoppedAt,
+			core.TrackedUpdatedAt: stoppedAt,
+		},
+	}
+
+	if _, err := dbTimeEntryCollection.UpdateMany(context.TODO(), filter, update); err != nil {
+		memoLogger.Warn("[TimeEntry] Stopping the timers of %v failed: %v", filter, err)
+	}
+}
+
+// findTimeEntries lists the entries of a board started in [from, to), all the
+// entries if the period is zero
+func findTimeEntries(boardID primitive.ObjectID, from time.Time, to time.Time) ([]TimeEntry, *core.ServiceMessage) {
+	filter := bson.M{"boardId": boardID}
+	if !from.IsZero() || !to.IsZero() {
+		filter["startedAt"] = bson.M{"$gte": from, "$lt": to}
+	}
+	options := &options.FindOptions{
+		Sort: bson.D{primitive.E{Key: "startedAt", Value: 1}},
+	}
+
+	entries := make([]TimeEntry, 0)
+	cur, err := dbTimeEntryCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if err := cur.All(context.TODO(), &entries); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return entries, nil
+}
+
+// deleteTimeEntry deletes an entry of a board. Users can only delete their
+// own entries
+func deleteTimeEntry(boardID string, entryID string, userID string) (int64, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	eID, _ := primitive.ObjectIDFromHex(entryID)
+	uID, _ := primitive.ObjectIDFromHex(userID)
+	filter := bson.M{
+		"_id":                 eID,
+		"boardId":             bID,
+		core.TrackedCreatedBy: uID,
+	}
+
+	deleted, err := dbTimeEntryCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return -1, core.NewServiceErrorMessage(err)
+	}
+
+	return deleted.DeletedCount, nil
+}
+
+func deleteTimeEntriesByBoardID(boardID primitive.ObjectID) *core.ServiceMessage {
+	if _, err := dbTimeEntryCollection.DeleteMany(context.TODO(), bson.M{"boardId": boardID}); err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package memo
+
+import (
+	"context"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/pkg/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbWatchCollectionName string
+	dbWatchCollection     *mongo.Collection
+)
+
+// initWatchDao loads the watches collection and ensures that an user watches a
+// board only once and that watches can be found by unsubscribe token
+func initWatchDao(memoMongoDb *mongo.Database) {
+	dbWatchCollectionName = "al_memos_watches"
+	dbWatchCollection = memoMongoDb.Collection(dbWatchCollectionName)
+
+	isUnique := true
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "boardId", Value: 1},
+				primitive.E{Key: "userId", Value: 1},
+			},
+			Options: &options.IndexOptions{Unique: &isUnique},
+		},
+		{
+			Keys: bson.D{primitive.E{Key: "token", Value: 1}},
+		},
+	}
+	if _, err := dbWatchCollection.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		memoLogger.Warn("[MongoDB] Watch indexes creation failed: %v", err)
+	}
+}
+
+// ---------- CRUD ------------------------------------------------------------
+
+func findWatchesByBoardID(boardID primitive.ObjectID) ([]Watch, *core.ServiceMessage) {
+	watches := make([]Watch, 0)
+
+	cur, err := dbWatchCollection.Find(context.TODO(), bson.M{"boardId": boardID})
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if err := cur.All(context.TODO(), &watches); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return watches, nil
+}
+
+// watchBoard creates the watch of an user or updates its unsubscribe URL if
+// the user is already watching the board. A new token is generated each time
+func watchBoard(boardID string, userID string, unsubscribeURL string) (*Watch, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	uID, _ := primitive.ObjectIDFromHex(userID)
+
+	token, err := crypto.GenerateRandomString(32)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	filter := bson.M{"boardId": bID, "userId": uID}
+	update := bson.M{
+		"$set": bson.M{
+			"unsubscribeUrl": unsubscribeURL,
+			"token":          token,
+		},
+		"$setOnInsert": bson.M{
+			"_id":       primitive.NewObjectID(),
+			"createdAt": time.Now(),
+		},
+	}
+	isUpsert := true
+	options := &options.FindOneAndUpdateOptions{
+		ReturnDocument: &returnOpt,
+		Upsert:         &isUpsert,
+	}
+
+	var watch Watch
+	if err := dbWatchCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&watch); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &watch, nil
+}
+
+func unwatchBoard(boardID string, userID string) (int64, *core.ServiceMessage) {
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	uID, _ := primitive.ObjectIDFromHex(userID)
+
+	deleted, err := dbWatchCollection.DeleteOne(context.TODO(), bson.M{"boardId": bID, "userId": uID})
+	if err != nil {
+		return -1, core.NewServiceErrorMessage(err)
+	}
+
+	return deleted.DeletedCount, nil
+}
+
+// unwatchBoardByToken removes the watch of an unsubscribe link
+func unwatchBoardByToken(token string) (int64, *core.ServiceMessage) {
+	deleted, err := dbWatchCollection.DeleteOne(context.TODO(), bson.M{"token": token})
+	if err != nil {
+		return -1, core.NewServiceErrorMessage(err)
+	}
+
+	return deleted.DeletedCount, nil
+}
+
+func deleteWatchesByBoardID(boardID primitive.ObjectID) *core.ServiceMessage {
+	if _, err := dbWatchCollection.DeleteMany(context.TODO(), bson.M{"boardId": boardID}); err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,491 @@
+package memo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	fieldTypeNumber = "number"
+	fieldTypeSelect = "select"
+	fieldTypeDate   = "date"
+	fieldTypeText   = "text"
+
+	// fieldFilterSeparator separates the field ID, the operator and the value
+	// of a field filter such as "<fieldId>:gte:3"
+	fieldFilterSeparator = ":"
+)
+
+// Field is a custom field of a board. Items of the board can have a value for
+// each of the board fields.
+//
+// The type of a field cannot be changed once created
+type Field struct {
+	ID      primitive.ObjectID `json:"id" bson:"_id"`
+	Name    string             `json:"name" bson:"name"`
+	Type    string             `json:"type" bson:"type"`
+	Options []string           `json:"options,omitempty" bson:"options,omitempty"` // Only for select fields
+}
+
+// FieldValue is the value of an item for a custom field. Only the attribute
+// matching the field type is set: Text is used by text and select fields
+type FieldValue struct {
+	FieldID primitive.ObjectID `json:"fieldId" bson:"fieldId"`
+	Number  *float64           `json:"number,omitempty" bson:"number,omitempty"`
+	Text    string             `json:"text,omitempty" bson:"text,omitempty"`
+	Date    *time.Time         `json:"date,omitempty" bson:"date,omitempty"`
+}
+
+// FieldFilter keeps the items whose value of a field matches
+type FieldFilter struct {
+	Field    Field
+	Operator string
+	Value    FieldValue
+}
+
+// FieldSort orders the sibling items by the value of a field
+type FieldSort struct {
+	Field      Field
+	Descending bool
+}
+
+// ----------------------------------------------------------------------------
+//	Fields definition
+// ----------------------------------------------------------------------------
+
+// prepare trims the field and generates its ID if missing. An error is
+// returned if the field definition is invalid
+func (f *Field) prepare() error {
+	if f.ID.IsZero() {
+		f.ID = primitive.NewObjectID()
+	}
+
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" {
+		return errors.New("Field name is missing")
+	}
+
+	switch f.Type {
+	case fieldTypeNumber, fieldTypeDate, fieldTypeText:
+		f.Options = nil
+	case fieldTypeSelect:
+		return f.prepareOptions()
+	default:
+		return errors.New("Field type is invalid")
+	}
+
+	return nil
+}
+
+// prepareOptions trims the options of a select field which must have at least
+// one option, each option being unique
+func (f *Field) prepareOptions() error {
+	if len(f.Options) == 0 {
+		return errors.New("Select field must have options")
+	}
+
+	existing := make(map[string]bool)
+	for idx, option := range f.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || existing[option] {
+			return errors.New("Select field options must be unique and not empty")
+		}
+		existing[option] = true
+		f.Options[idx] = option
+	}
+
+	return nil
+}
+
+// prepareFields prepares all the fields of a board
+func (b *Board) prepareFields() error {
+	for idx := range b.Fields {
+		if err := b.Fields[idx].prepare(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findField returns the field of a board, nil if not found
+func (b *Board) findField(fieldID primitive.ObjectID) *Field {
+	for idx := range b.Fields {
+		if b.Fields[idx].ID == fieldID {
+			return &b.Fields[idx]
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+//	Fields value
+// ----------------------------------------------------------------------------
+
+// isValid checks that a value matches the type of its field
+func (fv *FieldValue) isValid(field Field) bool {
+	switch field.Type {
+	case fieldTypeNumber:
+		return fv.Number != nil && fv.Text == "" && fv.Date == nil
+	case fieldTypeDate:
+		return fv.Date != nil && fv.Text == "" && fv.Number == nil
+	case fieldTypeText:
+		return fv.Text != "" && fv.Number == nil && fv.Date == nil
+	case fieldTypeSelect:
+		if fv.Number != nil || fv.Date != nil {
+			return false
+		}
+		for _, option := range field.Options {
+			if option == fv.Text {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (fv *FieldValue) equals(fv2 FieldValue) bool {
+	sameNumber := (fv.Number == nil && fv2.Number == nil) ||
+		(fv.Number != nil && fv2.Number != nil && *fv.Number == *fv2.Number)
+	sameDate := (fv.Date == nil && fv2.Date == nil) ||
+		(fv.Date != nil && fv2.Date != nil && fv.Date.Round(1*time.Minute).Equal(fv2.Date.Round(1*time.Minute)))
+
+	return fv.FieldID == fv2.FieldID && fv.Text == fv2.Text && sameNumber && sameDate
+}
+
+func areFieldValuesEquals(values1 []FieldValue, values2 []FieldValue) bool {
+	if len(values1) != len(values2) {
+		return false
+	}
+	for idx, value := range values1 {
+		if !value.equals(values2[idx]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// findFieldValue returns the value of an item for a field, nil if the item
+// has no value for this field
+func (i *Item) findFieldValue(fieldID primitive.ObjectID) *FieldValue {
+	for idx := range i.Fields {
+		if i.Fields[idx].FieldID == fieldID {
+			return &i.Fields[idx]
+		}
+	}
+
+	return nil
+}
+
+// validateFieldValues checks the fields value of the items of a memo against
+// the board fields.
+//
+// Values of deleted fields are dropped. A value which became invalid after a
+// field change, such as a removed select option, is dropped as well if it is
+// left unchanged. Any other invalid value is rejected
+func validateFieldValues(board *Board, before *Memo, after *Memo) error {
+	previousItems := make(map[primitive.ObjectID]Item)
+	if before != nil {
+		previousItems = flattenItems(before.Items)
+	}
+
+	var err error
+	walkItems(after.Items, func(item *Item, parentID primitive.ObjectID) {
+		if err != nil || len(item.Fields) == 0 {
+			return
+		}
+
+		previous := previousItems[item.ID]
+		values := make([]FieldValue, 0, len(item.Fields))
+		seen := make(map[primitive.ObjectID]bool)
+		for _, value := range item.Fields {
+			field := board.findField(value.FieldID)
+			if field == nil {
+				continue
+			}
+			if seen[value.FieldID] {
+				err = errors.New("Field has several values")
+				return
+			}
+			seen[value.FieldID] = true
+
+			if !value.isValid(*field) {
+				if stored := previous.findFieldValue(value.FieldID); stored != nil && stored.equals(value) {
+					continue
+				}
+				err = errors.New("Field value is invalid")
+				return
+			}
+			values = append(values, value)
+		}
+		item.Fields = values
+	})
+
+	return err
+}
+
+// ----------------------------------------------------------------------------
+//	Fields filter and sort
+// ----------------------------------------------------------------------------
+
+// fieldOperators lists the filter operators allowed by field type. The first
+// operator is the default one
+var fieldOperators = map[string][]string{
+	fieldTypeNumber: {"eq", "gt", "gte", "lt", "lte"},
+	fieldTypeDate:   {"eq", "gt", "gte", "lt", "lte"},
+	fieldTypeSelect: {"eq"},
+	fieldTypeText:   {"eq", "contains"},
+}
+
+// parseFieldValue reads the raw value of a filter according to the field type.
+// Dates are either a day "2006-01-02" or a RFC3339 time
+func parseFieldValue(field Field, raw string) (FieldValue, error) {
+	value := FieldValue{FieldID: field.ID}
+
+	switch field.Type {
+	case fieldTypeNumber:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return value, err
+		}
+		value.Number = &number
+	case fieldTypeDate:
+		date, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			if date, err = time.Parse(time.RFC3339, raw); err != nil {
+				return value, err
+			}
+		}
+		value.Date = &date
+	default:
+		value.Text = raw
+	}
+
+	if !value.isValid(field) {
+		return value, errors.New("Value does not match the field")
+	}
+
+	return value, nil
+}
+
+// parseFieldFilter reads a "<fieldId>:<value>" or "<fieldId>:<operator>:<value>"
+// filter of the fields of a board
+func parseFieldFilter(board *Board, param string) (FieldFilter, error) {
+	var filter FieldFilter
+
+	parts := strings.SplitN(param, fieldFilterSeparator, 2)
+	if len(parts) != 2 {
+		return filter, errors.New("Filter must be <fieldId>:[<operator>:]<value>")
+	}
+
+	fieldID, err := primitive.ObjectIDFromHex(parts[0])
+	if err != nil {
+		return filter, err
+	}
+	field := board.findField(fieldID)
+	if field == nil {
+		return filter, errors.New("Unknown field")
+	}
+
+	operator, raw := "", parts[1]
+	for _, candidate := range fieldOperators[field.Type] {
+		if strings.HasPrefix(raw, candidate+fieldFilterSeparator) {
+			operator = candidate
+			raw = strings.TrimPrefix(raw, candidate+fieldFilterSeparator)
+			break
+		}
+	}
+
+	return newFieldFilter(*field, operator, raw)
+}
+
+// newFieldFilter builds the filter of a field. The default operator of the
+// field type is used if the operator is empty
+func newFieldFilter(field Field, operator string, raw string) (FieldFilter, error) {
+	filter := FieldFilter{Field: field}
+
+	operators := fieldOperators[field.Type]
+	filter.Operator = operators[0]
+	if operator != "" {
+		filter.Operator = ""
+		for _, candidate := range operators {
+			if candidate == operator {
+				filter.Operator = operator
+			}
+		}
+		if filter.Operator == "" {
+			return filter, errors.New("Operator is not supported by the field")
+		}
+	}
+
+	var err error
+	filter.Value, err = parseFieldValue(field, raw)
+	return filter, err
+}
+
+// parseFieldSort reads a "<fieldId>" sort, or "-<fieldId>" for a descending
+// sort, of the fields of a board
+func parseFieldSort(board *Board, param string) (FieldSort, error) {
+	var fieldSort FieldSort
+
+	if strings.HasPrefix(param, "-") {
+		fieldSort.Descending = true
+		param = param[1:]
+	}
+
+	fieldID, err := primitive.ObjectIDFromHex(param)
+	if err != nil {
+		return fieldSort, err
+	}
+	field := board.findField(fieldID)
+	if field == nil {
+		return fieldSort, errors.New("Unknown field")
+	}
+	fieldSort.Field = *field
+
+	return fieldSort, nil
+}
+
+// compareFieldValues returns a negative number if v1 is before v2, a positive
+// number if v1 is after v2 and 0 if they are equivalent. Select values follow
+// the options order
+func compareFieldValues(field Field, v1 FieldValue, v2 FieldValue) int {
+	switch field.Type {
+	case fieldTypeNumber:
+		switch {
+		case *v1.Number < *v2.Number:
+			return -1
+		case *v1.Number > *v2.Number:
+			return 1
+		}
+		return 0
+	case fieldTypeDate:
+		switch {
+		case v1.Date.Before(*v2.Date):
+			return -1
+		case v1.Date.After(*v2.Date):
+			return 1
+		}
+		return 0
+	case fieldTypeSelect:
+		return optionIndex(field, v1.Text) - optionIndex(field, v2.Text)
+	}
+
+	return strings.Compare(strings.ToLower(v1.Text), strings.ToLower(v2.Text))
+}
+
+func optionIndex(field Field, option string) int {
+	for idx, o := range field.Options {
+		if o == option {
+			return idx
+		}
+	}
+
+	return len(field.Options)
+}
+
+// matches tells if an item value of the filter field matches the filter. Items
+// without value never match
+func (ff *FieldFilter) matches(item Item) bool {
+	value := item.findFieldValue(ff.Field.ID)
+	if value == nil || !value.isValid(ff.Field) {
+		return false
+	}
+
+	if ff.Operator == "contains" {
+		return strings.Contains(strings.ToLower(value.Text), strings.ToLower(ff.Value.Text))
+	}
+
+	comparison := compareFieldValues(ff.Field, *value, ff.Value)
+	switch ff.Operator {
+	case "gt":
+		return comparison > 0
+	case "gte":
+		return comparison >= 0
+	case "lt":
+		return comparison < 0
+	case "lte":
+		return comparison <= 0
+	}
+
+	return comparison == 0
+}
+
+// sortItems sorts the sibling items, at any depth, by the value of the sort
+// field. Items without value are always last and keep their order
+func (fs *FieldSort) sortItems(items []Item) {
+	sort.SliceStable(items, func(i, j int) bool {
+		vi, vj := items[i].findFieldValue(fs.Field.ID), items[j].findFieldValue(fs.Field.ID)
+		if vi == nil || !vi.isValid(fs.Field) {
+			return false
+		}
+		if vj == nil || !vj.isValid(fs.Field) {
+			return true
+		}
+
+		comparison := compareFieldValues(fs.Field, *vi, *vj)
+		if fs.Descending {
+			return comparison > 0
+		}
+		return comparison < 0
+	})
+
+	for idx := range items {
+		fs.sortItems(items[idx].Children)
+	}
+}
+
+// applyFieldQuery filters and sorts the items of the board memos according to
+// the "field" filters, which must all match, and to the "sort" parameter
+func (b *Board) applyFieldQuery(query url.Values) *core.ServiceMessage {
+	filters := make([]FieldFilter, 0)
+	for _, param := range query["field"] {
+		filter, err := parseFieldFilter(b, param)
+		if err != nil {
+			return newFieldError(fieldQueryInvalid, err)
+		}
+		filters = append(filters, filter)
+	}
+
+	if len(filters) > 0 {
+		b.filterMemoItems(func(item Item) bool {
+			for _, filter := range filters {
+				if !filter.matches(item) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	if sortParam := query.Get("sort"); sortParam != "" {
+		fieldSort, err := parseFieldSort(b, sortParam)
+		if err != nil {
+			return newFieldError(fieldQueryInvalid, err)
+		}
+		for idx := range b.Memos {
+			fieldSort.sortItems(b.Memos[idx].Items)
+		}
+	}
+
+	return nil
+}
+
+// newFieldError details a fields related service message with the error
+func newFieldError(base *core.ServiceMessage, err error) *core.ServiceMessage {
+	msg := *base
+	msg.Message = fmt.Sprintf("%s: %v", base.Message, err)
+
+	return &msg
+}
@@ -0,0 +1,204 @@
+package memo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newFieldsBoard() Board {
+	board := Board{Fields: []Field{
+		{Name: "Estimate", Type: fieldTypeNumber},
+		{Name: "Priority", Type: fieldTypeSelect, Options: []string{"High", "Medium", "Low"}},
+	}}
+	board.prepareFields()
+
+	return board
+}
+
+func numberValue(fieldID primitive.ObjectID, number float64) FieldValue {
+	return FieldValue{FieldID: fieldID, Number: &number}
+}
+
+func TestValidateFieldValues(t *testing.T) {
+	board := newFieldsBoard()
+	estimate, priority := board.Fields[0].ID, board.Fields[1].ID
+
+	memo := Memo{Items: []Item{{Text: "Design", Fields: []FieldValue{
+		numberValue(estimate, 3),
+		{FieldID: priority, Text: "High"},
+		{FieldID: primitive.NewObjectID(), Text: "Deleted field"},
+	}}}}
+	memo.prepareItems()
+	testutils.Ok(t, testutils.CallFromTestFile, validateFieldValues(&board, nil, &memo))
+	testutils.Equals(t, testutils.CallFromTestFile, 2, len(memo.Items[0].Fields))
+
+	// Type mismatch and unknown option are rejected
+	invalid := Memo{Items: []Item{{Text: "Design", Fields: []FieldValue{{FieldID: estimate, Text: "3"}}}}}
+	testutils.Assert(t, testutils.CallFromTestFile, validateFieldValues(&board, nil, &invalid) != nil, "Text is not a number")
+	invalid.Items[0].Fields = []FieldValue{{FieldID: priority, Text: "Urgent"}}
+	testutils.Assert(t, testutils.CallFromTestFile, validateFieldValues(&board, nil, &invalid) != nil, "Urgent is not an option")
+
+	// An unchanged value of a removed option is dropped
+	board.Fields[1].Options = []string{"Medium", "Low"}
+	after := Memo{Items: []Item{memo.Items[0]}}
+	testutils.Ok(t, testutils.CallFromTestFile, validateFieldValues(&board, &memo, &after))
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(after.Items[0].Fields))
+}
+
+func TestFieldQuery(t *testing.T) {
+	board := newFieldsBoard()
+	estimate, priority := board.Fields[0].ID, board.Fields[1].ID
+	board.Memos = []Memo{{Items: []Item{
+		{Text: "Deploy"},
+		{Text: "Test", Fields: []FieldValue{numberValue(estimate, 2), {FieldID: priority, Text: "Low"}}},
+		{Text: "Design", Fields: []FieldValue{numberValue(estimate, 5), {FieldID: priority, Text: "High"}}},
+		{Text: "Code", Fields: []FieldValue{numberValue(estimate, 8), {FieldID: priority, Text: "Medium"}}},
+	}}}
+
+	query := url.Values{
+		"field": []string{fmt.Sprintf("%s:gte:3", estimate.Hex())},
+		"sort":  []string{priority.Hex()},
+	}
+	testutils.Assert(t, testutils.CallFromTestFile, board.applyFieldQuery(query) == nil, "Query should be valid")
+
+	items := board.Memos[0].Items
+	testutils.Equals(t, testutils.CallFromTestFile, 2, len(items))
+	testutils.Equals(t, testutils.CallFromTestFile, "Design", items[0].Text)
+	testutils.Equals(t, testutils.CallFromTestFile, "Code", items[1].Text)
+
+	query = url.Values{"field": []string{fmt.Sprintf("%s:lt:pouet", estimate.Hex())}}
+	testutils.Assert(t, testutils.CallFromTestFile, board.applyFieldQuery(query) != nil, "Value is not a number")
+}
+
+func TestE2EFields(t *testing.T) {
+	t.Parallel()
+
+	var testInfo testutils.APITestInfo
+	user, token := setupUser(t)
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Fields board"},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	memo, _ := createMemo(board.ID.Hex(), Memo{
+		BasicInfo:     BasicInfo{Title: "Sprint"},
+		Items:         []Item{{Text: "Design"}, {Text: "Code"}},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	itemsPath := fmt.Sprintf("boards/%s/memos/%s/items", board.ID.Hex(), memo.ID.Hex())
+	otherBoard, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Not shared"},
+		Fields:        []Field{{Name: "Estimate", Type: fieldTypeNumber}},
+		TrackedEntity: trackedBy(primitive.NewObjectID()),
+	})
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+		deleteBoard(otherBoard.ID.Hex())
+	})
+
+	t.Run("InvalidFieldIsRejected", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/fields", board.ID.Hex()),
+			Method:             http.MethodPost,
+			Payload:            Field{Name: "Priority", Type: fieldTypeSelect},
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("CreateField", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/fields", board.ID.Hex()),
+			Method:             http.MethodPost,
+			Payload:            Field{Name: "Estimate", Type: fieldTypeNumber},
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+		json.NewDecoder(rr.Body).Decode(board)
+
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(board.Fields))
+	})
+
+	t.Run("InvalidValueIsRejected", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:   fmt.Sprintf("%s/%s", itemsPath, memo.Items[0].ID.Hex()),
+			Method: http.MethodPut,
+			Payload: Item{Text: "Design", Fields: []FieldValue{
+				{FieldID: board.Fields[0].ID, Text: "Three"},
+			}},
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("SetValues", func(t *testing.T) {
+		for idx, item := range memo.Items {
+			testInfo = testutils.APITestInfo{
+				Path:   fmt.Sprintf("%s/%s", itemsPath, item.ID.Hex()),
+				Method: http.MethodPut,
+				Payload: Item{Text: item.Text, Fields: []FieldValue{
+					numberValue(board.Fields[0].ID, float64(idx+1)),
+				}},
+				ExpectedHTTPStatus: http.StatusOK,
+				AuthToken:          token,
+			}
+			apiTester.TestPath(t, testInfo)
+		}
+	})
+
+	t.Run("SortByField", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s?sort=-%s", board.ID.Hex(), board.Fields[0].ID.Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var sortedBoard Board
+		json.NewDecoder(rr.Body).Decode(&sortedBoard)
+		memos := boardMemos(sortedBoard)
+		testutils.Equals(t, testutils.CallFromTestFile, "Code", memos[0].Items[0].Text)
+		testutils.Equals(t, testutils.CallFromTestFile, "Design", memos[0].Items[1].Text)
+	})
+
+	t.Run("DeleteField", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/fields/%s", board.ID.Hex(), board.Fields[0].ID.Hex()),
+			Method:             http.MethodDelete,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		testInfo.ExpectedHTTPStatus = http.StatusNotFound
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("OutsiderCannotChangeFields", func(t *testing.T) {
+		otherFieldPath := fmt.Sprintf("boards/%s/fields/%s", otherBoard.ID.Hex(), otherBoard.Fields[0].ID.Hex())
+		requests := []testutils.APITestInfo{
+			{Path: fmt.Sprintf("boards/%s/fields", otherBoard.ID.Hex()), Method: http.MethodPost, Payload: Field{Name: "Mine", Type: fieldTypeText}},
+			{Path: otherFieldPath, Method: http.MethodPut, Payload: Field{Name: "Mine", Type: fieldTypeNumber}},
+			{Path: otherFieldPath, Method: http.MethodDelete},
+		}
+		for _, testInfo := range requests {
+			testInfo.ExpectedHTTPStatus = http.StatusNotFound
+			testInfo.AuthToken = token
+			apiTester.TestPath(t, testInfo)
+		}
+
+		b, _ := findBoardByID(otherBoard.ID.Hex())
+		testutils.Equals(t, testutils.CallFromTestFile, []Field{otherBoard.Fields[0]}, b.Fields)
+	})
+}
@@ -3,8 +3,14 @@ package memo
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func handleListBoards(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
@@ -19,6 +25,10 @@ func handleListBoards(w http.ResponseWriter, r *http.Request, claims core.JwtCla
 }
 
 func handleGetBoard(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
 	boardID := core.GetVar(r, "boardId")
 	board, err := findBoardByID(boardID)
 	if err != nil {
@@ -26,6 +36,27 @@ func handleGetBoard(w http.ResponseWriter, r *http.Request, claims core.JwtClaim
 		return
 	}
 
+	// Optional filter on the items assigned to an user, "me" being the
+	// current user
+	if assignee := r.URL.Query().Get("assignee"); assignee != "" {
+		if assignee == "me" {
+			assignee = claims.UserID
+		}
+		assigneeID, parseErr := primitive.ObjectIDFromHex(assignee)
+		if parseErr != nil {
+			itemAssigneeInvalid.Write(w, r)
+			return
+		}
+		board.filterMemosByAssignee(assigneeID)
+	}
+
+	// Optional filters and sort on the board custom fields
+	if errMsg := board.applyFieldQuery(r.URL.Query()); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	board.groupMemosByColumn()
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(board)
 }
@@ -38,6 +69,7 @@ func handleCreateBoard(w http.ResponseWriter, r *http.Request, claims core.JwtCl
 	newBoard, err := createBoard(toCreateBoard)
 	if err != nil {
 		err.Write(w, r)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -50,9 +82,26 @@ func handleUpdateBoard(w http.ResponseWriter, r *http.Request, claims core.JwtCl
 	json.NewDecoder(r.Body).Decode(&toUpdateBoard)
 	toUpdateBoard.PrepareForUpdate(claims)
 
+	// Members can update the board but only its owner shares it
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	board, errMsg := findBoardMembers(bID)
+	if errMsg != nil && errMsg.Error != mongo.ErrNoDocuments {
+		errMsg.Write(w, r)
+		return
+	}
+	if errMsg != nil || !board.hasAccess(toUpdateBoard.UpdatedBy) {
+		boardNotFound.Write(w, r)
+		return
+	}
+	if toUpdateBoard.Members != nil && board.CreatedBy != toUpdateBoard.UpdatedBy {
+		boardMembersForbidden.Write(w, r)
+		return
+	}
+
 	updatedBoard, err := updateBoard(boardID, toUpdateBoard)
 	if err != nil {
 		err.Write(w, r)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -61,6 +110,24 @@ func handleUpdateBoard(w http.ResponseWriter, r *http.Request, claims core.JwtCl
 
 func handleDeleteBoard(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
 	boardID := core.GetVar(r, "boardId")
+
+	// Members can see the board but only its owner deletes it
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	userID, _ := primitive.ObjectIDFromHex(claims.UserID)
+	board, errMsg := findBoardMembers(bID)
+	if errMsg != nil && errMsg.Error != mongo.ErrNoDocuments {
+		errMsg.Write(w, r)
+		return
+	}
+	if errMsg != nil || !board.hasAccess(userID) {
+		boardNotFound.Write(w, r)
+		return
+	}
+	if board.CreatedBy != userID {
+		boardDeletionForbidden.Write(w, r)
+		return
+	}
+
 	deletedBoardCount, _, err := deleteBoard(boardID)
 
 	if err != nil {
@@ -76,6 +143,10 @@ func handleDeleteBoard(w http.ResponseWriter, r *http.Request, claims core.JwtCl
 }
 
 func handleCreateMemo(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
 	boardID := core.GetVar(r, "boardId")
 
 	var toCreateMemo Memo
@@ -85,6 +156,11 @@ func handleCreateMemo(w http.ResponseWriter, r *http.Request, claims core.JwtCla
 	toCreateMemo.PrepareForCreate(claims)
 	// memoLogger.Verbose("Prepared memo %v for creation with %v", toCreateMemo, claims)
 
+	if itemsDepth(toCreateMemo.Items) > itemsMaxDepth {
+		newItemsTooDeep().Write(w, r)
+		return
+	}
+
 	newMemo, err := createMemo(boardID, toCreateMemo)
 	if err != nil {
 		err.Write(w, r)
@@ -96,6 +172,10 @@ func handleCreateMemo(w http.ResponseWriter, r *http.Request, claims core.JwtCla
 }
 
 func handleUpdateMemo(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
 	boardID := core.GetVar(r, "boardId")
 	memoID := core.GetVar(r, "memoId")
 	var toUpdateMemo Memo
@@ -103,6 +183,11 @@ func handleUpdateMemo(w http.ResponseWriter, r *http.Request, claims core.JwtCla
 
 	toUpdateMemo.PrepareForUpdate(claims)
 
+	if itemsDepth(toUpdateMemo.Items) > itemsMaxDepth {
+		newItemsTooDeep().Write(w, r)
+		return
+	}
+
 	newMemo, err := updateMemo(boardID, memoID, toUpdateMemo)
 	if err != nil {
 		err.Write(w, r)
@@ -114,9 +199,13 @@ func handleUpdateMemo(w http.ResponseWriter, r *http.Request, claims core.JwtCla
 }
 
 func handleDeleteMemo(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
 	boardID := core.GetVar(r, "boardId")
 	memoID := core.GetVar(r, "memoId")
-	deleteCount, err := deleteMemo(boardID, memoID)
+	deleteCount, err := deleteMemo(boardID, memoID, claims.UserID)
 
 	if err != nil {
 		err.Write(w, r)
@@ -129,3 +218,116 @@ func handleDeleteMemo(w http.ResponseWriter, r *http.Request, claims core.JwtCla
 		w.WriteHeader(http.StatusNotFound)
 	}
 }
+
+// handleGetCompletions returns the daily completion history of a board, for
+// burndown charts, between the "from" and "to" days (YYYY-MM-DD, UTC)
+func handleGetCompletions(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	boardID := core.GetVar(r, "boardId")
+	query := r.URL.Query()
+
+	from, to, err := parseCompletionRange(query.Get("from"), query.Get("to"), time.Now().In(userLocation(claims.UserID)))
+	if err != nil {
+		completionRangeInvalid.Write(w, r)
+		return
+	}
+
+	isAccessible, errMsg := isBoardAccessible(boardID, claims.UserID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	if !isAccessible {
+		boardNotFound.Write(w, r)
+		return
+	}
+
+	bID, _ := primitive.ObjectIDFromHex(boardID)
+	history, errMsg := findCompletionHistory(bID, from, to)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(history)
+}
+
+// handleGetChanges returns the changes of the logged user boards, memos and
+// items after the provided cursor. Without cursor, the whole feed is returned
+func handleGetChanges(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	query := r.URL.Query()
+
+	after, err := parseSyncCursor(query.Get("cursor"))
+	if err != nil || after < 0 {
+		syncCursorInvalid.Write(w, r)
+		return
+	}
+
+	limit := syncDefaultLimit
+	if limitParam := query.Get("limit"); limitParam != "" {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err == nil && parsedLimit > 0 && parsedLimit <= syncMaxLimit {
+			limit = parsedLimit
+		}
+	}
+
+	feed, errMsg := findChanges(claims.UserID, after, limit)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(feed)
+}
+
+// parsePagination reads the "page", starting at 1, and "limit" query
+// parameters. Invalid values are replaced by the default ones
+func parsePagination(query url.Values) (int, int) {
+	page := 1
+	if pageParam, err := strconv.Atoi(query.Get("page")); err == nil && pageParam > 0 {
+		page = pageParam
+	}
+	limit := searchDefaultLimit
+	if limitParam, err := strconv.Atoi(query.Get("limit")); err == nil && limitParam > 0 && limitParam <= searchMaxLimit {
+		limit = limitParam
+	}
+
+	return page, limit
+}
+
+// handleSearch looks for boards, memos and items matching the "q" query
+// parameter among the boards the logged user can access
+func handleSearch(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	query := r.URL.Query()
+
+	searchQuery := strings.TrimSpace(query.Get("q"))
+	if len(tokenizeSearch(searchQuery)) == 0 {
+		searchQueryMissing.Write(w, r)
+		return
+	}
+
+	page, limit := parsePagination(query)
+
+	results, errMsg := searchBoards(claims.UserID, searchQuery, page, limit)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
+}
+
+// handleListAssignedItems lists the items assigned to the logged user across
+// all the boards the user can access
+func handleListAssignedItems(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	assignedItems, errMsg := findAssignedItems(claims.UserID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(assignedItems)
+}
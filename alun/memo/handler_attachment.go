@@ -0,0 +1,114 @@
+package memo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// handleCreateAttachment attaches the request body to a memo. The file name
+// is the "name" query parameter and the content type is the request one
+func handleCreateAttachment(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(r.Body, attachmentMaxBytes+1))
+	if err != nil {
+		newAttachmentInvalid(err).Write(w, r)
+		return
+	}
+	if len(content) > attachmentMaxBytes {
+		newAttachmentTooLarge().Write(w, r)
+		return
+	}
+
+	attachment := Attachment{
+		Name:        r.URL.Query().Get("name"),
+		ContentType: r.Header.Get("Content-Type"),
+		Content:     content,
+	}
+	if err := attachment.validate(); err != nil {
+		newAttachmentInvalid(err).Write(w, r)
+		return
+	}
+	attachment.BoardID, _ = primitive.ObjectIDFromHex(core.GetVar(r, "boardId"))
+	attachment.MemoID, _ = primitive.ObjectIDFromHex(core.GetVar(r, "memoId"))
+	attachment.PrepareForCreate(claims)
+
+	newAttachment, errMsg := createAttachment(attachment)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newAttachment)
+}
+
+// handleListAttachments lists the attachments of a memo, without content
+func handleListAttachments(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	bID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "boardId"))
+	mID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "memoId"))
+	attachments, errMsg := findAttachmentsByMemoID(bID, mID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// handleGetAttachment downloads the content of an attachment
+func handleGetAttachment(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	bID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "boardId"))
+	mID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "memoId"))
+	aID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "attachmentId"))
+	attachment, errMsg := findAttachmentByID(bID, mID, aID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.Name))
+	w.WriteHeader(http.StatusOK)
+	w.Write(attachment.Content)
+}
+
+// handleDeleteAttachment deletes an attachment and releases its size from the
+// quota of the board owner
+func handleDeleteAttachment(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	bID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "boardId"))
+	mID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "memoId"))
+	aID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "attachmentId"))
+	deleteCount, errMsg := deleteAttachment(bID, mID, aID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	if deleteCount > 0 {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
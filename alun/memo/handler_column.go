@@ -0,0 +1,141 @@
+package memo
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Al-un/alun-api/alun/core"
+)
+
+// handleCreateColumn appends a column to a board
+func handleCreateColumn(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	boardID := core.GetVar(r, "boardId")
+
+	var column Column
+	json.NewDecoder(r.Body).Decode(&column)
+	column.Title = strings.TrimSpace(column.Title)
+	if column.Title == "" {
+		columnTitleMissing.Write(w, r)
+		return
+	}
+
+	var tracking core.TrackedEntity
+	tracking.PrepareForUpdate(claims)
+
+	board, errMsg := createColumn(boardID, column, tracking)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(board)
+}
+
+// handleRenameColumn only changes the title of a column
+func handleRenameColumn(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	boardID := core.GetVar(r, "boardId")
+	columnID := core.GetVar(r, "columnId")
+
+	var column Column
+	json.NewDecoder(r.Body).Decode(&column)
+	column.Title = strings.TrimSpace(column.Title)
+	if column.Title == "" {
+		columnTitleMissing.Write(w, r)
+		return
+	}
+
+	var tracking core.TrackedEntity
+	tracking.PrepareForUpdate(claims)
+
+	board, errMsg := renameColumn(boardID, columnID, column.Title, tracking)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(board)
+}
+
+// handleReorderColumns changes the order of all the columns of a board
+func handleReorderColumns(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	boardID := core.GetVar(r, "boardId")
+
+	var order ColumnOrder
+	json.NewDecoder(r.Body).Decode(&order)
+
+	var tracking core.TrackedEntity
+	tracking.PrepareForUpdate(claims)
+
+	board, errMsg := reorderBoardColumns(boardID, order, tracking)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(board)
+}
+
+// handleDeleteColumn deletes a column and moves its memos to the column of
+// the "fallback" query parameter
+func handleDeleteColumn(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	boardID := core.GetVar(r, "boardId")
+	columnID := core.GetVar(r, "columnId")
+	fallbackID := r.URL.Query().Get("fallback")
+
+	var tracking core.TrackedEntity
+	tracking.PrepareForUpdate(claims)
+
+	board, errMsg := deleteColumn(boardID, columnID, fallbackID, tracking)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(board)
+}
+
+// handleMoveMemo moves a memo to another column and/or position
+func handleMoveMemo(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	boardID := core.GetVar(r, "boardId")
+	memoID := core.GetVar(r, "memoId")
+
+	var move MemoMove
+	json.NewDecoder(r.Body).Decode(&move)
+
+	var tracking core.TrackedEntity
+	tracking.PrepareForUpdate(claims)
+
+	memo, errMsg := moveMemo(boardID, memoID, move, tracking)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(memo)
+}
@@ -0,0 +1,79 @@
+package memo
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Al-un/alun-api/alun/core"
+)
+
+// handleCreateField adds a custom field to a board
+func handleCreateField(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	boardID := core.GetVar(r, "boardId")
+
+	var field Field
+	json.NewDecoder(r.Body).Decode(&field)
+
+	var tracking core.TrackedEntity
+	tracking.PrepareForUpdate(claims)
+
+	board, errMsg := createField(boardID, field, tracking)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(board)
+}
+
+// handleUpdateField changes the name and the options of a custom field
+func handleUpdateField(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	boardID := core.GetVar(r, "boardId")
+	fieldID := core.GetVar(r, "fieldId")
+
+	var field Field
+	json.NewDecoder(r.Body).Decode(&field)
+
+	var tracking core.TrackedEntity
+	tracking.PrepareForUpdate(claims)
+
+	board, errMsg := updateField(boardID, fieldID, field, tracking)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(board)
+}
+
+// handleDeleteField removes a custom field from a board
+func handleDeleteField(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	boardID := core.GetVar(r, "boardId")
+	fieldID := core.GetVar(r, "fieldId")
+
+	var tracking core.TrackedEntity
+	tracking.PrepareForUpdate(claims)
+
+	board, errMsg := deleteField(boardID, fieldID, tracking)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(board)
+}
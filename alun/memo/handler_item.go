@@ -0,0 +1,193 @@
+package memo
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/pkg/quickadd"
+)
+
+// updateMemoItems loads a memo, applies an operation on its items and saves
+// the memo if the items are still valid. The saved memo is the response.
+//
+// The board access must be checked beforehand, with checkBoardAccess
+func updateMemoItems(w http.ResponseWriter, r *http.Request, claims core.JwtClaims,
+	operation func(memo *Memo) *core.ServiceMessage) {
+
+	boardID := core.GetVar(r, "boardId")
+	memoID := core.GetVar(r, "memoId")
+
+	memo, errMsg := findMemoByID(boardID, memoID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	if errMsg := operation(memo); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	if itemsDepth(memo.Items) > itemsMaxDepth {
+		newItemsTooDeep().Write(w, r)
+		return
+	}
+
+	memo.PrepareForUpdate(claims)
+	updatedMemo, errMsg := updateMemo(boardID, memoID, *memo)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updatedMemo)
+}
+
+// findItemFromRequest finds the item of the "itemPath" variable in a memo
+func findItemFromRequest(r *http.Request, memo *Memo) (*[]Item, int, *core.ServiceMessage) {
+	path, err := parseItemPath(core.GetVar(r, "itemPath"))
+	if err != nil {
+		return nil, -1, itemPathInvalid
+	}
+
+	siblings, idx := findItemByPath(&memo.Items, path)
+	if siblings == nil {
+		return nil, -1, itemNotFound
+	}
+
+	return siblings, idx, nil
+}
+
+// handleCreateItem appends an item, which can have children, to the root
+// items of a memo
+func handleCreateItem(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	var item Item
+	json.NewDecoder(r.Body).Decode(&item)
+
+	updateMemoItems(w, r, claims, func(memo *Memo) *core.ServiceMessage {
+		memo.Items = append(memo.Items, item)
+		return nil
+	})
+}
+
+// handleCreateChildItem appends an item to the children of the item of the
+// path
+func handleCreateChildItem(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	var item Item
+	json.NewDecoder(r.Body).Decode(&item)
+
+	updateMemoItems(w, r, claims, func(memo *Memo) *core.ServiceMessage {
+		siblings, idx, errMsg := findItemFromRequest(r, memo)
+		if errMsg != nil {
+			return errMsg
+		}
+
+		parent := &(*siblings)[idx]
+		parent.Children = append(parent.Children, item)
+		return nil
+	})
+}
+
+// handleUpdateItem updates the fields of the item of the path. Its children
+// are kept. The completion of a parent only depends on its children
+func handleUpdateItem(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	var item Item
+	json.NewDecoder(r.Body).Decode(&item)
+
+	updateMemoItems(w, r, claims, func(memo *Memo) *core.ServiceMessage {
+		siblings, idx, errMsg := findItemFromRequest(r, memo)
+		if errMsg != nil {
+			return errMsg
+		}
+
+		existing := &(*siblings)[idx]
+		existing.Text = item.Text
+		existing.IsFinished = item.IsFinished
+		existing.DueDate = item.DueDate
+		existing.AssigneeID = item.AssigneeID
+		existing.Fields = item.Fields
+		existing.Labels = item.Labels
+		return nil
+	})
+}
+
+// handleDeleteItem deletes the item of the path with its children
+func handleDeleteItem(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	updateMemoItems(w, r, claims, func(memo *Memo) *core.ServiceMessage {
+		siblings, idx, errMsg := findItemFromRequest(r, memo)
+		if errMsg != nil {
+			return errMsg
+		}
+
+		*siblings = append((*siblings)[:idx], (*siblings)[idx+1:]...)
+		return nil
+	})
+}
+
+// handleExportMemoMarkdown renders a memo as a Markdown checklist
+func handleExportMemoMarkdown(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	boardID := core.GetVar(r, "boardId")
+	memoID := core.GetVar(r, "memoId")
+
+	memo, errMsg := findMemoByID(boardID, memoID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(memo.markdown(userLocation(claims.UserID))))
+}
+
+// handleQuickAddItem parses a natural language text, such as "buy milk
+// tomorrow 6pm #groceries", into a root item of a memo. Dates are interpreted
+// in the timezone of the user
+func handleQuickAddItem(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	var quickAdd QuickAdd
+	json.NewDecoder(r.Body).Decode(&quickAdd)
+
+	parsed := quickadd.Parse(quickAdd.Text, time.Now().In(userLocation(claims.UserID)))
+	if parsed.Text == "" {
+		quickAddTextMissing.Write(w, r)
+		return
+	}
+
+	item := Item{
+		Text:    parsed.Text,
+		DueDate: parsed.Due,
+		Labels:  parsed.Labels,
+	}
+
+	updateMemoItems(w, r, claims, func(memo *Memo) *core.ServiceMessage {
+		memo.Items = append(memo.Items, item)
+		return nil
+	})
+}
@@ -0,0 +1,78 @@
+package memo
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// handleGetQuotaUsage shows the consumption of an user against its limits
+func handleGetQuotaUsage(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	userID := core.GetVar(r, "userId")
+
+	usage, errMsg := findQuotaUsage(userID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(usage)
+}
+
+// handleGetQuotaOverride shows the limits overridden for an user
+func handleGetQuotaOverride(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	userID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "userId"))
+
+	override, errMsg := findQuotaOverride(userID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(override)
+}
+
+// handleUpdateQuotaOverride replaces the limits overridden for an user. Omitted
+// limits fall back to the default quota
+func handleUpdateQuotaOverride(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	var override QuotaOverride
+	json.NewDecoder(r.Body).Decode(&override)
+	if errMsg := override.validate(); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	override.UserID, _ = primitive.ObjectIDFromHex(core.GetVar(r, "userId"))
+	override.PrepareForCreate(claims)
+	override.PrepareForUpdate(claims)
+
+	savedOverride, errMsg := upsertQuotaOverride(override)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(savedOverride)
+}
+
+// handleDeleteQuotaOverride restores the default quota of an user
+func handleDeleteQuotaOverride(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	userID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "userId"))
+
+	deleteCount, errMsg := deleteQuotaOverride(userID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	if deleteCount > 0 {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
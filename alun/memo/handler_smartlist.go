@@ -0,0 +1,100 @@
+package memo
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+)
+
+func handleListSmartLists(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	smartLists, errMsg := findSmartListsByUserID(claims.UserID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(smartLists)
+}
+
+func handleCreateSmartList(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	var toCreateSmartList SmartList
+	json.NewDecoder(r.Body).Decode(&toCreateSmartList)
+	toCreateSmartList.PrepareForCreate(claims)
+
+	if err := toCreateSmartList.prepare(); err != nil {
+		newSmartListInvalid(err).Write(w, r)
+		return
+	}
+
+	newSmartList, errMsg := createSmartList(toCreateSmartList)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newSmartList)
+}
+
+func handleUpdateSmartList(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	smartListID := core.GetVar(r, "smartListId")
+
+	var toUpdateSmartList SmartList
+	json.NewDecoder(r.Body).Decode(&toUpdateSmartList)
+	toUpdateSmartList.PrepareForUpdate(claims)
+
+	if err := toUpdateSmartList.prepare(); err != nil {
+		newSmartListInvalid(err).Write(w, r)
+		return
+	}
+
+	updatedSmartList, errMsg := updateSmartList(smartListID, claims.UserID, toUpdateSmartList)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updatedSmartList)
+}
+
+func handleDeleteSmartList(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	smartListID := core.GetVar(r, "smartListId")
+
+	deleteCount, errMsg := deleteSmartList(smartListID, claims.UserID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	if deleteCount > 0 {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// handleGetSmartListItems evaluates a smart list and returns the requested
+// page of its items
+func handleGetSmartListItems(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	smartListID := core.GetVar(r, "smartListId")
+	page, limit := parsePagination(r.URL.Query())
+
+	smartList, errMsg := findSmartListByID(smartListID, claims.UserID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	items, errMsg := findSmartListItems(*smartList, claims.UserID, time.Now().In(userLocation(claims.UserID)))
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(paginateSmartListItems(items, page, limit))
+}
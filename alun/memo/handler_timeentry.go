@@ -0,0 +1,215 @@
+package memo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// checkBoardAccess writes boardNotFound if the logged user cannot access the
+// board of the "boardId" variable and returns false
+func checkBoardAccess(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) bool {
+	isAccessible, errMsg := isBoardAccessible(core.GetVar(r, "boardId"), claims.UserID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return false
+	}
+	if !isAccessible {
+		boardNotFound.Write(w, r)
+		return false
+	}
+
+	return true
+}
+
+// newItemTimeEntry builds an entry of the item of the request path, after
+// checking that the item exists in a board accessible by the logged user
+func newItemTimeEntry(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) (*TimeEntry, bool) {
+	if !checkBoardAccess(w, r, claims) {
+		return nil, false
+	}
+
+	memo, errMsg := findMemoByID(core.GetVar(r, "boardId"), core.GetVar(r, "memoId"))
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return nil, false
+	}
+
+	siblings, idx, errMsg := findItemFromRequest(r, memo)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return nil, false
+	}
+
+	entry := &TimeEntry{
+		BoardID: memo.BoardID,
+		MemoID:  memo.ID,
+		ItemID:  (*siblings)[idx].ID,
+	}
+	entry.PrepareForCreate(claims)
+
+	return entry, true
+}
+
+// handleStartTimer starts a timer of the logged user on an item
+func handleStartTimer(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	entry, ok := newItemTimeEntry(w, r, claims)
+	if !ok {
+		return
+	}
+	entry.StartedAt = entry.CreatedAt
+	entry.IsRunning = true
+
+	newEntry, errMsg := createTimeEntry(*entry)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newEntry)
+}
+
+// handleStopTimer stops the timer of the logged user on an item
+func handleStopTimer(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	entry, ok := newItemTimeEntry(w, r, claims)
+	if !ok {
+		return
+	}
+
+	stoppedEntry, errMsg := stopTimer(entry.ItemID, claims.UserID, time.Now())
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stoppedEntry)
+}
+
+// handleGetRunningTimer returns the running timer of the logged user, or no
+// content if no timer is running
+func handleGetRunningTimer(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	entry, errMsg := findRunningTimer(claims.UserID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	if entry == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// handleCreateTimeEntry saves a finished period spent by the logged user on
+// an item
+func handleCreateTimeEntry(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	var manualEntry TimeEntry
+	json.NewDecoder(r.Body).Decode(&manualEntry)
+
+	entry, ok := newItemTimeEntry(w, r, claims)
+	if !ok {
+		return
+	}
+	entry.StartedAt = manualEntry.StartedAt
+	entry.StoppedAt = manualEntry.StoppedAt
+	entry.Note = manualEntry.Note
+
+	if err := entry.validateManual(time.Now()); err != nil {
+		newTimeEntryInvalid(err).Write(w, r)
+		return
+	}
+
+	newEntry, errMsg := createTimeEntry(*entry)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newEntry)
+}
+
+func handleDeleteTimeEntry(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	deleteCount, errMsg := deleteTimeEntry(core.GetVar(r, "boardId"), core.GetVar(r, "entryId"), claims.UserID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	if deleteCount > 0 {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		timeEntryNotFound.Write(w, r)
+	}
+}
+
+// handleGetTimeTotals returns the tracked durations of a board, per memo and
+// per item
+func handleGetTimeTotals(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	bID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "boardId"))
+	entries, errMsg := findTimeEntries(bID, time.Time{}, time.Time{})
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(computeTimeTotals(bID, entries, time.Now()))
+}
+
+// handleExportTimeEntries exports as CSV the entries of a board started
+// between the "from" and "to" days, included, in the timezone of the user.
+// The default period is the same as the completion history
+func handleExportTimeEntries(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	query := r.URL.Query()
+	location := userLocation(claims.UserID)
+	now := time.Now().In(location)
+
+	from, to, err := parseCompletionRange(query.Get("from"), query.Get("to"), now)
+	if err != nil {
+		timeRangeInvalid.Write(w, r)
+		return
+	}
+
+	if !checkBoardAccess(w, r, claims) {
+		return
+	}
+
+	bID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "boardId"))
+	entries, errMsg := findTimeEntries(bID, from, to.AddDate(0, 0, 1))
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	memos, errMsg := findMemosByBoardID(bID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	var export bytes.Buffer
+	if err := writeTimeEntriesCSV(&export, entries, memos, location, now); err != nil {
+		core.NewServiceErrorMessage(err).Write(w, r)
+		return
+	}
+
+	fileName := fmt.Sprintf("time-%s-%s.csv", from.Format(completionDayFormat), to.Format(completionDayFormat))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	w.WriteHeader(http.StatusOK)
+	w.Write(export.Bytes())
+}
@@ -0,0 +1,86 @@
+package memo
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Al-un/alun-api/alun/core"
+)
+
+// handleWatchBoard subscribes the logged user to the activity of a board. The
+// unsubscribe URL is the client page receiving the unsubscribe token
+func handleWatchBoard(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	boardID := core.GetVar(r, "boardId")
+
+	// Activity emails need the email of the watcher
+	if core.GetUserDirectory() == nil {
+		watchUnavailable.Write(w, r)
+		return
+	}
+
+	var watchRequest WatchRequest
+	json.NewDecoder(r.Body).Decode(&watchRequest)
+	if strings.TrimSpace(watchRequest.UnsubscribeURL) == "" {
+		watchUnsubscribeURLMissing.Write(w, r)
+		return
+	}
+
+	isAccessible, errMsg := isBoardAccessible(boardID, claims.UserID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	if !isAccessible {
+		boardNotFound.Write(w, r)
+		return
+	}
+
+	watch, errMsg := watchBoard(boardID, claims.UserID, strings.TrimSpace(watchRequest.UnsubscribeURL))
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(watch)
+}
+
+func handleUnwatchBoard(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	boardID := core.GetVar(r, "boardId")
+
+	deleteCount, errMsg := unwatchBoard(boardID, claims.UserID)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	if deleteCount > 0 {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		watchNotFound.Write(w, r)
+	}
+}
+
+// handleUnsubscribeWatch stops a watch from the token of an activity email,
+// without being logged in
+func handleUnsubscribeWatch(w http.ResponseWriter, r *http.Request) {
+	var watchRequest WatchRequest
+	json.NewDecoder(r.Body).Decode(&watchRequest)
+	if watchRequest.Token == "" {
+		watchNotFound.Write(w, r)
+		return
+	}
+
+	deleteCount, errMsg := unwatchBoardByToken(watchRequest.Token)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	if deleteCount > 0 {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		watchNotFound.Write(w, r)
+	}
+}
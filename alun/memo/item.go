@@ -0,0 +1,318 @@
+package memo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/utils"
+	"github.com/Al-un/alun-api/pkg/quickadd"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// itemsDefaultMaxDepth allows items with children and grandchildren
+	itemsDefaultMaxDepth = 3
+	// itemPathSeparator separates the item IDs of a path from the root item
+	// to the targeted item
+	itemPathSeparator = "."
+	// itemMarkdownIndent is the indentation of a nesting level in Markdown
+	itemMarkdownIndent = "  "
+	// itemMarkdownDueFormat is the due date of an item in Markdown
+	itemMarkdownDueFormat = "2006-01-02 15:04 MST"
+)
+
+var (
+	// itemsMaxDepth is the maximum number of nesting levels of the items of a
+	// memo. Root items are at depth 1
+	itemsMaxDepth = itemsDefaultMaxDepth
+)
+
+// loadItemsMaxDepth overrides the default items depth with the environment
+// variable if it is defined
+func loadItemsMaxDepth() {
+	value := os.Getenv(utils.EnvVarMemoItemsMaxDepth)
+	if value == "" {
+		return
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		memoLogger.Warn("[Items] Invalid value <%s> for %s, keeping %d", value, utils.EnvVarMemoItemsMaxDepth, itemsMaxDepth)
+		return
+	}
+	itemsMaxDepth = parsed
+}
+
+// walkItems calls fn on each item of the tree, parents before their children.
+// Root items have no parent ID
+func walkItems(items []Item, fn func(item *Item, parentID primitive.ObjectID)) {
+	var walk func(items []Item, parentID primitive.ObjectID)
+	walk = func(items []Item, parentID primitive.ObjectID) {
+		for idx := range items {
+			fn(&items[idx], parentID)
+			walk(items[idx].Children, items[idx].ID)
+		}
+	}
+
+	walk(items, primitive.NilObjectID)
+}
+
+// flattenItems indexes all the items of a tree by ID
+func flattenItems(items []Item) map[primitive.ObjectID]Item {
+	flattened := make(map[primitive.ObjectID]Item)
+	walkItems(items, func(item *Item, parentID primitive.ObjectID) {
+		flattened[item.ID] = *item
+	})
+
+	return flattened
+}
+
+// countItems counts all the items of a tree
+func countItems(items []Item) int {
+	count := 0
+	walkItems(items, func(item *Item, parentID primitive.ObjectID) {
+		count++
+	})
+
+	return count
+}
+
+// itemsDepth is the number of nesting levels of a tree, 0 if empty
+func itemsDepth(items []Item) int {
+	depth := 0
+	for _, item := range items {
+		if childDepth := itemsDepth(item.Children) + 1; childDepth > depth {
+			depth = childDepth
+		}
+	}
+
+	return depth
+}
+
+// filterItems keeps the items for which keep returns true. Parents of a kept
+// item are kept as well to preserve the tree
+func filterItems(items []Item, keep func(item Item) bool) []Item {
+	filtered := make([]Item, 0)
+	for _, item := range items {
+		children := filterItems(item.Children, keep)
+		if len(children) == 0 && !keep(item) {
+			continue
+		}
+
+		item.Children = children
+		filtered = append(filtered, item)
+	}
+
+	return filtered
+}
+
+// filterMemoItems filters the items of each board memo. Memos without any
+// kept item are removed
+func (b *Board) filterMemoItems(keep func(item Item) bool) {
+	memos := make([]Memo, 0)
+	for _, memo := range b.Memos {
+		memo.Items = filterItems(memo.Items, keep)
+		if len(memo.Items) > 0 {
+			memos = append(memos, memo)
+		}
+	}
+
+	b.Memos = memos
+}
+
+// locateItems lists the items of a memo for which keep returns true, with
+// their board and memo
+func locateItems(board Board, memo Memo, keep func(item Item) bool) []LocatedItem {
+	located := make([]LocatedItem, 0)
+	walkItems(memo.Items, func(item *Item, parentID primitive.ObjectID) {
+		if !keep(*item) {
+			return
+		}
+
+		located = append(located, LocatedItem{
+			BoardID:    board.ID,
+			BoardTitle: board.Title,
+			MemoID:     memo.ID,
+			MemoTitle:  memo.Title,
+			ParentID:   parentID,
+			Item:       *item,
+		})
+	})
+
+	return located
+}
+
+// matchItems gives to the items sent without ID the ID of the previous item
+// they replace so that clients which do not send the item IDs do not delete
+// and recreate all the items on each update. At each level, an item without
+// ID matches the first unclaimed previous sibling with the same text, or the
+// unclaimed previous sibling at the same position. Previous items whose ID is
+// sent are claimed
+func (m *Memo) matchItems(previous *Memo) {
+	if previous == nil {
+		return
+	}
+
+	claimed := make(map[primitive.ObjectID]bool)
+	walkItems(m.Items, func(item *Item, parentID primitive.ObjectID) {
+		if !item.ID.IsZero() {
+			claimed[item.ID] = true
+		}
+	})
+
+	matchSiblings(m.Items, previous.Items, flattenItems(previous.Items), claimed)
+}
+
+func matchSiblings(items []Item, previousSiblings []Item,
+	previousItems map[primitive.ObjectID]Item, claimed map[primitive.ObjectID]bool) {
+
+	matches := make([]*Item, len(items))
+	claim := func(idx int, previous Item) {
+		items[idx].ID = previous.ID
+		claimed[previous.ID] = true
+		matches[idx] = &previous
+	}
+
+	// Text first so that inserting or removing an item does not shift the
+	// matches, then position for the items whose text changed
+	for idx := range items {
+		if !items[idx].ID.IsZero() {
+			if previous, exists := previousItems[items[idx].ID]; exists {
+				matches[idx] = &previous
+			}
+			continue
+		}
+		for _, previous := range previousSiblings {
+			if !claimed[previous.ID] && previous.Text == items[idx].Text {
+				claim(idx, previous)
+				break
+			}
+		}
+	}
+	for idx := range items {
+		if items[idx].ID.IsZero() && idx < len(previousSiblings) && !claimed[previousSiblings[idx].ID] {
+			claim(idx, previousSiblings[idx])
+		}
+	}
+
+	for idx := range items {
+		var previousChildren []Item
+		if matches[idx] != nil {
+			previousChildren = matches[idx].Children
+		}
+		matchSiblings(items[idx].Children, previousChildren, previousItems, claimed)
+	}
+}
+
+// propagateCompletion finishes the parents whose children are all finished
+// and reopens the parents having an unfinished child. Items without children
+// are left untouched
+func propagateCompletion(items []Item) {
+	for idx := range items {
+		item := &items[idx]
+		if len(item.Children) == 0 {
+			continue
+		}
+
+		propagateCompletion(item.Children)
+		item.IsFinished = true
+		for _, child := range item.Children {
+			item.IsFinished = item.IsFinished && child.IsFinished
+		}
+	}
+}
+
+// parseItemPath reads a path of item IDs separated by itemPathSeparator
+func parseItemPath(path string) ([]primitive.ObjectID, error) {
+	if path == "" {
+		return nil, errors.New("item path is empty")
+	}
+
+	parts := strings.Split(path, itemPathSeparator)
+	itemIDs := make([]primitive.ObjectID, len(parts))
+	for idx, part := range parts {
+		itemID, err := primitive.ObjectIDFromHex(part)
+		if err != nil {
+			return nil, err
+		}
+		itemIDs[idx] = itemID
+	}
+
+	return itemIDs, nil
+}
+
+// findItemByPath returns the siblings list holding the item at the end of the
+// path, and the item index in this list. The list is nil if the path does
+// not exist
+func findItemByPath(items *[]Item, path []primitive.ObjectID) (*[]Item, int) {
+	siblings := items
+	for depth, itemID := range path {
+		idx := -1
+		for siblingIdx, sibling := range *siblings {
+			if sibling.ID == itemID {
+				idx = siblingIdx
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, -1
+		}
+
+		if depth == len(path)-1 {
+			return siblings, idx
+		}
+		siblings = &(*siblings)[idx].Children
+	}
+
+	return nil, -1
+}
+
+// markdown renders a memo as a Markdown checklist where nested items are
+// indented. Due dates are written in the provided timezone
+func (m *Memo) markdown(location *time.Location) string {
+	var builder strings.Builder
+
+	builder.WriteString("# " + m.Title + "\n")
+	if m.Description != "" {
+		builder.WriteString("\n" + m.Description + "\n")
+	}
+	if len(m.Items) > 0 {
+		builder.WriteString("\n")
+		writeItemsMarkdown(&builder, m.Items, 0, location)
+	}
+
+	return builder.String()
+}
+
+func writeItemsMarkdown(builder *strings.Builder, items []Item, depth int, location *time.Location) {
+	for _, item := range items {
+		checkbox := "[ ]"
+		if item.IsFinished {
+			checkbox = "[x]"
+		}
+
+		builder.WriteString(strings.Repeat(itemMarkdownIndent, depth))
+		builder.WriteString("- " + checkbox + " " + item.Text)
+		for _, label := range item.Labels {
+			builder.WriteString(" " + quickadd.LabelPrefix + label)
+		}
+		if !item.DueDate.IsZero() {
+			builder.WriteString(" (due " + item.DueDate.In(location).Format(itemMarkdownDueFormat) + ")")
+		}
+		builder.WriteString("\n")
+		writeItemsMarkdown(builder, item.Children, depth+1, location)
+	}
+}
+
+// newItemsTooDeep details the maximum depth of the items
+func newItemsTooDeep() *core.ServiceMessage {
+	msg := *itemsTooDeep
+	msg.Message = fmt.Sprintf("%s: %d levels maximum", itemsTooDeep.Message, itemsMaxDepth)
+
+	return &msg
+}
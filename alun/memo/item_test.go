@@ -0,0 +1,367 @@
+package memo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newItemsTree() []Item {
+	return []Item{
+		{Text: "Pack", Children: []Item{
+			{Text: "Clothes", IsFinished: true},
+			{Text: "Toiletries", Children: []Item{
+				{Text: "Toothbrush", IsFinished: true},
+				{Text: "Soap", IsFinished: true},
+			}},
+		}},
+		{Text: "Book hotel"},
+	}
+}
+
+func TestItemsTree(t *testing.T) {
+	memo := Memo{Items: newItemsTree()}
+	memo.prepareItems()
+
+	testutils.Equals(t, testutils.CallFromTestFile, 6, countItems(memo.Items))
+	testutils.Equals(t, testutils.CallFromTestFile, 3, itemsDepth(memo.Items))
+	walkItems(memo.Items, func(item *Item, parentID primitive.ObjectID) {
+		testutils.Assert(t, testutils.CallFromTestFile, !item.ID.IsZero(), "Item %s has no ID", item.Text)
+	})
+
+	// All children are finished so parents complete automatically
+	testutils.Assert(t, testutils.CallFromTestFile, memo.Items[0].Children[1].IsFinished, "Toiletries should be finished")
+	testutils.Assert(t, testutils.CallFromTestFile, memo.Items[0].IsFinished, "Pack should be finished")
+
+	// Reopening a child reopens its parents
+	memo.Items[0].Children[1].Children[1].IsFinished = false
+	memo.prepareItems()
+	testutils.Assert(t, testutils.CallFromTestFile, !memo.Items[0].Children[1].IsFinished, "Toiletries should be reopened")
+	testutils.Assert(t, testutils.CallFromTestFile, !memo.Items[0].IsFinished, "Pack should be reopened")
+}
+
+func TestFindItemByPath(t *testing.T) {
+	memo := Memo{Items: newItemsTree()}
+	memo.prepareItems()
+	pack, toiletries := memo.Items[0], memo.Items[0].Children[1]
+
+	path, err := parseItemPath(fmt.Sprintf("%s.%s", pack.ID.Hex(), toiletries.ID.Hex()))
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+
+	siblings, idx := findItemByPath(&memo.Items, path)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, idx)
+	testutils.Equals(t, testutils.CallFromTestFile, "Toiletries", (*siblings)[idx].Text)
+
+	// Item is not a child of the previous one
+	siblings, _ = findItemByPath(&memo.Items, []primitive.ObjectID{toiletries.ID})
+	testutils.Assert(t, testutils.CallFromTestFile, siblings == nil, "Nested item is not a root item")
+
+	_, err = parseItemPath("pouet")
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Path should be invalid")
+}
+
+func TestItemsTreeEquality(t *testing.T) {
+	items1, items2 := newItemsTree(), newItemsTree()
+	testutils.Assert(t, testutils.CallFromTestFile, areItemsArrayEquals(items1, items2), "Trees should be equals")
+
+	items2[0].Children[1].Children[0].Text = "Toothpaste"
+	testutils.Assert(t, testutils.CallFromTestFile, !areItemsArrayEquals(items1, items2), "Nested items differ")
+}
+
+func TestDiffNestedItems(t *testing.T) {
+	before := Memo{ID: primitive.NewObjectID(), Items: newItemsTree()}
+	before.prepareItems()
+
+	after := Memo{ID: before.ID, Items: newItemsTree()}
+	after.Items[0].ID = before.Items[0].ID
+	after.Items[0].Children[0].ID = before.Items[0].Children[0].ID
+	after.Items[0].Children[1].ID = before.Items[0].Children[1].ID
+	after.Items[0].Children[1].Children[0].ID = before.Items[0].Children[1].Children[0].ID
+	after.Items[0].Children[1].Children = after.Items[0].Children[1].Children[:1] // Soap deleted
+	after.Items[1].ID = before.Items[1].ID
+	after.Items[1].Text = "Book a hotel"
+	after.prepareItems()
+
+	changes := diffItems(primitive.NewObjectID(), before, after)
+
+	testutils.Equals(t, testutils.CallFromTestFile, 2, len(changes))
+	testutils.Equals(t, testutils.CallFromTestFile, changeOpUpdated, changes[0].Operation)
+	testutils.Equals(t, testutils.CallFromTestFile, before.Items[1].ID, changes[0].EntityID)
+	testutils.Equals(t, testutils.CallFromTestFile, changeOpDeleted, changes[1].Operation)
+	testutils.Equals(t, testutils.CallFromTestFile, before.Items[0].Children[1].ID, changes[1].ParentID)
+}
+
+func TestMatchItems(t *testing.T) {
+	previous := Memo{Items: newItemsTree()}
+	previous.prepareItems()
+
+	// Items are sent without ID, "Book hotel" is moved first, "Clothes" is
+	// renamed and "Toothbrush" is removed
+	memo := Memo{Items: newItemsTree()}
+	memo.Items[0], memo.Items[1] = memo.Items[1], memo.Items[0]
+	memo.Items[1].Children[0].Text = "Warm clothes"
+	memo.Items[1].Children[1].Children = memo.Items[1].Children[1].Children[1:]
+	memo.Items = append(memo.Items, Item{Text: "Buy tickets"})
+	memo.matchItems(&previous)
+
+	testutils.Equals(t, testutils.CallFromTestFile, previous.Items[1].ID, memo.Items[0].ID)
+	testutils.Equals(t, testutils.CallFromTestFile, previous.Items[0].ID, memo.Items[1].ID)
+	testutils.Equals(t, testutils.CallFromTestFile, previous.Items[0].Children[0].ID, memo.Items[1].Children[0].ID)
+	testutils.Equals(t, testutils.CallFromTestFile, previous.Items[0].Children[1].Children[1].ID, memo.Items[1].Children[1].Children[0].ID)
+	testutils.Assert(t, testutils.CallFromTestFile, memo.Items[2].ID.IsZero(), "New item should not match any previous item")
+
+	// A sent ID claims its previous item
+	memo = Memo{Items: []Item{{Text: "Book hotel"}, {ID: previous.Items[1].ID, Text: "Book a hotel"}}}
+	memo.matchItems(&previous)
+	testutils.Equals(t, testutils.CallFromTestFile, previous.Items[0].ID, memo.Items[0].ID)
+}
+
+func TestMemoMarkdown(t *testing.T) {
+	memo := Memo{BasicInfo: BasicInfo{Title: "Holidays", Description: "Summer trip"}, Items: newItemsTree()}
+	memo.prepareItems()
+
+	expected := "# Holidays\n\nSummer trip\n\n" +
+		"- [x] Pack\n" +
+		"  - [x] Clothes\n" +
+		"  - [x] Toiletries\n" +
+		"    - [x] Toothbrush\n" +
+		"    - [x] Soap\n" +
+		"- [ ] Book hotel\n"
+	testutils.Equals(t, testutils.CallFromTestFile, expected, memo.markdown(time.UTC))
+}
+
+func TestMemoMarkdownDueDate(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Timezone database is not available: %v", err)
+	}
+
+	memo := Memo{BasicInfo: BasicInfo{Title: "Errands"}, Items: []Item{{
+		Text:    "Buy milk",
+		DueDate: time.Date(2020, time.March, 10, 23, 0, 0, 0, time.UTC),
+		Labels:  []string{"groceries"},
+	}}}
+
+	expected := "# Errands\n\n- [ ] Buy milk #groceries (due 2020-03-11 08:00 JST)\n"
+	testutils.Equals(t, testutils.CallFromTestFile, expected, memo.markdown(tokyo))
+}
+
+func TestE2EItems(t *testing.T) {
+	t.Parallel()
+
+	var testInfo testutils.APITestInfo
+	user, token := setupUser(t)
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Nested items board"},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	memo, _ := createMemo(board.ID.Hex(), Memo{
+		BasicInfo:     BasicInfo{Title: "Holidays"},
+		Items:         []Item{{Text: "Pack"}},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	pack := memo.Items[0]
+	itemsPath := fmt.Sprintf("boards/%s/memos/%s/items", board.ID.Hex(), memo.ID.Hex())
+	otherBoard, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Not shared"},
+		TrackedEntity: trackedBy(primitive.NewObjectID()),
+	})
+	otherMemo, _ := createMemo(otherBoard.ID.Hex(), Memo{
+		BasicInfo: BasicInfo{Title: "Not shared"},
+		Items:     []Item{{Text: "Secret"}},
+	})
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+		deleteBoard(otherBoard.ID.Hex())
+	})
+
+	t.Run("CreateChildItems", func(t *testing.T) {
+		for _, text := range []string{"Clothes", "Toiletries"} {
+			testInfo = testutils.APITestInfo{
+				Path:               fmt.Sprintf("%s/%s", itemsPath, pack.ID.Hex()),
+				Method:             http.MethodPost,
+				Payload:            Item{Text: text},
+				ExpectedHTTPStatus: http.StatusOK,
+				AuthToken:          token,
+			}
+			rr := apiTester.TestPath(t, testInfo)
+			json.NewDecoder(rr.Body).Decode(memo)
+		}
+
+		testutils.Equals(t, testutils.CallFromTestFile, 2, len(memo.Items[0].Children))
+		testutils.Equals(t, testutils.CallFromTestFile, "Toiletries", memo.Items[0].Children[1].Text)
+	})
+
+	t.Run("TooDeepItemsAreRejected", func(t *testing.T) {
+		tooDeep := Item{Text: "Level 1"}
+		for depth := 1; depth <= itemsMaxDepth; depth++ {
+			tooDeep = Item{Text: fmt.Sprintf("Level %d", depth+1), Children: []Item{tooDeep}}
+		}
+
+		testInfo = testutils.APITestInfo{
+			Path:               itemsPath,
+			Method:             http.MethodPost,
+			Payload:            tooDeep,
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("FinishingChildrenCompletesParent", func(t *testing.T) {
+		for _, child := range memo.Items[0].Children {
+			testInfo = testutils.APITestInfo{
+				Path:               fmt.Sprintf("%s/%s.%s", itemsPath, pack.ID.Hex(), child.ID.Hex()),
+				Method:             http.MethodPut,
+				Payload:            Item{Text: child.Text, IsFinished: true},
+				ExpectedHTTPStatus: http.StatusOK,
+				AuthToken:          token,
+			}
+			rr := apiTester.TestPath(t, testInfo)
+			json.NewDecoder(rr.Body).Decode(memo)
+		}
+
+		testutils.Assert(t, testutils.CallFromTestFile, memo.Items[0].IsFinished, "Parent should be finished")
+		testutils.Equals(t, testutils.CallFromTestFile, user.ID, memo.Items[0].CompletedBy)
+	})
+
+	t.Run("UnknownItemPath", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("%s/%s", itemsPath, memo.Items[0].Children[0].ID.Hex()),
+			Method:             http.MethodDelete,
+			ExpectedHTTPStatus: http.StatusNotFound,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("DeleteChildItem", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("%s/%s.%s", itemsPath, pack.ID.Hex(), memo.Items[0].Children[0].ID.Hex()),
+			Method:             http.MethodDelete,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+		json.NewDecoder(rr.Body).Decode(memo)
+
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(memo.Items[0].Children))
+		testutils.Equals(t, testutils.CallFromTestFile, "Toiletries", memo.Items[0].Children[0].Text)
+	})
+
+	t.Run("ExportMarkdown", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/memos/%s/markdown", board.ID.Hex(), memo.ID.Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		testutils.Equals(t, testutils.CallFromTestFile, "# Holidays\n\n- [x] Pack\n  - [x] Toiletries\n", rr.Body.String())
+	})
+
+	t.Run("QuickAdd", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/memos/%s/quickadd", board.ID.Hex(), memo.ID.Hex()),
+			Method:             http.MethodPost,
+			Payload:            QuickAdd{Text: "Buy sunscreen tomorrow 6pm #shopping"},
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var updatedMemo Memo
+		json.NewDecoder(rr.Body).Decode(&updatedMemo)
+		added := updatedMemo.Items[len(updatedMemo.Items)-1]
+		testutils.Equals(t, testutils.CallFromTestFile, "Buy sunscreen", added.Text)
+		testutils.Equals(t, testutils.CallFromTestFile, []string{"shopping"}, added.Labels)
+		testutils.Equals(t, testutils.CallFromTestFile, 18, added.DueDate.UTC().Hour()) // User without timezone
+
+		testInfo.Payload = QuickAdd{Text: "tomorrow #shopping"}
+		testInfo.ExpectedHTTPStatus = http.StatusBadRequest
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("OutsiderCannotAccessItems", func(t *testing.T) {
+		otherItemsPath := fmt.Sprintf("boards/%s/memos/%s/items", otherBoard.ID.Hex(), otherMemo.ID.Hex())
+		otherItemPath := fmt.Sprintf("%s/%s", otherItemsPath, otherMemo.Items[0].ID.Hex())
+		requests := []testutils.APITestInfo{
+			{Path: otherItemsPath, Method: http.MethodPost, Payload: Item{Text: "Mine"}},
+			{Path: otherItemPath, Method: http.MethodPost, Payload: Item{Text: "Mine"}},
+			{Path: otherItemPath, Method: http.MethodPut, Payload: Item{Text: "Mine"}},
+			{Path: otherItemPath, Method: http.MethodDelete},
+			{Path: fmt.Sprintf("boards/%s/memos/%s/markdown", otherBoard.ID.Hex(), otherMemo.ID.Hex()), Method: http.MethodGet},
+			{Path: fmt.Sprintf("boards/%s/memos/%s/quickadd", otherBoard.ID.Hex(), otherMemo.ID.Hex()), Method: http.MethodPost, Payload: QuickAdd{Text: "Mine"}},
+		}
+		for _, testInfo := range requests {
+			testInfo.ExpectedHTTPStatus = http.StatusNotFound
+			testInfo.AuthToken = token
+			apiTester.TestPath(t, testInfo)
+		}
+
+		m, _ := findMemoByID(otherBoard.ID.Hex(), otherMemo.ID.Hex())
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(m.Items))
+		testutils.Equals(t, testutils.CallFromTestFile, "Secret", m.Items[0].Text)
+	})
+}
+
+func TestE2EUpdateMemoWithoutItemIDs(t *testing.T) {
+	t.Parallel()
+
+	var testInfo testutils.APITestInfo
+	user, token := setupUser(t)
+	member := primitive.NewObjectID()
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Legacy client board"},
+		Members:       []primitive.ObjectID{member},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	memo, _ := createMemo(board.ID.Hex(), Memo{
+		BasicInfo:     BasicInfo{Title: "Chores"},
+		Items:         []Item{{Text: "Dishes", IsFinished: true}, {Text: "Laundry", AssigneeID: member}},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	memoPath := fmt.Sprintf("boards/%s/memos/%s", board.ID.Hex(), memo.ID.Hex())
+	previous, _ := findMemoByID(board.ID.Hex(), memo.ID.Hex())
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+	})
+
+	testInfo = testutils.APITestInfo{
+		Path:               fmt.Sprintf("%s/items/%s/timer", memoPath, previous.Items[1].ID.Hex()),
+		Method:             http.MethodPost,
+		ExpectedHTTPStatus: http.StatusOK,
+		AuthToken:          token,
+	}
+	apiTester.TestPath(t, testInfo)
+
+	testInfo = testutils.APITestInfo{
+		Path:   memoPath,
+		Method: http.MethodPut,
+		Payload: Memo{
+			BasicInfo: BasicInfo{Title: "Chores"},
+			Items:     []Item{{Text: "Dishes", IsFinished: true}, {Text: "Laundry", AssigneeID: member}},
+		},
+		ExpectedHTTPStatus: http.StatusOK,
+		AuthToken:          token,
+	}
+	apiTester.TestPath(t, testInfo)
+
+	updated, _ := findMemoByID(board.ID.Hex(), memo.ID.Hex())
+	testutils.Equals(t, testutils.CallFromTestFile, previous.Items, updated.Items)
+	testutils.Equals(t, testutils.CallFromTestFile, 0, len(newAssignments(previous, *updated, user.ID)))
+
+	timer, _ := findRunningTimer(user.ID.Hex())
+	testutils.Assert(t, testutils.CallFromTestFile, timer != nil, "Timer should still be running")
+	testutils.Equals(t, testutils.CallFromTestFile, previous.Items[1].ID, timer.ItemID)
+}
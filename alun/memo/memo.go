@@ -7,12 +7,16 @@ import (
 )
 
 var (
-	memoLogger logger.Logger
+	memoLogger   logger.Logger
+	memoSearcher searcher
+	alunEmail    utils.AlunEmailSender
 )
 
 func init() {
 	if utils.IsTest() {
 		memoLogger = logger.NewSilenceLogger()
+		memoSearcher = memorySearcher{}
+		alunEmail = utils.GetDummyEmail()
 	}
 
 	// --- Init logger
@@ -20,6 +24,25 @@ func init() {
 		memoLogger = logger.NewConsoleLogger(logger.LogLevelVerbose)
 	}
 
+	// --- Init search
+	if memoSearcher == nil {
+		memoSearcher = mongoSearcher{}
+	}
+
+	// --- Init Email
+	if alunEmail == nil {
+		alunEmail = utils.GetAlunEmail()
+	}
+
+	// --- Init items
+	loadItemsMaxDepth()
+
+	// --- Init watch emails
+	loadWatchBatcher()
+
+	// --- Init mentions
+	loadMentionPolicy()
+
 	// --- Init DAO
 	initDao()
 
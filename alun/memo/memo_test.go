@@ -3,10 +3,13 @@ package memo
 import (
 	"os"
 	"testing"
+	"time"
 
+	"github.com/Al-un/alun-api/alun/core"
 	"github.com/Al-un/alun-api/alun/testutils"
 	"github.com/Al-un/alun-api/alun/user"
 	"github.com/Al-un/alun-api/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // ---------- Variables ------------------------------------------------------
@@ -57,3 +60,19 @@ func tearDownUser(t *testing.T) {
 		t.Logf("Error when CleaningUp users: %+v\n", err)
 	}
 }
+
+// trackedBy builds a TrackedEntity created by the provided user, to create
+// entities straight from the DAO
+func trackedBy(userID primitive.ObjectID) core.TrackedEntity {
+	return core.TrackedEntity{CreatedBy: userID, CreatedAt: time.Now()}
+}
+
+// boardMemos lists the memos of a board grouped by column, column after column
+func boardMemos(b Board) []Memo {
+	memos := make([]Memo, 0)
+	for _, column := range b.Columns {
+		memos = append(memos, column.Memos...)
+	}
+
+	return memos
+}
@@ -0,0 +1,179 @@
+package memo
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// mentionPolicyIgnore drops the mentions of users who cannot access the
+	// board
+	mentionPolicyIgnore = "ignore"
+	// mentionPolicyReject refuses to save a memo mentioning users who cannot
+	// access the board
+	mentionPolicyReject = "reject"
+)
+
+var (
+	mentionPolicy = mentionPolicyIgnore
+
+	// A mention is "@" followed by an username, and not preceded by a word
+	// character to skip emails
+	mentionRegexp = regexp.MustCompile(`(?:^|[^\w@])@([\w][\w.-]*)`)
+)
+
+// loadMentionPolicy overrides the default mention policy with the environment
+// variable if it is defined
+func loadMentionPolicy() {
+	value := strings.ToLower(os.Getenv(utils.EnvVarMemoMentionPolicy))
+	switch value {
+	case "":
+	case mentionPolicyIgnore, mentionPolicyReject:
+		mentionPolicy = value
+	default:
+		memoLogger.Warn("[Mention] Invalid value <%s> for %s, keeping %s", value, utils.EnvVarMemoMentionPolicy, mentionPolicy)
+	}
+}
+
+// parseMentions lists the mentioned usernames of a text, in lower case and
+// without duplicates. Trailing dots are punctuation: "@alice." mentions alice
+func parseMentions(text string) []string {
+	usernames := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, match := range mentionRegexp.FindAllStringSubmatch(text, -1) {
+		username := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if username != "" && !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+
+	return usernames
+}
+
+// resolveMentions matches the mentioned usernames with the contacts having
+// these usernames. An username shared by several users mentions the one who
+// can access the board.
+//
+// Users who cannot access the board are ignored, or rejected with the reject
+// policy unless they were already mentioned before, so that removing a member
+// from a board does not prevent from updating the memos
+func resolveMentions(board *Board, previous []primitive.ObjectID, usernames []string, contacts []core.UserContact, policy string) ([]primitive.ObjectID, error) {
+	wasMentioned := make(map[primitive.ObjectID]bool)
+	for _, userID := range previous {
+		wasMentioned[userID] = true
+	}
+
+	mentions := make([]primitive.ObjectID, 0)
+	for _, username := range usernames {
+		var mentioned, inaccessible primitive.ObjectID
+		for _, contact := range contacts {
+			userID, err := primitive.ObjectIDFromHex(contact.ID)
+			if err != nil || !strings.EqualFold(contact.Username, username) {
+				continue
+			}
+
+			if board.hasAccess(userID) {
+				mentioned = userID
+			} else if !wasMentioned[userID] {
+				inaccessible = userID
+			}
+		}
+
+		switch {
+		case !mentioned.IsZero():
+			mentions = append(mentions, mentioned)
+		case !inaccessible.IsZero() && policy == mentionPolicyReject:
+			return nil, fmt.Errorf("@%s cannot access the board", username)
+		}
+	}
+
+	return mentions, nil
+}
+
+// prepareMentions resolves the mentions of the memo description. The previous
+// mentions are kept if the users directory is not available
+func (m *Memo) prepareMentions(board *Board, previous *Memo) *core.ServiceMessage {
+	var previousMentions []primitive.ObjectID
+	if previous != nil {
+		previousMentions = previous.Mentions
+	}
+
+	m.Mentions = nil
+	usernames := parseMentions(m.Description)
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	// Previous mentions are kept so that they are neither erased nor notified
+	// again once the mentions can be resolved
+	directory := core.GetUserDirectory()
+	if directory == nil {
+		memoLogger.Info("[Mention] No users directory, %d mentions skipped", len(usernames))
+		m.Mentions = previousMentions
+		return nil
+	}
+
+	contacts, err := directory.FindUserContactsByUsernames(usernames)
+	if err != nil {
+		memoLogger.Warn("[Mention] Cannot load the mentioned users: %v", err)
+		m.Mentions = previousMentions
+		return nil
+	}
+
+	mentions, err := resolveMentions(board, previousMentions, usernames, contacts, mentionPolicy)
+	if err != nil {
+		return newMentionInvalid(err)
+	}
+	if len(mentions) > 0 {
+		m.Mentions = mentions
+	}
+
+	return nil
+}
+
+// newMentionNotifications notifies the users mentioned by a memo who were not
+// mentioned before, except the author
+func newMentionNotifications(board *Board, before *Memo, after Memo, authorID primitive.ObjectID) []core.Notification {
+	wasMentioned := make(map[primitive.ObjectID]bool)
+	if before != nil {
+		for _, userID := range before.Mentions {
+			wasMentioned[userID] = true
+		}
+	}
+
+	notifications := make([]core.Notification, 0)
+	for _, userID := range after.Mentions {
+		if wasMentioned[userID] || userID == authorID {
+			continue
+		}
+
+		notifications = append(notifications, core.Notification{
+			UserID:  userID.Hex(),
+			Type:    core.NotificationMemoMentioned,
+			Title:   fmt.Sprintf("You have been mentioned in %s", after.Title),
+			Message: after.Description,
+			Data: map[string]string{
+				"boardId": board.ID.Hex(),
+				"memoId":  after.ID.Hex(),
+			},
+		})
+	}
+
+	return notifications
+}
+
+// newMentionInvalid details which mention is rejected
+func newMentionInvalid(err error) *core.ServiceMessage {
+	msg := *mentionInvalid
+	msg.Message = fmt.Sprintf("%s: %v", mentionInvalid.Message, err)
+
+	return &msg
+}
@@ -0,0 +1,85 @@
+package memo
+
+import (
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseMentions(t *testing.T) {
+	usernames := parseMentions("@Alice please check with @bob.smith. Ping @alice again, not mail@example.com or @@carol")
+	testutils.Equals(t, testutils.CallFromTestFile, []string{"alice", "bob.smith"}, usernames)
+
+	testutils.Equals(t, testutils.CallFromTestFile, 0, len(parseMentions("No mention here")))
+}
+
+func TestResolveMentions(t *testing.T) {
+	owner, member, outsider, homonym := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	board := &Board{
+		Members:       []primitive.ObjectID{member},
+		TrackedEntity: core.TrackedEntity{CreatedBy: owner},
+	}
+	contacts := []core.UserContact{
+		{ID: member.Hex(), Username: "Alice"},
+		{ID: homonym.Hex(), Username: "alice"},
+		{ID: outsider.Hex(), Username: "bob"},
+	}
+
+	mentions, err := resolveMentions(board, nil, []string{"alice", "bob", "unknown"}, contacts, mentionPolicyIgnore)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, []primitive.ObjectID{member}, mentions)
+
+	_, err = resolveMentions(board, nil, []string{"alice", "bob"}, contacts, mentionPolicyReject)
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Mentioning an outsider should be rejected")
+
+	// Unknown usernames are not rejected
+	_, err = resolveMentions(board, nil, []string{"unknown"}, contacts, mentionPolicyReject)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+
+	// An user mentioned before losing access is dropped without rejection
+	mentions, err = resolveMentions(board, []primitive.ObjectID{outsider}, []string{"bob"}, contacts, mentionPolicyReject)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, 0, len(mentions))
+}
+
+func TestNewMentionNotifications(t *testing.T) {
+	author, alice, bob := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	board := &Board{ID: primitive.NewObjectID()}
+	before := &Memo{Mentions: []primitive.ObjectID{alice}}
+	after := Memo{ID: primitive.NewObjectID(), Mentions: []primitive.ObjectID{alice, bob, author}}
+
+	notifications := newMentionNotifications(board, before, after, author)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(notifications))
+	testutils.Equals(t, testutils.CallFromTestFile, bob.Hex(), notifications[0].UserID)
+	testutils.Equals(t, testutils.CallFromTestFile, core.NotificationMemoMentioned, notifications[0].Type)
+	testutils.Equals(t, testutils.CallFromTestFile, after.ID.Hex(), notifications[0].Data["memoId"])
+}
+
+func TestE2EMentions(t *testing.T) {
+	t.Parallel()
+
+	user, _ := setupUser(t)
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Mention board"},
+		TrackedEntity: trackedBy(user.ID),
+	})
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+	})
+
+	memo, errMsg := createMemo(board.ID.Hex(), Memo{
+		BasicInfo:     BasicInfo{Title: "Mentions", Description: "@" + userTestUsername + " please check"},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Memo creation failed: %v", errMsg)
+	testutils.Equals(t, testutils.CallFromTestFile, []primitive.ObjectID{user.ID}, memo.Mentions)
+
+	memo.Description = "Nobody to check"
+	updatedMemo, errMsg := updateMemo(board.ID.Hex(), memo.ID.Hex(), *memo)
+	testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Memo update failed: %v", errMsg)
+	testutils.Equals(t, testutils.CallFromTestFile, 0, len(updatedMemo.Mentions))
+}
@@ -23,23 +23,66 @@ func (bi *BasicInfo) equals(bi2 BasicInfo) bool {
 	return bi.Title == bi2.Title && bi.Description == bi2.Description
 }
 
-// Board is a memo container
+// Board is a memo container organised as a Kanban: each memo belongs to one
+// of the board ordered columns.
+//
+// A board is shared with its members who have the same access as its creator.
+// Its custom fields define the typed values its items can have
 type Board struct {
 	ID                 primitive.ObjectID `json:"id" bson:"_id"`
 	BasicInfo          `bson:",inline"`
-	Access             int    `json:"access" bson:"access"`
-	Memos              []Memo `json:"memos,omitempty" bson:"memos,omitempty"`
+	Access             int                  `json:"access" bson:"access"`
+	Columns            []Column             `json:"columns" bson:"columns"`
+	Members            []primitive.ObjectID `json:"members,omitempty" bson:"members,omitempty"`
+	Fields             []Field              `json:"fields,omitempty" bson:"fields,omitempty"`
+	Memos              []Memo               `json:"memos,omitempty" bson:"-"` // Memos are stored in their own collection
 	core.TrackedEntity `bson:",inline"`
 }
 
+// Column is a step of a board such as "To do", "Doing" or "Done". Columns
+// order is the order of the board columns array
+type Column struct {
+	ID    primitive.ObjectID `json:"id" bson:"_id"`
+	Title string             `json:"title" bson:"title"`
+	Memos []Memo             `json:"memos,omitempty" bson:"-"` // Only loaded for the board details
+}
+
+// recipients lists the users whose change feed is impacted by a change in
+// the board: all the users who can access it
+func (b *Board) recipients() []primitive.ObjectID {
+	recipients := []primitive.ObjectID{b.CreatedBy}
+	for _, member := range b.Members {
+		if member != b.CreatedBy {
+			recipients = append(recipients, member)
+		}
+	}
+
+	return recipients
+}
+
+// hasAccess tells if an user can access the board. It must stay consistent
+// with boardAccessFilter
+func (b *Board) hasAccess(userID primitive.ObjectID) bool {
+	for _, recipient := range b.recipients() {
+		if recipient == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Memo is a group of items to be remembered. Comparing to a manual TODO list
 // or checklist, a memo would be a single page
 type Memo struct {
 	ID                 primitive.ObjectID `json:"id" bson:"_id"`
 	BasicInfo          `bson:",inline"`
-	Items              []Item `json:"items,omitempty" bson:"items"`
+	Items              []Item               `json:"items,omitempty" bson:"items"`
+	BoardID            primitive.ObjectID   `json:"-" bson:"boardId"`
+	ColumnID           primitive.ObjectID   `json:"columnId" bson:"columnId"`
+	Position           int                  `json:"position" bson:"position"`                     // Position of the memo in its column, starting at 0
+	Mentions           []primitive.ObjectID `json:"mentions,omitempty" bson:"mentions,omitempty"` // Resolved from the description by the server
 	core.TrackedEntity `bson:",inline"`
-	// BoardID            primitive.ObjectID `json:"boardId" bson:"boardId"`
 }
 
 func (m *Memo) equals(m2 Memo) bool {
@@ -54,21 +97,85 @@ func (m *Memo) equals(m2 Memo) bool {
 	return areItemsArrayEquals(m.Items, m2.Items)
 }
 
-// Item is a single action or thing to remember
+// Item is a single action or thing to remember.
+//
+// An item ID is generated by the server when missing so that each item can
+// be individually tracked in the change feed. On update, an item sent without
+// ID keeps the ID of the previous item it matches. Completion fields are managed by
+// the server when IsFinished changes.
+//
+// Items can have children, up to itemsMaxDepth levels. A parent is finished
+// when all its children are finished
 type Item struct {
-	Text       string    `json:"text" bson:"text"`
-	IsFinished bool      `json:"isFinished,omitempty" bson:"isFinished"`
-	DueDate    time.Time `json:"dueDate,omitempty" bson:"dueDate,omitempty"`
+	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Text        string             `json:"text" bson:"text"`
+	IsFinished  bool               `json:"isFinished,omitempty" bson:"isFinished"`
+	DueDate     time.Time          `json:"dueDate,omitempty" bson:"dueDate,omitempty"`
+	CompletedAt time.Time          `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+	CompletedBy primitive.ObjectID `json:"completedBy,omitempty" bson:"completedBy,omitempty"`
+	AssigneeID  primitive.ObjectID `json:"assigneeId,omitempty" bson:"assigneeId,omitempty"` // Must have access to the board
+	Fields      []FieldValue       `json:"fields,omitempty" bson:"fields,omitempty"`         // Values of the board custom fields
+	Labels      []string           `json:"labels,omitempty" bson:"labels,omitempty"`
+	Children    []Item             `json:"children,omitempty" bson:"children,omitempty"`
+}
+
+// QuickAdd is a natural language text describing an item
+type QuickAdd struct {
+	Text string `json:"text"`
+}
+
+// LocatedItem is an item listed outside of its memo, with the location of the
+// item
+type LocatedItem struct {
+	BoardID    primitive.ObjectID `json:"boardId"`
+	BoardTitle string             `json:"boardTitle"`
+	MemoID     primitive.ObjectID `json:"memoId"`
+	MemoTitle  string             `json:"memoTitle"`
+	ParentID   primitive.ObjectID `json:"parentId,omitempty"`
+	Item       Item               `json:"item"`
 }
 
 // Equals checks the equality all fields and time values are checked with a precision
-// of one minute
+// of one minute. The ID and the completion are not compared as they are set by
+// the server. Children are compared recursively
 func (i *Item) equals(i2 Item) bool {
+	return i.hasSameFields(i2) && areItemsArrayEquals(i.Children, i2.Children)
+}
+
+// hasSameFields is equals without the children
+func (i *Item) hasSameFields(i2 Item) bool {
 	return i.Text == i2.Text &&
 		i.IsFinished == i2.IsFinished &&
+		i.AssigneeID == i2.AssigneeID &&
+		areFieldValuesEquals(i.Fields, i2.Fields) &&
+		areLabelsEquals(i.Labels, i2.Labels) &&
 		i.DueDate.Round(1*time.Minute).Equal(i2.DueDate.Round(1*time.Minute))
 }
 
+// prepareItems generates an ID for each item, at any depth, which does not
+// have one yet and completes the parents whose children are all finished
+func (m *Memo) prepareItems() {
+	walkItems(m.Items, func(item *Item, parentID primitive.ObjectID) {
+		if item.ID.IsZero() {
+			item.ID = primitive.NewObjectID()
+		}
+	})
+	propagateCompletion(m.Items)
+}
+
+func areLabelsEquals(labels1 []string, labels2 []string) bool {
+	if len(labels1) != len(labels2) {
+		return false
+	}
+	for idx, label := range labels1 {
+		if label != labels2[idx] {
+			return false
+		}
+	}
+
+	return true
+}
+
 func areItemsArrayEquals(items1 []Item, items2 []Item) bool {
 	if len(items1) != len(items2) {
 		return false
@@ -82,3 +189,47 @@ func areItemsArrayEquals(items1 []Item, items2 []Item) bool {
 
 	return true
 }
+
+// ----------------------------------------------------------------------------
+//	Types: Change feed
+// ----------------------------------------------------------------------------
+
+const (
+	changeEntityBoard = "board"
+	changeEntityMemo  = "memo"
+	changeEntityItem  = "item"
+
+	changeOpCreated = "created"
+	changeOpUpdated = "updated"
+	changeOpDeleted = "deleted"
+)
+
+// Change is a single entry of an user change feed. Each write on a board, a
+// memo or an item generates a Change with a monotonic Sequence.
+//
+// A deleted entity is a tombstone: only its identifiers are kept. Deleting a
+// board or a memo implicitly deletes its children so no tombstone is generated
+// for them
+type Change struct {
+	ID         primitive.ObjectID `json:"-" bson:"_id"`
+	Sequence   int64              `json:"sequence" bson:"sequence"`
+	UserID     primitive.ObjectID `json:"-" bson:"userId"` // Recipient of the change
+	EntityType string             `json:"entityType" bson:"entityType"`
+	EntityID   primitive.ObjectID `json:"entityId" bson:"entityId"`
+	BoardID    primitive.ObjectID `json:"boardId" bson:"boardId"`
+	MemoID     primitive.ObjectID `json:"memoId,omitempty" bson:"memoId,omitempty"`
+	ParentID   primitive.ObjectID `json:"parentId,omitempty" bson:"parentId,omitempty"` // Parent of a nested item
+	Operation  string             `json:"operation" bson:"operation"`
+	UpdatedAt  time.Time          `json:"updatedAt" bson:"updatedAt"`
+	Board      *Board             `json:"board,omitempty" bson:"board,omitempty"`
+	Memo       *Memo              `json:"memo,omitempty" bson:"memo,omitempty"`
+	Item       *Item              `json:"item,omitempty" bson:"item,omitempty"`
+}
+
+// ChangeFeed is a page of changes. Cursor has to be sent back to fetch the
+// next page or, if HasMore is false, the next changes
+type ChangeFeed struct {
+	Changes []Change `json:"changes"`
+	Cursor  string   `json:"cursor"`
+	HasMore bool     `json:"hasMore"`
+}
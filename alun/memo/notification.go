@@ -0,0 +1,63 @@
+package memo
+
+import (
+	"fmt"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newBoardInvites notifies the members who gained access to a board, except
+// the user inviting them
+func newBoardInvites(board Board, previousRecipients []primitive.ObjectID, inviterID primitive.ObjectID) []core.Notification {
+	previous := make(map[primitive.ObjectID]bool)
+	for _, recipient := range previousRecipients {
+		previous[recipient] = true
+	}
+
+	notifications := make([]core.Notification, 0)
+	for _, recipient := range board.recipients() {
+		if previous[recipient] || recipient == inviterID || recipient.IsZero() {
+			continue
+		}
+
+		notifications = append(notifications, core.Notification{
+			UserID:  recipient.Hex(),
+			Type:    core.NotificationBoardInvite,
+			Title:   "You have been added to a board",
+			Message: board.Title,
+			Data:    map[string]string{"boardId": board.ID.Hex()},
+		})
+	}
+
+	return notifications
+}
+
+// newAssignmentNotifications notifies each new assignee, one notification per
+// assigned item
+func newAssignmentNotifications(board *Board, memo Memo, assignments map[primitive.ObjectID][]Item) []core.Notification {
+	notifications := make([]core.Notification, 0)
+	for assigneeID, items := range assignments {
+		for _, item := range items {
+			notifications = append(notifications, core.Notification{
+				UserID:  assigneeID.Hex(),
+				Type:    core.NotificationItemAssigned,
+				Title:   fmt.Sprintf("Item assigned to you in %s", memo.Title),
+				Message: item.Text,
+				Data: map[string]string{
+					"boardId": board.ID.Hex(),
+					"memoId":  memo.ID.Hex(),
+					"itemId":  item.ID.Hex(),
+				},
+			})
+		}
+	}
+
+	return notifications
+}
+
+// sendNotifications produces in-app notifications in the background. Changes
+// are already saved so failures are only logged
+func sendNotifications(notifications []core.Notification) {
+	core.NotifyAsync(notifications...)
+}
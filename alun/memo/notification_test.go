@@ -0,0 +1,30 @@
+package memo
+
+import (
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNewBoardInvites(t *testing.T) {
+	owner, member, invited := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	board := Board{
+		ID:            primitive.NewObjectID(),
+		BasicInfo:     BasicInfo{Title: "Shared"},
+		Members:       []primitive.ObjectID{member, invited},
+		TrackedEntity: core.TrackedEntity{CreatedBy: owner},
+	}
+
+	notifications := newBoardInvites(board, []primitive.ObjectID{owner, member}, owner)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(notifications))
+	testutils.Equals(t, testutils.CallFromTestFile, invited.Hex(), notifications[0].UserID)
+	testutils.Equals(t, testutils.CallFromTestFile, core.NotificationBoardInvite, notifications[0].Type)
+	testutils.Equals(t, testutils.CallFromTestFile, board.ID.Hex(), notifications[0].Data["boardId"])
+
+	// The member inviting somebody else is not notified
+	notifications = newBoardInvites(board, []primitive.ObjectID{owner}, member)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(notifications))
+	testutils.Equals(t, testutils.CallFromTestFile, invited.Hex(), notifications[0].UserID)
+}
@@ -0,0 +1,154 @@
+package memo
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	quotaResourceBoards          = "boards"
+	quotaResourceMemosPerBoard   = "memos per board"
+	quotaResourceItemsPerMemo    = "items per memo"
+	quotaResourceAttachmentBytes = "attachment bytes"
+
+	// A limit of zero means no limit
+	quotaUnlimited = 0
+)
+
+var (
+	// defaultQuota applies to all users without override
+	defaultQuota = QuotaLimits{
+		Boards:          50,
+		MemosPerBoard:   200,
+		ItemsPerMemo:    500,
+		AttachmentBytes: 100 * 1024 * 1024,
+	}
+)
+
+// QuotaLimits are the maximum resources an user can own. A zero limit means
+// that the resource is unlimited. Attachment bytes are the total size of the
+// attachments of all the boards of the user
+type QuotaLimits struct {
+	Boards          int64 `json:"boards" bson:"boards"`
+	MemosPerBoard   int64 `json:"memosPerBoard" bson:"memosPerBoard"`
+	ItemsPerMemo    int64 `json:"itemsPerMemo" bson:"itemsPerMemo"`
+	AttachmentBytes int64 `json:"attachmentBytes" bson:"attachmentBytes"`
+}
+
+// QuotaOverride is set by an admin to change some limits of a specific user.
+// A nil limit falls back to the default quota
+type QuotaOverride struct {
+	UserID             primitive.ObjectID `json:"userId" bson:"_id"`
+	Boards             *int64             `json:"boards,omitempty" bson:"boards,omitempty"`
+	MemosPerBoard      *int64             `json:"memosPerBoard,omitempty" bson:"memosPerBoard,omitempty"`
+	ItemsPerMemo       *int64             `json:"itemsPerMemo,omitempty" bson:"itemsPerMemo,omitempty"`
+	AttachmentBytes    *int64             `json:"attachmentBytes,omitempty" bson:"attachmentBytes,omitempty"`
+	core.TrackedEntity `bson:",inline"`
+}
+
+// QuotaUsage is the consumption of a single resource against its limit
+type QuotaUsage struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+// BoardQuotaUsage is the consumption of the resources of a single board
+type BoardQuotaUsage struct {
+	BoardID      primitive.ObjectID `json:"boardId"`
+	Memos        QuotaUsage         `json:"memos"`
+	MaxMemoItems QuotaUsage         `json:"maxMemoItems"` // Items count of the biggest memo
+}
+
+// UserQuotaUsage is the consumption of all the resources of an user
+type UserQuotaUsage struct {
+	UserID          primitive.ObjectID `json:"userId"`
+	Limits          QuotaLimits        `json:"limits"`
+	Boards          QuotaUsage         `json:"boards"`
+	AttachmentBytes QuotaUsage         `json:"attachmentBytes"`
+	PerBoard        []BoardQuotaUsage  `json:"perBoard"`
+}
+
+// loadDefaultQuota overrides the default limits with the environment variables
+// if they are defined
+func loadDefaultQuota() {
+	loadLimit := func(envVarName string, limit *int64) {
+		value := os.Getenv(envVarName)
+		if value == "" {
+			return
+		}
+
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			memoLogger.Warn("[Quota] Invalid value <%s> for %s, keeping %d", value, envVarName, *limit)
+			return
+		}
+		*limit = parsed
+	}
+
+	loadLimit(utils.EnvVarMemoQuotaBoards, &defaultQuota.Boards)
+	loadLimit(utils.EnvVarMemoQuotaMemosPerBoard, &defaultQuota.MemosPerBoard)
+	loadLimit(utils.EnvVarMemoQuotaItemsPerMemo, &defaultQuota.ItemsPerMemo)
+	loadLimit(utils.EnvVarMemoQuotaAttachmentBytes, &defaultQuota.AttachmentBytes)
+}
+
+// apply returns the default limits with the overridden values
+func (qo *QuotaOverride) apply(limits QuotaLimits) QuotaLimits {
+	if qo.Boards != nil {
+		limits.Boards = *qo.Boards
+	}
+	if qo.MemosPerBoard != nil {
+		limits.MemosPerBoard = *qo.MemosPerBoard
+	}
+	if qo.ItemsPerMemo != nil {
+		limits.ItemsPerMemo = *qo.ItemsPerMemo
+	}
+	if qo.AttachmentBytes != nil {
+		limits.AttachmentBytes = *qo.AttachmentBytes
+	}
+
+	return limits
+}
+
+// validate rejects negative limits. Zero stays unlimited
+func (qo *QuotaOverride) validate() *core.ServiceMessage {
+	limits := map[string]*int64{
+		quotaResourceBoards:          qo.Boards,
+		quotaResourceMemosPerBoard:   qo.MemosPerBoard,
+		quotaResourceItemsPerMemo:    qo.ItemsPerMemo,
+		quotaResourceAttachmentBytes: qo.AttachmentBytes,
+	}
+	for resource, limit := range limits {
+		if limit != nil && *limit < 0 {
+			return newQuotaOverrideInvalid(fmt.Errorf("%s limit cannot be negative", resource))
+		}
+	}
+
+	return nil
+}
+
+// isExceededBy tells if adding some resources to the used ones goes beyond
+// the limit
+func (qu QuotaUsage) isExceededBy(added int64) bool {
+	return qu.Limit != quotaUnlimited && qu.Used+added > qu.Limit
+}
+
+// newQuotaExceeded details which quota is exceeded
+func newQuotaExceeded(resource string, limit int64) *core.ServiceMessage {
+	msg := *quotaExceeded
+	msg.Message = fmt.Sprintf("%s: %d %s maximum", quotaExceeded.Message, limit, resource)
+
+	return &msg
+}
+
+// newQuotaOverrideInvalid details why a quota override is invalid
+func newQuotaOverrideInvalid(err error) *core.ServiceMessage {
+	msg := *quotaOverrideInvalid
+	msg.Message = fmt.Sprintf("%s: %v", quotaOverrideInvalid.Message, err)
+
+	return &msg
+}
@@ -0,0 +1,199 @@
+package memo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestQuotaOverrideValidate(t *testing.T) {
+	negative, unlimited := int64(-1), int64(quotaUnlimited)
+
+	errMsg := (&QuotaOverride{Boards: &unlimited}).validate()
+	testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Zero limit should be valid: %v", errMsg)
+
+	errMsg = (&QuotaOverride{Boards: &unlimited, ItemsPerMemo: &negative}).validate()
+	testutils.Assert(t, testutils.CallFromTestFile, errMsg != nil && errMsg.Code == quotaOverrideInvalid.Code,
+		"Negative limit should be invalid: %v", errMsg)
+}
+
+func TestE2EQuota(t *testing.T) {
+	t.Parallel()
+
+	user, token := setupUser(t)
+	oneBoard, oneItem := int64(1), int64(1)
+	board := Board{BasicInfo: BasicInfo{Title: "Only board"}}
+	concurrentBoards := make(chan *Board, 5)
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+		close(concurrentBoards)
+		for concurrentBoard := range concurrentBoards {
+			deleteBoard(concurrentBoard.ID.Hex())
+		}
+		deleteQuotaOverride(user.ID)
+		deleteChangesByUserID(user.ID)
+	})
+
+	upsertQuotaOverride(QuotaOverride{UserID: user.ID, Boards: &oneBoard, ItemsPerMemo: &oneItem})
+
+	t.Run("FirstBoardIsAllowed", func(t *testing.T) {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "boards",
+			Method:             http.MethodPost,
+			Payload:            board,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		})
+		json.NewDecoder(rr.Body).Decode(&board)
+	})
+
+	t.Run("SecondBoardIsRejected", func(t *testing.T) {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "boards",
+			Method:             http.MethodPost,
+			Payload:            Board{BasicInfo: BasicInfo{Title: "One too many"}},
+			ExpectedHTTPStatus: http.StatusForbidden,
+			AuthToken:          token,
+		})
+
+		var msg core.ServiceMessage
+		json.NewDecoder(rr.Body).Decode(&msg)
+		testutils.Equals(t, testutils.CallFromTestFile, quotaExceeded.Code, msg.Code)
+	})
+
+	t.Run("TooManyItemsAreRejected", func(t *testing.T) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/memos", board.ID.Hex()),
+			Method:             http.MethodPost,
+			Payload:            Memo{Items: []Item{{Text: "One"}, {Text: "Two"}}},
+			ExpectedHTTPStatus: http.StatusForbidden,
+			AuthToken:          token,
+		})
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("quotas/%s/usage", user.ID.Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		})
+
+		var usage UserQuotaUsage
+		json.NewDecoder(rr.Body).Decode(&usage)
+		testutils.Equals(t, testutils.CallFromTestFile, QuotaUsage{Used: 1, Limit: 1}, usage.Boards)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(usage.PerBoard))
+		testutils.Equals(t, testutils.CallFromTestFile, defaultQuota.MemosPerBoard, usage.PerBoard[0].Memos.Limit)
+	})
+
+	t.Run("OverridesAreAdminOnly", func(t *testing.T) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("quotas/%s", user.ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            QuotaOverride{Boards: &oneBoard},
+			ExpectedHTTPStatus: http.StatusUnauthorized,
+			AuthToken:          token,
+		})
+	})
+
+	t.Run("ConcurrentBoardsRespectLimit", func(t *testing.T) {
+		threeBoards := int64(3)
+		upsertQuotaOverride(QuotaOverride{UserID: user.ID, Boards: &threeBoards})
+
+		var wg sync.WaitGroup
+		for i := 0; i < cap(concurrentBoards); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if newBoard, errMsg := createBoard(Board{TrackedEntity: trackedBy(user.ID)}); errMsg == nil {
+					concurrentBoards <- newBoard
+				}
+			}()
+		}
+		wg.Wait()
+
+		testutils.Equals(t, testutils.CallFromTestFile, 2, len(concurrentBoards))
+	})
+
+	t.Run("ConcurrentItemsUpdatesAreCounted", func(t *testing.T) {
+		memo, errMsg := createMemo(board.ID.Hex(), Memo{Items: []Item{{Text: "First"}}, TrackedEntity: trackedBy(user.ID)})
+		testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Cannot create memo: %v", errMsg)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				toUpdateMemo := Memo{Items: []Item{memo.Items[0], {Text: fmt.Sprintf("Item %d", i)}}}
+				toUpdateMemo.UpdatedBy = user.ID
+				updateMemo(board.ID.Hex(), memo.ID.Hex(), toUpdateMemo)
+			}(i)
+		}
+		wg.Wait()
+
+		updatedMemo, _ := findMemoByID(board.ID.Hex(), memo.ID.Hex())
+		var usage struct {
+			Count int64 `bson:"count"`
+		}
+		dbQuotaUsageCollection.FindOne(context.TODO(), bson.M{"_id": itemsUsageKey(board.ID, memo.ID)}).Decode(&usage)
+		testutils.Equals(t, testutils.CallFromTestFile, int64(countItems(updatedMemo.Items)), usage.Count)
+
+		// An update counted from outdated items is rejected
+		testutils.Equals(t, testutils.CallFromTestFile, memoItemsConflict, reserveItemsQuota(board.ID, memo.ID, 1, 3))
+
+		// A drifted counter is reset from the stored items
+		itemsCount := int64(countItems(updatedMemo.Items))
+		dbQuotaUsageCollection.UpdateOne(context.TODO(), bson.M{"_id": itemsUsageKey(board.ID, memo.ID)}, bson.M{"$set": bson.M{"count": 42}})
+		errMsg = reserveItemsQuota(board.ID, memo.ID, itemsCount, itemsCount+1)
+		testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Drifted counter should be reset: %v", errMsg)
+		dbQuotaUsageCollection.FindOne(context.TODO(), bson.M{"_id": itemsUsageKey(board.ID, memo.ID)}).Decode(&usage)
+		testutils.Equals(t, testutils.CallFromTestFile, itemsCount+1, usage.Count)
+	})
+
+	t.Run("AttachmentBytesRespectLimit", func(t *testing.T) {
+		tenBytes := int64(10)
+		upsertQuotaOverride(QuotaOverride{UserID: user.ID, AttachmentBytes: &tenBytes})
+		memo, _ := createMemo(board.ID.Hex(), Memo{TrackedEntity: trackedBy(user.ID)})
+
+		uploadAttachment := func(t *testing.T, content string, expectedStatus int) *httptest.ResponseRecorder {
+			path := fmt.Sprintf("/%s/boards/%s/memos/%s/attachments?name=notes.txt", core.APIv1, board.ID.Hex(), memo.ID.Hex())
+			req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(content))
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			req.Header.Set("Content-Type", "text/plain")
+
+			rr := apiTester.ServeReq(req)
+			testutils.CheckHTTPStatus(t, testutils.CallFromTestFile, rr, expectedStatus)
+			return rr
+		}
+		findAttachmentBytesUsage := func(t *testing.T) QuotaUsage {
+			usage, errMsg := findQuotaUsage(user.ID.Hex())
+			testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Cannot find usage: %v", errMsg)
+			return usage.AttachmentBytes
+		}
+
+		var attachment Attachment
+		json.NewDecoder(uploadAttachment(t, "8 bytes!", http.StatusOK).Body).Decode(&attachment)
+		testutils.Equals(t, testutils.CallFromTestFile, int64(8), attachment.Size)
+		testutils.Equals(t, testutils.CallFromTestFile, QuotaUsage{Used: 8, Limit: 10}, findAttachmentBytesUsage(t))
+
+		var msg core.ServiceMessage
+		json.NewDecoder(uploadAttachment(t, "Over", http.StatusForbidden).Body).Decode(&msg)
+		testutils.Equals(t, testutils.CallFromTestFile, quotaExceeded.Code, msg.Code)
+
+		// Deleting the memo releases its attachments
+		deleteMemo(board.ID.Hex(), memo.ID.Hex(), user.ID.Hex())
+		testutils.Equals(t, testutils.CallFromTestFile, QuotaUsage{Used: 0, Limit: 10}, findAttachmentBytesUsage(t))
+		uploadAttachment(t, "Over", http.StatusNotFound)
+	})
+}
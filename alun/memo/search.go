@@ -0,0 +1,338 @@
+package memo
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 100
+
+	// Matches are wrapped with these markers in the highlighted fragments
+	searchHighlightStart = "<mark>"
+	searchHighlightEnd   = "</mark>"
+	// Maximum number of characters around the first match of a fragment
+	searchFragmentRadius = 40
+
+	// Weights of the fields when ranking the results
+	searchWeightTitle       = 3.0
+	searchWeightItem        = 2.0
+	searchWeightDescription = 1.0
+)
+
+// SearchHighlight is a field fragment where the matches are highlighted
+type SearchHighlight struct {
+	Field    string `json:"field"`
+	Fragment string `json:"fragment"`
+}
+
+// SearchResult is a board, a memo or an item matching a search query
+type SearchResult struct {
+	EntityType string             `json:"entityType"`
+	BoardID    primitive.ObjectID `json:"boardId"`
+	MemoID     primitive.ObjectID `json:"memoId,omitempty"`
+	ItemID     primitive.ObjectID `json:"itemId,omitempty"`
+	Title      string             `json:"title"`
+	Score      float64            `json:"score"`
+	Highlights []SearchHighlight  `json:"highlights"`
+}
+
+// SearchResults is a page of ranked search results
+type SearchResults struct {
+	Results []SearchResult `json:"results"`
+	Total   int            `json:"total"`
+	Page    int            `json:"page"`
+	Limit   int            `json:"limit"`
+}
+
+// searcher ranks the boards, memos and items matching a query among the
+// provided boards
+type searcher interface {
+	search(boardIDs []primitive.ObjectID, query string) ([]SearchResult, error)
+}
+
+// scoredBoard is a board matched by a MongoDB text search with its score
+type scoredBoard struct {
+	Board     `bson:",inline"`
+	TextScore float64 `bson:"textScore"`
+}
+
+// scoredMemo is a memo matched by a MongoDB text search with its score
+type scoredMemo struct {
+	Memo      `bson:",inline"`
+	TextScore float64 `bson:"textScore"`
+}
+
+// isSearchWordRune tells if a rune is part of a word
+func isSearchWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// tokenizeSearch splits a text in lower-cased words
+func tokenizeSearch(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isSearchWordRune(r)
+	})
+}
+
+// isMatchingWord checks if a word starts with one of the terms. Prefix matching
+// roughly handles plurals for the in-memory search and the highlights but it
+// does not agree with the MongoDB text search stemming, so it must not decide
+// which text search results are kept
+func isMatchingWord(word string, terms []string) bool {
+	lowerWord := strings.ToLower(word)
+	for _, term := range terms {
+		if strings.HasPrefix(lowerWord, term) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchTerms scores a text against the search terms: each matching word
+// counts as one match
+func matchTerms(text string, terms []string) float64 {
+	score := 0.0
+	for _, word := range tokenizeSearch(text) {
+		if isMatchingWord(word, terms) {
+			score++
+		}
+	}
+
+	return score
+}
+
+// highlightTerms returns a fragment of the text around the first match with
+// all matching words wrapped by the highlight markers. The text is HTML escaped
+// so that only the markers are markup. An empty string is returned if nothing
+// matches
+func highlightTerms(text string, terms []string) string {
+	var builder strings.Builder
+	firstMatch, last, start := -1, 0, -1
+
+	highlightWord := func(end int) {
+		if isMatchingWord(text[start:end], terms) {
+			builder.WriteString(html.EscapeString(text[last:start]))
+			if firstMatch < 0 {
+				firstMatch = builder.Len()
+			}
+			builder.WriteString(searchHighlightStart)
+			builder.WriteString(html.EscapeString(text[start:end]))
+			builder.WriteString(searchHighlightEnd)
+			last = end
+		}
+		start = -1
+	}
+
+	for idx, r := range text {
+		if isSearchWordRune(r) && start < 0 {
+			start = idx
+		} else if !isSearchWordRune(r) && start >= 0 {
+			highlightWord(idx)
+		}
+	}
+	if start >= 0 {
+		highlightWord(len(text))
+	}
+
+	if firstMatch < 0 {
+		return ""
+	}
+	builder.WriteString(html.EscapeString(text[last:]))
+
+	return trimFragment(builder.String(), firstMatch)
+}
+
+// trimFragment keeps about searchFragmentRadius characters before the first
+// match and twice after. Text is only cut on spaces
+func trimFragment(text string, firstMatch int) string {
+	fragmentStart := firstMatch - searchFragmentRadius
+	if fragmentStart <= 0 {
+		fragmentStart = 0
+	} else {
+		for fragmentStart < firstMatch && text[fragmentStart] != ' ' {
+			fragmentStart++
+		}
+	}
+
+	fragmentEnd := firstMatch + 2*searchFragmentRadius
+	if fragmentEnd >= len(text) {
+		fragmentEnd = len(text)
+	} else {
+		for fragmentEnd < len(text) && text[fragmentEnd] != ' ' {
+			fragmentEnd++
+		}
+	}
+
+	fragment := strings.TrimSpace(text[fragmentStart:fragmentEnd])
+	if fragmentStart > 0 {
+		fragment = "..." + fragment
+	}
+	if fragmentEnd < len(text) {
+		fragment = fragment + "..."
+	}
+
+	return fragment
+}
+
+// highlightField adds the field highlight to a result if it matches
+func (sr *SearchResult) highlightField(field string, text string, terms []string) {
+	if fragment := highlightTerms(text, terms); fragment != "" {
+		sr.Highlights = append(sr.Highlights, SearchHighlight{Field: field, Fragment: fragment})
+	}
+}
+
+// scoreField adds the field score and highlight to a result if it matches
+func (sr *SearchResult) scoreField(field string, text string, weight float64, terms []string) {
+	score := matchTerms(text, terms)
+	if score == 0 {
+		return
+	}
+
+	sr.Score += score * weight
+	sr.Highlights = append(sr.Highlights, SearchHighlight{
+		Field:    field,
+		Fragment: highlightTerms(text, terms),
+	})
+}
+
+// rankSearchResults scores the candidates against the query terms and returns
+// the matching boards, memos and items by decreasing score. It is the ranking
+// of the in-memory search
+func rankSearchResults(query string, boards []Board, memos []Memo) []SearchResult {
+	terms := tokenizeSearch(query)
+	results := make([]SearchResult, 0)
+
+	keepIfMatch := func(result SearchResult) {
+		if result.Score > 0 {
+			results = append(results, result)
+		}
+	}
+
+	for _, board := range boards {
+		result := SearchResult{EntityType: changeEntityBoard, BoardID: board.ID, Title: board.Title}
+		result.scoreField("title", board.Title, searchWeightTitle, terms)
+		result.scoreField("description", board.Description, searchWeightDescription, terms)
+		keepIfMatch(result)
+	}
+
+	for _, memo := range memos {
+		result := SearchResult{EntityType: changeEntityMemo, BoardID: memo.BoardID, MemoID: memo.ID, Title: memo.Title}
+		result.scoreField("title", memo.Title, searchWeightTitle, terms)
+		result.scoreField("description", memo.Description, searchWeightDescription, terms)
+		keepIfMatch(result)
+
+		walkItems(memo.Items, func(item *Item, parentID primitive.ObjectID) {
+			itemResult := SearchResult{EntityType: changeEntityItem, BoardID: memo.BoardID,
+				MemoID: memo.ID, ItemID: item.ID, Title: memo.Title}
+			itemResult.scoreField("text", item.Text, searchWeightItem, terms)
+			keepIfMatch(itemResult)
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results
+}
+
+// rankTextSearchResults orders the boards and memos matched by a MongoDB text
+// search by decreasing text score. All of them are kept, even if the
+// highlights find nothing as they do not stem the words. The items of a memo
+// which can be highlighted are listed after it with the memo score, as the
+// text score is per document
+func rankTextSearchResults(query string, boards []scoredBoard, memos []scoredMemo) []SearchResult {
+	terms := tokenizeSearch(query)
+	results := make([]SearchResult, 0)
+
+	for _, board := range boards {
+		result := SearchResult{EntityType: changeEntityBoard, BoardID: board.ID, Title: board.Title, Score: board.TextScore}
+		result.highlightField("title", board.Title, terms)
+		result.highlightField("description", board.Description, terms)
+		results = append(results, result)
+	}
+
+	for _, memo := range memos {
+		result := SearchResult{EntityType: changeEntityMemo, BoardID: memo.BoardID, MemoID: memo.ID, Title: memo.Title, Score: memo.TextScore}
+		result.highlightField("title", memo.Title, terms)
+		result.highlightField("description", memo.Description, terms)
+		results = append(results, result)
+
+		walkItems(memo.Items, func(item *Item, parentID primitive.ObjectID) {
+			itemResult := SearchResult{EntityType: changeEntityItem, BoardID: memo.BoardID,
+				MemoID: memo.ID, ItemID: item.ID, Title: memo.Title, Score: memo.TextScore}
+			itemResult.highlightField("text", item.Text, terms)
+			if len(itemResult.Highlights) > 0 {
+				results = append(results, itemResult)
+			}
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results
+}
+
+// paginateSearchResults slices the ranked results for the requested page
+// which starts at 1
+func paginateSearchResults(results []SearchResult, page int, limit int) SearchResults {
+	start, end := paginationBounds(len(results), page, limit)
+
+	return SearchResults{
+		Results: results[start:end],
+		Total:   len(results),
+		Page:    page,
+		Limit:   limit,
+	}
+}
+
+// paginationBounds returns the [start, end) indexes of a page, starting at 1,
+// in a list of total elements. Pages after the end are empty. The page is
+// compared before computing the start so that a huge page cannot overflow
+func paginationBounds(total int, page int, limit int) (int, int) {
+	if page < 1 || limit < 1 || page-1 >= (total+limit-1)/limit {
+		return total, total
+	}
+	start := (page - 1) * limit
+	end := start + limit
+	if end > total {
+		end = total
+	}
+
+	return start, end
+}
+
+// memorySearcher matches all the documents of the boards in Go. It does not
+// require any index and is used for tests
+type memorySearcher struct{}
+
+func (ms memorySearcher) search(boardIDs []primitive.ObjectID, query string) ([]SearchResult, error) {
+	boards, memos, err := findSearchableDocuments(boardIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return rankSearchResults(query, boards, memos), nil
+}
+
+// mongoSearcher relies on the MongoDB text indexes to only load the matching
+// documents, ranked by their text score
+type mongoSearcher struct{}
+
+func (ms mongoSearcher) search(boardIDs []primitive.ObjectID, query string) ([]SearchResult, error) {
+	boards, memos, err := findTextScoredDocuments(boardIDs, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return rankTextSearchResults(query, boards, memos), nil
+}
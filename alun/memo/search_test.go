@@ -0,0 +1,147 @@
+package memo
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHighlightTerms(t *testing.T) {
+	terms := tokenizeSearch("Plumber")
+
+	testutils.Equals(t, testutils.CallFromTestFile,
+		"Call the <mark>plumbers</mark> today", highlightTerms("Call the plumbers today", terms))
+	testutils.Equals(t, testutils.CallFromTestFile,
+		"", highlightTerms("Call the electrician", terms))
+
+	long := "This is a very long description which talks about many things before the plumber is mentioned at the end"
+	fragment := highlightTerms(long, terms)
+	testutils.Equals(t, testutils.CallFromTestFile,
+		"...talks about many things before the <mark>plumber</mark> is mentioned at the end", fragment)
+
+	testutils.Equals(t, testutils.CallFromTestFile,
+		"&lt;b&gt;Call&lt;/b&gt; the <mark>plumber</mark> &amp; co", highlightTerms("<b>Call</b> the plumber & co", terms))
+}
+
+func TestPaginationBounds(t *testing.T) {
+	start, end := paginationBounds(45, 3, 20)
+	testutils.Equals(t, testutils.CallFromTestFile, 40, start)
+	testutils.Equals(t, testutils.CallFromTestFile, 45, end)
+
+	start, end = paginationBounds(45, 4, 20)
+	testutils.Equals(t, testutils.CallFromTestFile, 45, start)
+	testutils.Equals(t, testutils.CallFromTestFile, 45, end)
+
+	start, end = paginationBounds(45, math.MaxInt64, 20)
+	testutils.Equals(t, testutils.CallFromTestFile, 45, start)
+	testutils.Equals(t, testutils.CallFromTestFile, 45, end)
+}
+
+func TestRankSearchResults(t *testing.T) {
+	boardID := primitive.NewObjectID()
+	boards := []Board{
+		{ID: boardID, BasicInfo: BasicInfo{Title: "House", Description: "Call the plumber"}},
+	}
+	memos := []Memo{
+		{
+			ID:        primitive.NewObjectID(),
+			BoardID:   boardID,
+			BasicInfo: BasicInfo{Title: "Plumber", Description: "Plumber phone number"},
+			Items:     []Item{{ID: primitive.NewObjectID(), Text: "Ask the plumber for a quote"}, {Text: "Buy paint"}},
+		},
+	}
+
+	results := rankSearchResults("plumber", boards, memos)
+
+	// memo title+description, item text then board description
+	testutils.Equals(t, testutils.CallFromTestFile, 3, len(results))
+	testutils.Equals(t, testutils.CallFromTestFile, changeEntityMemo, results[0].EntityType)
+	testutils.Equals(t, testutils.CallFromTestFile, 2, len(results[0].Highlights))
+	testutils.Equals(t, testutils.CallFromTestFile, changeEntityItem, results[1].EntityType)
+	testutils.Equals(t, testutils.CallFromTestFile, memos[0].Items[0].ID, results[1].ItemID)
+	testutils.Equals(t, testutils.CallFromTestFile, changeEntityBoard, results[2].EntityType)
+
+	page := paginateSearchResults(results, 2, 2)
+	testutils.Equals(t, testutils.CallFromTestFile, 3, page.Total)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(page.Results))
+	testutils.Equals(t, testutils.CallFromTestFile, changeEntityBoard, page.Results[0].EntityType)
+}
+
+func TestRankTextSearchResults(t *testing.T) {
+	boardID := primitive.NewObjectID()
+	boards := []scoredBoard{
+		{Board: Board{ID: boardID, BasicInfo: BasicInfo{Title: "House", Description: "Call the plumber"}}, TextScore: 0.75},
+	}
+	memos := []scoredMemo{
+		{
+			Memo: Memo{
+				ID:        primitive.NewObjectID(),
+				BoardID:   boardID,
+				BasicInfo: BasicInfo{Title: "Plumbers"},
+				Items:     []Item{{ID: primitive.NewObjectID(), Text: "Ask the plumbers for a quote"}, {Text: "Buy paint"}},
+			},
+			TextScore: 2.5,
+		},
+	}
+
+	// "plumber" is only found by MongoDB stemming: text scores rank and
+	// nothing is dropped
+	results := rankTextSearchResults("plumbers", boards, memos)
+
+	testutils.Equals(t, testutils.CallFromTestFile, 3, len(results))
+	testutils.Equals(t, testutils.CallFromTestFile, changeEntityMemo, results[0].EntityType)
+	testutils.Equals(t, testutils.CallFromTestFile, 2.5, results[0].Score)
+	testutils.Equals(t, testutils.CallFromTestFile, changeEntityItem, results[1].EntityType)
+	testutils.Equals(t, testutils.CallFromTestFile, memos[0].Items[0].ID, results[1].ItemID)
+	testutils.Equals(t, testutils.CallFromTestFile, changeEntityBoard, results[2].EntityType)
+	testutils.Equals(t, testutils.CallFromTestFile, 0.75, results[2].Score)
+	testutils.Equals(t, testutils.CallFromTestFile, 0, len(results[2].Highlights))
+}
+
+func TestE2ESearch(t *testing.T) {
+	t.Parallel()
+
+	user, token := setupUser(t)
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "House"},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	createMemo(board.ID.Hex(), Memo{
+		BasicInfo: BasicInfo{Title: "Works"},
+		Items:     []Item{{Text: "Call the plumber"}},
+	})
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+		deleteChangesByUserID(user.ID)
+	})
+
+	t.Run("MissingQuery", func(t *testing.T) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "search?q=%20",
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		})
+	})
+
+	t.Run("FindItem", func(t *testing.T) {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "search?q=plumber",
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		})
+
+		var results SearchResults
+		json.NewDecoder(rr.Body).Decode(&results)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, results.Total)
+		testutils.Equals(t, testutils.CallFromTestFile, changeEntityItem, results.Results[0].EntityType)
+		testutils.Equals(t, testutils.CallFromTestFile, board.ID, results.Results[0].BoardID)
+	})
+}
@@ -0,0 +1,239 @@
+package memo
+
+import (
+	"net/http"
+
+	"github.com/Al-un/alun-api/alun/core"
+)
+
+// ----------------------------------------------------------------------------
+//	Memo management: Code 103xx
+// ----------------------------------------------------------------------------
+
+var syncCursorInvalid = &core.ServiceMessage{
+	Code:       10300,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Sync cursor is invalid",
+}
+
+var searchQueryMissing = &core.ServiceMessage{
+	Code:       10301,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Search query is missing",
+}
+
+var quotaExceeded = &core.ServiceMessage{
+	Code:       10302,
+	HTTPStatus: http.StatusForbidden,
+	Message:    "Quota exceeded",
+}
+
+var completionRangeInvalid = &core.ServiceMessage{
+	Code:       10303,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Completion history range is invalid",
+}
+
+var boardNotFound = &core.ServiceMessage{
+	Code:       10304,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Board not found",
+}
+
+var columnNotFound = &core.ServiceMessage{
+	Code:       10305,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Column not found",
+}
+
+var columnOrderInvalid = &core.ServiceMessage{
+	Code:       10306,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Column order must contain each column once",
+}
+
+var columnLastDeletion = &core.ServiceMessage{
+	Code:       10307,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "A board must keep at least one column",
+}
+
+var columnFallbackInvalid = &core.ServiceMessage{
+	Code:       10308,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Fallback column is invalid",
+}
+
+var columnTitleMissing = &core.ServiceMessage{
+	Code:       10309,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Column title is missing",
+}
+
+var itemsTooDeep = &core.ServiceMessage{
+	Code:       10310,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Items are nested too deeply",
+}
+
+var itemPathInvalid = &core.ServiceMessage{
+	Code:       10311,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Item path is invalid",
+}
+
+var itemNotFound = &core.ServiceMessage{
+	Code:       10312,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Item not found",
+}
+
+var itemAssigneeInvalid = &core.ServiceMessage{
+	Code:       10313,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Item assignee cannot access the board",
+}
+
+var fieldNotFound = &core.ServiceMessage{
+	Code:       10314,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Field not found",
+}
+
+var fieldInvalid = &core.ServiceMessage{
+	Code:       10315,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Field is invalid",
+}
+
+var fieldValueInvalid = &core.ServiceMessage{
+	Code:       10316,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Field value is invalid",
+}
+
+var fieldQueryInvalid = &core.ServiceMessage{
+	Code:       10317,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Field filter or sort is invalid",
+}
+
+var smartListNotFound = &core.ServiceMessage{
+	Code:       10318,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Smart list not found",
+}
+
+var smartListInvalid = &core.ServiceMessage{
+	Code:       10319,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Smart list is invalid",
+}
+
+var quickAddTextMissing = &core.ServiceMessage{
+	Code:       10320,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Quick add text has no item text",
+}
+
+var watchUnsubscribeURLMissing = &core.ServiceMessage{
+	Code:       10321,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Unsubscribe URL is missing",
+}
+
+var watchNotFound = &core.ServiceMessage{
+	Code:       10322,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Watch not found",
+}
+
+var mentionInvalid = &core.ServiceMessage{
+	Code:       10323,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Mention is invalid",
+}
+
+var timerAlreadyRunning = &core.ServiceMessage{
+	Code:       10324,
+	HTTPStatus: http.StatusConflict,
+	Message:    "Another timer is already running",
+}
+
+var timerNotRunning = &core.ServiceMessage{
+	Code:       10325,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "No timer is running on this item",
+}
+
+var timeEntryInvalid = &core.ServiceMessage{
+	Code:       10326,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Time entry is invalid",
+}
+
+var timeEntryNotFound = &core.ServiceMessage{
+	Code:       10327,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Time entry not found",
+}
+
+var timeRangeInvalid = &core.ServiceMessage{
+	Code:       10328,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Time entries range is invalid",
+}
+
+var quotaOverrideInvalid = &core.ServiceMessage{
+	Code:       10329,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Quota override is invalid",
+}
+
+var boardMembersForbidden = &core.ServiceMessage{
+	Code:       10330,
+	HTTPStatus: http.StatusForbidden,
+	Message:    "Only the owner of a board can change its members",
+}
+
+var watchUnavailable = &core.ServiceMessage{
+	Code:       10331,
+	HTTPStatus: http.StatusServiceUnavailable,
+	Message:    "Watching a board requires the users directory",
+}
+
+var memoItemsConflict = &core.ServiceMessage{
+	Code:       10332,
+	HTTPStatus: http.StatusConflict,
+	Message:    "Memo items have been changed concurrently, reload the memo",
+}
+
+var boardDeletionForbidden = &core.ServiceMessage{
+	Code:       10333,
+	HTTPStatus: http.StatusForbidden,
+	Message:    "Only the owner of a board can delete it",
+}
+
+var memoNotFound = &core.ServiceMessage{
+	Code:       10334,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Memo not found",
+}
+
+var attachmentNotFound = &core.ServiceMessage{
+	Code:       10335,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Attachment not found",
+}
+
+var attachmentInvalid = &core.ServiceMessage{
+	Code:       10336,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Attachment is invalid",
+}
+
+var attachmentTooLarge = &core.ServiceMessage{
+	Code:       10337,
+	HTTPStatus: http.StatusRequestEntityTooLarge,
+	Message:    "Attachment is too large",
+}
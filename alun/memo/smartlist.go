@@ -0,0 +1,296 @@
+package memo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	smartDueOverdue   = "overdue"
+	smartDueToday     = "today"
+	smartDueThisWeek  = "thisWeek"
+	smartDueNext7Days = "next7Days"
+	smartDueNone      = "none"
+
+	smartSortDueDate = "dueDate"
+	smartSortText    = "text"
+	smartSortBoard   = "board"
+
+	// smartAssigneeMe is replaced by the user evaluating the smart list
+	smartAssigneeMe = "me"
+)
+
+// SmartList is a named query of an user over the items of all the boards the
+// user can access
+type SmartList struct {
+	ID                 primitive.ObjectID `json:"id" bson:"_id"`
+	Name               string             `json:"name" bson:"name"`
+	Query              SmartQuery         `json:"query" bson:"query"`
+	core.TrackedEntity `bson:",inline"`
+}
+
+// SmartQuery are the conditions an item must all fulfill to be part of a smart
+// list. Dates are relative to the evaluation so that a saved query stays
+// relevant over time
+type SmartQuery struct {
+	BoardIDs   []primitive.ObjectID  `json:"boardIds,omitempty" bson:"boardIds,omitempty"` // All the accessible boards if empty
+	Text       string                `json:"text,omitempty" bson:"text,omitempty"`
+	IsFinished *bool                 `json:"isFinished,omitempty" bson:"isFinished,omitempty"`
+	Assignee   string                `json:"assignee,omitempty" bson:"assignee,omitempty"` // An user ID or "me"
+	Due        string                `json:"due,omitempty" bson:"due,omitempty"`
+	Labels     []string              `json:"labels,omitempty" bson:"labels,omitempty"` // Items must have all the labels
+	Fields     []SmartFieldCondition `json:"fields,omitempty" bson:"fields,omitempty"`
+	Sort       string                `json:"sort,omitempty" bson:"sort,omitempty"` // Prefixed by "-" for a descending sort
+}
+
+// SmartFieldCondition is a condition on a custom field. As fields belong to a
+// board, the field is found by name in each board. Boards without this field
+// have no matching item
+type SmartFieldCondition struct {
+	Name     string `json:"name" bson:"name"`
+	Operator string `json:"operator,omitempty" bson:"operator,omitempty"`
+	Value    string `json:"value" bson:"value"`
+}
+
+// SmartListResults is a page of the items of a smart list
+type SmartListResults struct {
+	Items []LocatedItem `json:"items"`
+	Total int           `json:"total"`
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+}
+
+// ----------------------------------------------------------------------------
+//	Smart list definition
+// ----------------------------------------------------------------------------
+
+// prepare trims the smart list and checks that its query is valid
+func (sl *SmartList) prepare() error {
+	sl.Name = strings.TrimSpace(sl.Name)
+	if sl.Name == "" {
+		return errors.New("Name is missing")
+	}
+
+	return sl.Query.validate()
+}
+
+func (q *SmartQuery) validate() error {
+	q.Text = strings.TrimSpace(q.Text)
+
+	switch q.Due {
+	case "", smartDueOverdue, smartDueToday, smartDueThisWeek, smartDueNext7Days, smartDueNone:
+	default:
+		return errors.New("Due must be overdue, today, thisWeek, next7Days or none")
+	}
+
+	switch strings.TrimPrefix(q.Sort, "-") {
+	case "", smartSortDueDate, smartSortText, smartSortBoard:
+	default:
+		return errors.New("Sort must be dueDate, text or board")
+	}
+
+	if q.Assignee != "" && q.Assignee != smartAssigneeMe {
+		if _, err := primitive.ObjectIDFromHex(q.Assignee); err != nil {
+			return errors.New("Assignee must be an user ID or me")
+		}
+	}
+
+	for _, condition := range q.Fields {
+		if strings.TrimSpace(condition.Name) == "" {
+			return errors.New("Field condition name is missing")
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+//	Smart list evaluation
+// ----------------------------------------------------------------------------
+
+// assigneeID resolves the assignee condition for the user evaluating the query
+func (q *SmartQuery) assigneeID(userID primitive.ObjectID) primitive.ObjectID {
+	if q.Assignee == smartAssigneeMe {
+		return userID
+	}
+
+	assigneeID, _ := primitive.ObjectIDFromHex(q.Assignee)
+	return assigneeID
+}
+
+// dueRange returns the [from, to) range of the due condition. Days are in the
+// timezone of now and weeks start on Monday
+func dueRange(due string, now time.Time) (time.Time, time.Time) {
+	today := truncateToDay(now)
+
+	switch due {
+	case smartDueToday:
+		return today, today.AddDate(0, 0, 1)
+	case smartDueThisWeek:
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return monday, monday.AddDate(0, 0, 7)
+	case smartDueNext7Days:
+		return now, now.AddDate(0, 0, 7)
+	}
+
+	return time.Time{}, now
+}
+
+// matcher builds the item condition of the query for a board. It returns nil
+// if no item of the board can match, such as a board without the fields of
+// the query
+func (q *SmartQuery) matcher(board *Board, userID primitive.ObjectID, now time.Time) func(item Item) bool {
+	filters := make([]FieldFilter, 0, len(q.Fields))
+	for _, condition := range q.Fields {
+		var field *Field
+		for idx := range board.Fields {
+			if strings.EqualFold(board.Fields[idx].Name, strings.TrimSpace(condition.Name)) {
+				field = &board.Fields[idx]
+			}
+		}
+		if field == nil {
+			return nil
+		}
+
+		filter, err := newFieldFilter(*field, condition.Operator, condition.Value)
+		if err != nil {
+			return nil
+		}
+		filters = append(filters, filter)
+	}
+
+	assigneeID := q.assigneeID(userID)
+	text := strings.ToLower(q.Text)
+	from, to := dueRange(q.Due, now)
+
+	return func(item Item) bool {
+		if q.IsFinished != nil && item.IsFinished != *q.IsFinished {
+			return false
+		}
+		if q.Assignee != "" && item.AssigneeID != assigneeID {
+			return false
+		}
+		if text != "" && !strings.Contains(strings.ToLower(item.Text), text) {
+			return false
+		}
+		if !hasLabels(item, q.Labels) {
+			return false
+		}
+
+		switch q.Due {
+		case "":
+		case smartDueNone:
+			if !item.DueDate.IsZero() {
+				return false
+			}
+		case smartDueOverdue:
+			if item.DueDate.IsZero() || item.IsFinished || !item.DueDate.Before(to) {
+				return false
+			}
+		default:
+			if item.DueDate.IsZero() || item.DueDate.Before(from) || !item.DueDate.Before(to) {
+				return false
+			}
+		}
+
+		for _, filter := range filters {
+			if !filter.matches(item) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// hasLabels checks that an item has all the labels, ignoring the case
+func hasLabels(item Item, labels []string) bool {
+	for _, label := range labels {
+		found := false
+		for _, itemLabel := range item.Labels {
+			found = found || strings.EqualFold(itemLabel, label)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// evaluate lists the matching items of the provided memos, sorted according
+// to the query
+func (q *SmartQuery) evaluate(boards []Board, memos []Memo, userID primitive.ObjectID, now time.Time) []LocatedItem {
+	matchers := make(map[primitive.ObjectID]func(item Item) bool)
+	boardsByID := make(map[primitive.ObjectID]Board)
+	for idx := range boards {
+		boardsByID[boards[idx].ID] = boards[idx]
+		matchers[boards[idx].ID] = q.matcher(&boards[idx], userID, now)
+	}
+
+	items := make([]LocatedItem, 0)
+	for _, memo := range memos {
+		if matcher := matchers[memo.BoardID]; matcher != nil {
+			items = append(items, locateItems(boardsByID[memo.BoardID], memo, matcher)...)
+		}
+	}
+
+	q.sortItems(items)
+	return items
+}
+
+// sortItems sorts the matching items. Items without due date are last when
+// sorting by due date, which is the default sort
+func (q *SmartQuery) sortItems(items []LocatedItem) {
+	descending := strings.HasPrefix(q.Sort, "-")
+	sortBy := strings.TrimPrefix(q.Sort, "-")
+
+	sort.SliceStable(items, func(i, j int) bool {
+		first, second := items[i], items[j]
+		if descending {
+			first, second = second, first
+		}
+
+		switch sortBy {
+		case smartSortText:
+			return strings.ToLower(first.Item.Text) < strings.ToLower(second.Item.Text)
+		case smartSortBoard:
+			if first.BoardTitle != second.BoardTitle {
+				return first.BoardTitle < second.BoardTitle
+			}
+			return first.MemoTitle < second.MemoTitle
+		}
+
+		if items[i].Item.DueDate.IsZero() || items[j].Item.DueDate.IsZero() {
+			return items[j].Item.DueDate.IsZero() && !items[i].Item.DueDate.IsZero()
+		}
+		return first.Item.DueDate.Before(second.Item.DueDate)
+	})
+}
+
+// paginateSmartListItems slices the items for the requested page which starts
+// at 1
+func paginateSmartListItems(items []LocatedItem, page int, limit int) SmartListResults {
+	start, end := paginationBounds(len(items), page, limit)
+
+	return SmartListResults{
+		Items: items[start:end],
+		Total: len(items),
+		Page:  page,
+		Limit: limit,
+	}
+}
+
+// newSmartListInvalid details why a smart list is invalid
+func newSmartListInvalid(err error) *core.ServiceMessage {
+	msg := *smartListInvalid
+	msg.Message = fmt.Sprintf("%s: %v", smartListInvalid.Message, err)
+
+	return &msg
+}
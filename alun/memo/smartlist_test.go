@@ -0,0 +1,140 @@
+package memo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSmartQueryEvaluate(t *testing.T) {
+	user := primitive.NewObjectID()
+	now := time.Date(2020, time.March, 11, 12, 0, 0, 0, time.UTC) // Wednesday
+
+	work := newFieldsBoard()
+	work.ID, work.Title = primitive.NewObjectID(), "Work"
+	home := Board{ID: primitive.NewObjectID(), BasicInfo: BasicInfo{Title: "Home"}}
+	boards := []Board{work, home}
+	memos := []Memo{
+		{BoardID: work.ID, BasicInfo: BasicInfo{Title: "Sprint"}, Items: []Item{
+			{Text: "Review", DueDate: now.AddDate(0, 0, 2), AssigneeID: user, Fields: []FieldValue{{FieldID: work.Fields[1].ID, Text: "High"}}},
+			{Text: "Deploy", DueDate: now.AddDate(0, 0, -1), AssigneeID: user, Fields: []FieldValue{{FieldID: work.Fields[1].ID, Text: "High"}}},
+			{Text: "Retro", DueDate: now.AddDate(0, 0, 1), Fields: []FieldValue{{FieldID: work.Fields[1].ID, Text: "High"}}},
+			{Text: "Release", DueDate: now.AddDate(0, 0, 7), AssigneeID: user},
+		}},
+		{BoardID: home.ID, BasicInfo: BasicInfo{Title: "Chores"}, Items: []Item{
+			{Text: "Laundry", DueDate: now.AddDate(0, 0, 1), AssigneeID: user},
+			{Text: "Dishes", DueDate: now, IsFinished: true},
+		}},
+	}
+
+	unfinished := false
+	query := SmartQuery{IsFinished: &unfinished, Due: smartDueThisWeek}
+	testutils.Ok(t, testutils.CallFromTestFile, query.validate())
+	items := query.evaluate(boards, memos, user, now)
+	testutils.Equals(t, testutils.CallFromTestFile, 4, len(items))
+	testutils.Equals(t, testutils.CallFromTestFile, "Deploy", items[0].Item.Text)
+	testutils.Equals(t, testutils.CallFromTestFile, "Review", items[3].Item.Text)
+
+	query = SmartQuery{
+		Assignee: smartAssigneeMe,
+		Fields:   []SmartFieldCondition{{Name: "priority", Value: "High"}},
+		Sort:     "-" + smartSortText,
+	}
+	testutils.Ok(t, testutils.CallFromTestFile, query.validate())
+	items = query.evaluate(boards, memos, user, now)
+	testutils.Equals(t, testutils.CallFromTestFile, 2, len(items))
+	testutils.Equals(t, testutils.CallFromTestFile, "Review", items[0].Item.Text)
+	testutils.Equals(t, testutils.CallFromTestFile, "Sprint", items[0].MemoTitle)
+
+	query = SmartQuery{Due: smartDueOverdue}
+	items = query.evaluate(boards, memos, user, now)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(items))
+	testutils.Equals(t, testutils.CallFromTestFile, "Deploy", items[0].Item.Text)
+
+	query = SmartQuery{Due: "tomorrow"}
+	testutils.Assert(t, testutils.CallFromTestFile, query.validate() != nil, "Due should be invalid")
+}
+
+func TestE2ESmartList(t *testing.T) {
+	t.Parallel()
+
+	var testInfo testutils.APITestInfo
+	user, token := setupUser(t)
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Smart board"},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	createMemo(board.ID.Hex(), Memo{
+		BasicInfo: BasicInfo{Title: "Errands"},
+		Items: []Item{
+			{Text: "Bakery", AssigneeID: user.ID},
+			{Text: "Post office", AssigneeID: user.ID},
+			{Text: "Bank"},
+		},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	var smartList SmartList
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+		deleteSmartList(smartList.ID.Hex(), user.ID.Hex())
+	})
+
+	t.Run("InvalidSmartListIsRejected", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               "smartlists",
+			Method:             http.MethodPost,
+			Payload:            SmartList{Name: "Mine", Query: SmartQuery{Assignee: "pouet"}},
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("CreateSmartList", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               "smartlists",
+			Method:             http.MethodPost,
+			Payload:            SmartList{Name: "Mine", Query: SmartQuery{Assignee: smartAssigneeMe, Sort: smartSortText}},
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+		json.NewDecoder(rr.Body).Decode(&smartList)
+
+		testutils.Equals(t, testutils.CallFromTestFile, user.ID, smartList.CreatedBy)
+	})
+
+	t.Run("EvaluateSmartList", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("smartlists/%s/items?limit=1&page=2", smartList.ID.Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var results SmartListResults
+		json.NewDecoder(rr.Body).Decode(&results)
+		testutils.Equals(t, testutils.CallFromTestFile, 2, results.Total)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(results.Items))
+		testutils.Equals(t, testutils.CallFromTestFile, "Post office", results.Items[0].Item.Text)
+		testutils.Equals(t, testutils.CallFromTestFile, board.ID, results.Items[0].BoardID)
+	})
+
+	t.Run("UnknownSmartList", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("smartlists/%s/items", primitive.NewObjectID().Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusNotFound,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+}
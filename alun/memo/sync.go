@@ -0,0 +1,150 @@
+package memo
+
+import (
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	syncDefaultLimit = 100
+	syncMaxLimit     = 500
+)
+
+// lastUpdate returns the most recent time of a TrackedEntity-like pair
+func lastUpdate(createdAt time.Time, updatedAt time.Time) time.Time {
+	if updatedAt.IsZero() {
+		return createdAt
+	}
+	return updatedAt
+}
+
+// newBoardChange builds a board change. Memos are never part of the payload
+// as they have their own changes
+func newBoardChange(board Board, operation string) Change {
+	change := Change{
+		EntityType: changeEntityBoard,
+		EntityID:   board.ID,
+		BoardID:    board.ID,
+		Operation:  operation,
+		UpdatedAt:  lastUpdate(board.CreatedAt, board.UpdatedAt),
+	}
+
+	if operation == changeOpDeleted {
+		change.UpdatedAt = time.Now()
+	} else {
+		board.Memos = nil
+		change.Board = &board
+	}
+
+	return change
+}
+
+// newMemoChange builds a memo change. Items are never part of the payload as
+// they have their own changes
+func newMemoChange(boardID primitive.ObjectID, memo Memo, operation string) Change {
+	change := Change{
+		EntityType: changeEntityMemo,
+		EntityID:   memo.ID,
+		BoardID:    boardID,
+		MemoID:     memo.ID,
+		Operation:  operation,
+		UpdatedAt:  lastUpdate(memo.CreatedAt, memo.UpdatedAt),
+	}
+
+	if operation == changeOpDeleted {
+		change.UpdatedAt = time.Now()
+	} else {
+		memo.Items = nil
+		change.Memo = &memo
+	}
+
+	return change
+}
+
+// newItemChange builds an item change. Items are not tracked entities so
+// the memo modification time is used. Like memos, children are never part of
+// the payload as they have their own changes
+func newItemChange(boardID primitive.ObjectID, memoID primitive.ObjectID, parentID primitive.ObjectID,
+	item Item, operation string, updatedAt time.Time) Change {
+
+	change := Change{
+		EntityType: changeEntityItem,
+		EntityID:   item.ID,
+		BoardID:    boardID,
+		MemoID:     memoID,
+		ParentID:   parentID,
+		Operation:  operation,
+		UpdatedAt:  updatedAt,
+	}
+
+	if operation != changeOpDeleted {
+		item.Children = nil
+		change.Item = &item
+	}
+
+	return change
+}
+
+// diffItems compares the items of a memo before and after an update and
+// builds the matching item changes. Items are matched by their ID at any depth
+// so that an item moved to another parent is an update
+func diffItems(boardID primitive.ObjectID, before Memo, after Memo) []Change {
+	changes := make([]Change, 0)
+	updatedAt := lastUpdate(after.CreatedAt, after.UpdatedAt)
+
+	previousItems := flattenItems(before.Items)
+	previousParents := make(map[primitive.ObjectID]primitive.ObjectID)
+	walkItems(before.Items, func(item *Item, parentID primitive.ObjectID) {
+		previousParents[item.ID] = parentID
+	})
+
+	walkItems(after.Items, func(item *Item, parentID primitive.ObjectID) {
+		previous, exists := previousItems[item.ID]
+		if !exists {
+			changes = append(changes, newItemChange(boardID, after.ID, parentID, *item, changeOpCreated, updatedAt))
+			return
+		}
+
+		if !item.hasSameFields(previous) || previousParents[item.ID] != parentID {
+			changes = append(changes, newItemChange(boardID, after.ID, parentID, *item, changeOpUpdated, updatedAt))
+		}
+		delete(previousItems, item.ID)
+	})
+
+	// Keep the original order for the deleted items
+	walkItems(before.Items, func(item *Item, parentID primitive.ObjectID) {
+		if _, isDeleted := previousItems[item.ID]; isDeleted {
+			changes = append(changes, newItemChange(boardID, after.ID, parentID, *item, changeOpDeleted, time.Now()))
+		}
+	})
+
+	return changes
+}
+
+// newBoardSnapshot builds the changes creating a board with its memos and
+// their items, for an user whose feed does not have them yet
+func newBoardSnapshot(board Board, memos []Memo) []Change {
+	changes := []Change{newBoardChange(board, changeOpCreated)}
+	for _, memo := range memos {
+		changes = append(changes, newMemoChange(board.ID, memo, changeOpCreated))
+		changes = append(changes, diffItems(board.ID, Memo{}, memo)...)
+	}
+
+	return changes
+}
+
+// parseSyncCursor reads a cursor sent by a client. An empty cursor starts
+// the feed from the beginning
+func parseSyncCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(cursor, 10, 64)
+}
+
+func formatSyncCursor(sequence int64) string {
+	return strconv.FormatInt(sequence, 10)
+}
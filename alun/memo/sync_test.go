@@ -0,0 +1,169 @@
+package memo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestE2ESync(t *testing.T) {
+	t.Parallel()
+
+	var testInfo testutils.APITestInfo
+	var cursor string
+	user, token := setupUser(t)
+	member := primitive.NewObjectID()
+
+	board := Board{BasicInfo: BasicInfo{Title: "Synced board"}, Access: accessPrivate}
+	memo := Memo{
+		BasicInfo: BasicInfo{Title: "Synced memo"},
+		Items:     []Item{{Text: "Item 1"}, {Text: "Item 2"}},
+	}
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+		deleteChangesByUserID(user.ID)
+		deleteChangesByUserID(member)
+	})
+
+	loadChanges := func(t *testing.T, cursor string, limit int) ChangeFeed {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("sync?cursor=%s&limit=%d", cursor, limit),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var feed ChangeFeed
+		json.NewDecoder(rr.Body).Decode(&feed)
+		return feed
+	}
+
+	t.Run("InitialEmptyFeed", func(t *testing.T) {
+		feed := loadChanges(t, "", syncDefaultLimit)
+
+		testutils.Equals(t, testutils.CallFromTestFile, 0, len(feed.Changes))
+		testutils.Equals(t, testutils.CallFromTestFile, "0", feed.Cursor)
+		cursor = feed.Cursor
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               "sync?cursor=pouet",
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("CreationsArePaginated", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               "boards",
+			Method:             http.MethodPost,
+			Payload:            board,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+		json.NewDecoder(rr.Body).Decode(&board)
+
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/memos", board.ID.Hex()),
+			Method:             http.MethodPost,
+			Payload:            memo,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr = apiTester.TestPath(t, testInfo)
+		json.NewDecoder(rr.Body).Decode(&memo)
+
+		// board + memo + 2 items
+		firstPage := loadChanges(t, cursor, 2)
+		testutils.Equals(t, testutils.CallFromTestFile, 2, len(firstPage.Changes))
+		testutils.Assert(t, testutils.CallFromTestFile, firstPage.HasMore, "First page should have more")
+		testutils.Equals(t, testutils.CallFromTestFile, changeEntityBoard, firstPage.Changes[0].EntityType)
+		testutils.Equals(t, testutils.CallFromTestFile, changeEntityMemo, firstPage.Changes[1].EntityType)
+
+		secondPage := loadChanges(t, firstPage.Cursor, 2)
+		testutils.Equals(t, testutils.CallFromTestFile, 2, len(secondPage.Changes))
+		testutils.Assert(t, testutils.CallFromTestFile, !secondPage.HasMore, "Second page should be the last")
+		testutils.Equals(t, testutils.CallFromTestFile, changeEntityItem, secondPage.Changes[0].EntityType)
+		testutils.Equals(t, testutils.CallFromTestFile, memo.Items[0].ID, secondPage.Changes[0].EntityID)
+
+		cursor = secondPage.Cursor
+	})
+
+	t.Run("ItemUpdatesAndTombstones", func(t *testing.T) {
+		memo.Items = []Item{memo.Items[0], {Text: "Item 3"}}
+		memo.Items[0].IsFinished = true
+
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/memos/%s", board.ID.Hex(), memo.ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            memo,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		// memo updated, item 1 updated, item 3 created, item 2 deleted
+		feed := loadChanges(t, cursor, syncDefaultLimit)
+		testutils.Equals(t, testutils.CallFromTestFile, 4, len(feed.Changes))
+		testutils.Equals(t, testutils.CallFromTestFile, changeOpUpdated, feed.Changes[1].Operation)
+		testutils.Equals(t, testutils.CallFromTestFile, changeOpCreated, feed.Changes[2].Operation)
+		testutils.Equals(t, testutils.CallFromTestFile, changeOpDeleted, feed.Changes[3].Operation)
+		testutils.Assert(t, testutils.CallFromTestFile, feed.Changes[3].Item == nil,
+			"Tombstone should not have any payload: %+v", feed.Changes[3].Item)
+
+		cursor = feed.Cursor
+	})
+
+	t.Run("AddedMemberReceivesBoardContent", func(t *testing.T) {
+		board.Members = []primitive.ObjectID{member}
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s", board.ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            board,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		feed := loadChanges(t, cursor, syncDefaultLimit)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(feed.Changes))
+		testutils.Equals(t, testutils.CallFromTestFile, changeOpUpdated, feed.Changes[0].Operation)
+		cursor = feed.Cursor
+
+		// board + memo + 2 items
+		memberFeed, _ := findChanges(member.Hex(), 0, syncDefaultLimit)
+		testutils.Equals(t, testutils.CallFromTestFile, 4, len(memberFeed.Changes))
+		for _, change := range memberFeed.Changes {
+			testutils.Equals(t, testutils.CallFromTestFile, changeOpCreated, change.Operation)
+		}
+		testutils.Equals(t, testutils.CallFromTestFile, board.ID, memberFeed.Changes[0].EntityID)
+		testutils.Equals(t, testutils.CallFromTestFile, memo.ID, memberFeed.Changes[1].EntityID)
+		testutils.Equals(t, testutils.CallFromTestFile, changeEntityItem, memberFeed.Changes[3].EntityType)
+	})
+
+	t.Run("BoardTombstone", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s", board.ID.Hex()),
+			Method:             http.MethodDelete,
+			ExpectedHTTPStatus: http.StatusNoContent,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		feed := loadChanges(t, cursor, syncDefaultLimit)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(feed.Changes))
+		testutils.Equals(t, testutils.CallFromTestFile, changeOpDeleted, feed.Changes[0].Operation)
+		testutils.Equals(t, testutils.CallFromTestFile, board.ID, feed.Changes[0].EntityID)
+	})
+}
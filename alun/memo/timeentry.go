@@ -0,0 +1,164 @@
+package memo
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// timeEntryCSVHeader are the columns of the time entries export. Durations are
+// in seconds
+var timeEntryCSVHeader = []string{"id", "memo", "item", "userId", "startedAt", "stoppedAt", "duration", "note"}
+
+// TimeEntry is a period spent by an user on an item. The user is the creator
+// of the entry. A running timer is an entry without stop time, an user can
+// only have one running timer
+type TimeEntry struct {
+	ID                 primitive.ObjectID `json:"id" bson:"_id"`
+	BoardID            primitive.ObjectID `json:"boardId" bson:"boardId"`
+	MemoID             primitive.ObjectID `json:"memoId" bson:"memoId"`
+	ItemID             primitive.ObjectID `json:"itemId" bson:"itemId"`
+	StartedAt          time.Time          `json:"startedAt" bson:"startedAt"`
+	StoppedAt          time.Time          `json:"stoppedAt,omitempty" bson:"stoppedAt,omitempty"`
+	IsRunning          bool               `json:"isRunning" bson:"isRunning"`
+	Note               string             `json:"note,omitempty" bson:"note,omitempty"`
+	core.TrackedEntity `bson:",inline"`
+}
+
+// TimeTotals sums up the tracked durations of a board, in seconds. Running
+// timers count until now
+type TimeTotals struct {
+	BoardID primitive.ObjectID `json:"boardId"`
+	Total   int64              `json:"total"`
+	Memos   map[string]int64   `json:"memos"` // By memo ID
+	Items   map[string]int64   `json:"items"` // By item ID
+}
+
+// validateManual checks a manual entry which must be a finished period
+func (te *TimeEntry) validateManual(now time.Time) error {
+	te.Note = strings.TrimSpace(te.Note)
+
+	if te.StartedAt.IsZero() || te.StoppedAt.IsZero() {
+		return errors.New("Start and stop times are required")
+	}
+	if !te.StoppedAt.After(te.StartedAt) {
+		return errors.New("Stop time must be after start time")
+	}
+	if te.StoppedAt.After(now) {
+		return errors.New("Stop time cannot be in the future")
+	}
+
+	return nil
+}
+
+// duration is the tracked duration of the entry, until now for a running
+// timer
+func (te *TimeEntry) duration(now time.Time) time.Duration {
+	if te.IsRunning {
+		return now.Sub(te.StartedAt)
+	}
+
+	return te.StoppedAt.Sub(te.StartedAt)
+}
+
+// computeTimeTotals sums the durations of the entries of a board per memo and
+// per item
+func computeTimeTotals(boardID primitive.ObjectID, entries []TimeEntry, now time.Time) TimeTotals {
+	totals := TimeTotals{
+		BoardID: boardID,
+		Memos:   make(map[string]int64),
+		Items:   make(map[string]int64),
+	}
+
+	for _, entry := range entries {
+		seconds := int64(entry.duration(now) / time.Second)
+		totals.Total += seconds
+		totals.Memos[entry.MemoID.Hex()] += seconds
+		totals.Items[entry.ItemID.Hex()] += seconds
+	}
+
+	return totals
+}
+
+// writeTimeEntriesCSV exports entries with the titles of their memo and item.
+// Times are in the provided location and the titles of deleted memos or
+// items are empty
+func writeTimeEntriesCSV(w io.Writer, entries []TimeEntry, memos []Memo, location *time.Location, now time.Time) error {
+	memoTitles := make(map[primitive.ObjectID]string)
+	itemTexts := make(map[primitive.ObjectID]string)
+	for _, memo := range memos {
+		memoTitles[memo.ID] = memo.Title
+		for itemID, item := range flattenItems(memo.Items) {
+			itemTexts[itemID] = item.Text
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(timeEntryCSVHeader); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		stoppedAt := ""
+		if !entry.IsRunning {
+			stoppedAt = entry.StoppedAt.In(location).Format(time.RFC3339)
+		}
+
+		record := []string{
+			entry.ID.Hex(),
+			escapeCSVFormula(memoTitles[entry.MemoID]),
+			escapeCSVFormula(itemTexts[entry.ItemID]),
+			entry.CreatedBy.Hex(),
+			entry.StartedAt.In(location).Format(time.RFC3339),
+			stoppedAt,
+			strconv.FormatInt(int64(entry.duration(now)/time.Second), 10),
+			escapeCSVFormula(entry.Note),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// escapeCSVFormula prefixes with a quote the user texts which a spreadsheet
+// would run as a formula
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// removedItemIDs lists the items, children included, of a memo which are not
+// in its updated version
+func removedItemIDs(before Memo, after Memo) []primitive.ObjectID {
+	remainingItems := flattenItems(after.Items)
+
+	removedIDs := make([]primitive.ObjectID, 0)
+	walkItems(before.Items, func(item *Item, parentID primitive.ObjectID) {
+		if _, exists := remainingItems[item.ID]; !exists {
+			removedIDs = append(removedIDs, item.ID)
+		}
+	})
+
+	return removedIDs
+}
+
+// newTimeEntryInvalid details why a time entry is invalid
+func newTimeEntryInvalid(err error) *core.ServiceMessage {
+	msg := *timeEntryInvalid
+	msg.Message = fmt.Sprintf("%s: %v", timeEntryInvalid.Message, err)
+
+	return &msg
+}
@@ -0,0 +1,193 @@
+package memo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTimeTotals(t *testing.T) {
+	now := time.Date(2020, time.March, 11, 12, 0, 0, 0, time.UTC)
+	boardID, memoID, otherMemoID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	itemID, otherItemID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	entries := []TimeEntry{
+		{MemoID: memoID, ItemID: itemID, StartedAt: now.Add(-3 * time.Hour), StoppedAt: now.Add(-2 * time.Hour)},
+		{MemoID: memoID, ItemID: otherItemID, StartedAt: now.Add(-30 * time.Minute), IsRunning: true},
+		{MemoID: otherMemoID, ItemID: primitive.NewObjectID(), StartedAt: now.Add(-time.Hour), StoppedAt: now.Add(-50 * time.Minute)},
+	}
+
+	totals := computeTimeTotals(boardID, entries, now)
+	testutils.Equals(t, testutils.CallFromTestFile, int64(3600+1800+600), totals.Total)
+	testutils.Equals(t, testutils.CallFromTestFile, int64(3600+1800), totals.Memos[memoID.Hex()])
+	testutils.Equals(t, testutils.CallFromTestFile, int64(1800), totals.Items[otherItemID.Hex()])
+
+	manual := TimeEntry{StartedAt: now.Add(-time.Hour), StoppedAt: now.Add(-2 * time.Hour)}
+	testutils.Assert(t, testutils.CallFromTestFile, manual.validateManual(now) != nil, "Entry cannot stop before it starts")
+	manual = TimeEntry{StartedAt: now.Add(-time.Hour), StoppedAt: now.Add(time.Hour)}
+	testutils.Assert(t, testutils.CallFromTestFile, manual.validateManual(now) != nil, "Entry cannot stop in the future")
+	manual = TimeEntry{StartedAt: now.Add(-time.Hour), StoppedAt: now, Note: " Review "}
+	testutils.Ok(t, testutils.CallFromTestFile, manual.validateManual(now))
+	testutils.Equals(t, testutils.CallFromTestFile, "Review", manual.Note)
+}
+
+func TestTimeEntriesCSV(t *testing.T) {
+	now := time.Date(2020, time.March, 11, 12, 0, 0, 0, time.UTC)
+	memo := Memo{ID: primitive.NewObjectID(), BasicInfo: BasicInfo{Title: "Sprint"}, Items: []Item{{Text: "Review, then merge"}}}
+	memo.prepareItems()
+	userID := primitive.NewObjectID()
+
+	entry := TimeEntry{
+		ID:        primitive.NewObjectID(),
+		MemoID:    memo.ID,
+		ItemID:    memo.Items[0].ID,
+		StartedAt: now.Add(-time.Hour),
+		StoppedAt: now,
+		Note:      "Billable",
+	}
+	entry.CreatedBy = userID
+
+	var export bytes.Buffer
+	testutils.Ok(t, testutils.CallFromTestFile, writeTimeEntriesCSV(&export, []TimeEntry{entry}, []Memo{memo}, time.UTC, now))
+
+	expected := "id,memo,item,userId,startedAt,stoppedAt,duration,note\n" +
+		fmt.Sprintf("%s,Sprint,\"Review, then merge\",%s,2020-03-11T11:00:00Z,2020-03-11T12:00:00Z,3600,Billable\n", entry.ID.Hex(), userID.Hex())
+	testutils.Equals(t, testutils.CallFromTestFile, expected, export.String())
+}
+
+func TestEscapeCSVFormula(t *testing.T) {
+	testutils.Equals(t, testutils.CallFromTestFile, "'=HYPERLINK(\"http://pouet\")", escapeCSVFormula("=HYPERLINK(\"http://pouet\")"))
+	testutils.Equals(t, testutils.CallFromTestFile, "'-1+1", escapeCSVFormula("-1+1"))
+	testutils.Equals(t, testutils.CallFromTestFile, "'@SUM(A1)", escapeCSVFormula("@SUM(A1)"))
+	testutils.Equals(t, testutils.CallFromTestFile, "Review", escapeCSVFormula("Review"))
+	testutils.Equals(t, testutils.CallFromTestFile, "", escapeCSVFormula(""))
+}
+
+func TestRemovedItemIDs(t *testing.T) {
+	before := Memo{Items: []Item{
+		{Text: "Kept", Children: []Item{{Text: "Removed child"}}},
+		{Text: "Removed", Children: []Item{{Text: "Removed with parent"}}},
+	}}
+	before.prepareItems()
+	after := Memo{Items: []Item{{ID: before.Items[0].ID, Text: "Kept"}}}
+
+	testutils.Equals(t, testutils.CallFromTestFile, []primitive.ObjectID{
+		before.Items[0].Children[0].ID,
+		before.Items[1].ID,
+		before.Items[1].Children[0].ID,
+	}, removedItemIDs(before, after))
+}
+
+func TestE2ETimeTracking(t *testing.T) {
+	t.Parallel()
+
+	var testInfo testutils.APITestInfo
+	user, token := setupUser(t)
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Billable board"},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	memo, _ := createMemo(board.ID.Hex(), Memo{
+		BasicInfo:     BasicInfo{Title: "Client"},
+		Items:         []Item{{Text: "Design"}, {Text: "Develop"}},
+		TrackedEntity: trackedBy(user.ID),
+	})
+	itemPath := func(idx int) string {
+		return fmt.Sprintf("boards/%s/memos/%s/items/%s", board.ID.Hex(), memo.ID.Hex(), memo.Items[idx].ID.Hex())
+	}
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+	})
+
+	t.Run("StartTimer", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               itemPath(0) + "/timer",
+			Method:             http.MethodPost,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var entry TimeEntry
+		json.NewDecoder(rr.Body).Decode(&entry)
+		testutils.Assert(t, testutils.CallFromTestFile, entry.IsRunning, "Timer should be running")
+		testutils.Equals(t, testutils.CallFromTestFile, user.ID, entry.CreatedBy)
+	})
+
+	t.Run("OnlyOneRunningTimer", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               itemPath(1) + "/timer",
+			Method:             http.MethodPost,
+			ExpectedHTTPStatus: http.StatusConflict,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("StopTimer", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               itemPath(0) + "/timer",
+			Method:             http.MethodDelete,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		testInfo.ExpectedHTTPStatus = http.StatusNotFound
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("ManualEntry", func(t *testing.T) {
+		stoppedAt := time.Now().Add(-time.Hour)
+		testInfo = testutils.APITestInfo{
+			Path:               itemPath(1) + "/time",
+			Method:             http.MethodPost,
+			Payload:            TimeEntry{StartedAt: stoppedAt.Add(-2 * time.Hour), StoppedAt: stoppedAt, Note: "Billable"},
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		testInfo.Payload = TimeEntry{StartedAt: stoppedAt, StoppedAt: stoppedAt.Add(-time.Hour)}
+		testInfo.ExpectedHTTPStatus = http.StatusBadRequest
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("Totals", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/time", board.ID.Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var totals TimeTotals
+		json.NewDecoder(rr.Body).Decode(&totals)
+		testutils.Equals(t, testutils.CallFromTestFile, int64(7200), totals.Items[memo.Items[1].ID.Hex()])
+		testutils.Assert(t, testutils.CallFromTestFile, totals.Total >= 7200, "Total should include all entries, got %d", totals.Total)
+	})
+
+	t.Run("ExportCSV", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/time/export", board.ID.Hex()),
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+		testutils.Equals(t, testutils.CallFromTestFile, 3, len(lines))
+		testutils.Assert(t, testutils.CallFromTestFile, strings.Contains(lines[1], "Billable"), "Manual entry, started first, should be exported first: %s", lines[1])
+	})
+}
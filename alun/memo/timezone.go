@@ -0,0 +1,68 @@
+package memo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+)
+
+// userLocationCacheDuration limits how long a timezone change takes to apply
+const userLocationCacheDuration = 5 * time.Minute
+
+// userLocations caches the timezone of the users to avoid a directory lookup
+// per request
+var userLocations = struct {
+	sync.Mutex
+	entries map[string]cachedLocation
+}{entries: make(map[string]cachedLocation)}
+
+type cachedLocation struct {
+	location *time.Location
+	cachedAt time.Time
+}
+
+// userLocation loads the timezone of an user from the users directory. UTC is
+// used when the user has no valid timezone or when no directory is available
+// so that due dates are always interpreted the same way
+func userLocation(userID string) *time.Location {
+	now := time.Now()
+	userLocations.Lock()
+	cached, isCached := userLocations.entries[userID]
+	userLocations.Unlock()
+	if isCached && now.Sub(cached.cachedAt) < userLocationCacheDuration {
+		return cached.location
+	}
+
+	directory := core.GetUserDirectory()
+	if directory == nil {
+		return time.UTC
+	}
+
+	contacts, err := directory.FindUserContacts([]string{userID})
+	if err != nil {
+		// Not cached so that the next request retries
+		memoLogger.Warn("[Timezone] Cannot load the contact of user %s: %v", userID, err)
+		return time.UTC
+	}
+
+	location := time.UTC
+	if len(contacts) > 0 && contacts[0].Timezone != "" {
+		if loaded, err := time.LoadLocation(contacts[0].Timezone); err == nil {
+			location = loaded
+		} else {
+			memoLogger.Warn("[Timezone] Invalid timezone %s of user %s: %v", contacts[0].Timezone, userID, err)
+		}
+	}
+
+	userLocations.Lock()
+	for cachedID, entry := range userLocations.entries {
+		if now.Sub(entry.cachedAt) >= userLocationCacheDuration {
+			delete(userLocations.entries, cachedID)
+		}
+	}
+	userLocations.entries[userID] = cachedLocation{location: location, cachedAt: now}
+	userLocations.Unlock()
+
+	return location
+}
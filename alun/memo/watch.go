@@ -0,0 +1,271 @@
+package memo
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	watchEventMemoCreated   = "memoCreated"
+	watchEventItemCompleted = "itemCompleted"
+	watchEventBoardUpdated  = "boardUpdated"
+
+	// watchDefaultWindow is how long the activity of the watched boards is
+	// gathered before sending a single email to a watcher
+	watchDefaultWindow = 2 * time.Minute
+)
+
+// Watch is the subscription of an user to the activity of a board. The token
+// is appended to the unsubscribe URL so that the user can stop watching from
+// an email without being logged in
+type Watch struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	BoardID        primitive.ObjectID `json:"boardId" bson:"boardId"`
+	UserID         primitive.ObjectID `json:"userId" bson:"userId"`
+	UnsubscribeURL string             `json:"unsubscribeUrl" bson:"unsubscribeUrl"`
+	Token          string             `json:"-" bson:"token"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// WatchRequest is sent to watch a board or to stop watching it from an email
+type WatchRequest struct {
+	UnsubscribeURL string `json:"unsubscribeUrl,omitempty"`
+	Token          string `json:"token,omitempty"`
+}
+
+// watchEvent is a change of a board that watchers are emailed about
+type watchEvent struct {
+	Type   string
+	MemoID primitive.ObjectID
+	ItemID primitive.ObjectID
+	Text   string
+}
+
+// watchActivity is an event waiting to be sent to a watcher
+type watchActivity struct {
+	Watch      Watch
+	BoardTitle string
+	Event      watchEvent
+}
+
+// watchEmailData is the content of the memo_board-activity email
+type watchEmailData struct {
+	Boards []watchEmailBoard
+}
+
+// watchEmailBoard is the activity of a single board in an email
+type watchEmailBoard struct {
+	Title          string
+	UnsubscribeURL string
+	Activities     []string
+}
+
+// ----------------------------------------------------------------------------
+//	Events
+// ----------------------------------------------------------------------------
+
+// newCompletedItemEvents lists the items of a memo completed by the completion
+// events
+func newCompletedItemEvents(memo Memo, events []CompletionEvent) []watchEvent {
+	items := flattenItems(memo.Items)
+
+	watchEvents := make([]watchEvent, 0)
+	for _, event := range events {
+		if event.Type != completionCompleted {
+			continue
+		}
+
+		watchEvents = append(watchEvents, watchEvent{
+			Type:   watchEventItemCompleted,
+			MemoID: memo.ID,
+			ItemID: event.ItemID,
+			Text:   items[event.ItemID].Text,
+		})
+	}
+
+	return watchEvents
+}
+
+// describe is the line of an event in the activity email
+func (we watchEvent) describe() string {
+	switch we.Type {
+	case watchEventMemoCreated:
+		return fmt.Sprintf("New memo: %s", we.Text)
+	case watchEventItemCompleted:
+		return fmt.Sprintf("Completed: %s", we.Text)
+	}
+
+	return "Board settings updated"
+}
+
+// ----------------------------------------------------------------------------
+//	Batching
+// ----------------------------------------------------------------------------
+
+// watchBatcher gathers the activities of each watcher over a window which
+// starts with the first activity. All the activities of the window are then
+// flushed together.
+//
+// Pending activities are only kept in memory: the activities of a window still
+// open when the service stops are lost without being emailed or logged
+type watchBatcher struct {
+	window  time.Duration
+	flush   func(watcherID primitive.ObjectID, activities []watchActivity)
+	mutex   sync.Mutex
+	pending map[primitive.ObjectID][]watchActivity
+}
+
+var watchActivities *watchBatcher
+
+func newWatchBatcher(window time.Duration, flush func(watcherID primitive.ObjectID, activities []watchActivity)) *watchBatcher {
+	return &watchBatcher{
+		window:  window,
+		flush:   flush,
+		pending: make(map[primitive.ObjectID][]watchActivity),
+	}
+}
+
+// loadWatchBatcher initialises the batcher of the watch emails with the
+// window of the environment variable if it is defined
+func loadWatchBatcher() {
+	window := watchDefaultWindow
+
+	if value := os.Getenv(utils.EnvVarMemoWatchWindowSeconds); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			window = time.Duration(seconds) * time.Second
+		} else {
+			memoLogger.Warn("[Watch] Invalid value <%s> for %s, keeping %v", value, utils.EnvVarMemoWatchWindowSeconds, window)
+		}
+	}
+
+	watchActivities = newWatchBatcher(window, sendWatchEmail)
+}
+
+// add queues activities of a watcher. An activity identical to a pending one,
+// such as an item completed again, is only sent once
+func (wb *watchBatcher) add(watcherID primitive.ObjectID, activities ...watchActivity) {
+	wb.mutex.Lock()
+	defer wb.mutex.Unlock()
+
+	pending, isPending := wb.pending[watcherID]
+	if !isPending {
+		time.AfterFunc(wb.window, func() { wb.flushWatcher(watcherID) })
+	}
+
+	for _, activity := range activities {
+		isDuplicate := false
+		for _, previous := range pending {
+			isDuplicate = isDuplicate ||
+				(previous.Watch.BoardID == activity.Watch.BoardID && previous.Event == activity.Event)
+		}
+		if !isDuplicate {
+			pending = append(pending, activity)
+		}
+	}
+	wb.pending[watcherID] = pending
+}
+
+// flushWatcher sends the pending activities of a watcher
+func (wb *watchBatcher) flushWatcher(watcherID primitive.ObjectID) {
+	wb.mutex.Lock()
+	activities := wb.pending[watcherID]
+	delete(wb.pending, watcherID)
+	wb.mutex.Unlock()
+
+	if len(activities) > 0 {
+		wb.flush(watcherID, activities)
+	}
+}
+
+// ----------------------------------------------------------------------------
+//	Notifications
+// ----------------------------------------------------------------------------
+
+// notifyWatchers queues the events of a board for its watchers, except the
+// user who made the change. Watchers who lost access to the board are
+// skipped.
+//
+// The change is already saved so failures are only logged
+func notifyWatchers(board *Board, actorID primitive.ObjectID, events ...watchEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	watches, errMsg := findWatchesByBoardID(board.ID)
+	if errMsg != nil {
+		memoLogger.Warn("[Watch] Cannot load watchers of board %s: %v", board.ID.Hex(), errMsg.Error)
+		return
+	}
+
+	for _, watch := range watches {
+		if watch.UserID == actorID || !board.hasAccess(watch.UserID) {
+			continue
+		}
+
+		activities := make([]watchActivity, 0, len(events))
+		for _, event := range events {
+			activities = append(activities, watchActivity{Watch: watch, BoardTitle: board.Title, Event: event})
+		}
+		watchActivities.add(watch.UserID, activities...)
+	}
+}
+
+// newWatchEmailData groups the activities by board, in the order of their
+// first activity
+func newWatchEmailData(activities []watchActivity) watchEmailData {
+	data := watchEmailData{Boards: make([]watchEmailBoard, 0)}
+	boardIndexes := make(map[primitive.ObjectID]int)
+
+	for _, activity := range activities {
+		idx, ok := boardIndexes[activity.Watch.BoardID]
+		if !ok {
+			idx = len(data.Boards)
+			boardIndexes[activity.Watch.BoardID] = idx
+			data.Boards = append(data.Boards, watchEmailBoard{
+				Title:          activity.BoardTitle,
+				UnsubscribeURL: activity.Watch.UnsubscribeURL + activity.Watch.Token,
+			})
+		}
+
+		data.Boards[idx].Activities = append(data.Boards[idx].Activities, activity.Event.describe())
+	}
+
+	return data
+}
+
+// sendWatchEmail emails the batched activities to a watcher. It runs in the
+// timer of the batcher, never in a request. Emails are resolved with the users
+// directory and are not sent if no directory is available
+func sendWatchEmail(watcherID primitive.ObjectID, activities []watchActivity) {
+	directory := core.GetUserDirectory()
+	if directory == nil {
+		memoLogger.Info("[Watch] No users directory, %d activities skipped", len(activities))
+		return
+	}
+
+	contacts, err := directory.FindUserContacts([]string{watcherID.Hex()})
+	if err != nil {
+		memoLogger.Warn("[Watch] Cannot load contact of %s: %v", watcherID.Hex(), err)
+		return
+	}
+	if len(contacts) == 0 || contacts[0].Email == "" {
+		return
+	}
+
+	data := newWatchEmailData(activities)
+	subject := "Activity on your watched boards"
+	if len(data.Boards) == 1 {
+		subject = fmt.Sprintf("Activity on %s", data.Boards[0].Title)
+	}
+
+	if err := alunEmail.SendNoReplyEmail([]string{contacts[0].Email}, subject, utils.EmailTemplateMemoBoardActivity, data); err != nil {
+		memoLogger.Warn("[Watch] Cannot notify user %s: %v", watcherID.Hex(), err)
+	}
+}
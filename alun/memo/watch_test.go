@@ -0,0 +1,136 @@
+package memo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestWatchBatcher(t *testing.T) {
+	watcherID := primitive.NewObjectID()
+	watch := Watch{BoardID: primitive.NewObjectID(), UserID: watcherID, UnsubscribeURL: "http://unsubscribe?t=", Token: "pouet"}
+	otherWatch := Watch{BoardID: primitive.NewObjectID(), UserID: watcherID, UnsubscribeURL: "http://unsubscribe?t=", Token: "plop"}
+
+	flushed := make(chan []watchActivity, 2)
+	batcher := newWatchBatcher(50*time.Millisecond, func(id primitive.ObjectID, activities []watchActivity) {
+		flushed <- activities
+	})
+
+	// Toggling an item ten times only sends one completion
+	itemID := primitive.NewObjectID()
+	for i := 0; i < 10; i++ {
+		batcher.add(watcherID, watchActivity{
+			Watch:      watch,
+			BoardTitle: "Work",
+			Event:      watchEvent{Type: watchEventItemCompleted, ItemID: itemID, Text: "Deploy"},
+		})
+	}
+	batcher.add(watcherID, watchActivity{Watch: otherWatch, BoardTitle: "Home", Event: watchEvent{Type: watchEventBoardUpdated}})
+	batcher.add(watcherID, watchActivity{Watch: watch, BoardTitle: "Work", Event: watchEvent{Type: watchEventMemoCreated, Text: "Sprint"}})
+
+	select {
+	case activities := <-flushed:
+		testutils.Equals(t, testutils.CallFromTestFile, 3, len(activities))
+
+		data := newWatchEmailData(activities)
+		testutils.Equals(t, testutils.CallFromTestFile, 2, len(data.Boards))
+		testutils.Equals(t, testutils.CallFromTestFile, "Work", data.Boards[0].Title)
+		testutils.Equals(t, testutils.CallFromTestFile, "http://unsubscribe?t=pouet", data.Boards[0].UnsubscribeURL)
+		testutils.Equals(t, testutils.CallFromTestFile, []string{"Completed: Deploy", "New memo: Sprint"}, data.Boards[0].Activities)
+		testutils.Equals(t, testutils.CallFromTestFile, []string{"Board settings updated"}, data.Boards[1].Activities)
+	case <-time.After(time.Second):
+		t.Fatal("Activities were not flushed")
+	}
+
+	select {
+	case <-flushed:
+		t.Error("Activities should be flushed once")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNewCompletedItemEvents(t *testing.T) {
+	memo := Memo{ID: primitive.NewObjectID(), Items: newItemsTree()}
+	memo.prepareItems()
+	child := memo.Items[0].Children[0]
+	events := []CompletionEvent{
+		{ItemID: child.ID, Type: completionCompleted},
+		{ItemID: memo.Items[0].ID, Type: completionReopened},
+	}
+
+	watchEvents := newCompletedItemEvents(memo, events)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(watchEvents))
+	testutils.Equals(t, testutils.CallFromTestFile, child.Text, watchEvents[0].Text)
+	testutils.Equals(t, testutils.CallFromTestFile, memo.ID, watchEvents[0].MemoID)
+}
+
+func TestE2EWatch(t *testing.T) {
+	t.Parallel()
+
+	var testInfo testutils.APITestInfo
+	user, token := setupUser(t)
+	board, _ := createBoard(Board{
+		BasicInfo:     BasicInfo{Title: "Watched board"},
+		TrackedEntity: trackedBy(user.ID),
+	})
+
+	t.Cleanup(func() {
+		tearDownUser(t)
+		deleteBoard(board.ID.Hex())
+	})
+
+	t.Run("UnsubscribeURLIsRequired", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/watch", board.ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            WatchRequest{},
+			ExpectedHTTPStatus: http.StatusBadRequest,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("WatchAndUnsubscribe", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/watch", board.ID.Hex()),
+			Method:             http.MethodPut,
+			Payload:            WatchRequest{UnsubscribeURL: "http://whatever-url.com?t="},
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var watch Watch
+		json.NewDecoder(rr.Body).Decode(&watch)
+		testutils.Equals(t, testutils.CallFromTestFile, user.ID, watch.UserID)
+
+		watches, _ := findWatchesByBoardID(board.ID)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(watches))
+
+		testInfo = testutils.APITestInfo{
+			Path:               "watches/unsubscribe",
+			Method:             http.MethodPost,
+			Payload:            WatchRequest{Token: watches[0].Token},
+			ExpectedHTTPStatus: http.StatusNoContent,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		testInfo.ExpectedHTTPStatus = http.StatusNotFound
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("UnwatchNotWatchedBoard", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("boards/%s/watch", board.ID.Hex()),
+			Method:             http.MethodDelete,
+			ExpectedHTTPStatus: http.StatusNotFound,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+}
@@ -0,0 +1,29 @@
+package notification
+
+import (
+	"net/http"
+
+	"github.com/Al-un/alun-api/alun/core"
+)
+
+// NotificationAPI exposes the notification center endpoints
+var NotificationAPI *core.API
+
+// Personal access tokens need a notification scope, reading or writing
+var (
+	checkNotificationRead  = core.RequireScope(core.ScopeNotificationRead, core.CheckIfLogged)
+	checkNotificationWrite = core.RequireScope(core.ScopeNotificationWrite, core.CheckIfLogged)
+)
+
+func initAPI() {
+	apiRoot := "notifications"
+	NotificationAPI = core.NewAPI(apiRoot, notificationLogger)
+	NotificationAPI.AddMiddleware(core.AddJSONHeaders)
+
+	NotificationAPI.AddProtectedEndpoint("notifications", http.MethodGet, core.APIv1, checkNotificationRead, handleListNotifications)
+	NotificationAPI.AddProtectedEndpoint("notifications/read", http.MethodPut, core.APIv1, checkNotificationWrite, handleMarkAllRead)
+	NotificationAPI.AddProtectedEndpoint("notifications/{notificationId}/read", http.MethodPut, core.APIv1, checkNotificationWrite, handleMarkRead)
+
+	// Internal endpoint for the other services, authenticated by a secret
+	NotificationAPI.AddPublicEndpoint("internal/notifications", http.MethodPost, core.APIv1, handleReceiveNotifications)
+}
@@ -0,0 +1,152 @@
+package notification
+
+import (
+	"context"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbNotificationCollectionName string
+	dbNotificationCollection     *mongo.Collection
+)
+
+// Init the connection with MongoDB upon app initialisation
+func initDao() {
+	_, mongoDb, err := core.MongoConnectFromEnvVar(utils.EnvVarNotificationDbURL, notificationLogger)
+	if err != nil {
+		notificationLogger.Fatal(1, "%v", err)
+	}
+
+	dbNotificationCollectionName = "al_notifications"
+	dbNotificationCollection = mongoDb.Collection(dbNotificationCollectionName)
+
+	// Notifications are listed per user, most recent first, and counted when
+	// unread
+	index := mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "userId", Value: 1},
+			primitive.E{Key: "isRead", Value: 1},
+			primitive.E{Key: "createdAt", Value: -1},
+		},
+	}
+	if _, err := dbNotificationCollection.Indexes().CreateOne(context.TODO(), index); err != nil {
+		notificationLogger.Warn("[MongoDB] Notification index creation failed: %v", err)
+	}
+
+	notificationLogger.Info("[MongoDB] Notification initialisation!")
+}
+
+// ---------- CRUD ------------------------------------------------------------
+
+func createNotifications(notifications []Notification) *core.ServiceMessage {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, 0, len(notifications))
+	for _, notification := range notifications {
+		documents = append(documents, notification)
+	}
+
+	if _, err := dbNotificationCollection.InsertMany(context.TODO(), documents); err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	return nil
+}
+
+// findNotificationsByUserID loads a page, starting at 1, of the notifications
+// of an user and counts the unread ones
+func findNotificationsByUserID(userID string, unreadOnly bool, page int, limit int) (*NotificationList, *core.ServiceMessage) {
+	uID, _ := primitive.ObjectIDFromHex(userID)
+	filter := bson.M{"userId": uID}
+	if unreadOnly {
+		filter["isRead"] = false
+	}
+
+	total, err := dbNotificationCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	unread, err := dbNotificationCollection.CountDocuments(context.TODO(), bson.M{"userId": uID, "isRead": false})
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	skip := int64((page - 1) * limit)
+	pageLimit := int64(limit)
+	options := &options.FindOptions{
+		Sort:  bson.D{primitive.E{Key: "createdAt", Value: -1}, primitive.E{Key: "_id", Value: -1}},
+		Skip:  &skip,
+		Limit: &pageLimit,
+	}
+
+	notifications := make([]Notification, 0)
+	cur, err := dbNotificationCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if err := cur.All(context.TODO(), &notifications); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &NotificationList{
+		Items:  notifications,
+		Total:  total,
+		Unread: unread,
+		Page:   page,
+		Limit:  limit,
+	}, nil
+}
+
+// markNotificationRead marks a notification of an user as read. Reading an
+// already read notification keeps its first read date
+func markNotificationRead(notificationID string, userID string, readAt time.Time) (*Notification, *core.ServiceMessage) {
+	nID, _ := primitive.ObjectIDFromHex(notificationID)
+	uID, _ := primitive.ObjectIDFromHex(userID)
+	filter := bson.M{"_id": nID, "userId": uID}
+
+	var notification Notification
+	if err := dbNotificationCollection.FindOne(context.TODO(), filter).Decode(&notification); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, notificationNotFound
+		}
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if notification.IsRead {
+		return &notification, nil
+	}
+
+	filter["isRead"] = false
+	update := bson.M{"$set": bson.M{"isRead": true, "readAt": readAt}}
+	if _, err := dbNotificationCollection.UpdateOne(context.TODO(), filter, update); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	notification.IsRead = true
+	notification.ReadAt = &readAt
+	return &notification, nil
+}
+
+// markAllNotificationsRead marks all the unread notifications of an user as
+// read and returns how many were unread
+func markAllNotificationsRead(userID string, readAt time.Time) (int64, *core.ServiceMessage) {
+	uID, _ := primitive.ObjectIDFromHex(userID)
+	filter := bson.M{"userId": uID, "isRead": false}
+	update := bson.M{"$set": bson.M{"isRead": true, "readAt": readAt}}
+
+	result, err := dbNotificationCollection.UpdateMany(context.TODO(), filter, update)
+	if err != nil {
+		return -1, core.NewServiceErrorMessage(err)
+	}
+
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,109 @@
+package notification
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+)
+
+const (
+	listDefaultLimit = 20
+	listMaxLimit     = 100
+)
+
+// parsePagination reads the "page", starting at 1, and "limit" query
+// parameters. Invalid values are replaced by the default ones
+func parsePagination(query url.Values) (int, int) {
+	page := 1
+	if pageParam, err := strconv.Atoi(query.Get("page")); err == nil && pageParam > 0 {
+		page = pageParam
+	}
+	limit := listDefaultLimit
+	if limitParam, err := strconv.Atoi(query.Get("limit")); err == nil && limitParam > 0 && limitParam <= listMaxLimit {
+		limit = limitParam
+	}
+
+	return page, limit
+}
+
+// handleListNotifications lists the notifications of the logged user. Only
+// the unread notifications are listed with "unread=true"
+func handleListNotifications(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	query := r.URL.Query()
+	page, limit := parsePagination(query)
+	unreadOnly := query.Get("unread") == "true"
+
+	notifications, errMsg := findNotificationsByUserID(claims.UserID, unreadOnly, page, limit)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(notifications)
+}
+
+func handleMarkRead(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	notificationID := core.GetVar(r, "notificationId")
+
+	notification, errMsg := markNotificationRead(notificationID, claims.UserID, time.Now())
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(notification)
+}
+
+func handleMarkAllRead(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	if _, errMsg := markAllNotificationsRead(claims.UserID, time.Now()); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReceiveNotifications stores the notifications posted by the other
+// services in microservice mode
+func handleReceiveNotifications(w http.ResponseWriter, r *http.Request) {
+	secret := r.Header.Get(core.NotificationSecretHeader)
+	if internalSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(internalSecret)) != 1 {
+		internalSecretInvalid.Write(w, r)
+		return
+	}
+
+	var notifications []core.Notification
+	if err := json.NewDecoder(r.Body).Decode(&notifications); err != nil {
+		newNotificationInvalid(err).Write(w, r)
+		return
+	}
+
+	toCreate, err := newNotifications(notifications, time.Now())
+	if err != nil {
+		newNotificationInvalid(err).Write(w, r)
+		return
+	}
+
+	if errMsg := createNotifications(toCreate); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newNotificationInvalid details why posted notifications are invalid
+func newNotificationInvalid(err error) *core.ServiceMessage {
+	msg := *notificationInvalid
+	msg.Message = fmt.Sprintf("%s: %v", notificationInvalid.Message, err)
+
+	return &msg
+}
@@ -0,0 +1,30 @@
+package notification
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification is a stored notification of an user
+type Notification struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	Type      string             `json:"type" bson:"type"`
+	Title     string             `json:"title" bson:"title"`
+	Message   string             `json:"message,omitempty" bson:"message,omitempty"`
+	Data      map[string]string  `json:"data,omitempty" bson:"data,omitempty"`
+	IsRead    bool               `json:"isRead" bson:"isRead"`
+	ReadAt    *time.Time         `json:"readAt,omitempty" bson:"readAt,omitempty"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// NotificationList is a page of the notifications of an user, most recent
+// first
+type NotificationList struct {
+	Items  []Notification `json:"items"`
+	Total  int64          `json:"total"`
+	Unread int64          `json:"unread"`
+	Page   int            `json:"page"`
+	Limit  int            `json:"limit"`
+}
@@ -0,0 +1,46 @@
+// Package notification is the in-app notification center: each user has a
+// list of notifications produced by the other services.
+//
+// Services produce notifications with core.Notify:
+//   - in monolithic mode, this package registers itself as the transport
+//   - in microservice mode, services use an HTTP transport targeting the
+//     internal endpoint of the notification service
+package notification
+
+import (
+	"os"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/utils"
+	"github.com/Al-un/alun-api/pkg/logger"
+)
+
+var (
+	notificationLogger logger.Logger
+	// internalSecret authenticates the services posting notifications. The
+	// internal endpoint is disabled if empty
+	internalSecret string
+)
+
+func init() {
+	if utils.IsTest() {
+		notificationLogger = logger.NewSilenceLogger()
+	}
+
+	// --- Init logger
+	if notificationLogger == nil {
+		notificationLogger = logger.NewConsoleLogger(logger.LogLevelVerbose)
+	}
+
+	// --- Init secret
+	internalSecret = os.Getenv(utils.EnvVarNotificationSecret)
+
+	// --- Init DAO
+	initDao()
+
+	// ---- Init API
+	initAPI()
+
+	// ---- Init transport for other packages
+	core.SetNotificationTransport(localTransport{})
+}
@@ -0,0 +1,180 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/testutils"
+	"github.com/Al-un/alun-api/alun/user"
+	"github.com/Al-un/alun-api/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	userTestEmail    = "notification@test.com"
+	userTestUsername = "notification"
+	userTestPassword = "notification"
+	testSecret       = "pouetSecret"
+)
+
+var (
+	apiTester *testutils.APITester
+	userTest  = user.User{
+		BaseUser: user.BaseUser{Email: userTestEmail},
+		Username: userTestUsername,
+	}
+)
+
+func TestMain(m *testing.M) {
+	notificationLogger = logger.NewSilenceLogger()
+	internalSecret = testSecret
+	apiTester = testutils.NewAPITester(NotificationAPI)
+
+	code := m.Run()
+
+	os.Exit(code)
+}
+
+func TestNewNotifications(t *testing.T) {
+	now := time.Now()
+	userID := primitive.NewObjectID()
+
+	notifications, err := newNotifications([]core.Notification{
+		{UserID: userID.Hex(), Type: core.NotificationBoardInvite, Title: " Board "},
+	}, now)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(notifications))
+	testutils.Equals(t, testutils.CallFromTestFile, userID, notifications[0].UserID)
+	testutils.Equals(t, testutils.CallFromTestFile, "Board", notifications[0].Title)
+	testutils.Equals(t, testutils.CallFromTestFile, false, notifications[0].IsRead)
+	testutils.Equals(t, testutils.CallFromTestFile, now, notifications[0].CreatedAt)
+
+	_, err = newNotifications([]core.Notification{
+		{UserID: userID.Hex(), Type: core.NotificationBoardInvite},
+		{UserID: "pouet", Type: core.NotificationBoardInvite},
+	}, now)
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Invalid user ID should be rejected")
+
+	_, err = newNotifications([]core.Notification{{UserID: userID.Hex()}}, now)
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Missing type should be rejected")
+}
+
+func TestE2ENotifications(t *testing.T) {
+	t.Parallel()
+
+	var testInfo testutils.APITestInfo
+	testUser, token, errMsg := user.SetupUser(userTest, t.Name(), userTestPassword)
+	testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Error when setting up users: %v", errMsg)
+	userID := testUser.ID.Hex()
+
+	t.Cleanup(func() {
+		user.TearDownUsers([]user.User{userTest}, t.Name())
+		dbNotificationCollection.DeleteMany(context.TODO(), bson.M{"userId": testUser.ID})
+	})
+
+	err := core.Notify(
+		core.Notification{UserID: userID, Type: core.NotificationBoardInvite, Title: "First"},
+		core.Notification{UserID: userID, Type: core.NotificationItemAssigned, Title: "Second"},
+	)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+
+	listNotifications := func(t *testing.T, path string) NotificationList {
+		testInfo = testutils.APITestInfo{
+			Path:               path,
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var notifications NotificationList
+		json.NewDecoder(rr.Body).Decode(&notifications)
+		return notifications
+	}
+
+	var notificationID string
+
+	t.Run("ListNotifications", func(t *testing.T) {
+		notifications := listNotifications(t, "notifications?limit=1")
+
+		testutils.Equals(t, testutils.CallFromTestFile, int64(2), notifications.Total)
+		testutils.Equals(t, testutils.CallFromTestFile, int64(2), notifications.Unread)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(notifications.Items))
+		notificationID = notifications.Items[0].ID.Hex()
+	})
+
+	t.Run("MarkRead", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("notifications/%s/read", notificationID),
+			Method:             http.MethodPut,
+			ExpectedHTTPStatus: http.StatusOK,
+			AuthToken:          token,
+		}
+		rr := apiTester.TestPath(t, testInfo)
+
+		var notification Notification
+		json.NewDecoder(rr.Body).Decode(&notification)
+		testutils.Assert(t, testutils.CallFromTestFile, notification.IsRead, "Notification should be read")
+
+		notifications := listNotifications(t, "notifications?unread=true")
+		testutils.Equals(t, testutils.CallFromTestFile, int64(1), notifications.Total)
+		testutils.Equals(t, testutils.CallFromTestFile, int64(1), notifications.Unread)
+	})
+
+	t.Run("MarkUnknownRead", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               fmt.Sprintf("notifications/%s/read", primitive.NewObjectID().Hex()),
+			Method:             http.MethodPut,
+			ExpectedHTTPStatus: http.StatusNotFound,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("InternalRequiresSecret", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               "internal/notifications",
+			Method:             http.MethodPost,
+			Payload:            []core.Notification{{UserID: userID, Type: core.NotificationItemMentioned}},
+			Headers:            map[string]string{core.NotificationSecretHeader: "pouet"},
+			ExpectedHTTPStatus: http.StatusForbidden,
+		}
+		apiTester.TestPath(t, testInfo)
+	})
+
+	t.Run("InternalNotifications", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               "internal/notifications",
+			Method:             http.MethodPost,
+			Payload:            []core.Notification{{UserID: userID, Type: core.NotificationItemMentioned}},
+			Headers:            map[string]string{core.NotificationSecretHeader: testSecret},
+			ExpectedHTTPStatus: http.StatusNoContent,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		notifications := listNotifications(t, "notifications")
+		testutils.Equals(t, testutils.CallFromTestFile, int64(3), notifications.Total)
+		testutils.Equals(t, testutils.CallFromTestFile, int64(2), notifications.Unread)
+		testutils.Equals(t, testutils.CallFromTestFile, core.NotificationItemMentioned, notifications.Items[0].Type)
+	})
+
+	t.Run("MarkAllRead", func(t *testing.T) {
+		testInfo = testutils.APITestInfo{
+			Path:               "notifications/read",
+			Method:             http.MethodPut,
+			ExpectedHTTPStatus: http.StatusNoContent,
+			AuthToken:          token,
+		}
+		apiTester.TestPath(t, testInfo)
+
+		notifications := listNotifications(t, "notifications")
+		testutils.Equals(t, testutils.CallFromTestFile, int64(0), notifications.Unread)
+	})
+}
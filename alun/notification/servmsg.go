@@ -0,0 +1,29 @@
+package notification
+
+import (
+	"net/http"
+
+	"github.com/Al-un/alun-api/alun/core"
+)
+
+// ----------------------------------------------------------------------------
+//	Notification management: Code 104xx
+// ----------------------------------------------------------------------------
+
+var notificationNotFound = &core.ServiceMessage{
+	Code:       10400,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Notification not found",
+}
+
+var notificationInvalid = &core.ServiceMessage{
+	Code:       10401,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Notification is invalid",
+}
+
+var internalSecretInvalid = &core.ServiceMessage{
+	Code:       10402,
+	HTTPStatus: http.StatusForbidden,
+	Message:    "Notification secret is invalid",
+}
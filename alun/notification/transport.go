@@ -0,0 +1,56 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// localTransport stores the notifications produced by the other packages of
+// the same process
+type localTransport struct{}
+
+// Send stores the notifications
+func (lt localTransport) Send(notifications []core.Notification) error {
+	toCreate, err := newNotifications(notifications, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if errMsg := createNotifications(toCreate); errMsg != nil {
+		return errMsg.Error
+	}
+
+	return nil
+}
+
+// newNotifications converts produced notifications into unread notifications.
+// All notifications are rejected if one of them has no valid user or no type
+func newNotifications(notifications []core.Notification, now time.Time) ([]Notification, error) {
+	toCreate := make([]Notification, 0, len(notifications))
+
+	for _, notification := range notifications {
+		userID, err := primitive.ObjectIDFromHex(notification.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("User ID <%s> is invalid", notification.UserID)
+		}
+		if strings.TrimSpace(notification.Type) == "" {
+			return nil, fmt.Errorf("Type is missing for user <%s>", notification.UserID)
+		}
+
+		toCreate = append(toCreate, Notification{
+			ID:        primitive.NewObjectID(),
+			UserID:    userID,
+			Type:      notification.Type,
+			Title:     strings.TrimSpace(notification.Title),
+			Message:   strings.TrimSpace(notification.Message),
+			Data:      notification.Data,
+			CreatedAt: now,
+		})
+	}
+
+	return toCreate, nil
+}
@@ -0,0 +1,241 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/pkg/crypto"
+	"github.com/dgrijalva/jwt-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	accessTokenLength        = 32
+	accessTokenNameMaxLength = 100
+	// Number of characters at the end of a token displayed to recognize it
+	accessTokenHintLength = 4
+	// The last usage of a token is saved at most every interval
+	accessTokenUsageInterval = time.Minute
+)
+
+// errAccessTokenExpired is returned when verifying an expired token
+var errAccessTokenExpired = errors.New("personal access token is expired")
+
+// PersonalAccessToken is a long-lived token of an user, for scripts and
+// integrations. Only its hash is saved and the token is shown once at
+// creation
+type PersonalAccessToken struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	UserID     primitive.ObjectID `json:"userId" bson:"userId"`
+	Name       string             `json:"name" bson:"name"`
+	Scopes     []string           `json:"scopes" bson:"scopes"`
+	Hint       string             `json:"hint" bson:"hint"` // last characters of the token
+	Hash       string             `json:"-" bson:"hash"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt  *time.Time         `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"` // never expires if empty
+	LastUsedAt *time.Time         `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+}
+
+// accessTokenRequest creates a personal access token
+type accessTokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// createdAccessToken is the only response with the token
+type createdAccessToken struct {
+	PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// newAccessToken validates a request and generates the token
+func newAccessToken(userID primitive.ObjectID, tokenReq accessTokenRequest, now time.Time) (PersonalAccessToken, string, *core.ServiceMessage) {
+	name := strings.TrimSpace(tokenReq.Name)
+	if name == "" || len(name) > accessTokenNameMaxLength {
+		return PersonalAccessToken{}, "", newAccessTokenInvalid(
+			fmt.Errorf("name is required, up to %d characters", accessTokenNameMaxLength))
+	}
+
+	if len(tokenReq.Scopes) == 0 {
+		return PersonalAccessToken{}, "", newAccessTokenInvalid(fmt.Errorf("at least one scope is required"))
+	}
+	scopes := make([]string, 0, len(tokenReq.Scopes))
+	for _, scope := range tokenReq.Scopes {
+		if !core.IsKnownScope(scope) {
+			return PersonalAccessToken{}, "", newAccessTokenInvalid(fmt.Errorf("unknown scope %s", scope))
+		}
+		if !containsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if tokenReq.ExpiresAt != nil && !tokenReq.ExpiresAt.After(now) {
+		return PersonalAccessToken{}, "", newAccessTokenInvalid(fmt.Errorf("expiration must be in the future"))
+	}
+
+	random, err := crypto.GenerateRandomString(accessTokenLength)
+	if err != nil {
+		return PersonalAccessToken{}, "", core.NewServiceErrorMessage(err)
+	}
+	token := core.PersonalAccessTokenPrefix + strings.TrimRight(random, "=")
+
+	return PersonalAccessToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		Hint:      token[len(token)-accessTokenHintLength:],
+		Hash:      hashSecretCode(token),
+		CreatedAt: now,
+		ExpiresAt: tokenReq.ExpiresAt,
+	}, token, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// newAccessTokenClaims are the claims of a personal access token, never
+// admin and restricted to the token scopes
+func newAccessTokenClaims(pat PersonalAccessToken) *core.JwtClaims {
+	claims := &core.JwtClaims{
+		UserID: pat.UserID.Hex(),
+		Scopes: pat.Scopes,
+		StandardClaims: jwt.StandardClaims{
+			Id:       pat.ID.Hex(),
+			IssuedAt: pat.CreatedAt.Unix(),
+		},
+	}
+	if pat.ExpiresAt != nil {
+		claims.ExpiresAt = pat.ExpiresAt.Unix()
+	}
+
+	return claims
+}
+
+// localAccessTokenVerifier verifies the personal access tokens from the
+// database, for the services of the same process
+type localAccessTokenVerifier struct{}
+
+func (localAccessTokenVerifier) VerifyPersonalAccessToken(token string) (*core.JwtClaims, error) {
+	now := time.Now()
+	pat, err := findAccessTokenByHash(hashSecretCode(token))
+	if err != nil {
+		return nil, err
+	}
+	if pat.ExpiresAt != nil && !pat.ExpiresAt.After(now) {
+		return nil, errAccessTokenExpired
+	}
+
+	user, err := findUserByID(pat.UserID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDisabled {
+		return nil, errUserDisabled
+	}
+
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) >= accessTokenUsageInterval {
+		if err := updateAccessTokenUsage(pat.ID, now); err != nil {
+			userLogger.Warn("[PAT] Usage of token %s not saved: %v", pat.ID.Hex(), err)
+		}
+	}
+
+	return newAccessTokenClaims(pat), nil
+}
+
+// handleCreateAccessToken creates a personal access token. The token is only
+// returned in this response
+func handleCreateAccessToken(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	var tokenReq accessTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&tokenReq); err != nil {
+		newAccessTokenInvalid(err).Write(w, r)
+		return
+	}
+
+	userID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "userId"))
+	pat, token, errMsg := newAccessToken(userID, tokenReq, time.Now())
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	if err := createAccessToken(pat); err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createdAccessToken{PersonalAccessToken: pat, Token: token})
+}
+
+// handleListAccessTokens lists the personal access tokens of an user, most
+// recent first
+func handleListAccessTokens(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	userID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "userId"))
+	tokens, err := findAccessTokens(userID)
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// handleDeleteAccessToken revokes a personal access token
+func handleDeleteAccessToken(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	userID, _ := primitive.ObjectIDFromHex(core.GetVar(r, "userId"))
+	tokenID, err := primitive.ObjectIDFromHex(core.GetVar(r, "tokenId"))
+	if err != nil {
+		accessTokenNotFound.Write(w, r)
+		return
+	}
+
+	if errMsg := deleteAccessToken(userID, tokenID); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleVerifyAccessToken verifies a personal access token for the other
+// services in microservice mode
+func handleVerifyAccessToken(w http.ResponseWriter, r *http.Request) {
+	if !hasRevocationSecret(r) {
+		revocationSecretInvalid.Write(w, r)
+		return
+	}
+
+	var verification core.AccessTokenVerification
+	json.NewDecoder(r.Body).Decode(&verification)
+
+	claims, err := localAccessTokenVerifier{}.VerifyPersonalAccessToken(verification.Token)
+	if err != nil {
+		accessTokenNotFound.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(claims)
+}
+
+// newAccessTokenInvalid details why a personal access token request is
+// invalid
+func newAccessTokenInvalid(err error) *core.ServiceMessage {
+	msg := *accessTokenInvalid
+	msg.Message = fmt.Sprintf("%s: %v", accessTokenInvalid.Message, err)
+
+	return &msg
+}
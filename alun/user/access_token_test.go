@@ -0,0 +1,182 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNewAccessToken(t *testing.T) {
+	now := time.Now()
+	userID := primitive.NewObjectID()
+	past := now.Add(-time.Hour)
+
+	invalidRequests := map[string]accessTokenRequest{
+		"MissingName":   {Name: " ", Scopes: []string{core.ScopeMemoRead}},
+		"MissingScopes": {Name: "Backup"},
+		"UnknownScope":  {Name: "Backup", Scopes: []string{"user:write"}},
+		"PastExpiry":    {Name: "Backup", Scopes: []string{core.ScopeMemoRead}, ExpiresAt: &past},
+	}
+	for name, tokenReq := range invalidRequests {
+		_, _, errMsg := newAccessToken(userID, tokenReq, now)
+		testutils.Assert(t, testutils.CallFromTestFile, errMsg != nil && errMsg.Code == accessTokenInvalid.Code,
+			"%s should be invalid: %v", name, errMsg)
+	}
+
+	pat, token, errMsg := newAccessToken(userID, accessTokenRequest{
+		Name:   " Backup ",
+		Scopes: []string{core.ScopeMemoRead, core.ScopeMemoRead},
+	}, now)
+	testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Request should be valid: %v", errMsg)
+	testutils.Assert(t, testutils.CallFromTestFile, strings.HasPrefix(token, core.PersonalAccessTokenPrefix),
+		"Token should be prefixed: %s", token)
+	testutils.Equals(t, testutils.CallFromTestFile, "Backup", pat.Name)
+	testutils.Equals(t, testutils.CallFromTestFile, []string{core.ScopeMemoRead}, pat.Scopes)
+	testutils.Equals(t, testutils.CallFromTestFile, hashSecretCode(token), pat.Hash)
+	testutils.Assert(t, testutils.CallFromTestFile, strings.HasSuffix(token, pat.Hint), "Hint should end the token")
+}
+
+func TestAccessTokenClaims(t *testing.T) {
+	claims := newAccessTokenClaims(PersonalAccessToken{
+		ID:     primitive.NewObjectID(),
+		UserID: primitive.NewObjectID(),
+		Scopes: []string{core.ScopeMemoWrite},
+	})
+
+	testutils.Assert(t, testutils.CallFromTestFile, claims.IsPersonalAccessToken() && !claims.IsAdmin,
+		"Claims should be a non admin access token: %+v", claims)
+	testutils.Assert(t, testutils.CallFromTestFile, claims.HasScope(core.ScopeMemoRead) && claims.HasScope(core.ScopeMemoWrite),
+		"Write scope should grant read scope")
+	testutils.Assert(t, testutils.CallFromTestFile, !claims.HasScope(core.ScopeNotificationRead),
+		"Other scopes should not be granted")
+	testutils.Assert(t, testutils.CallFromTestFile, core.JwtClaims{}.HasScope(core.ScopeNotificationWrite),
+		"Login tokens should grant all scopes")
+}
+
+func TestScopedAccessCheckers(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	pat := core.JwtClaims{UserID: "pouet", Scopes: []string{core.ScopeMemoRead}}
+	readMemo := core.RequireScope(core.ScopeMemoRead, core.CheckIfLogged)
+	writeMemo := core.RequireScope(core.ScopeMemoWrite, core.CheckIfLogged)
+
+	checkers := map[string]struct {
+		checker  core.AccessChecker
+		expected core.Access
+	}{
+		"GrantedScope":        {readMemo, core.Access{IsAllowed: true, IsScoped: true}},
+		"MissingScope":        {writeMemo, core.Access{}},
+		"AnyWithoutScope":     {core.Any(writeMemo, core.CheckIfLogged), core.Access{IsAllowed: true}},
+		"AnyPrefersScoped":    {core.Any(core.CheckIfLogged, readMemo), core.Access{IsAllowed: true, IsScoped: true}},
+		"NotIsNeverScoped":    {core.Not(writeMemo), core.Access{IsAllowed: true}},
+		"AllWithScope":        {core.All(readMemo, core.CheckIfLogged), core.Access{IsAllowed: true, IsScoped: true}},
+		"AllWithMissingScope": {core.All(core.CheckIfLogged, writeMemo), core.Access{}},
+	}
+	for name, check := range checkers {
+		access := check.checker(r, pat)
+		testutils.Assert(t, testutils.CallFromTestFile, access == check.expected,
+			"%s should be %+v but is %+v", name, check.expected, access)
+	}
+}
+
+func TestE2EAccessTokens(t *testing.T) {
+	_, adminToken, basicUser, basicToken := setupUserBasicAndAdmin(t)
+	tokensPath := fmt.Sprintf("detail/%s/tokens", basicUser.ID.Hex())
+
+	t.Cleanup(func() {
+		tearDownBasicAndAdmin(t)
+		dbUserTokenCollection.DeleteMany(context.TODO(), bson.M{"userId": basicUser.ID})
+	})
+
+	// Mimics an endpoint of the memo service
+	scopedHandler := core.DoIfAccess(core.RequireScope(core.ScopeMemoRead, core.CheckIfLogged),
+		func(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+			w.WriteHeader(http.StatusOK)
+		})
+	callScoped := func(t *testing.T, token string, expectedStatus int) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/boards", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		scopedHandler.ServeHTTP(rr, r)
+		testutils.Equals(t, testutils.CallFromHelperMethod, expectedStatus, rr.Code)
+	}
+
+	var created createdAccessToken
+
+	t.Run("AdminCannotCreateForOtherUser", func(t *testing.T) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               tokensPath,
+			Method:             http.MethodPost,
+			AuthToken:          adminToken,
+			Payload:            accessTokenRequest{Name: "Backup", Scopes: []string{core.ScopeMemoRead}},
+			ExpectedHTTPStatus: http.StatusUnauthorized,
+		})
+	})
+
+	t.Run("CreateAndList", func(t *testing.T) {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               tokensPath,
+			Method:             http.MethodPost,
+			AuthToken:          basicToken,
+			Payload:            accessTokenRequest{Name: "Backup", Scopes: []string{core.ScopeMemoRead}},
+			ExpectedHTTPStatus: http.StatusCreated,
+		})
+		json.NewDecoder(rr.Body).Decode(&created)
+
+		rr = apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               tokensPath,
+			Method:             http.MethodGet,
+			AuthToken:          basicToken,
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+		body := rr.Body.String()
+		testutils.Assert(t, testutils.CallFromTestFile, !strings.Contains(body, created.Token) && !strings.Contains(body, created.Hash),
+			"Token and hash should not be listed: %s", body)
+		testutils.Assert(t, testutils.CallFromTestFile, strings.Contains(body, created.Hint), "Hint should be listed: %s", body)
+	})
+
+	t.Run("ScopesAreEnforced", func(t *testing.T) {
+		callScoped(t, created.Token, http.StatusOK)
+		callScoped(t, created.Token+"pouet", http.StatusForbidden)
+
+		// Endpoints without scope reject personal access tokens
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("detail/%s", basicUser.ID.Hex()),
+			Method:             http.MethodGet,
+			AuthToken:          created.Token,
+			ExpectedHTTPStatus: http.StatusForbidden,
+		})
+
+		pat, _ := findAccessTokenByHash(hashSecretCode(created.Token))
+		testutils.Assert(t, testutils.CallFromTestFile, pat.LastUsedAt != nil, "Usage should be tracked")
+	})
+
+	t.Run("InternalVerificationNeedsSecret", func(t *testing.T) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "internal/tokens/verify",
+			Method:             http.MethodPost,
+			Payload:            core.AccessTokenVerification{Token: created.Token},
+			ExpectedHTTPStatus: http.StatusUnauthorized,
+		})
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("%s/%s", tokensPath, created.ID.Hex()),
+			Method:             http.MethodDelete,
+			AuthToken:          basicToken,
+			ExpectedHTTPStatus: http.StatusNoContent,
+		})
+
+		callScoped(t, created.Token, http.StatusForbidden)
+	})
+}
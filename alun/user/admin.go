@@ -0,0 +1,339 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	adminListDefaultLimit = 20
+	adminListMaxLimit     = 100
+)
+
+// Audited admin actions
+const (
+	auditUserPromoted       = "user.promoted"
+	auditUserDemoted        = "user.demoted"
+	auditUserDisabled       = "user.disabled"
+	auditUserEnabled        = "user.enabled"
+	auditUserPasswordReset  = "user.passwordReset"
+	auditUserTwoFactorReset = "user.twoFactorReset"
+)
+
+// UserList is a page of users, sorted by email
+type UserList struct {
+	Items []User `json:"items"`
+	Total int64  `json:"total"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+}
+
+// UserDetail is an user seen by an admin, with its activity. LastLoginAt is
+// the last authentication with credentials and LastSeenAt the last refresh
+type UserDetail struct {
+	User
+	LastLoginAt    *time.Time `json:"lastLoginAt,omitempty"`
+	LastSeenAt     *time.Time `json:"lastSeenAt,omitempty"`
+	ActiveSessions int        `json:"activeSessions"`
+}
+
+// AuditEntry traces an action of an admin on an user or on a role
+type AuditEntry struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	ActorID   primitive.ObjectID `json:"actorId" bson:"actorId"`
+	TargetID  primitive.ObjectID `json:"targetId" bson:"targetId"`
+	Role      string             `json:"role,omitempty" bson:"role,omitempty"` // name of the role, for role actions
+	Action    string             `json:"action" bson:"action"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// AuditList is a page of audit entries, most recent first
+type AuditList struct {
+	Items []AuditEntry `json:"items"`
+	Total int64        `json:"total"`
+	Page  int          `json:"page"`
+	Limit int          `json:"limit"`
+}
+
+// userFlagRequest changes a boolean flag of an user
+type userFlagRequest struct {
+	Value *bool `json:"value"`
+}
+
+// parsePagination reads the "page", starting at 1, and "limit" query
+// parameters. Invalid values are replaced by the default ones
+func parsePagination(query url.Values) (int, int) {
+	page := 1
+	if pageParam, err := strconv.Atoi(query.Get("page")); err == nil && pageParam > 0 {
+		page = pageParam
+	}
+	limit := adminListDefaultLimit
+	if limitParam, err := strconv.Atoi(query.Get("limit")); err == nil && limitParam > 0 && limitParam <= adminListMaxLimit {
+		limit = limitParam
+	}
+
+	return page, limit
+}
+
+// newUsersFilter filters the users with the query parameters:
+//   - "q" searches in the email and the username, ignoring the case
+//   - "isAdmin" and "isDisabled" are "true" or "false", other values are
+//     ignored
+func newUsersFilter(query url.Values) bson.M {
+	filter := bson.M{}
+
+	if search := strings.TrimSpace(query.Get("q")); search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"email": pattern},
+			bson.M{"username": pattern},
+		}
+	}
+
+	if isAdmin, err := strconv.ParseBool(query.Get("isAdmin")); err == nil {
+		filter["isAdmin"] = isAdmin
+	}
+
+	// Enabled users have no isDisabled field
+	if isDisabled, err := strconv.ParseBool(query.Get("isDisabled")); err == nil {
+		if isDisabled {
+			filter["isDisabled"] = true
+		} else {
+			filter["isDisabled"] = bson.M{"$ne": true}
+		}
+	}
+
+	return filter
+}
+
+// newAuditEntry traces an admin action at the current time
+func newAuditEntry(claims core.JwtClaims, targetID primitive.ObjectID, action string) AuditEntry {
+	actorID, _ := primitive.ObjectIDFromHex(claims.UserID)
+
+	return AuditEntry{
+		ID:        primitive.NewObjectID(),
+		ActorID:   actorID,
+		TargetID:  targetID,
+		Action:    action,
+		CreatedAt: time.Now(),
+	}
+}
+
+// checkManagedUser prevents an user manager, who is not an admin, from acting
+// on an admin
+func checkManagedUser(claims core.JwtClaims, userID string) *core.ServiceMessage {
+	user, err := findUserByID(userID)
+	if err != nil {
+		return userNotFound
+	}
+	if user.IsAdmin && !claims.IsAdmin {
+		return adminTargetForbidden
+	}
+
+	return nil
+}
+
+// auditAction saves an admin action which is already done. A failure is only
+// logged as the action cannot be rolled back
+func auditAction(claims core.JwtClaims, targetID primitive.ObjectID, action string) {
+	entry := newAuditEntry(claims, targetID, action)
+	if errMsg := createAuditEntry(entry); errMsg != nil {
+		userLogger.Warn("[Admin] Audit of %s by %s on %s failed: %v",
+			action, claims.UserID, targetID.Hex(), errMsg.Message)
+	}
+}
+
+// decodeUserFlag reads the flag of a request, required
+func decodeUserFlag(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	var flagReq userFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&flagReq); err != nil || flagReq.Value == nil {
+		newAdminRequestInvalid(fmt.Errorf("a boolean \"value\" is required")).Write(w, r)
+		return false, false
+	}
+
+	return *flagReq.Value, true
+}
+
+// handleListUsers lists the users matching the query filters
+func handleListUsers(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	query := r.URL.Query()
+	page, limit := parsePagination(query)
+
+	users, errMsg := findUsers(newUsersFilter(query), page, limit)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(users)
+}
+
+// handleGetUserDetail returns an user with its last login and sessions
+func handleGetUserDetail(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	userID := core.GetVar(r, "userId")
+	user, err := findUserByID(userID)
+	if err != nil {
+		userNotFound.Write(w, r)
+		return
+	}
+
+	detail, errMsg := findUserDetail(user, time.Now())
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(detail)
+}
+
+// handleSetUserAdmin promotes or demotes an user. A demoted user is logged
+// out as its tokens still have the admin claim
+func handleSetUserAdmin(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	isAdmin, ok := decodeUserFlag(w, r)
+	if !ok {
+		return
+	}
+
+	userID := core.GetVar(r, "userId")
+	if userID == claims.UserID {
+		adminSelfAction.Write(w, r)
+		return
+	}
+
+	user, errMsg := updateUserFlag(userID, "isAdmin", isAdmin)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	action := auditUserPromoted
+	if !isAdmin {
+		action = auditUserDemoted
+		if errMsg := revokeUserLogins(user.ID); errMsg != nil {
+			errMsg.Write(w, r)
+			return
+		}
+	}
+	auditAction(claims, user.ID, action)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// handleSetUserDisabled disables or re-enables an user. A disabled user
+// cannot login and is logged out
+func handleSetUserDisabled(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	isDisabled, ok := decodeUserFlag(w, r)
+	if !ok {
+		return
+	}
+
+	userID := core.GetVar(r, "userId")
+	if userID == claims.UserID {
+		adminSelfAction.Write(w, r)
+		return
+	}
+	if errMsg := checkManagedUser(claims, userID); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	user, errMsg := updateUserFlag(userID, "isDisabled", isDisabled)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	action := auditUserEnabled
+	if isDisabled {
+		action = auditUserDisabled
+		if errMsg := revokeUserLogins(user.ID); errMsg != nil {
+			errMsg.Write(w, r)
+			return
+		}
+	}
+	auditAction(claims, user.ID, action)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// handleForcePasswordReset removes the password of an user, logs it out and
+// sends a password reset email
+func handleForcePasswordReset(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	var pwdReq PasswordRequest
+	json.NewDecoder(r.Body).Decode(&pwdReq)
+
+	user, err := findUserByID(core.GetVar(r, "userId"))
+	if err != nil {
+		userNotFound.Write(w, r)
+		return
+	}
+	if user.IsAdmin && !claims.IsAdmin {
+		adminTargetForbidden.Write(w, r)
+		return
+	}
+
+	pwdReq.RequestType = userPwdRequestPwdReset
+	pwdReq.Email = user.Email
+	resetUser, err := pwdReq.createPwdResetToken()
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+
+	if errMsg := forcePasswordReset(user.ID, resetUser.PwdResetToken); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	if errMsg := revokeUserLogins(user.ID); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	auditAction(claims, user.ID, auditUserPasswordReset)
+
+	sendPwdResetEmail(resetUser, pwdReq.RedirectURL)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListAudit lists the admin actions, on a single user with the "userId"
+// query parameter
+func handleListAudit(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	query := r.URL.Query()
+	page, limit := parsePagination(query)
+
+	filter := bson.M{}
+	if targetID, err := primitive.ObjectIDFromHex(query.Get("userId")); err == nil {
+		filter["targetId"] = targetID
+	}
+
+	entries, errMsg := findAuditEntries(filter, page, limit)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entries)
+}
+
+// newAdminRequestInvalid details why an admin request is invalid
+func newAdminRequestInvalid(err error) *core.ServiceMessage {
+	msg := *adminRequestInvalid
+	msg.Message = fmt.Sprintf("%s: %v", adminRequestInvalid.Message, err)
+
+	return &msg
+}
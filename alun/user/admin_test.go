@@ -0,0 +1,172 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNewUsersFilter(t *testing.T) {
+	filter := newUsersFilter(url.Values{})
+	testutils.Equals(t, testutils.CallFromTestFile, 0, len(filter))
+
+	filter = newUsersFilter(url.Values{"q": {" a.b "}, "isAdmin": {"true"}, "isDisabled": {"false"}})
+	pattern := primitive.Regex{Pattern: `a\.b`, Options: "i"}
+	testutils.Equals(t, testutils.CallFromTestFile, bson.A{bson.M{"email": pattern}, bson.M{"username": pattern}}, filter["$or"])
+	testutils.Equals(t, testutils.CallFromTestFile, true, filter["isAdmin"])
+	testutils.Equals(t, testutils.CallFromTestFile, bson.M{"$ne": true}, filter["isDisabled"])
+
+	filter = newUsersFilter(url.Values{"isAdmin": {"pouet"}, "isDisabled": {"true"}})
+	_, hasAdminFilter := filter["isAdmin"]
+	testutils.Assert(t, testutils.CallFromTestFile, !hasAdminFilter, "Invalid isAdmin should be ignored")
+	testutils.Equals(t, testutils.CallFromTestFile, true, filter["isDisabled"])
+}
+
+func TestE2EAdmin(t *testing.T) {
+	adminUser, adminToken, basicUser, basicToken := setupUserBasicAndAdmin(t)
+
+	t.Cleanup(func() {
+		tearDownBasicAndAdmin(t)
+		tearDownLogins(t, basicUser.ID)
+	})
+
+	adminPath := func(format string, args ...interface{}) string {
+		return fmt.Sprintf("admin/users/%s%s", basicUser.ID.Hex(), fmt.Sprintf(format, args...))
+	}
+	login := func(t *testing.T, expectedStatus int) successfulLogin {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:   "login",
+			Method: http.MethodPost,
+			Payload: authenticatedUser{
+				User:     User{BaseUser: BaseUser{Email: basicUser.Email}},
+				Password: userBasicPassword,
+			},
+			ExpectedHTTPStatus: expectedStatus,
+		})
+
+		var tokens successfulLogin
+		json.NewDecoder(rr.Body).Decode(&tokens)
+		return tokens
+	}
+	setFlag := func(t *testing.T, path string, token string, value bool, expectedStatus int) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               path,
+			Method:             http.MethodPut,
+			Payload:            userFlagRequest{Value: &value},
+			AuthToken:          token,
+			ExpectedHTTPStatus: expectedStatus,
+		})
+	}
+	getUser := func(t *testing.T, token string, expectedStatus int) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("detail/%s", basicUser.ID.Hex()),
+			Method:             http.MethodGet,
+			AuthToken:          token,
+			ExpectedHTTPStatus: expectedStatus,
+		})
+	}
+
+	t.Run("AdminOnly", func(t *testing.T) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "admin/users",
+			Method:             http.MethodGet,
+			AuthToken:          basicToken,
+			ExpectedHTTPStatus: http.StatusUnauthorized,
+		})
+	})
+
+	t.Run("ListUsers", func(t *testing.T) {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("admin/users?q=%s&isAdmin=false", url.QueryEscape(basicUser.Email)),
+			Method:             http.MethodGet,
+			AuthToken:          adminToken,
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+
+		var users UserList
+		json.NewDecoder(rr.Body).Decode(&users)
+		testutils.Assert(t, testutils.CallFromTestFile, users.Total == 1 && users.Items[0].ID == basicUser.ID,
+			"Only the basic user should be found: %+v", users)
+	})
+
+	t.Run("UserDetail", func(t *testing.T) {
+		login(t, http.StatusOK)
+
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               adminPath(""),
+			Method:             http.MethodGet,
+			AuthToken:          adminToken,
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+
+		var detail UserDetail
+		json.NewDecoder(rr.Body).Decode(&detail)
+		testutils.Assert(t, testutils.CallFromTestFile, detail.LastLoginAt != nil && detail.ActiveSessions == 1,
+			"Last login should be found: %+v", detail)
+	})
+
+	t.Run("SelfAction", func(t *testing.T) {
+		setFlag(t, fmt.Sprintf("admin/users/%s/admin", adminUser.ID.Hex()), adminToken, false, http.StatusBadRequest)
+		setFlag(t, fmt.Sprintf("admin/users/%s/disabled", adminUser.ID.Hex()), adminToken, true, http.StatusBadRequest)
+	})
+
+	t.Run("PromoteAndDemote", func(t *testing.T) {
+		setFlag(t, adminPath("/admin"), adminToken, true, http.StatusOK)
+		promotedToken := login(t, http.StatusOK).Token
+
+		setFlag(t, adminPath("/admin"), adminToken, false, http.StatusOK)
+		getUser(t, promotedToken, http.StatusForbidden)
+	})
+
+	t.Run("DisableAndEnable", func(t *testing.T) {
+		tokens := login(t, http.StatusOK)
+
+		setFlag(t, adminPath("/disabled"), adminToken, true, http.StatusOK)
+		getUser(t, tokens.Token, http.StatusForbidden)
+		login(t, http.StatusForbidden)
+
+		setFlag(t, adminPath("/disabled"), adminToken, false, http.StatusOK)
+		login(t, http.StatusOK)
+	})
+
+	t.Run("ForcePasswordReset", func(t *testing.T) {
+		tokens := login(t, http.StatusOK)
+
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               adminPath("/password/reset"),
+			Method:             http.MethodPost,
+			Payload:            PasswordRequest{RedirectURL: "http://localhost/reset/"},
+			AuthToken:          adminToken,
+			ExpectedHTTPStatus: http.StatusNoContent,
+		})
+
+		getUser(t, tokens.Token, http.StatusForbidden)
+		login(t, http.StatusForbidden)
+	})
+
+	t.Run("AuditTrail", func(t *testing.T) {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("admin/audit?userId=%s", basicUser.ID.Hex()),
+			Method:             http.MethodGet,
+			AuthToken:          adminToken,
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+
+		var audit AuditList
+		json.NewDecoder(rr.Body).Decode(&audit)
+		actions := make([]string, 0)
+		for _, entry := range audit.Items {
+			testutils.Equals(t, testutils.CallFromTestFile, adminUser.ID, entry.ActorID)
+			actions = append(actions, entry.Action)
+		}
+		testutils.Equals(t, testutils.CallFromTestFile, []string{
+			auditUserPasswordReset, auditUserEnabled, auditUserDisabled, auditUserDemoted, auditUserPromoted,
+		}, actions)
+	})
+}
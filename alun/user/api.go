@@ -1,24 +1,23 @@
 package user
 
 import (
-	"net/http"
-
 	"github.com/Al-un/alun-api/alun/core"
 )
 
 // UserAPI exposes User endpoints
 var UserAPI *core.API
 
-// isAdminOrOwnUser checks access for user modification: only self data
-// are allowed
-var isAdminOrOwnUser = func(r *http.Request, jwtClaims core.JwtClaims) bool {
-	if jwtClaims.IsAdmin {
-		return true
-	}
-
-	userID := core.GetVar(r, "userId")
-	return userID == jwtClaims.UserID
-}
+// Access checkers of the endpoints
+var (
+	// The data of an user are accessed by the user or by an admin
+	checkOwnerOrAdmin = core.Any(core.CheckIfAdmin, core.IsOwnerOf("userId"))
+	// The secrets of an user, such as the 2FA enrollment, are not readable by
+	// admins
+	checkOwner       = core.IsOwnerOf("userId")
+	checkUsersManage = core.HasPermission(core.PermissionUsersManage)
+	checkUsersRead   = core.Any(core.HasPermission(core.PermissionUsersRead), checkUsersManage)
+	checkRolesManage = core.HasPermission(core.PermissionRolesManage)
+)
 
 func initAPI() {
 	apiRoot := "users"
@@ -26,11 +25,39 @@ func initAPI() {
 	UserAPI.AddMiddleware(core.AddJSONHeaders)
 
 	UserAPI.AddPublicEndpoint("login", "POST", core.APIv1, authUser)
+	UserAPI.AddPublicEndpoint("login/2fa", "POST", core.APIv1, handleTwoFactorLogin)
+	UserAPI.AddPublicEndpoint("refresh", "POST", core.APIv1, handleRefreshLogin)
 	UserAPI.AddProtectedEndpoint("logout", "POST", core.APIv1, core.CheckIfLogged, logoutUser)
 	UserAPI.AddPublicEndpoint("register", "POST", core.APIv1, handleRequestPassword)
 	UserAPI.AddPublicEndpoint("password/update", "POST", core.APIv1, handleUpdatePassword)
 	UserAPI.AddPublicEndpoint("password/request", "POST", core.APIv1, handleRequestPassword)
-	UserAPI.AddProtectedEndpoint("detail/{userId}", "GET", core.APIv1, isAdminOrOwnUser, handleGetUser)
-	UserAPI.AddProtectedEndpoint("detail/{userId}", "PUT", core.APIv1, isAdminOrOwnUser, handleUpdateUser)
-	UserAPI.AddProtectedEndpoint("detail/{userId}", "DELETE", core.APIv1, isAdminOrOwnUser, handleDeleteUser)
+	UserAPI.AddPublicEndpoint("email/confirm", "POST", core.APIv1, handleConfirmEmailChange)
+	UserAPI.AddPublicEndpoint("email/revert", "POST", core.APIv1, handleRevertEmailChange)
+	UserAPI.AddPublicEndpoint("jwks", "GET", core.APIv1, handleGetJWKS)
+	UserAPI.AddPublicEndpoint("internal/revocations", "GET", core.APIv1, handleGetRevocations)
+	UserAPI.AddPublicEndpoint("internal/tokens/verify", "POST", core.APIv1, handleVerifyAccessToken)
+	UserAPI.AddPublicEndpoint("internal/users/contacts", "POST", core.APIv1, handleFindUserContacts)
+	UserAPI.AddProtectedEndpoint("detail/{userId}", "GET", core.APIv1, checkOwnerOrAdmin, handleGetUser)
+	UserAPI.AddProtectedEndpoint("detail/{userId}", "PUT", core.APIv1, checkOwnerOrAdmin, handleUpdateUser)
+	UserAPI.AddProtectedEndpoint("detail/{userId}", "DELETE", core.APIv1, checkOwnerOrAdmin, handleDeleteUser)
+	UserAPI.AddProtectedEndpoint("detail/{userId}/email", "POST", core.APIv1, checkOwnerOrAdmin, handleRequestEmailChange)
+	UserAPI.AddProtectedEndpoint("detail/{userId}/2fa", "POST", core.APIv1, checkOwner, handleEnrollTwoFactor)
+	UserAPI.AddProtectedEndpoint("detail/{userId}/2fa/confirm", "POST", core.APIv1, checkOwner, handleConfirmTwoFactor)
+	UserAPI.AddProtectedEndpoint("detail/{userId}/tokens", "GET", core.APIv1, checkOwnerOrAdmin, handleListAccessTokens)
+	UserAPI.AddProtectedEndpoint("detail/{userId}/tokens", "POST", core.APIv1, checkOwner, handleCreateAccessToken)
+	UserAPI.AddProtectedEndpoint("detail/{userId}/tokens/{tokenId}", "DELETE", core.APIv1, checkOwnerOrAdmin, handleDeleteAccessToken)
+	UserAPI.AddProtectedEndpoint("detail/{userId}/sessions", "GET", core.APIv1, checkOwnerOrAdmin, handleListSessions)
+	UserAPI.AddProtectedEndpoint("detail/{userId}/sessions", "DELETE", core.APIv1, checkOwnerOrAdmin, handleRevokeOtherSessions)
+	UserAPI.AddProtectedEndpoint("detail/{userId}/sessions/{sessionId}", "DELETE", core.APIv1, checkOwnerOrAdmin, handleRevokeSession)
+	UserAPI.AddProtectedEndpoint("admin/users", "GET", core.APIv1, checkUsersRead, handleListUsers)
+	UserAPI.AddProtectedEndpoint("admin/users/{userId}", "GET", core.APIv1, checkUsersRead, handleGetUserDetail)
+	UserAPI.AddProtectedEndpoint("admin/users/{userId}/admin", "PUT", core.APIv1, core.CheckIfAdmin, handleSetUserAdmin)
+	UserAPI.AddProtectedEndpoint("admin/users/{userId}/disabled", "PUT", core.APIv1, checkUsersManage, handleSetUserDisabled)
+	UserAPI.AddProtectedEndpoint("admin/users/{userId}/password/reset", "POST", core.APIv1, checkUsersManage, handleForcePasswordReset)
+	UserAPI.AddProtectedEndpoint("admin/users/{userId}/2fa", "DELETE", core.APIv1, checkUsersManage, handleResetTwoFactor)
+	UserAPI.AddProtectedEndpoint("admin/users/{userId}/roles", "PUT", core.APIv1, checkRolesManage, handleSetUserRoles)
+	UserAPI.AddProtectedEndpoint("admin/roles", "GET", core.APIv1, checkRolesManage, handleListRoles)
+	UserAPI.AddProtectedEndpoint("admin/roles/{roleName}", "PUT", core.APIv1, checkRolesManage, handleSaveRole)
+	UserAPI.AddProtectedEndpoint("admin/roles/{roleName}", "DELETE", core.APIv1, checkRolesManage, handleDeleteRole)
+	UserAPI.AddProtectedEndpoint("admin/audit", "GET", core.APIv1, checkUsersRead, handleListAudit)
 }
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/Al-un/alun-api/alun/testutils"
 	"go.mongodb.org/mongo-driver/bson"
@@ -213,7 +214,7 @@ func TestEndpointLogin(t *testing.T) {
 		var login successfulLogin
 		json.NewDecoder(rr.Body).Decode(&login)
 
-		testutils.Assert(t, testutils.CallFromTestFile, login.Token != "",
+		testutils.Assert(t, testutils.CallFromTestFile, login.Token != "" && login.RefreshToken != "",
 			"Login failed: no token found in response %v",
 			rr.Body.String())
 	}
@@ -294,6 +295,89 @@ func TestEndpointLogin(t *testing.T) {
 	})
 }
 
+func TestEndpointRefresh(t *testing.T) {
+	t.Parallel()
+
+	_, _, basicUser, _ := setupUserBasicAndAdmin(t)
+
+	t.Cleanup(func() {
+		tearDownBasicAndAdmin(t)
+		tearDownLogins(t, basicUser.ID)
+	})
+
+	login := func(t *testing.T) successfulLogin {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:   "login",
+			Method: http.MethodPost,
+			Payload: authenticatedUser{
+				User:     User{BaseUser: BaseUser{Email: basicUser.Email}},
+				Password: userBasicPassword,
+			},
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+
+		var tokens successfulLogin
+		json.NewDecoder(rr.Body).Decode(&tokens)
+		return tokens
+	}
+	refresh := func(t *testing.T, refreshToken string, expectedStatus int) successfulLogin {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "refresh",
+			Method:             http.MethodPost,
+			Payload:            refreshRequest{RefreshToken: refreshToken},
+			ExpectedHTTPStatus: expectedStatus,
+		})
+
+		var tokens successfulLogin
+		json.NewDecoder(rr.Body).Decode(&tokens)
+		return tokens
+	}
+
+	t.Run("RefreshRotatesToken", func(t *testing.T) {
+		tokens := login(t)
+		testutils.Assert(t, testutils.CallFromTestFile, tokens.ExpiresIn == int64(accessTokenDuration/time.Second),
+			"Unexpected access token validity: %d", tokens.ExpiresIn)
+
+		refreshed := refresh(t, tokens.RefreshToken, http.StatusOK)
+		testutils.Assert(t, testutils.CallFromTestFile,
+			refreshed.Token != "" && refreshed.RefreshToken != "" && refreshed.RefreshToken != tokens.RefreshToken,
+			"Refresh should rotate the refresh token: %+v", refreshed)
+
+		refresh(t, refreshed.RefreshToken, http.StatusOK)
+	})
+
+	t.Run("ReuseRevokesFamily", func(t *testing.T) {
+		tokens := login(t)
+		refreshed := refresh(t, tokens.RefreshToken, http.StatusOK)
+
+		// Reusing the first token revokes the refreshed one as well
+		refresh(t, tokens.RefreshToken, http.StatusUnauthorized)
+		refresh(t, refreshed.RefreshToken, http.StatusUnauthorized)
+
+		// Other logins are not revoked
+		refresh(t, login(t).RefreshToken, http.StatusOK)
+	})
+
+	t.Run("LogoutRevokesRefreshToken", func(t *testing.T) {
+		tokens := login(t)
+		refreshed := refresh(t, tokens.RefreshToken, http.StatusOK)
+
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "logout",
+			Method:             http.MethodPost,
+			ExpectedHTTPStatus: http.StatusNoContent,
+			AuthToken:          refreshed.Token,
+		})
+
+		refresh(t, refreshed.RefreshToken, http.StatusUnauthorized)
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		refresh(t, "", http.StatusUnauthorized)
+		refresh(t, "pouet", http.StatusUnauthorized)
+	})
+}
+
 func TestEndpointGetUser(t *testing.T) {
 	t.Parallel()
 
@@ -356,14 +440,15 @@ func TestEndpointUpdateUser(t *testing.T) {
 		tearDownBasicAndAdmin(t)
 	})
 
-	checkUserUpdate := func(rr *httptest.ResponseRecorder, newUsername, newEmail string) {
+	// Email changes require a confirmation and are ignored
+	checkUserUpdate := func(rr *httptest.ResponseRecorder, newUsername string) {
 		var userResp User
 		json.NewDecoder(rr.Body).Decode(&userResp)
-		testutils.Equals(t, testutils.CallFromHelperMethod, newEmail, userResp.Email)
+		testutils.Equals(t, testutils.CallFromHelperMethod, basicUser.Email, userResp.Email)
 		testutils.Equals(t, testutils.CallFromHelperMethod, newUsername, userResp.Username)
 
 		updatedUser, _ := findUserByID(basicUser.ID.Hex())
-		testutils.Equals(t, testutils.CallFromHelperMethod, newEmail, updatedUser.Email)
+		testutils.Equals(t, testutils.CallFromHelperMethod, basicUser.Email, updatedUser.Email)
 		testutils.Equals(t, testutils.CallFromHelperMethod, newUsername, updatedUser.Username)
 		testutils.Equals(t, testutils.CallFromHelperMethod, false, updatedUser.IsAdmin)
 	}
@@ -385,10 +470,9 @@ func TestEndpointUpdateUser(t *testing.T) {
 		}
 		rr := apiTester.TestPath(t, testInfo)
 
-		checkUserUpdate(rr, basicUserNewUsername, basicUserNewEmail)
+		checkUserUpdate(rr, basicUserNewUsername)
 	})
 
-	// Align basic user email with expected emails to tearDownBasicAndAdmin
 	t.Run("UpdateUserWithAdminToken", func(t2 *testing.T) {
 		testInfo = testutils.APITestInfo{
 			Path:      fmt.Sprintf("detail/%s", basicUser.ID.Hex()),
@@ -403,7 +487,7 @@ func TestEndpointUpdateUser(t *testing.T) {
 		}
 		rr := apiTester.TestPath(t, testInfo)
 
-		checkUserUpdate(rr, userBasicUsername, fmt.Sprintf("%s%s", t.Name(), userBasicEmail))
+		checkUserUpdate(rr, userBasicUsername)
 	})
 
 	t.Run("UpdateAdminUserWithBasicToken", func(t *testing.T) {
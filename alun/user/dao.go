@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -23,10 +24,12 @@ import (
 // dbUserCollectionName : user collection name
 var dbUserCollectionName string
 var dbUserLoginCollectionName string
+var dbUserRevocationCollectionName string
 
 // dbUserCollection : user collection instance
 var dbUserCollection *mongo.Collection
 var dbUserLoginCollection *mongo.Collection
+var dbUserRevocationCollection *mongo.Collection
 
 // Init the connection with MongoDB upon app initialisation
 func initDao() {
@@ -38,33 +41,152 @@ func initDao() {
 	// Initialisation: collections name
 	dbUserCollectionName = "al_users"
 	dbUserLoginCollectionName = "al_users_login"
+	dbUserRevocationCollectionName = "al_users_revocations"
 
 	// Initialisation: collections instances
 	dbUserCollection = mongoDb.Collection(dbUserCollectionName)
 	dbUserLoginCollection = mongoDb.Collection(dbUserLoginCollectionName)
+	dbUserRevocationCollection = mongoDb.Collection(dbUserRevocationCollectionName)
+
+	// Initialisation: indexes
+	// Only users changing their email have email change tokens
+	sparse := true
+	userIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{primitive.E{Key: "emailChange.token", Value: 1}},
+			Options: &options.IndexOptions{Sparse: &sparse},
+		},
+		{
+			Keys:    bson.D{primitive.E{Key: "emailChange.revertToken", Value: 1}},
+			Options: &options.IndexOptions{Sparse: &sparse},
+		},
+	}
+	if _, err := dbUserCollection.Indexes().CreateMany(context.TODO(), userIndexes); err != nil {
+		userLogger.Warn("[MongoDB] User indexes creation failed: %v", err)
+	}
+
+	loginIndexes := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "refreshHash", Value: 1}}},
+		{Keys: bson.D{primitive.E{Key: "familyId", Value: 1}}},
+		{Keys: bson.D{primitive.E{Key: "token.jti", Value: 1}}},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "userId", Value: 1},
+				primitive.E{Key: "token.status", Value: 1},
+			},
+		},
+	}
+	if _, err := dbUserLoginCollection.Indexes().CreateMany(context.TODO(), loginIndexes); err != nil {
+		userLogger.Warn("[MongoDB] Login indexes creation failed: %v", err)
+	}
+
+	// Revocations are useless once the token is expired
+	expireAfterSeconds := int32(0)
+	revocationIndexes := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "revokedAt", Value: 1}}},
+		{
+			Keys:    bson.D{primitive.E{Key: "expiresAt", Value: 1}},
+			Options: &options.IndexOptions{ExpireAfterSeconds: &expireAfterSeconds},
+		},
+	}
+	if _, err := dbUserRevocationCollection.Indexes().CreateMany(context.TODO(), revocationIndexes); err != nil {
+		userLogger.Warn("[MongoDB] Revocation indexes creation failed: %v", err)
+	}
+
+	initAdminDao(mongoDb)
+	initTwoFactorDao(mongoDb)
+	initAccessTokenDao(mongoDb)
+	initRoleDao(mongoDb)
 
 	userLogger.Info("[MongoDB] User initialisation!")
 }
 
 // ---------- CRUD ------------------------------------------------------------
 
-// findUser fetches an user for a given email and CLEAR password
+var (
+	// errInvalidCredentials is returned when the password of an user is not
+	// valid
+	errInvalidCredentials = errors.New("invalid credentials")
+	// errUserDisabled is returned when a disabled user has valid credentials
+	errUserDisabled = errors.New("user is disabled")
+)
+
+// findUserByEmailPassword fetches an user for a given email and CLEAR
+// password. Legacy password hashes are upgraded once the password is verified.
+//
+// A password is verified even if the email is unknown, so that the response
+// time does not tell whether an account exists
 func findUserByEmailPassword(email string, clearPassword string) (User, error) {
 	var authUser authenticatedUser
-	var hashedPassword = hashPassword(clearPassword)
 
-	filter := bson.M{"email": email, "password": hashedPassword}
+	filter := bson.M{"email": email}
 	if err := dbUserCollection.FindOne(context.TODO(), filter).Decode(&authUser); err != nil {
-		userLogger.Verbose("Credentials %s/%s (hashed: %s) are NOT valid T_T due to error: %v",
-			email, clearPassword, hashedPassword, err)
-		return User{}, err
+		userLogger.Verbose("Credentials of %s are NOT valid T_T due to error: %v", email, err)
+		if err != mongo.ErrNoDocuments {
+			return User{}, err
+		}
+
+		// Unknown emails are as slow as invalid passwords
+		checkCredentials(nil, clearPassword)
+		return User{}, errInvalidCredentials
 	}
 
-	userLogger.Verbose("Credentials %s/%s are valid \\o/", email, clearPassword)
+	isValid, needsRehash := checkCredentials(&authUser, clearPassword)
+	if !isValid {
+		userLogger.Verbose("Credentials of %s are NOT valid T_T", email)
+		return User{}, errInvalidCredentials
+	}
+
+	if needsRehash {
+		rehashPassword(authUser, clearPassword)
+	}
+
+	if authUser.IsDisabled {
+		userLogger.Verbose("Credentials of %s are valid but the user is disabled", email)
+		return User{}, errUserDisabled
+	}
+
+	userLogger.Verbose("Credentials of %s are valid \\o/", email)
 
 	return authUser.User, nil
 }
 
+// checkUserPassword verifies the CLEAR password of an user, before an action
+// which a stolen token must not be enough for. Legacy password hashes are
+// upgraded once the password is verified
+func checkUserPassword(userID primitive.ObjectID, clearPassword string) (bool, error) {
+	var authUser authenticatedUser
+
+	filter := bson.M{"_id": userID}
+	if err := dbUserCollection.FindOne(context.TODO(), filter).Decode(&authUser); err != nil {
+		return false, err
+	}
+
+	isValid, needsRehash := checkCredentials(&authUser, clearPassword)
+	if isValid && needsRehash {
+		rehashPassword(authUser, clearPassword)
+	}
+
+	return isValid, nil
+}
+
+// rehashPassword replaces the stored hash of a verified password with the
+// current hash. The hash is only replaced if the password was not changed in
+// the meantime. A failure is logged and the user keeps the previous hash
+func rehashPassword(authUser authenticatedUser, clearPassword string) {
+	hashedPassword, err := hashPassword(clearPassword)
+	if err != nil {
+		userLogger.Warn("[User] Rehashing password of user %s failed: %v", authUser.ID.Hex(), err)
+		return
+	}
+
+	filter := bson.M{"_id": authUser.ID, "password": authUser.Password}
+	update := bson.M{"$set": bson.M{"password": hashedPassword}}
+	if _, err := dbUserCollection.UpdateOne(context.TODO(), filter, update); err != nil {
+		userLogger.Warn("[User] Rehashing password of user %s failed: %v", authUser.ID.Hex(), err)
+	}
+}
+
 // findUserById fetches an user for a given ID in string format
 func findUserByID(userID string) (User, error) {
 	var user User
@@ -78,11 +200,9 @@ func findUserByID(userID string) (User, error) {
 	return user, nil
 }
 
-// findLoginWithValidToken find the first valid token for an user
-// TODO: returns an array?
-func findLoginWithValidToken(user User) (Login, error) {
+func findLoginByToken(jwt string) (Login, error) {
 	var login Login
-	filter := bson.M{"userId": user.ID, "token.status": tokenStatusActive}
+	filter := bson.M{"token.jwt": jwt}
 
 	if err := dbUserLoginCollection.FindOne(context.TODO(), filter).Decode(&login); err != nil {
 		return Login{}, err
@@ -91,9 +211,10 @@ func findLoginWithValidToken(user User) (Login, error) {
 	return login, nil
 }
 
-func findLoginByToken(jwt string) (Login, error) {
+// findLoginByTokenID finds the login of a "jti" claim
+func findLoginByTokenID(tokenID string) (Login, error) {
 	var login Login
-	filter := bson.M{"token.jwt": jwt}
+	filter := bson.M{"token.jti": tokenID}
 
 	if err := dbUserLoginCollection.FindOne(context.TODO(), filter).Decode(&login); err != nil {
 		return Login{}, err
@@ -102,6 +223,29 @@ func findLoginByToken(jwt string) (Login, error) {
 	return login, nil
 }
 
+// findActiveLogins lists the logins of an user which can be refreshed, one
+// per login family
+func findActiveLogins(userID string, now time.Time) ([]Login, *core.ServiceMessage) {
+	uID, _ := primitive.ObjectIDFromHex(userID)
+	filter := bson.M{
+		"userId":           uID,
+		"familyId":         bson.M{"$exists": true},
+		"token.status":     tokenStatusActive,
+		"refreshExpiresOn": bson.M{"$gt": now},
+	}
+
+	logins := make([]Login, 0)
+	cur, err := dbUserLoginCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if err := cur.All(context.TODO(), &logins); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return logins, nil
+}
+
 // isEmailAlreadyRegistered checks if an email is available
 func isEmailAlreadyRegistered(email string) (bool, *core.ServiceMessage) {
 	filter := bson.M{"email": email}
@@ -194,12 +338,12 @@ func updateUser(userID string, user User) (User, error) {
 		ReturnDocument: &(returnOpt),
 	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"email":    user.Email,
-			"username": user.Username,
-		},
+	// An omitted timezone keeps the current one
+	toSet := bson.M{"username": user.Username}
+	if user.Timezone != "" {
+		toSet["timezone"] = user.Timezone
 	}
+	update := bson.M{"$set": toSet}
 
 	var updatedUser User
 
@@ -226,7 +370,10 @@ func updatePassword(pwdChgRequest pwdChangeRequest) (authenticatedUser, *core.Se
 	}
 
 	// Hash password
-	hashedPassword := hashPassword(pwdChgRequest.Password)
+	hashedPassword, err := hashPassword(pwdChgRequest.Password)
+	if err != nil {
+		return authenticatedUser{}, core.NewServiceErrorMessage(err)
+	}
 
 	// Update password and username if application
 	updatedFields := bson.M{
@@ -276,18 +423,132 @@ func updatePwdResetToken(user *User) *core.ServiceMessage {
 	return nil
 }
 
-// invalidateToken invalidates the login for the given token by setting up a non-active
-// status on the token
-func invalidateToken(jwt string, invalidStatusCode int) (Login, error) {
-	filter := bson.M{"token.jwt": jwt}
+// saveEmailChange replaces the email change of an user, unless a confirmed
+// change can still be reverted
+func saveEmailChange(userID primitive.ObjectID, change *emailChange, now time.Time) *core.ServiceMessage {
+	filter := bson.M{
+		"_id": userID,
+		"$nor": bson.A{bson.M{
+			"emailChange.confirmedAt":     bson.M{"$exists": true},
+			"emailChange.revertExpiresAt": bson.M{"$gt": now},
+		}},
+	}
+	update := bson.M{"$set": bson.M{"emailChange": change}}
+
+	res, err := dbUserCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if res.MatchedCount == 0 {
+		// The user is loaded beforehand so a change was confirmed meanwhile
+		return emailChangeRevertPending
+	}
+
+	return nil
+}
+
+// confirmEmailChange applies the pending email change of the confirmation
+// token. The new email availability is checked again as it may have been
+// registered since the request. The revert token remains valid
+func confirmEmailChange(token string, now time.Time) (User, *core.ServiceMessage) {
+	user, errMsg := findUserByEmailChange("emailChange.token", token)
+	if errMsg != nil {
+		return User{}, errMsg
+	}
+	if user.EmailChange.ExpiresAt.Before(now) {
+		return User{}, emailChangeTokenExpired
+	}
+	if errMsg := checkEmailAvailable(user.EmailChange.NewEmail); errMsg != nil {
+		return User{}, errMsg
+	}
+
+	filter := bson.M{"_id": user.ID, "emailChange.token": token}
 	update := bson.M{
 		"$set": bson.M{
-			"token.status": invalidStatusCode,
+			"email":                   user.EmailChange.NewEmail,
+			"emailChange.confirmedAt": now,
 		},
+		"$unset": bson.M{"emailChange.token": 1},
+	}
+
+	return updateEmailChange(filter, update)
+}
+
+// revertEmailChange cancels the pending email change of the revert token, or
+// restores the previous email if the change is already applied
+func revertEmailChange(token string, now time.Time) (User, *core.ServiceMessage) {
+	user, errMsg := findUserByEmailChange("emailChange.revertToken", token)
+	if errMsg != nil {
+		return User{}, errMsg
+	}
+	if user.EmailChange.RevertExpiresAt.Before(now) {
+		return User{}, emailChangeTokenExpired
 	}
 
-	var invalidatedLogin Login
-	if err := dbUserLoginCollection.FindOneAndUpdate(context.TODO(), filter, update).Decode(&invalidatedLogin); err != nil {
+	filter := bson.M{"_id": user.ID, "emailChange.revertToken": token}
+	update := bson.M{"$unset": bson.M{"emailChange": 1}}
+	if user.EmailChange.ConfirmedAt != nil {
+		if errMsg := checkEmailAvailable(user.EmailChange.PreviousEmail); errMsg != nil {
+			return User{}, errMsg
+		}
+		update["$set"] = bson.M{"email": user.EmailChange.PreviousEmail}
+	}
+
+	return updateEmailChange(filter, update)
+}
+
+// findUserByEmailChange finds the user of an email change token
+func findUserByEmailChange(tokenField string, token string) (User, *core.ServiceMessage) {
+	if token == "" {
+		return User{}, emailChangeTokenNotFound
+	}
+
+	var user User
+	if err := dbUserCollection.FindOne(context.TODO(), bson.M{tokenField: token}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return User{}, emailChangeTokenNotFound
+		}
+		return User{}, core.NewServiceErrorMessage(err)
+	}
+
+	return user, nil
+}
+
+// updateEmailChange updates an user if its email change token is unchanged
+func updateEmailChange(filter bson.M, update bson.M) (User, *core.ServiceMessage) {
+	returnOpt := options.After
+	options := &options.FindOneAndUpdateOptions{
+		ReturnDocument: &returnOpt,
+	}
+
+	var updatedUser User
+	if err := dbUserCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&updatedUser); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return User{}, emailChangeTokenNotFound
+		}
+		return User{}, core.NewServiceErrorMessage(err)
+	}
+
+	return updatedUser, nil
+}
+
+// invalidateToken invalidates the login family of the given token by setting
+// up a non-active status on their tokens, so that they cannot be refreshed
+func invalidateToken(jwt string, invalidStatusCode int) (Login, error) {
+	invalidatedLogin, err := findLoginByToken(jwt)
+	if err != nil {
+		return Login{}, err
+	}
+
+	// Logins created before refresh tokens have no family
+	filter := bson.M{"_id": invalidatedLogin.ID}
+	update := bson.M{"$set": bson.M{"token.status": invalidStatusCode}}
+	if invalidatedLogin.FamilyID.IsZero() {
+		_, err = dbUserLoginCollection.UpdateOne(context.TODO(), filter, update)
+	} else {
+		err = revokeLoginFamily(invalidatedLogin.FamilyID, invalidStatusCode)
+	}
+	if err != nil {
 		return Login{}, err
 	}
 	userLogger.Debug("[User] Invalidate <%v> with result <%v>", jwt, invalidatedLogin)
@@ -295,6 +556,141 @@ func invalidateToken(jwt string, invalidStatusCode int) (Login, error) {
 
 }
 
+// refreshLogin marks as refreshed the active login of a refresh token. If the
+// refresh token was already used, the whole login family is revoked as either
+// the legitimate user or an attacker uses a stolen token
+func refreshLogin(refreshToken string, now time.Time) (Login, *core.ServiceMessage) {
+	refreshHash := hashRefreshToken(refreshToken)
+	filter := bson.M{
+		"refreshHash":      refreshHash,
+		"token.status":     tokenStatusActive,
+		"refreshExpiresOn": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"token.status": tokenStatusRefreshed}}
+
+	var refreshedLogin Login
+	err := dbUserLoginCollection.FindOneAndUpdate(context.TODO(), filter, update).Decode(&refreshedLogin)
+	if err == nil {
+		return refreshedLogin, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return Login{}, core.NewServiceErrorMessage(err)
+	}
+
+	// Refresh token is unknown, expired or already used
+	var usedLogin Login
+	filter = bson.M{"refreshHash": refreshHash, "token.status": tokenStatusRefreshed}
+	if err := dbUserLoginCollection.FindOne(context.TODO(), filter).Decode(&usedLogin); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Login{}, refreshTokenInvalid
+		}
+		return Login{}, core.NewServiceErrorMessage(err)
+	}
+
+	userLogger.Warn("[User] Refresh token reuse for user %s, revoking login family %s",
+		usedLogin.UserID.Hex(), usedLogin.FamilyID.Hex())
+	if err := revokeLoginFamily(usedLogin.FamilyID, tokenStatusRevoked); err != nil {
+		return Login{}, core.NewServiceErrorMessage(err)
+	}
+
+	return Login{}, refreshTokenReused
+}
+
+// revokeSessions revokes the login families of an user which are still
+// active and returns how many families are revoked
+func revokeSessions(userID string, familyIDs []primitive.ObjectID) (int, *core.ServiceMessage) {
+	activeLogins, errMsg := findActiveLogins(userID, time.Now())
+	if errMsg != nil {
+		return 0, errMsg
+	}
+
+	toRevoke := make(map[primitive.ObjectID]bool)
+	for _, familyID := range familyIDs {
+		toRevoke[familyID] = true
+	}
+
+	revokedCount := 0
+	for _, login := range activeLogins {
+		if !toRevoke[login.FamilyID] {
+			continue
+		}
+		if err := revokeLoginFamily(login.FamilyID, tokenStatusInvalidated); err != nil {
+			return revokedCount, core.NewServiceErrorMessage(err)
+		}
+		revokedCount++
+	}
+
+	return revokedCount, nil
+}
+
+// revokeLoginFamily deactivates all the active logins of a family and revokes
+// their access tokens which are not expired yet, including the tokens of the
+// refreshed logins
+func revokeLoginFamily(familyID primitive.ObjectID, invalidStatusCode int) error {
+	now := time.Now()
+	if err := revokeLoginTokens(bson.M{"familyId": familyID}, invalidStatusCode, now); err != nil {
+		return err
+	}
+
+	filter := bson.M{"familyId": familyID, "token.status": tokenStatusActive}
+	update := bson.M{"$set": bson.M{"token.status": invalidStatusCode}}
+
+	_, err := dbUserLoginCollection.UpdateMany(context.TODO(), filter, update)
+	return err
+}
+
+// revokeLoginTokens revokes the access tokens of the logins of the filter
+// which are not expired yet
+func revokeLoginTokens(filter bson.M, invalidStatusCode int, now time.Time) error {
+	filter["token.expiresOn"] = bson.M{"$gt": now}
+
+	logins := make([]Login, 0)
+	cur, err := dbUserLoginCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	if err := cur.All(context.TODO(), &logins); err != nil {
+		return err
+	}
+
+	return createRevocations(newTokenRevocations(logins, invalidStatusCode, now))
+}
+
+// createRevocations saves revoked tokens, which are immediately rejected by
+// this service
+func createRevocations(revocations []core.TokenRevocation) error {
+	if len(revocations) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(revocations))
+	for i, revocation := range revocations {
+		documents[i] = revocation
+	}
+	if _, err := dbUserRevocationCollection.InsertMany(context.TODO(), documents); err != nil {
+		return err
+	}
+
+	core.AddTokenRevocations(revocations...)
+	return nil
+}
+
+// findRevocations lists the revocations saved since a given time
+func findRevocations(since time.Time) ([]core.TokenRevocation, error) {
+	filter := bson.M{"revokedAt": bson.M{"$gte": since}}
+
+	revocations := make([]core.TokenRevocation, 0)
+	cur, err := dbUserRevocationCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(context.TODO(), &revocations); err != nil {
+		return nil, err
+	}
+
+	return revocations, nil
+}
+
 func deleteUser(userID string) int64 {
 	id, _ := primitive.ObjectIDFromHex(userID)
 	filter := bson.M{"_id": id}
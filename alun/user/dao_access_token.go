@@ -0,0 +1,100 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbUserTokenCollectionName string
+	dbUserTokenCollection     *mongo.Collection
+)
+
+// initAccessTokenDao loads the personal access tokens, found by hash when
+// verified and listed per user
+func initAccessTokenDao(userMongoDb *mongo.Database) {
+	dbUserTokenCollectionName = "al_users_tokens"
+	dbUserTokenCollection = userMongoDb.Collection(dbUserTokenCollectionName)
+
+	isUnique := true
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{primitive.E{Key: "hash", Value: 1}},
+			Options: &options.IndexOptions{Unique: &isUnique},
+		},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "userId", Value: 1},
+				primitive.E{Key: "createdAt", Value: -1},
+			},
+		},
+	}
+	if _, err := dbUserTokenCollection.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		userLogger.Warn("[MongoDB] Access token indexes creation failed: %v", err)
+	}
+}
+
+// ---------- Personal access tokens ------------------------------------------
+
+func createAccessToken(pat PersonalAccessToken) error {
+	_, err := dbUserTokenCollection.InsertOne(context.TODO(), pat)
+	return err
+}
+
+func findAccessTokenByHash(hash string) (PersonalAccessToken, error) {
+	var pat PersonalAccessToken
+	if err := dbUserTokenCollection.FindOne(context.TODO(), bson.M{"hash": hash}).Decode(&pat); err != nil {
+		return PersonalAccessToken{}, err
+	}
+
+	return pat, nil
+}
+
+// findAccessTokens lists the personal access tokens of an user, most recent
+// first
+func findAccessTokens(userID primitive.ObjectID) ([]PersonalAccessToken, error) {
+	options := &options.FindOptions{
+		Sort: bson.D{primitive.E{Key: "createdAt", Value: -1}},
+	}
+
+	tokens := make([]PersonalAccessToken, 0)
+	cur, err := dbUserTokenCollection.Find(context.TODO(), bson.M{"userId": userID}, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(context.TODO(), &tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// updateAccessTokenUsage saves the last usage of a token
+func updateAccessTokenUsage(tokenID primitive.ObjectID, now time.Time) error {
+	filter := bson.M{"_id": tokenID}
+	update := bson.M{"$set": bson.M{"lastUsedAt": now}}
+
+	_, err := dbUserTokenCollection.UpdateOne(context.TODO(), filter, update)
+	return err
+}
+
+// deleteAccessToken deletes a token of an user
+func deleteAccessToken(userID primitive.ObjectID, tokenID primitive.ObjectID) *core.ServiceMessage {
+	filter := bson.M{"_id": tokenID, "userId": userID}
+	res, err := dbUserTokenCollection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if res.DeletedCount == 0 {
+		return accessTokenNotFound
+	}
+
+	return nil
+}
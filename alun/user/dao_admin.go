@@ -0,0 +1,198 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbUserAuditCollectionName string
+	dbUserAuditCollection     *mongo.Collection
+)
+
+// initAdminDao loads the audit trail of the admin actions, listed per user
+// and by date
+func initAdminDao(userMongoDb *mongo.Database) {
+	dbUserAuditCollectionName = "al_users_audit"
+	dbUserAuditCollection = userMongoDb.Collection(dbUserAuditCollectionName)
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "createdAt", Value: -1}}},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "targetId", Value: 1},
+				primitive.E{Key: "createdAt", Value: -1},
+			},
+		},
+	}
+	if _, err := dbUserAuditCollection.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		userLogger.Warn("[MongoDB] Audit indexes creation failed: %v", err)
+	}
+}
+
+// ---------- Users -----------------------------------------------------------
+
+// findUsers lists a page of the users of the filter, sorted by email
+func findUsers(filter bson.M, page int, limit int) (*UserList, *core.ServiceMessage) {
+	total, err := dbUserCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	skip := int64((page - 1) * limit)
+	pageLimit := int64(limit)
+	options := &options.FindOptions{
+		Sort:  bson.D{primitive.E{Key: "email", Value: 1}},
+		Skip:  &skip,
+		Limit: &pageLimit,
+	}
+
+	users := make([]User, 0)
+	cur, err := dbUserCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if err := cur.All(context.TODO(), &users); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &UserList{Items: users, Total: total, Page: page, Limit: limit}, nil
+}
+
+// findUserDetail adds the login activity of an user. The first login of a
+// family is the authentication with credentials
+func findUserDetail(user User, now time.Time) (*UserDetail, *core.ServiceMessage) {
+	detail := &UserDetail{User: user}
+	latestFirst := &options.FindOneOptions{
+		Sort: bson.D{primitive.E{Key: "timestamp", Value: -1}},
+	}
+
+	var lastSeen Login
+	err := dbUserLoginCollection.FindOne(context.TODO(), bson.M{"userId": user.ID}, latestFirst).Decode(&lastSeen)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if err == nil {
+		detail.LastSeenAt = &lastSeen.Timestamp
+	}
+
+	var lastLogin Login
+	filter := bson.M{"userId": user.ID, "$expr": bson.M{"$eq": bson.A{"$_id", "$familyId"}}}
+	err = dbUserLoginCollection.FindOne(context.TODO(), filter, latestFirst).Decode(&lastLogin)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if err == nil {
+		detail.LastLoginAt = &lastLogin.Timestamp
+	}
+
+	activeLogins, errMsg := findActiveLogins(user.ID.Hex(), now)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	detail.ActiveSessions = len(activeLogins)
+
+	return detail, nil
+}
+
+// updateUserFlag sets a boolean field of an user
+func updateUserFlag(userID string, field string, value bool) (User, *core.ServiceMessage) {
+	id, _ := primitive.ObjectIDFromHex(userID)
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{field: value}}
+	returnOpt := options.After
+	options := &options.FindOneAndUpdateOptions{
+		ReturnDocument: &returnOpt,
+	}
+
+	var updatedUser User
+	if err := dbUserCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&updatedUser); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return User{}, userNotFound
+		}
+		return User{}, core.NewServiceErrorMessage(err)
+	}
+
+	return updatedUser, nil
+}
+
+// forcePasswordReset removes the password of an user, who has to define a new
+// one with the password reset token
+func forcePasswordReset(userID primitive.ObjectID, resetToken pwdResetToken) *core.ServiceMessage {
+	filter := bson.M{"_id": userID}
+	update := bson.M{
+		"$set":   bson.M{"pwdResetToken": resetToken},
+		"$unset": bson.M{"password": 1},
+	}
+
+	res, err := dbUserCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if res.MatchedCount == 0 {
+		return userNotFound
+	}
+
+	return nil
+}
+
+// revokeUserLogins logs out all the sessions of an user and revokes the
+// access tokens which are not expired yet
+func revokeUserLogins(userID primitive.ObjectID) *core.ServiceMessage {
+	if err := revokeLoginTokens(bson.M{"userId": userID}, tokenStatusInvalidated, time.Now()); err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	filter := bson.M{"userId": userID, "token.status": tokenStatusActive}
+	update := bson.M{"$set": bson.M{"token.status": tokenStatusInvalidated}}
+	if _, err := dbUserLoginCollection.UpdateMany(context.TODO(), filter, update); err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	return nil
+}
+
+// ---------- Audit -----------------------------------------------------------
+
+func createAuditEntry(entry AuditEntry) *core.ServiceMessage {
+	if _, err := dbUserAuditCollection.InsertOne(context.TODO(), entry); err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	return nil
+}
+
+// findAuditEntries lists a page of the audit entries of the filter, most
+// recent first
+func findAuditEntries(filter bson.M, page int, limit int) (*AuditList, *core.ServiceMessage) {
+	total, err := dbUserAuditCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	skip := int64((page - 1) * limit)
+	pageLimit := int64(limit)
+	options := &options.FindOptions{
+		Sort:  bson.D{primitive.E{Key: "createdAt", Value: -1}, primitive.E{Key: "_id", Value: -1}},
+		Skip:  &skip,
+		Limit: &pageLimit,
+	}
+
+	entries := make([]AuditEntry, 0)
+	cur, err := dbUserAuditCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+	if err := cur.All(context.TODO(), &entries); err != nil {
+		return nil, core.NewServiceErrorMessage(err)
+	}
+
+	return &AuditList{Items: entries, Total: total, Page: page, Limit: limit}, nil
+}
@@ -0,0 +1,133 @@
+package user
+
+import (
+	"context"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbUserRoleCollectionName string
+	dbUserRoleCollection     *mongo.Collection
+)
+
+// initRoleDao loads the role definitions, identified by their name
+func initRoleDao(userMongoDb *mongo.Database) {
+	dbUserRoleCollectionName = "al_users_roles"
+	dbUserRoleCollection = userMongoDb.Collection(dbUserRoleCollectionName)
+
+	index := mongo.IndexModel{Keys: bson.D{primitive.E{Key: "roles", Value: 1}}}
+	if _, err := dbUserCollection.Indexes().CreateOne(context.TODO(), index); err != nil {
+		userLogger.Warn("[MongoDB] User roles index creation failed: %v", err)
+	}
+}
+
+// ---------- Roles -----------------------------------------------------------
+
+// findRoles lists the roles of the names, or all the roles if names is nil,
+// sorted by name
+func findRoles(names []string) ([]Role, error) {
+	filter := bson.M{}
+	if names != nil {
+		filter["_id"] = bson.M{"$in": names}
+	}
+	options := &options.FindOptions{
+		Sort: bson.D{primitive.E{Key: "_id", Value: 1}},
+	}
+
+	roles := make([]Role, 0)
+	cur, err := dbUserRoleCollection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(context.TODO(), &roles); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// findRolePermissions lists the permissions granted by the roles of an user
+func findRolePermissions(roleNames []string) ([]string, error) {
+	if len(roleNames) == 0 {
+		return nil, nil
+	}
+
+	roles, err := findRoles(roleNames)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergePermissions(roles), nil
+}
+
+// findRoleUserIDs lists the users having a role
+func findRoleUserIDs(roleName string) ([]primitive.ObjectID, error) {
+	ids, err := dbUserCollection.Distinct(context.TODO(), "_id", bson.M{"roles": roleName})
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if userID, ok := id.(primitive.ObjectID); ok {
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	return userIDs, nil
+}
+
+// saveRole creates or replaces a role definition
+func saveRole(role Role) error {
+	filter := bson.M{"_id": role.Name}
+	isUpsert := true
+	options := &options.ReplaceOptions{Upsert: &isUpsert}
+
+	_, err := dbUserRoleCollection.ReplaceOne(context.TODO(), filter, role, options)
+	return err
+}
+
+// deleteRole deletes a role definition and removes it from the users
+func deleteRole(name string) *core.ServiceMessage {
+	res, err := dbUserRoleCollection.DeleteOne(context.TODO(), bson.M{"_id": name})
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if res.DeletedCount == 0 {
+		return roleNotFound
+	}
+
+	filter := bson.M{"roles": name}
+	update := bson.M{"$pull": bson.M{"roles": name}}
+	if _, err := dbUserCollection.UpdateMany(context.TODO(), filter, update); err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	return nil
+}
+
+// updateUserRoles replaces the roles of an user
+func updateUserRoles(userID primitive.ObjectID, roleNames []string) (User, *core.ServiceMessage) {
+	filter := bson.M{"_id": userID}
+	update := bson.M{"$set": bson.M{"roles": roleNames}}
+	returnOpt := options.After
+	options := &options.FindOneAndUpdateOptions{
+		ReturnDocument: &returnOpt,
+	}
+
+	var updatedUser User
+	if err := dbUserCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&updatedUser); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return User{}, userNotFound
+		}
+		return User{}, core.NewServiceErrorMessage(err)
+	}
+
+	return updatedUser, nil
+}
@@ -0,0 +1,211 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ---------- Variable and init -----------------------------------------------
+var (
+	dbUserChallengeCollectionName string
+	dbUserChallengeCollection     *mongo.Collection
+)
+
+// initTwoFactorDao loads the login challenges, found by hash and removed once
+// expired
+func initTwoFactorDao(userMongoDb *mongo.Database) {
+	dbUserChallengeCollectionName = "al_users_challenges"
+	dbUserChallengeCollection = userMongoDb.Collection(dbUserChallengeCollectionName)
+
+	expireAfterSeconds := int32(0)
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "hash", Value: 1}}},
+		{
+			Keys:    bson.D{primitive.E{Key: "expiresAt", Value: 1}},
+			Options: &options.IndexOptions{ExpireAfterSeconds: &expireAfterSeconds},
+		},
+	}
+	if _, err := dbUserChallengeCollection.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		userLogger.Warn("[MongoDB] Challenge indexes creation failed: %v", err)
+	}
+}
+
+// ---------- Two-factor ------------------------------------------------------
+
+// enrollTwoFactor saves a new secret, replacing a pending enrollment but not
+// an enabled 2FA
+func enrollTwoFactor(userID primitive.ObjectID, tf *twoFactor) *core.ServiceMessage {
+	filter := bson.M{"_id": userID, "twoFactor.isEnabled": bson.M{"$ne": true}}
+	update := bson.M{"$set": bson.M{"twoFactor": tf}}
+
+	res, err := dbUserCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if res.MatchedCount == 0 {
+		return twoFactorAlreadyEnabled
+	}
+
+	return nil
+}
+
+// enableTwoFactor enables the 2FA of the confirmed secret. The confirmation
+// code cannot be used to login
+func enableTwoFactor(userID primitive.ObjectID, secret string, recoveryHashes []string,
+	counter int64, now time.Time) *core.ServiceMessage {
+	filter := bson.M{"_id": userID, "twoFactor.secret": secret, "twoFactor.isEnabled": false}
+	update := bson.M{
+		"$set": bson.M{
+			"twoFactor.isEnabled":     true,
+			"twoFactor.recoveryCodes": recoveryHashes,
+			"twoFactor.lastCounter":   counter,
+			"twoFactor.enabledAt":     now,
+		},
+	}
+
+	res, err := dbUserCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if res.MatchedCount == 0 {
+		return twoFactorNotEnrolled
+	}
+
+	return nil
+}
+
+// useTwoFactorCounter saves the time step of a valid code, unless the same or
+// a later code was already used
+func useTwoFactorCounter(userID primitive.ObjectID, counter int64) *core.ServiceMessage {
+	filter := bson.M{"_id": userID, "twoFactor.lastCounter": bson.M{"$lt": counter}}
+	update := bson.M{
+		"$set": bson.M{
+			"twoFactor.lastCounter":    counter,
+			"twoFactor.failedAttempts": 0,
+		},
+	}
+
+	res, err := dbUserCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if res.MatchedCount == 0 {
+		return twoFactorCodeInvalid
+	}
+
+	return nil
+}
+
+// useRecoveryCode removes a recovery code, which can be used only once
+func useRecoveryCode(userID primitive.ObjectID, recoveryHash string) *core.ServiceMessage {
+	filter := bson.M{"_id": userID, "twoFactor.recoveryCodes": recoveryHash}
+	update := bson.M{
+		"$pull": bson.M{"twoFactor.recoveryCodes": recoveryHash},
+		"$set":  bson.M{"twoFactor.failedAttempts": 0},
+	}
+
+	res, err := dbUserCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if res.MatchedCount == 0 {
+		return twoFactorCodeInvalid
+	}
+
+	return nil
+}
+
+// recordTwoFactorFailure counts an invalid code of an user and locks its 2FA
+// once too many codes are invalid in a row
+func recordTwoFactorFailure(userID primitive.ObjectID, now time.Time) *core.ServiceMessage {
+	filter := bson.M{"_id": userID, "twoFactor.isEnabled": true}
+	update := bson.M{"$inc": bson.M{"twoFactor.failedAttempts": 1}}
+	returnOpt := options.After
+	options := &options.FindOneAndUpdateOptions{ReturnDocument: &returnOpt}
+
+	var user User
+	if err := dbUserCollection.FindOneAndUpdate(context.TODO(), filter, update, options).Decode(&user); err != nil {
+		// 2FA was reset meanwhile
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return core.NewServiceErrorMessage(err)
+	}
+	if user.TwoFactor.FailedAttempts < twoFactorMaxFailures {
+		return nil
+	}
+
+	userLogger.Info("[2FA] User %s is locked after %d invalid codes", userID.Hex(), user.TwoFactor.FailedAttempts)
+	update = bson.M{
+		"$set": bson.M{
+			"twoFactor.failedAttempts": 0,
+			"twoFactor.lockedUntil":    now.Add(twoFactorLockout),
+		},
+	}
+	if _, err := dbUserCollection.UpdateOne(context.TODO(), filter, update); err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	return nil
+}
+
+// resetTwoFactor removes the 2FA of an user and its pending challenges
+func resetTwoFactor(userID primitive.ObjectID) *core.ServiceMessage {
+	filter := bson.M{"_id": userID}
+	update := bson.M{"$unset": bson.M{"twoFactor": 1}}
+
+	res, err := dbUserCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+	if res.MatchedCount == 0 {
+		return userNotFound
+	}
+
+	if _, err := dbUserChallengeCollection.DeleteMany(context.TODO(), bson.M{"userId": userID}); err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	return nil
+}
+
+// ---------- Challenges ------------------------------------------------------
+
+func createLoginChallenge(challenge loginChallenge) error {
+	_, err := dbUserChallengeCollection.InsertOne(context.TODO(), challenge)
+	return err
+}
+
+// useLoginChallenge counts an attempt on an unexpired challenge. A challenge
+// with too many attempts is rejected
+func useLoginChallenge(hash string, now time.Time) (loginChallenge, *core.ServiceMessage) {
+	filter := bson.M{
+		"hash":      hash,
+		"expiresAt": bson.M{"$gt": now},
+		"attempts":  bson.M{"$lt": twoFactorChallengeMaxAttempts},
+	}
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+
+	var challenge loginChallenge
+	if err := dbUserChallengeCollection.FindOneAndUpdate(context.TODO(), filter, update).Decode(&challenge); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return loginChallenge{}, twoFactorChallengeInvalid
+		}
+		return loginChallenge{}, core.NewServiceErrorMessage(err)
+	}
+
+	return challenge, nil
+}
+
+// deleteLoginChallenge removes a challenge once the login succeeded
+func deleteLoginChallenge(challengeID primitive.ObjectID) {
+	if _, err := dbUserChallengeCollection.DeleteOne(context.TODO(), bson.M{"_id": challengeID}); err != nil {
+		userLogger.Warn("[2FA] Challenge %s deletion failed: %v", challengeID.Hex(), err)
+	}
+}
@@ -0,0 +1,92 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// userDirectory gives other packages access to the users contact without
+// exposing the user model
+type userDirectory struct{}
+
+// FindUserContacts loads the contact of the existing users among the provided
+// IDs
+func (ud userDirectory) FindUserContacts(userIDs []string) ([]core.UserContact, error) {
+	ids := make([]primitive.ObjectID, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if id, err := primitive.ObjectIDFromHex(userID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return findUserContacts(bson.M{"_id": bson.M{"$in": ids}})
+}
+
+// FindUserContactsByUsernames loads the contact of the users having one of the
+// provided usernames, ignoring the case
+func (ud userDirectory) FindUserContactsByUsernames(usernames []string) ([]core.UserContact, error) {
+	patterns := make(bson.A, 0, len(usernames))
+	for _, username := range usernames {
+		patterns = append(patterns, primitive.Regex{Pattern: "^" + regexp.QuoteMeta(username) + "$", Options: "i"})
+	}
+
+	return findUserContacts(bson.M{"username": bson.M{"$in": patterns}})
+}
+
+// handleFindUserContacts gives the users directory to the other services in
+// microservice mode
+func handleFindUserContacts(w http.ResponseWriter, r *http.Request) {
+	if !hasRevocationSecret(r) {
+		revocationSecretInvalid.Write(w, r)
+		return
+	}
+
+	var contactsReq core.UserContactsRequest
+	json.NewDecoder(r.Body).Decode(&contactsReq)
+
+	var contacts []core.UserContact
+	var err error
+	if len(contactsReq.UserIDs) > 0 {
+		contacts, err = userDirectory{}.FindUserContacts(contactsReq.UserIDs)
+	} else {
+		contacts, err = userDirectory{}.FindUserContactsByUsernames(contactsReq.Usernames)
+	}
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(contacts)
+}
+
+func findUserContacts(filter bson.M) ([]core.UserContact, error) {
+	cur, err := dbUserCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	contacts := make([]core.UserContact, 0)
+	for cur.Next(context.TODO()) {
+		var user User
+		if err := cur.Decode(&user); err != nil {
+			return nil, err
+		}
+
+		contacts = append(contacts, core.UserContact{
+			ID:       user.ID.Hex(),
+			Email:    user.Email,
+			Username: user.Username,
+			Timezone: user.Timezone,
+		})
+	}
+
+	return contacts, nil
+}
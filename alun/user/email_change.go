@@ -0,0 +1,210 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/utils"
+	"github.com/Al-un/alun-api/pkg/crypto"
+)
+
+const (
+	// Validity of the confirmation link sent to the new email
+	emailChangeTTL = 24 * time.Hour
+	// Validity of the revert link sent to the previous email
+	emailRevertTTL = 7 * 24 * time.Hour
+)
+
+// emailChange is a change of email of an user. The change is applied when
+// the new email is confirmed, and the previous email can revert the change,
+// applied or not, until the revert link expires
+type emailChange struct {
+	NewEmail        string     `bson:"newEmail"`
+	PreviousEmail   string     `bson:"previousEmail"`
+	Token           string     `bson:"token,omitempty"` // unset once confirmed
+	RevertToken     string     `bson:"revertToken"`
+	CreatedAt       time.Time  `bson:"createdAt"`
+	ExpiresAt       time.Time  `bson:"expiresAt"`
+	RevertExpiresAt time.Time  `bson:"revertExpiresAt"`
+	ConfirmedAt     *time.Time `bson:"confirmedAt,omitempty"`
+}
+
+// emailChangeRequest asks for a new email. Links sent by email are the URL
+// followed by the token
+type emailChangeRequest struct {
+	Email       string `json:"email"`
+	RedirectURL string `json:"redirectUrl"`
+	RevertURL   string `json:"revertUrl"`
+}
+
+// isRevertible tells if a confirmed change can still be reverted by the
+// previous email
+func (ec *emailChange) isRevertible(now time.Time) bool {
+	return ec != nil && ec.ConfirmedAt != nil && ec.RevertExpiresAt.After(now)
+}
+
+// emailTokenRequest confirms or reverts an email change
+type emailTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// newEmailChange builds the tokens of a change from the current email of an
+// user
+func newEmailChange(user User, newEmail string, now time.Time) (*emailChange, error) {
+	token, err := crypto.GenerateRandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	revertToken, err := crypto.GenerateRandomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &emailChange{
+		NewEmail:        newEmail,
+		PreviousEmail:   user.Email,
+		Token:           token,
+		RevertToken:     revertToken,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(emailChangeTTL),
+		RevertExpiresAt: now.Add(emailRevertTTL),
+	}, nil
+}
+
+// validateNewEmail checks that an email is a plain address such as
+// "john@example.com"
+func validateNewEmail(email string) *core.ServiceMessage {
+	if email == "" {
+		return hasNoEmail
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return hasNoValidEmail
+	}
+
+	return nil
+}
+
+// checkEmailAvailable rejects an email which is already registered
+func checkEmailAvailable(email string) *core.ServiceMessage {
+	isEmailTaken, errMsg := isEmailAlreadyRegistered(email)
+	if errMsg != nil {
+		return errMsg
+	}
+	if isEmailTaken {
+		return hasEmailNotAvailable
+	}
+
+	return nil
+}
+
+// sendEmailChangeEmails sends the confirmation link to the new email and
+// notifies the previous email with a revert link
+func sendEmailChangeEmails(change *emailChange, emailReq emailChangeRequest) {
+	confirmData := struct{ URL, Email string }{
+		URL:   emailReq.RedirectURL + change.Token,
+		Email: change.NewEmail,
+	}
+	if err := alunEmail.SendNoReplyEmail([]string{change.NewEmail}, "Email change Al-un.fr",
+		utils.EmailTemplateUserEmailConfirm, confirmData); err != nil {
+		userLogger.Warn("[Email] Confirmation to %s failed: %v", change.NewEmail, err)
+	}
+
+	noticeData := struct{ URL, Email string }{
+		URL:   emailReq.RevertURL + change.RevertToken,
+		Email: change.NewEmail,
+	}
+	if err := alunEmail.SendNoReplyEmail([]string{change.PreviousEmail}, "Email change Al-un.fr",
+		utils.EmailTemplateUserEmailNotice, noticeData); err != nil {
+		userLogger.Warn("[Email] Notice to %s failed: %v", change.PreviousEmail, err)
+	}
+}
+
+// handleRequestEmailChange saves a pending email change, replacing any
+// previous pending one. The email is updated only once confirmed. A confirmed
+// change is not replaced while it can be reverted so that the revert link of
+// the previous email remains valid
+func handleRequestEmailChange(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	var emailReq emailChangeRequest
+	json.NewDecoder(r.Body).Decode(&emailReq)
+	emailReq.Email = strings.TrimSpace(emailReq.Email)
+
+	if errMsg := validateNewEmail(emailReq.Email); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	user, err := findUserByID(core.GetVar(r, "userId"))
+	if err != nil {
+		userNotFound.Write(w, r)
+		return
+	}
+
+	now := time.Now()
+	if user.EmailChange.isRevertible(now) {
+		emailChangeRevertPending.Write(w, r)
+		return
+	}
+
+	if errMsg := checkEmailAvailable(emailReq.Email); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	change, err := newEmailChange(user, emailReq.Email, now)
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+	if errMsg := saveEmailChange(user.ID, change, now); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	sendEmailChangeEmails(change, emailReq)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleConfirmEmailChange applies an email change if the new email is still
+// available
+func handleConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	var tokenReq emailTokenRequest
+	json.NewDecoder(r.Body).Decode(&tokenReq)
+
+	user, errMsg := confirmEmailChange(tokenReq.Token, time.Now())
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// handleRevertEmailChange cancels a pending email change or restores the
+// previous email. As the change may come from a stolen session, the user is
+// logged out
+func handleRevertEmailChange(w http.ResponseWriter, r *http.Request) {
+	var tokenReq emailTokenRequest
+	json.NewDecoder(r.Body).Decode(&tokenReq)
+
+	user, errMsg := revertEmailChange(tokenReq.Token, time.Now())
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	if errMsg := revokeUserLogins(user.ID); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
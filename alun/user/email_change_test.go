@@ -0,0 +1,133 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+)
+
+func TestValidateNewEmail(t *testing.T) {
+	testutils.Assert(t, testutils.CallFromTestFile, validateNewEmail("john@example.com") == nil, "Plain email should be valid")
+	testutils.Equals(t, testutils.CallFromTestFile, hasNoEmail, validateNewEmail(""))
+	testutils.Equals(t, testutils.CallFromTestFile, hasNoValidEmail, validateNewEmail("john"))
+	testutils.Equals(t, testutils.CallFromTestFile, hasNoValidEmail, validateNewEmail("John <john@example.com>"))
+}
+
+func TestNewEmailChange(t *testing.T) {
+	now := time.Now()
+	user := User{BaseUser: BaseUser{Email: "old@example.com"}}
+
+	change, err := newEmailChange(user, "new@example.com", now)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, "old@example.com", change.PreviousEmail)
+	testutils.Equals(t, testutils.CallFromTestFile, "new@example.com", change.NewEmail)
+	testutils.Assert(t, testutils.CallFromTestFile, change.Token != "" && change.Token != change.RevertToken,
+		"Confirmation and revert tokens should be distinct: %+v", change)
+	testutils.Equals(t, testutils.CallFromTestFile, now.Add(emailChangeTTL), change.ExpiresAt)
+	testutils.Equals(t, testutils.CallFromTestFile, now.Add(emailRevertTTL), change.RevertExpiresAt)
+
+	testutils.Assert(t, testutils.CallFromTestFile, !change.isRevertible(now), "Pending change should be replaceable")
+	change.ConfirmedAt = &now
+	testutils.Assert(t, testutils.CallFromTestFile, change.isRevertible(now), "Confirmed change should be revertible")
+	testutils.Assert(t, testutils.CallFromTestFile, !change.isRevertible(change.RevertExpiresAt), "Revert window should be closed")
+}
+
+func TestE2EEmailChange(t *testing.T) {
+	adminUser, _, basicUser, basicToken := setupUserBasicAndAdmin(t)
+	newEmail := fmt.Sprintf("%snew-%s", t.Name(), userBasicEmail)
+
+	t.Cleanup(func() {
+		tearDownBasicAndAdmin(t)
+		deleteUser(basicUser.ID.Hex())
+		tearDownLogins(t, basicUser.ID)
+	})
+
+	requestChange := func(t *testing.T, email string, expectedStatus int) *emailChange {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:      fmt.Sprintf("detail/%s/email", basicUser.ID.Hex()),
+			Method:    http.MethodPost,
+			AuthToken: basicToken,
+			Payload: emailChangeRequest{
+				Email:       email,
+				RedirectURL: "http://localhost/email/confirm/",
+				RevertURL:   "http://localhost/email/revert/",
+			},
+			ExpectedHTTPStatus: expectedStatus,
+		})
+
+		user, _ := findUserByID(basicUser.ID.Hex())
+		return user.EmailChange
+	}
+	useToken := func(t *testing.T, path string, token string, expectedStatus int) User {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               path,
+			Method:             http.MethodPost,
+			Payload:            emailTokenRequest{Token: token},
+			ExpectedHTTPStatus: expectedStatus,
+		})
+
+		var user User
+		json.NewDecoder(rr.Body).Decode(&user)
+		return user
+	}
+	currentEmail := func() string {
+		user, _ := findUserByID(basicUser.ID.Hex())
+		return user.Email
+	}
+
+	t.Run("TakenEmailIsRejected", func(t *testing.T) {
+		requestChange(t, adminUser.Email, http.StatusBadRequest)
+		requestChange(t, "not-an-email", http.StatusBadRequest)
+	})
+
+	t.Run("ChangeIsAppliedOnConfirmation", func(t *testing.T) {
+		change := requestChange(t, newEmail, http.StatusNoContent)
+		testutils.Assert(t, testutils.CallFromTestFile, change != nil, "Email change should be pending")
+		testutils.Equals(t, testutils.CallFromTestFile, basicUser.Email, currentEmail())
+
+		user := useToken(t, "email/confirm", change.Token, http.StatusOK)
+		testutils.Equals(t, testutils.CallFromTestFile, newEmail, user.Email)
+		testutils.Equals(t, testutils.CallFromTestFile, newEmail, currentEmail())
+
+		// A confirmation token is used once
+		useToken(t, "email/confirm", change.Token, http.StatusNotFound)
+
+		// The revert token of the previous email cannot be replaced
+		requestChange(t, basicUser.Email, http.StatusConflict)
+
+		user = useToken(t, "email/revert", change.RevertToken, http.StatusOK)
+		testutils.Equals(t, testutils.CallFromTestFile, basicUser.Email, user.Email)
+		testutils.Equals(t, testutils.CallFromTestFile, basicUser.Email, currentEmail())
+		useToken(t, "email/revert", change.RevertToken, http.StatusNotFound)
+	})
+
+	t.Run("PendingChangeIsCancelledOnRevert", func(t *testing.T) {
+		change := requestChange(t, newEmail, http.StatusNoContent)
+
+		useToken(t, "email/revert", change.RevertToken, http.StatusOK)
+		useToken(t, "email/confirm", change.Token, http.StatusNotFound)
+		testutils.Equals(t, testutils.CallFromTestFile, basicUser.Email, currentEmail())
+	})
+
+	t.Run("EmailTakenBeforeConfirmationIsRejected", func(t *testing.T) {
+		change := requestChange(t, newEmail, http.StatusNoContent)
+		change.NewEmail = adminUser.Email
+		saveEmailChange(basicUser.ID, change, time.Now())
+
+		useToken(t, "email/confirm", change.Token, http.StatusBadRequest)
+		testutils.Equals(t, testutils.CallFromTestFile, basicUser.Email, currentEmail())
+	})
+
+	t.Run("ExpiredTokenIsRejected", func(t *testing.T) {
+		change := requestChange(t, newEmail, http.StatusNoContent)
+		change.ExpiresAt = time.Now().Add(-time.Minute)
+		saveEmailChange(basicUser.ID, change, time.Now())
+
+		useToken(t, "email/confirm", change.Token, http.StatusBadRequest)
+		testutils.Equals(t, testutils.CallFromTestFile, basicUser.Email, currentEmail())
+	})
+}
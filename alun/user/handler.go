@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/Al-un/alun-api/alun/core"
 	"github.com/Al-un/alun-api/alun/utils"
@@ -21,8 +22,7 @@ func authUser(w http.ResponseWriter, r *http.Request) {
 		var user authenticatedUser
 		json.NewDecoder(r.Body).Decode(&user)
 		if user.Email != "" && user.Password != "" {
-			userLogger.Verbose("JSON authentication: %s/%s", user.Email, user.Password)
-			authenticateCredentials(user.Email, user.Password)(w)
+			authenticateCredentials(user.Email, user.Password, newLoginDevice(r))(w)
 			return
 		}
 	}
@@ -44,7 +44,7 @@ func authUser(w http.ResponseWriter, r *http.Request) {
 
 	// ------ BASIC Authentication
 	if authHeader[:5] == "Basic" {
-		authenticateBasic(authHeader)(w)
+		authenticateBasic(authHeader, newLoginDevice(r))(w)
 	}
 }
 
@@ -57,6 +57,38 @@ func logoutUser(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleRefreshLogin exchanges a refresh token for a new access token and a
+// new refresh token. Refresh tokens can only be used once
+func handleRefreshLogin(w http.ResponseWriter, r *http.Request) {
+	var refreshReq refreshRequest
+	json.NewDecoder(r.Body).Decode(&refreshReq)
+	if refreshReq.RefreshToken == "" {
+		refreshTokenInvalid.Write(w, r)
+		return
+	}
+
+	refreshedLogin, errMsg := refreshLogin(refreshReq.RefreshToken, time.Now())
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	user, err := findUserByID(refreshedLogin.UserID.Hex())
+	if err != nil || user.IsDisabled {
+		refreshTokenInvalid.Write(w, r)
+		return
+	}
+
+	login, refreshToken, err := issueLogin(user, refreshedLogin.FamilyID, refreshedLogin.Device)
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newSuccessfulLogin(login, refreshToken))
+}
+
 // handleRequestPassword handles a password request which can be for a new user
 // (user creation) or an existing user (password reset)
 //
@@ -130,10 +162,17 @@ func doResetPassword(w http.ResponseWriter, r *http.Request, user *User, pwdReq
 		return
 	}
 
+	sendPwdResetEmail(user, pwdReq.RedirectURL)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendPwdResetEmail sends the password setup url of a password reset token
+func sendPwdResetEmail(user *User, redirectURL string) {
 	// Email: Password setup url
 	subject := "Password reset Al-un.fr"
 	destURL := fmt.Sprintf("%s%s",
-		pwdReq.RedirectURL, user.PwdResetToken.Token)
+		redirectURL, user.PwdResetToken.Token)
 
 	// TODO: update email
 	alunEmail.SendNoReplyEmail(
@@ -142,8 +181,6 @@ func doResetPassword(w http.ResponseWriter, r *http.Request, user *User, pwdReq
 		utils.EmailTemplateUserPwdReset,
 		struct{ URL string }{URL: destURL},
 	)
-
-	w.WriteHeader(http.StatusNoContent)
 }
 
 func handleUpdatePassword(w http.ResponseWriter, r *http.Request) {
@@ -156,7 +193,10 @@ func handleUpdatePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userLogger.Verbose("Password updated for %+v", authUser)
+	// New users set their first password, only resets are notified
+	if authUser.PwdResetToken.RequestType == userPwdRequestPwdReset {
+		notifyPasswordUpdated(authUser.ID)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -176,6 +216,12 @@ func handleUpdateUser(w http.ResponseWriter, r *http.Request, claims core.JwtCla
 	var updatingUser User
 	json.NewDecoder(r.Body).Decode(&updatingUser)
 
+	// An empty name is UTC for LoadLocation but means an unchanged timezone
+	if _, err := time.LoadLocation(updatingUser.Timezone); updatingUser.Timezone != "" && err != nil {
+		timezoneInvalid.Write(w, r)
+		return
+	}
+
 	userID := core.GetVar(r, "userId")
 	result, err := updateUser(userID, updatingUser)
 	if err != nil {
@@ -1,30 +1,56 @@
 package user
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
 	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/pkg/crypto"
 	"github.com/dgrijalva/jwt-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-const jwtClaimsIssuer = "api.al-un.fr"
+const (
+	jwtClaimsIssuer = "api.al-un.fr"
+	// accessTokenDuration is short as JWT are checked without the database.
+	// Revoked tokens are only rejected once the services refresh their
+	// revocation list
+	accessTokenDuration = 15 * time.Minute
+	// refreshTokenDuration is the longest duration of a login without
+	// entering credentials again
+	refreshTokenDuration = 60 * 24 * time.Hour
+	refreshTokenLength   = 32
+)
 
 // generateJWT generate a JWT for a specific user with claims basically representing
 // the user properties. List of claims is based on https://tools.ietf.org/html/rfc7519
-// found through https://auth0.com/docs/tokens/jwt-claims. Tokens are valid 60 days
+// found through https://auth0.com/docs/tokens/jwt-claims. Tokens are valid a
+// few minutes and are renewed with a refresh token
 //
-// HMAC is chosen over RSA to protect against manipulation:
-// https://security.stackexchange.com/a/220190
+// Tokens are signed with RS256 and the "kid" header names the signing key, so
+// that the other services verify them with the published public keys. The
+// "jti" claim identifies a token to revoke it before its expiration
 //
-// Generate Token	: https://godoc.org/github.com/dgrijalva/jwt-go#example-New--Hmac
 // Custom claims	: https://godoc.org/github.com/dgrijalva/jwt-go#NewWithClaims
 func generateJWT(user User) (authToken, error) {
-	tokenExpiration := time.Now().Add(time.Hour * 24 * 60)
+	tokenExpiration := time.Now().Add(accessTokenDuration)
+	tokenID := primitive.NewObjectID().Hex()
+
+	// Role changes apply when the token is refreshed
+	permissions, err := findRolePermissions(user.Roles)
+	if err != nil {
+		userLogger.Warn("[JWT generation] roles of %s: %s", user.ID.Hex(), err.Error())
+		return authToken{}, err
+	}
 
 	userClaims := core.JwtClaims{
-		IsAdmin: user.IsAdmin,
-		UserID:  user.ID.Hex(),
+		IsAdmin:     user.IsAdmin,
+		UserID:      user.ID.Hex(),
+		Roles:       user.Roles,
+		Permissions: permissions,
 		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
 			ExpiresAt: tokenExpiration.Unix(),
 			Issuer:    jwtClaimsIssuer,
 			IssuedAt:  time.Now().Unix(),
@@ -32,12 +58,30 @@ func generateJWT(user User) (authToken, error) {
 		},
 	}
 
-	tokenString, err := core.BuildJWT(userClaims)
+	tokenString, err := jwtKeys.sign(userClaims)
 
 	if err != nil {
 		userLogger.Warn("[JWT generation] error: %s", err.Error())
 		return authToken{}, err
 	}
 
-	return authToken{Jwt: tokenString, ExpiresOn: tokenExpiration, Status: tokenStatusActive}, nil
+	return authToken{ID: tokenID, Jwt: tokenString, ExpiresOn: tokenExpiration, Status: tokenStatusActive}, nil
+}
+
+// generateRefreshToken generates an opaque refresh token and its hash. Only
+// the hash is saved so that a leaked database does not leak refresh tokens
+func generateRefreshToken() (string, string, error) {
+	token, err := crypto.GenerateRandomString(refreshTokenLength)
+	if err != nil {
+		return "", "", err
+	}
+
+	return token, hashRefreshToken(token), nil
+}
+
+// hashRefreshToken hashes a refresh token. Refresh tokens are random enough
+// for a fast unsalted hash, which allows to find a login by its hash
+func hashRefreshToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
 }
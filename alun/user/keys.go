@@ -0,0 +1,178 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// signingKeyMinBits is the minimum size of the RSA signing keys
+const signingKeyMinBits = 2048
+
+// signingKeyring holds the RSA keys of the user service. Only the current key
+// signs tokens, while all keys verify tokens: a key rotation adds a new key
+// which becomes the current key, and the previous key is removed once all the
+// tokens it signed are expired
+type signingKeyring struct {
+	currentKid string
+	keys       map[string]*rsa.PrivateKey
+}
+
+var jwtKeys *signingKeyring
+
+// loadSigningKeys loads the "<kid>.pem" files of the keys directory. The
+// current key is the configured one, or the last key ID in alphabetical
+// order. Without keys directory, an ephemeral key is generated and tokens do
+// not survive a restart
+func loadSigningKeys() (*signingKeyring, error) {
+	keysDir := os.Getenv(utils.EnvVarJwtKeysDir)
+	if keysDir == "" {
+		if !utils.IsTest() {
+			userLogger.Warn("[JWT] %s is not defined, generating an ephemeral signing key", utils.EnvVarJwtKeysDir)
+		}
+		return newEphemeralKeyring()
+	}
+
+	keyFiles, err := filepath.Glob(filepath.Join(keysDir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := &signingKeyring{keys: make(map[string]*rsa.PrivateKey)}
+	for _, keyFile := range keyFiles {
+		kid := strings.TrimSuffix(filepath.Base(keyFile), ".pem")
+		pemBytes, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseSigningKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("Key %s: %v", kid, err)
+		}
+		keyring.keys[kid] = key
+	}
+
+	return keyring, keyring.selectCurrentKey(os.Getenv(utils.EnvVarJwtSigningKid))
+}
+
+func newEphemeralKeyring() (*signingKeyring, error) {
+	key, err := rsa.GenerateKey(rand.Reader, signingKeyMinBits)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := fmt.Sprintf("ephemeral-%s", primitive.NewObjectID().Hex())
+	return &signingKeyring{
+		currentKid: kid,
+		keys:       map[string]*rsa.PrivateKey{kid: key},
+	}, nil
+}
+
+// parseSigningKey decodes a PKCS #1 or PKCS #8 RSA private key
+func parseSigningKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("No PEM block found")
+	}
+
+	var key *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		pkcs1Key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = pkcs1Key
+	case "PRIVATE KEY":
+		pkcs8Key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := pkcs8Key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("Only RSA keys are supported")
+		}
+		key = rsaKey
+	default:
+		return nil, fmt.Errorf("Unsupported PEM block %s", block.Type)
+	}
+
+	if key.N.BitLen() < signingKeyMinBits {
+		return nil, fmt.Errorf("Keys must have at least %d bits", signingKeyMinBits)
+	}
+
+	return key, nil
+}
+
+// selectCurrentKey defines the key signing the tokens
+func (k *signingKeyring) selectCurrentKey(kid string) error {
+	if len(k.keys) == 0 {
+		return errors.New("No signing key found")
+	}
+
+	if kid == "" {
+		kids := make([]string, 0, len(k.keys))
+		for keyID := range k.keys {
+			kids = append(kids, keyID)
+		}
+		sort.Strings(kids)
+		kid = kids[len(kids)-1]
+	}
+
+	if _, ok := k.keys[kid]; !ok {
+		return fmt.Errorf("Signing key %s not found", kid)
+	}
+	k.currentKid = kid
+
+	return nil
+}
+
+// sign signs the claims with the current key
+func (k *signingKeyring) sign(claims core.JwtClaims) (string, error) {
+	return core.BuildJWT(claims, k.currentKid, k.keys[k.currentKid])
+}
+
+// PublicKey verifies the tokens of this service without fetching the JWKS
+func (k *signingKeyring) PublicKey(kid string) (*rsa.PublicKey, error) {
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown key ID %s", kid)
+	}
+
+	return &key.PublicKey, nil
+}
+
+// keySet publishes the public keys, sorted by key ID
+func (k *signingKeyring) keySet() core.JSONWebKeySet {
+	keySet := core.JSONWebKeySet{Keys: make([]core.JSONWebKey, 0, len(k.keys))}
+	for kid, key := range k.keys {
+		keySet.Keys = append(keySet.Keys, core.NewJSONWebKey(kid, &key.PublicKey))
+	}
+	sort.Slice(keySet.Keys, func(i, j int) bool {
+		return keySet.Keys[i].Kid < keySet.Keys[j].Kid
+	})
+
+	return keySet
+}
+
+// handleGetJWKS publishes the public keys for the other services to verify
+// the tokens
+func handleGetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(jwtKeys.keySet())
+}
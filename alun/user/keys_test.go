@@ -0,0 +1,124 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/testutils"
+	"github.com/Al-un/alun-api/alun/utils"
+	"github.com/dgrijalva/jwt-go"
+)
+
+func newTestKeyPEM(t *testing.T, bits int, pkcs8 bool) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	testutils.Ok(t, testutils.CallFromHelperMethod, err)
+
+	if !pkcs8 {
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	testutils.Ok(t, testutils.CallFromHelperMethod, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestLoadSigningKeys(t *testing.T) {
+	keysDir, err := ioutil.TempDir("", "alun-keys")
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	defer os.RemoveAll(keysDir)
+
+	ioutil.WriteFile(filepath.Join(keysDir, "2026-01.pem"), newTestKeyPEM(t, 2048, false), 0600)
+	ioutil.WriteFile(filepath.Join(keysDir, "2026-07.pem"), newTestKeyPEM(t, 2048, true), 0600)
+
+	os.Setenv(utils.EnvVarJwtKeysDir, keysDir)
+	defer os.Unsetenv(utils.EnvVarJwtKeysDir)
+
+	keyring, err := loadSigningKeys()
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, "2026-07", keyring.currentKid)
+	testutils.Equals(t, testutils.CallFromTestFile, 2, len(keyring.keySet().Keys))
+
+	os.Setenv(utils.EnvVarJwtSigningKid, "2026-01")
+	defer os.Unsetenv(utils.EnvVarJwtSigningKid)
+	keyring, err = loadSigningKeys()
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, "2026-01", keyring.currentKid)
+
+	os.Setenv(utils.EnvVarJwtSigningKid, "pouet")
+	_, err = loadSigningKeys()
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Unknown signing key should fail")
+}
+
+func TestParseSigningKey(t *testing.T) {
+	_, err := parseSigningKey(newTestKeyPEM(t, 1024, false))
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Small keys should be rejected")
+
+	_, err = parseSigningKey([]byte("pouet"))
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Invalid PEM should be rejected")
+}
+
+func TestJSONWebKey(t *testing.T) {
+	keyring, err := newEphemeralKeyring()
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+
+	keySet := keyring.keySet()
+	testutils.Equals(t, testutils.CallFromTestFile, 1, len(keySet.Keys))
+	testutils.Equals(t, testutils.CallFromTestFile, keyring.currentKid, keySet.Keys[0].Kid)
+
+	publicKey, err := keySet.Keys[0].PublicKey()
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, keyring.keys[keyring.currentKid].PublicKey, *publicKey)
+}
+
+func TestDecodeSignedJWT(t *testing.T) {
+	decode := func(tokenString string) *core.ServiceMessage {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tokenString))
+		_, msg := core.DecodeJWT(r)
+		return msg
+	}
+	claims := core.JwtClaims{
+		UserID: "pouet",
+		StandardClaims: jwt.StandardClaims{
+			Id:        "jti",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		},
+	}
+
+	// Signed by the current key
+	tokenString, err := jwtKeys.sign(claims)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, 0, decode(tokenString).HTTPStatus)
+
+	// Signed by an unknown key
+	otherKeyring, _ := newEphemeralKeyring()
+	tokenString, _ = otherKeyring.sign(claims)
+	testutils.Equals(t, testutils.CallFromTestFile, http.StatusForbidden, decode(tokenString).HTTPStatus)
+
+	// Signed with HMAC
+	tokenString, _ = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	testutils.Equals(t, testutils.CallFromTestFile, http.StatusForbidden, decode(tokenString).HTTPStatus)
+}
+
+func TestEndpointJWKS(t *testing.T) {
+	rr := apiTester.TestPath(t, testutils.APITestInfo{
+		Path:               "jwks",
+		Method:             http.MethodGet,
+		ExpectedHTTPStatus: http.StatusOK,
+	})
+
+	var keySet core.JSONWebKeySet
+	json.NewDecoder(rr.Body).Decode(&keySet)
+	testutils.Assert(t, testutils.CallFromTestFile, len(keySet.Keys) == 1 && keySet.Keys[0].Kid == jwtKeys.currentKid,
+		"JWKS should publish the signing key: %+v", keySet)
+}
@@ -79,7 +79,12 @@ type User struct {
 	BaseUser      `bson:",inline"`
 	Username      string        `json:"username,omitempty" bson:"username,omitempty"`
 	IsAdmin       bool          `json:"isAdmin" bson:"isAdmin"`
-	PwdResetToken pwdResetToken `json:"-" bson:"pwdResetToken,omitempty"` // not present in JSON: https://golang.org/pkg/encoding/json/
+	IsDisabled    bool          `json:"isDisabled" bson:"isDisabled,omitempty"`       // Disabled users cannot login
+	Roles         []string      `json:"roles,omitempty" bson:"roles,omitempty"`       // names of the roles granting permissions
+	Timezone      string        `json:"timezone,omitempty" bson:"timezone,omitempty"` // IANA name such as "Europe/Paris", UTC if empty
+	PwdResetToken pwdResetToken `json:"-" bson:"pwdResetToken,omitempty"`             // not present in JSON: https://golang.org/pkg/encoding/json/
+	EmailChange   *emailChange  `json:"-" bson:"emailChange,omitempty"`               // pending or revertible email change
+	TwoFactor     *twoFactor    `json:"-" bson:"twoFactor,omitempty"`                 // TOTP secret and recovery codes
 }
 
 // AuthenticatedUser has the password field so that when the server sends
@@ -119,6 +124,7 @@ type pwdChangeRequest struct {
 // authToken saves the generated JWT in the database for re-usability or other
 // features such as token invalidation
 type authToken struct {
+	ID        string    `json:"jti" bson:"jti,omitempty"`                       // "jti" claim, to revoke the token
 	Jwt       string    `json:"jwt" bson:"jwt,omitempty"`                       // Stringified JWT
 	ExpiresOn time.Time `json:"expiresOn,omitempty" bson:"expiresOn,omitempty"` // Convenience for checking token expiration
 	Status    int       `json:"status" bson:"status"`                           // Token status [11-Apr-2020] Obsolete?
@@ -127,6 +133,8 @@ type authToken struct {
 const tokenStatusActive = 0       // Token is still active
 const tokenStatusLogout = 1       // Token has been disabled by an user logout
 const tokenStatusExpired = 2      // Token has expired
+const tokenStatusRefreshed = 3    // Token has been replaced by a refresh
+const tokenStatusRevoked = 4      // Token family has been revoked after a refresh token reuse
 const tokenStatusInvalidated = 10 // Token has been manually disabled by user
 
 // ----------------------------------------------------------------------------
@@ -135,16 +143,47 @@ const tokenStatusInvalidated = 10 // Token has been manually disabled by user
 
 // Login tracks user login and associated generated token.
 //
-// If a token was re-generated, it should create another Login as there is
-// no automatic regeneration upon token expiration.
+// A login is created on each successful authentication with credentials and
+// on each refresh. Refreshing a login marks it as refreshed and creates a new
+// login in the same family: all the logins created from the same credentials
+// authentication share a family ID.
 // UserID field is required to avoid decoding the JWT from `Token`
 type Login struct {
-	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"` // Uniquely identify to invalidate it
-	Timestamp time.Time          `json:"timestamp" bson:"timestamp"`        // Login timestamp
-	UserID    primitive.ObjectID `json:"userId" bson:"userId"`              // Logged-in user, should match the token of the token :)
-	Token     authToken          `json:"token" bson:"token"`                // Token generated during login
+	ID               primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"` // Uniquely identify to invalidate it
+	Timestamp        time.Time          `json:"timestamp" bson:"timestamp"`        // Login timestamp
+	UserID           primitive.ObjectID `json:"userId" bson:"userId"`              // Logged-in user, should match the token of the token :)
+	Token            authToken          `json:"token" bson:"token"`                // Token generated during login
+	FamilyID         primitive.ObjectID `json:"familyId" bson:"familyId"`          // First login of the family
+	RefreshHash      string             `json:"-" bson:"refreshHash"`              // Hash of the refresh token
+	RefreshExpiresOn time.Time          `json:"refreshExpiresOn" bson:"refreshExpiresOn"`
+	Device           loginDevice        `json:"device" bson:"device"` // Device of the credentials authentication
+}
+
+// loginDevice is the client which authenticated with credentials
+type loginDevice struct {
+	UserAgent string `json:"userAgent" bson:"userAgent"`
+	IP        string `json:"ip" bson:"ip"`
+}
+
+// Session is a login family seen by an user: a device which stays logged in
+// by refreshing its tokens. The session ID is the family ID
+type Session struct {
+	ID         primitive.ObjectID `json:"id"`
+	UserAgent  string             `json:"userAgent"`
+	IP         string             `json:"ip"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	LastSeenAt time.Time          `json:"lastSeenAt"` // Last refresh
+	ExpiresAt  time.Time          `json:"expiresAt"`  // Unless refreshed
+	IsCurrent  bool               `json:"isCurrent"`  // Session of the request token
 }
 
 type successfulLogin struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"` // Access token validity, in seconds
+}
+
+// refreshRequest exchanges a refresh token for new tokens
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
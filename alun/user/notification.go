@@ -0,0 +1,20 @@
+package user
+
+import (
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// notifyPasswordUpdated warns an user that the password has been reset, so
+// that an unexpected reset does not go unnoticed. The notification is sent in
+// the background as the password is already saved
+func notifyPasswordUpdated(userID primitive.ObjectID) {
+	notification := core.Notification{
+		UserID:  userID.Hex(),
+		Type:    core.NotificationPasswordUpdated,
+		Title:   "Your password has been updated",
+		Message: "Reset your password again if you did not request this change",
+	}
+
+	core.NotifyAsync(notification)
+}
@@ -0,0 +1,88 @@
+package user
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+)
+
+// revocationSyncOverlap is subtracted from the "since" time of the services
+// fetching the revocations so that a revocation saved while listing is not
+// missed. Revocations listed twice are harmless
+const revocationSyncOverlap = 5 * time.Second
+
+// localRevocationSource lists the revoked tokens from the database, for the
+// services of the same process
+type localRevocationSource struct{}
+
+func (localRevocationSource) FindRevocations(since time.Time) ([]core.TokenRevocation, time.Time, error) {
+	syncedAt := time.Now()
+	revocations, err := findRevocations(since.Add(-revocationSyncOverlap))
+	if err != nil {
+		return nil, since, err
+	}
+
+	return revocations, syncedAt, nil
+}
+
+// newTokenRevocations revokes the access tokens of logins which are not
+// expired yet
+func newTokenRevocations(logins []Login, invalidStatusCode int, now time.Time) []core.TokenRevocation {
+	reason := core.TokenRevokedInvalidated
+	if invalidStatusCode == tokenStatusLogout {
+		reason = core.TokenRevokedLogout
+	}
+
+	revocations := make([]core.TokenRevocation, 0)
+	for _, login := range logins {
+		if login.Token.ID == "" || !login.Token.ExpiresOn.After(now) {
+			continue
+		}
+
+		revocations = append(revocations, core.TokenRevocation{
+			ID:        login.Token.ID,
+			Reason:    reason,
+			RevokedAt: now,
+			ExpiresAt: login.Token.ExpiresOn,
+		})
+	}
+
+	return revocations
+}
+
+// hasRevocationSecret authenticates the internal requests of the other
+// services. Internal endpoints are disabled if no secret is defined
+func hasRevocationSecret(r *http.Request) bool {
+	secret := r.Header.Get(core.RevocationSecretHeader)
+	return revocationSecret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(revocationSecret)) == 1
+}
+
+// handleGetRevocations lists the revoked tokens for the other services in
+// microservice mode
+func handleGetRevocations(w http.ResponseWriter, r *http.Request) {
+	if !hasRevocationSecret(r) {
+		revocationSecretInvalid.Write(w, r)
+		return
+	}
+
+	var since time.Time
+	if sinceParam := r.URL.Query().Get("since"); sinceParam != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339Nano, sinceParam); err != nil {
+			revocationSinceInvalid.Write(w, r)
+			return
+		}
+	}
+
+	revocations, syncedAt, err := localRevocationSource{}.FindRevocations(since)
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(core.RevocationList{Revocations: revocations, SyncedAt: syncedAt})
+}
@@ -0,0 +1,127 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/testutils"
+)
+
+func TestNewTokenRevocations(t *testing.T) {
+	now := time.Now()
+	logins := []Login{
+		{Token: authToken{ID: "active", ExpiresOn: now.Add(time.Minute)}},
+		{Token: authToken{ID: "expired", ExpiresOn: now.Add(-time.Minute)}},
+		{Token: authToken{ExpiresOn: now.Add(time.Minute)}}, // Legacy token without ID
+	}
+
+	revocations := newTokenRevocations(logins, tokenStatusLogout, now)
+	testutils.Assert(t, testutils.CallFromTestFile, len(revocations) == 1 && revocations[0].ID == "active",
+		"Only the unexpired token should be revoked: %+v", revocations)
+	testutils.Equals(t, testutils.CallFromTestFile, core.TokenRevokedLogout, revocations[0].Reason)
+	testutils.Equals(t, testutils.CallFromTestFile, logins[0].Token.ExpiresOn, revocations[0].ExpiresAt)
+
+	revocations = newTokenRevocations(logins, tokenStatusRevoked, now)
+	testutils.Equals(t, testutils.CallFromTestFile, core.TokenRevokedInvalidated, revocations[0].Reason)
+}
+
+func TestE2ERevocation(t *testing.T) {
+	_, _, basicUser, _ := setupUserBasicAndAdmin(t)
+
+	t.Cleanup(func() {
+		tearDownBasicAndAdmin(t)
+		tearDownLogins(t, basicUser.ID)
+	})
+
+	login := func(t *testing.T) successfulLogin {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:   "login",
+			Method: http.MethodPost,
+			Payload: authenticatedUser{
+				User:     User{BaseUser: BaseUser{Email: basicUser.Email}},
+				Password: userBasicPassword,
+			},
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+
+		var tokens successfulLogin
+		json.NewDecoder(rr.Body).Decode(&tokens)
+		return tokens
+	}
+	getUser := func(t *testing.T, token string, expectedStatus int) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("detail/%s", basicUser.ID.Hex()),
+			Method:             http.MethodGet,
+			AuthToken:          token,
+			ExpectedHTTPStatus: expectedStatus,
+		})
+	}
+
+	t.Run("LogoutRevokesAccessToken", func(t *testing.T) {
+		tokens := login(t)
+		getUser(t, tokens.Token, http.StatusOK)
+
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "logout",
+			Method:             http.MethodPost,
+			AuthToken:          tokens.Token,
+			ExpectedHTTPStatus: http.StatusNoContent,
+		})
+
+		getUser(t, tokens.Token, http.StatusForbidden)
+	})
+
+	t.Run("RefreshTokenReuseRevokesAccessTokens", func(t *testing.T) {
+		tokens := login(t)
+
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "refresh",
+			Method:             http.MethodPost,
+			Payload:            refreshRequest{RefreshToken: tokens.RefreshToken},
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+		var refreshed successfulLogin
+		json.NewDecoder(rr.Body).Decode(&refreshed)
+
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "refresh",
+			Method:             http.MethodPost,
+			Payload:            refreshRequest{RefreshToken: tokens.RefreshToken},
+			ExpectedHTTPStatus: http.StatusUnauthorized,
+		})
+
+		getUser(t, tokens.Token, http.StatusForbidden)
+		getUser(t, refreshed.Token, http.StatusForbidden)
+	})
+
+	t.Run("InternalEndpoint", func(t *testing.T) {
+		since := time.Now().UTC().Add(-time.Minute).Format(time.RFC3339Nano)
+		revocationsPath := fmt.Sprintf("internal/revocations?since=%s", since)
+
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               revocationsPath,
+			Method:             http.MethodGet,
+			ExpectedHTTPStatus: http.StatusUnauthorized,
+		})
+
+		previousSecret := revocationSecret
+		revocationSecret = "revocationSecret"
+		defer func() { revocationSecret = previousSecret }()
+
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               revocationsPath,
+			Method:             http.MethodGet,
+			Headers:            map[string]string{core.RevocationSecretHeader: revocationSecret},
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+
+		var list core.RevocationList
+		json.NewDecoder(rr.Body).Decode(&list)
+		testutils.Assert(t, testutils.CallFromTestFile, len(list.Revocations) >= 3 && !list.SyncedAt.IsZero(),
+			"Revocations of the previous tests are expected: %+v", list)
+	})
+}
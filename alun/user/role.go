@@ -0,0 +1,312 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audited role actions
+const (
+	auditRoleUpdated      = "role.updated"
+	auditRoleDeleted      = "role.deleted"
+	auditUserRolesChanged = "user.rolesChanged"
+)
+
+// roleNamePattern restricts the role names, which are embedded in the tokens
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// Role is a named set of permissions, assigned to users. The permissions are
+// embedded in the tokens so that a change applies when the tokens of the users
+// are refreshed
+type Role struct {
+	Name        string    `json:"name" bson:"_id"`
+	Description string    `json:"description,omitempty" bson:"description,omitempty"`
+	Permissions []string  `json:"permissions" bson:"permissions"`
+	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// userRolesRequest replaces the roles of an user
+type userRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// newRole validates a role definition
+func newRole(name string, role Role, now time.Time) (Role, *core.ServiceMessage) {
+	if !roleNamePattern.MatchString(name) {
+		return Role{}, newRoleInvalid(fmt.Errorf("name must match %s", roleNamePattern.String()))
+	}
+
+	permissions := make([]string, 0, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		if !core.IsKnownPermission(permission) {
+			return Role{}, newRoleInvalid(fmt.Errorf("unknown permission %s", permission))
+		}
+		if !containsString(permissions, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	return Role{
+		Name:        name,
+		Description: strings.TrimSpace(role.Description),
+		Permissions: permissions,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// mergePermissions lists the permissions granted by roles, without duplicates
+func mergePermissions(roles []Role) []string {
+	permissions := make([]string, 0)
+	for _, role := range roles {
+		for _, permission := range role.Permissions {
+			if !containsString(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	return permissions
+}
+
+// hasRemovedValue is true if a previous role, or permission, is not in the
+// new values
+func hasRemovedValue(previous []string, values []string) bool {
+	for _, value := range previous {
+		if !containsString(values, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// findCurrentPermissions loads the permissions an user has now, from its
+// roles in the database: the permissions of its token can be outdated. Admins
+// have all the permissions
+func findCurrentPermissions(userID string) (bool, []string, *core.ServiceMessage) {
+	user, err := findUserByID(userID)
+	if err != nil {
+		return false, nil, core.NewServiceErrorMessage(err)
+	}
+	if user.IsAdmin {
+		return true, nil, nil
+	}
+
+	permissions, err := findRolePermissions(user.Roles)
+	if err != nil {
+		return false, nil, core.NewServiceErrorMessage(err)
+	}
+
+	return false, permissions, nil
+}
+
+// checkGrantablePermissions ensures that an user has all the permissions it
+// grants, through a role definition or a role assignment. Otherwise, a
+// roles.manage holder could grant any permission to itself or to another
+// account it controls
+func checkGrantablePermissions(claims core.JwtClaims, granted []string) *core.ServiceMessage {
+	if len(granted) == 0 {
+		return nil
+	}
+
+	isAdmin, permissions, errMsg := findCurrentPermissions(claims.UserID)
+	if errMsg != nil {
+		return errMsg
+	}
+	if !isAdmin && hasRemovedValue(granted, permissions) {
+		return rolePermissionsForbidden
+	}
+
+	return nil
+}
+
+// revokeRoleLogins logs out the users of a role which loses permissions, as
+// their tokens still have the permissions
+func revokeRoleLogins(roleName string) *core.ServiceMessage {
+	userIDs, err := findRoleUserIDs(roleName)
+	if err != nil {
+		return core.NewServiceErrorMessage(err)
+	}
+
+	for _, userID := range userIDs {
+		if errMsg := revokeUserLogins(userID); errMsg != nil {
+			return errMsg
+		}
+	}
+
+	return nil
+}
+
+// auditRoleAction saves an action on a role definition
+func auditRoleAction(claims core.JwtClaims, roleName string, action string) {
+	entry := newAuditEntry(claims, primitive.NilObjectID, action)
+	entry.Role = roleName
+	if errMsg := createAuditEntry(entry); errMsg != nil {
+		userLogger.Warn("[Admin] Audit of %s by %s on role %s failed: %v",
+			action, claims.UserID, roleName, errMsg.Message)
+	}
+}
+
+// handleListRoles lists the role definitions, sorted by name
+func handleListRoles(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	roles, err := findRoles(nil)
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(roles)
+}
+
+// handleSaveRole creates or replaces a role definition. Users of a role losing
+// permissions are logged out. Only admins can add permissions they do not have
+func handleSaveRole(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	var roleReq Role
+	if err := json.NewDecoder(r.Body).Decode(&roleReq); err != nil {
+		newRoleInvalid(err).Write(w, r)
+		return
+	}
+
+	role, errMsg := newRole(core.GetVar(r, "roleName"), roleReq, time.Now())
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	previousRoles, err := findRoles([]string{role.Name})
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+	previousPermissions := make([]string, 0)
+	if len(previousRoles) > 0 {
+		previousPermissions = previousRoles[0].Permissions
+	}
+	addedPermissions := make([]string, 0)
+	for _, permission := range role.Permissions {
+		if !containsString(previousPermissions, permission) {
+			addedPermissions = append(addedPermissions, permission)
+		}
+	}
+	if errMsg := checkGrantablePermissions(claims, addedPermissions); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	if err := saveRole(role); err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+	if hasRemovedValue(previousPermissions, role.Permissions) {
+		if errMsg := revokeRoleLogins(role.Name); errMsg != nil {
+			errMsg.Write(w, r)
+			return
+		}
+	}
+	auditRoleAction(claims, role.Name, auditRoleUpdated)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(role)
+}
+
+// handleDeleteRole deletes a role definition and removes it from the users,
+// who are logged out
+func handleDeleteRole(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	roleName := core.GetVar(r, "roleName")
+	userIDs, err := findRoleUserIDs(roleName)
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+	if errMsg := deleteRole(roleName); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	for _, userID := range userIDs {
+		if errMsg := revokeUserLogins(userID); errMsg != nil {
+			errMsg.Write(w, r)
+			return
+		}
+	}
+	auditRoleAction(claims, roleName, auditRoleDeleted)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSetUserRoles replaces the roles of an user. An user losing a role is
+// logged out as its tokens still have the permissions of the role. Users
+// cannot change their own roles, nor grant a role with permissions they do not
+// have, and the roles of admins, who have all the permissions, cannot be
+// changed
+func handleSetUserRoles(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	var rolesReq userRolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&rolesReq); err != nil || rolesReq.Roles == nil {
+		newRoleInvalid(fmt.Errorf("a \"roles\" list is required")).Write(w, r)
+		return
+	}
+
+	roleNames := make([]string, 0, len(rolesReq.Roles))
+	for _, roleName := range rolesReq.Roles {
+		if !containsString(roleNames, roleName) {
+			roleNames = append(roleNames, roleName)
+		}
+	}
+	roles, err := findRoles(roleNames)
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+	if len(roles) != len(roleNames) {
+		roleNotFound.Write(w, r)
+		return
+	}
+
+	user, err := findUserByID(core.GetVar(r, "userId"))
+	if err != nil {
+		userNotFound.Write(w, r)
+		return
+	}
+	if user.ID.Hex() == claims.UserID || user.IsAdmin {
+		userRolesForbidden.Write(w, r)
+		return
+	}
+	addedRoles := make([]Role, 0)
+	for _, role := range roles {
+		if !containsString(user.Roles, role.Name) {
+			addedRoles = append(addedRoles, role)
+		}
+	}
+	if errMsg := checkGrantablePermissions(claims, mergePermissions(addedRoles)); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	updatedUser, errMsg := updateUserRoles(user.ID, roleNames)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	if hasRemovedValue(user.Roles, roleNames) {
+		if errMsg := revokeUserLogins(user.ID); errMsg != nil {
+			errMsg.Write(w, r)
+			return
+		}
+	}
+	auditAction(claims, user.ID, auditUserRolesChanged)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updatedUser)
+}
+
+// newRoleInvalid details why a role request is invalid
+func newRoleInvalid(err error) *core.ServiceMessage {
+	msg := *roleInvalid
+	msg.Message = fmt.Sprintf("%s: %v", roleInvalid.Message, err)
+
+	return &msg
+}
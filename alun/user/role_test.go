@@ -0,0 +1,246 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/testutils"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNewRole(t *testing.T) {
+	now := time.Now()
+
+	invalidRoles := map[string]Role{
+		"Support!": {Permissions: []string{core.PermissionUsersRead}},
+		"s":        {Permissions: []string{core.PermissionUsersRead}},
+		"support":  {Permissions: []string{"users.delete"}},
+	}
+	for name, role := range invalidRoles {
+		_, errMsg := newRole(name, role, now)
+		testutils.Assert(t, testutils.CallFromTestFile, errMsg != nil && errMsg.Code == roleInvalid.Code,
+			"%s should be invalid: %v", name, errMsg)
+	}
+
+	role, errMsg := newRole("support", Role{
+		Description: " Help desk ",
+		Permissions: []string{core.PermissionUsersRead, core.PermissionUsersRead},
+	}, now)
+	testutils.Assert(t, testutils.CallFromTestFile, errMsg == nil, "Role should be valid: %v", errMsg)
+	testutils.Equals(t, testutils.CallFromTestFile, "Help desk", role.Description)
+	testutils.Equals(t, testutils.CallFromTestFile, []string{core.PermissionUsersRead}, role.Permissions)
+
+	permissions := mergePermissions([]Role{
+		role,
+		{Permissions: []string{core.PermissionUsersRead, core.PermissionQuotasManage}},
+	})
+	testutils.Equals(t, testutils.CallFromTestFile, []string{core.PermissionUsersRead, core.PermissionQuotasManage}, permissions)
+	testutils.Assert(t, testutils.CallFromTestFile, hasRemovedValue([]string{"a", "b"}, []string{"b"}), "a should be removed")
+	testutils.Assert(t, testutils.CallFromTestFile, !hasRemovedValue([]string{"a"}, []string{"a", "b"}), "No role should be removed")
+}
+
+func TestAccessCheckers(t *testing.T) {
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"userId": "pouet"})
+	owner := core.JwtClaims{UserID: "pouet"}
+	support := core.JwtClaims{UserID: "plop", Permissions: []string{core.PermissionUsersRead}}
+	admin := core.JwtClaims{UserID: "admin", IsAdmin: true}
+
+	checkers := map[string]struct {
+		checker  core.AccessChecker
+		claims   core.JwtClaims
+		expected bool
+	}{
+		"OwnerOfVar":           {core.IsOwnerOf("userId"), owner, true},
+		"NotOwnerOfVar":        {core.IsOwnerOf("userId"), support, false},
+		"AnonymousNotOwner":    {core.IsOwnerOf("tokenId"), core.JwtClaims{}, false},
+		"GrantedPermission":    {core.HasPermission(core.PermissionUsersRead), support, true},
+		"MissingPermission":    {core.HasPermission(core.PermissionUsersManage), support, false},
+		"AdminHasPermissions":  {core.HasPermission(core.PermissionRolesManage), admin, true},
+		"AnyPasses":            {checkOwnerOrAdmin, owner, true},
+		"AnyFails":             {checkOwnerOrAdmin, support, false},
+		"AllPasses":            {core.All(core.IsOwnerOf("userId"), core.Not(core.CheckIfAdmin)), owner, true},
+		"AllFails":             {core.All(core.IsOwnerOf("userId"), core.Not(core.CheckIfAdmin)), admin, false},
+		"ManageImpliesReading": {checkUsersRead, core.JwtClaims{Permissions: []string{core.PermissionUsersManage}}, true},
+	}
+	for name, check := range checkers {
+		testutils.Assert(t, testutils.CallFromTestFile, check.checker(r, check.claims).IsAllowed == check.expected,
+			"%s should be %v", name, check.expected)
+	}
+}
+
+func TestE2ERoles(t *testing.T) {
+	adminUser, adminToken, basicUser, _ := setupUserBasicAndAdmin(t)
+	roleName := "support"
+	escalationRoleName := "escalation"
+
+	t.Cleanup(func() {
+		tearDownBasicAndAdmin(t)
+		tearDownLogins(t, basicUser.ID)
+		dbUserRoleCollection.DeleteMany(context.TODO(), bson.M{"_id": bson.M{"$in": []string{roleName, escalationRoleName}}})
+	})
+
+	login := func(t *testing.T) string {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:   "login",
+			Method: http.MethodPost,
+			Payload: authenticatedUser{
+				User:     User{BaseUser: BaseUser{Email: basicUser.Email}},
+				Password: userBasicPassword,
+			},
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+
+		var tokens successfulLogin
+		json.NewDecoder(rr.Body).Decode(&tokens)
+		return tokens.Token
+	}
+	listUsers := func(t *testing.T, token string, expectedStatus int) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "admin/users",
+			Method:             http.MethodGet,
+			AuthToken:          token,
+			ExpectedHTTPStatus: expectedStatus,
+		})
+	}
+	setUserRoles := func(t *testing.T, userID string, roles []string, expectedStatus int) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("admin/users/%s/roles", userID),
+			Method:             http.MethodPut,
+			Payload:            userRolesRequest{Roles: roles},
+			AuthToken:          adminToken,
+			ExpectedHTTPStatus: expectedStatus,
+		})
+	}
+	setRoles := func(t *testing.T, roles []string, expectedStatus int) {
+		setUserRoles(t, basicUser.ID.Hex(), roles, expectedStatus)
+	}
+	saveRole := func(t *testing.T, permissions []string) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("admin/roles/%s", roleName),
+			Method:             http.MethodPut,
+			Payload:            Role{Permissions: permissions},
+			AuthToken:          adminToken,
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+	}
+
+	t.Run("UnknownRoleCannotBeAssigned", func(t *testing.T) {
+		setRoles(t, []string{roleName}, http.StatusNotFound)
+	})
+
+	t.Run("RoleGrantsPermission", func(t *testing.T) {
+		saveRole(t, []string{core.PermissionUsersRead})
+		listUsers(t, login(t), http.StatusUnauthorized)
+
+		setRoles(t, []string{roleName}, http.StatusOK)
+		supportToken := login(t)
+		listUsers(t, supportToken, http.StatusOK)
+
+		// users.read does not grant the management of the roles
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               "admin/roles",
+			Method:             http.MethodGet,
+			AuthToken:          supportToken,
+			ExpectedHTTPStatus: http.StatusUnauthorized,
+		})
+	})
+
+	t.Run("AdminRolesCannotBeChanged", func(t *testing.T) {
+		setUserRoles(t, adminUser.ID.Hex(), []string{roleName}, http.StatusForbidden)
+	})
+
+	t.Run("RemovedPermissionLogsOut", func(t *testing.T) {
+		supportToken := login(t)
+		saveRole(t, []string{core.PermissionUsersRead, core.PermissionQuotasManage})
+		listUsers(t, supportToken, http.StatusOK)
+
+		saveRole(t, []string{core.PermissionQuotasManage})
+		listUsers(t, supportToken, http.StatusForbidden)
+		saveRole(t, []string{core.PermissionUsersRead})
+	})
+
+	t.Run("OwnRoleCannotGainPermissions", func(t *testing.T) {
+		saveRole(t, []string{core.PermissionUsersRead, core.PermissionRolesManage})
+		supportToken := login(t)
+		saveOwnRole := func(permissions []string, expectedStatus int) {
+			apiTester.TestPath(t, testutils.APITestInfo{
+				Path:               fmt.Sprintf("admin/roles/%s", roleName),
+				Method:             http.MethodPut,
+				Payload:            Role{Permissions: permissions},
+				AuthToken:          supportToken,
+				ExpectedHTTPStatus: expectedStatus,
+			})
+		}
+
+		saveOwnRole([]string{core.PermissionUsersRead, core.PermissionRolesManage, core.PermissionUsersManage}, http.StatusForbidden)
+		saveOwnRole([]string{core.PermissionUsersRead, core.PermissionRolesManage}, http.StatusOK)
+
+		roles, _ := findRoles([]string{roleName})
+		testutils.Equals(t, testutils.CallFromTestFile,
+			[]string{core.PermissionUsersRead, core.PermissionRolesManage}, roles[0].Permissions)
+		saveRole(t, []string{core.PermissionUsersRead})
+	})
+
+	t.Run("GrantedPermissionsMustBeHeld", func(t *testing.T) {
+		saveRole(t, []string{core.PermissionUsersRead, core.PermissionRolesManage})
+		supportToken := login(t)
+		otherUser, _ := setupUsers(t, userBasic, userBasicPassword)
+		t.Cleanup(func() {
+			TearDownUsers([]User{userBasic}, t.Name())
+		})
+		saveRoleAs := func(token string, permissions []string, expectedStatus int) {
+			apiTester.TestPath(t, testutils.APITestInfo{
+				Path:               fmt.Sprintf("admin/roles/%s", escalationRoleName),
+				Method:             http.MethodPut,
+				Payload:            Role{Permissions: permissions},
+				AuthToken:          token,
+				ExpectedHTTPStatus: expectedStatus,
+			})
+		}
+		setRolesAs := func(roles []string, expectedStatus int) {
+			apiTester.TestPath(t, testutils.APITestInfo{
+				Path:               fmt.Sprintf("admin/users/%s/roles", otherUser.ID.Hex()),
+				Method:             http.MethodPut,
+				Payload:            userRolesRequest{Roles: roles},
+				AuthToken:          supportToken,
+				ExpectedHTTPStatus: expectedStatus,
+			})
+		}
+
+		// A role with a permission the support does not have can neither be
+		// created nor assigned by the support
+		saveRoleAs(supportToken, []string{core.PermissionUsersManage}, http.StatusForbidden)
+		saveRoleAs(adminToken, []string{core.PermissionUsersManage}, http.StatusOK)
+		setRolesAs([]string{escalationRoleName}, http.StatusForbidden)
+
+		setRolesAs([]string{roleName}, http.StatusOK)
+
+		// Permissions are loaded from the roles, not from the token
+		staleClaims := core.JwtClaims{UserID: basicUser.ID.Hex(), Permissions: []string{core.PermissionUsersManage}}
+		testutils.Equals(t, testutils.CallFromTestFile, rolePermissionsForbidden,
+			checkGrantablePermissions(staleClaims, []string{core.PermissionUsersManage}))
+		saveRole(t, []string{core.PermissionUsersRead})
+	})
+
+	t.Run("DeletedRoleIsRemoved", func(t *testing.T) {
+		supportToken := login(t)
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("admin/roles/%s", roleName),
+			Method:             http.MethodDelete,
+			AuthToken:          adminToken,
+			ExpectedHTTPStatus: http.StatusNoContent,
+		})
+
+		user, _ := findUserByID(basicUser.ID.Hex())
+		testutils.Equals(t, testutils.CallFromTestFile, 0, len(user.Roles))
+		listUsers(t, supportToken, http.StatusForbidden)
+		listUsers(t, login(t), http.StatusUnauthorized)
+	})
+}
@@ -45,3 +45,153 @@ var isEmailNotFound = &core.ServiceMessage{
 	HTTPStatus: http.StatusNotFound,
 	Message:    "Email is not found",
 }
+
+var timezoneInvalid = &core.ServiceMessage{
+	Code:       10206,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Timezone is not a valid IANA timezone",
+}
+
+var refreshTokenInvalid = &core.ServiceMessage{
+	Code:       10207,
+	HTTPStatus: http.StatusUnauthorized,
+	Message:    "Refresh token is invalid or expired",
+}
+
+var refreshTokenReused = &core.ServiceMessage{
+	Code:       10208,
+	HTTPStatus: http.StatusUnauthorized,
+	Message:    "Refresh token has already been used, all the related tokens are revoked",
+}
+
+var revocationSecretInvalid = &core.ServiceMessage{
+	Code:       10209,
+	HTTPStatus: http.StatusUnauthorized,
+	Message:    "Internal secret is invalid",
+}
+
+var revocationSinceInvalid = &core.ServiceMessage{
+	Code:       10210,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Parameter since must be a RFC 3339 time",
+}
+
+var sessionNotFound = &core.ServiceMessage{
+	Code:       10211,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Session not found",
+}
+
+var userNotFound = &core.ServiceMessage{
+	Code:       10212,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "User not found",
+}
+
+var adminRequestInvalid = &core.ServiceMessage{
+	Code:       10213,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Admin request is invalid",
+}
+
+var adminSelfAction = &core.ServiceMessage{
+	Code:       10214,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Admins cannot demote or disable themselves",
+}
+
+var emailChangeTokenNotFound = &core.ServiceMessage{
+	Code:       10215,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Email change token not found",
+}
+
+var emailChangeTokenExpired = &core.ServiceMessage{
+	Code:       10216,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Email change token is expired",
+}
+
+var twoFactorAlreadyEnabled = &core.ServiceMessage{
+	Code:       10217,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Two-factor authentication is already enabled",
+}
+
+var twoFactorNotEnrolled = &core.ServiceMessage{
+	Code:       10218,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Two-factor authentication is not enrolled",
+}
+
+var twoFactorCodeInvalid = &core.ServiceMessage{
+	Code:       10219,
+	HTTPStatus: http.StatusUnauthorized,
+	Message:    "Two-factor code is invalid or already used",
+}
+
+var twoFactorChallengeInvalid = &core.ServiceMessage{
+	Code:       10220,
+	HTTPStatus: http.StatusUnauthorized,
+	Message:    "Login challenge is invalid or expired",
+}
+
+var accessTokenInvalid = &core.ServiceMessage{
+	Code:       10221,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Personal access token request is invalid",
+}
+
+var accessTokenNotFound = &core.ServiceMessage{
+	Code:       10222,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Personal access token not found",
+}
+
+var roleInvalid = &core.ServiceMessage{
+	Code:       10223,
+	HTTPStatus: http.StatusBadRequest,
+	Message:    "Role request is invalid",
+}
+
+var roleNotFound = &core.ServiceMessage{
+	Code:       10224,
+	HTTPStatus: http.StatusNotFound,
+	Message:    "Role not found",
+}
+
+var adminTargetForbidden = &core.ServiceMessage{
+	Code:       10225,
+	HTTPStatus: http.StatusForbidden,
+	Message:    "Only admins can manage an admin",
+}
+
+var emailChangeRevertPending = &core.ServiceMessage{
+	Code:       10226,
+	HTTPStatus: http.StatusConflict,
+	Message:    "Previous email change can still be reverted",
+}
+
+var twoFactorLocked = &core.ServiceMessage{
+	Code:       10227,
+	HTTPStatus: http.StatusTooManyRequests,
+	Message:    "Two-factor is locked after too many invalid codes, try again later",
+}
+
+var userRolesForbidden = &core.ServiceMessage{
+	Code:       10228,
+	HTTPStatus: http.StatusForbidden,
+	Message:    "Roles of admins and own roles cannot be changed",
+}
+
+var rolePermissionsForbidden = &core.ServiceMessage{
+	Code:       10229,
+	HTTPStatus: http.StatusForbidden,
+	Message:    "Only admins can grant permissions they do not have",
+}
+
+var passwordInvalid = &core.ServiceMessage{
+	Code:       10230,
+	HTTPStatus: http.StatusForbidden,
+	Message:    "Current password is invalid",
+}
@@ -0,0 +1,129 @@
+package user
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// userAgentMaxLength truncates the user agent saved with a login
+const userAgentMaxLength = 256
+
+// newLoginDevice captures the client of a login request. The IP is the first
+// address forwarded by the proxy, if any
+func newLoginDevice(r *http.Request) loginDevice {
+	userAgent := r.UserAgent()
+	if len(userAgent) > userAgentMaxLength {
+		userAgent = userAgent[:userAgentMaxLength]
+	}
+
+	ip := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-For"), ",")[0])
+	if ip == "" {
+		ip = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			ip = host
+		}
+	}
+
+	return loginDevice{UserAgent: userAgent, IP: ip}
+}
+
+// newSessions lists the sessions of the active logins, the most recently seen
+// first. A family has one active login, its latest one, and the family ID is
+// the ID of the first login
+func newSessions(activeLogins []Login, currentFamilyID primitive.ObjectID) []Session {
+	sessions := make([]Session, 0, len(activeLogins))
+	for _, login := range activeLogins {
+		sessions = append(sessions, Session{
+			ID:         login.FamilyID,
+			UserAgent:  login.Device.UserAgent,
+			IP:         login.Device.IP,
+			CreatedAt:  login.FamilyID.Timestamp(),
+			LastSeenAt: login.Timestamp,
+			ExpiresAt:  login.RefreshExpiresOn,
+			IsCurrent:  !currentFamilyID.IsZero() && login.FamilyID == currentFamilyID,
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return sessions
+}
+
+// currentFamilyID is the session of the request token, nil if the token was
+// not issued by a login
+func currentFamilyID(claims core.JwtClaims) primitive.ObjectID {
+	login, err := findLoginByTokenID(claims.Id)
+	if err != nil {
+		return primitive.NilObjectID
+	}
+
+	return login.FamilyID
+}
+
+// handleListSessions lists the active sessions of an user
+func handleListSessions(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	activeLogins, errMsg := findActiveLogins(core.GetVar(r, "userId"), time.Now())
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newSessions(activeLogins, currentFamilyID(claims)))
+}
+
+// handleRevokeSession logs out a session of an user and revokes its tokens
+func handleRevokeSession(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	familyID, err := primitive.ObjectIDFromHex(core.GetVar(r, "sessionId"))
+	if err != nil {
+		sessionNotFound.Write(w, r)
+		return
+	}
+
+	revokedCount, errMsg := revokeSessions(core.GetVar(r, "userId"), []primitive.ObjectID{familyID})
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	if revokedCount == 0 {
+		sessionNotFound.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRevokeOtherSessions logs out all the sessions of an user except the
+// session of the request token
+func handleRevokeOtherSessions(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	userID := core.GetVar(r, "userId")
+	activeLogins, errMsg := findActiveLogins(userID, time.Now())
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	currentFamily := currentFamilyID(claims)
+	familyIDs := make([]primitive.ObjectID, 0)
+	for _, login := range activeLogins {
+		if login.FamilyID != currentFamily {
+			familyIDs = append(familyIDs, login.FamilyID)
+		}
+	}
+
+	if _, errMsg := revokeSessions(userID, familyIDs); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,158 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNewLoginDevice(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodPost, "/v1/login", nil)
+	r.RemoteAddr = "10.0.0.1:4242"
+	r.Header.Set("User-Agent", strings.Repeat("a", userAgentMaxLength+10))
+
+	device := newLoginDevice(r)
+	testutils.Equals(t, testutils.CallFromTestFile, "10.0.0.1", device.IP)
+	testutils.Equals(t, testutils.CallFromTestFile, userAgentMaxLength, len(device.UserAgent))
+
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	testutils.Equals(t, testutils.CallFromTestFile, "203.0.113.7", newLoginDevice(r).IP)
+}
+
+func TestNewSessions(t *testing.T) {
+	now := time.Now()
+	current, other := primitive.NewObjectID(), primitive.NewObjectID()
+	logins := []Login{
+		{FamilyID: current, Timestamp: now.Add(-time.Hour), Device: loginDevice{UserAgent: "Firefox"}},
+		{FamilyID: other, Timestamp: now, Device: loginDevice{UserAgent: "Curl"}},
+	}
+
+	sessions := newSessions(logins, current)
+	testutils.Equals(t, testutils.CallFromTestFile, 2, len(sessions))
+	testutils.Equals(t, testutils.CallFromTestFile, other, sessions[0].ID)
+	testutils.Equals(t, testutils.CallFromTestFile, "Curl", sessions[0].UserAgent)
+	testutils.Assert(t, testutils.CallFromTestFile, !sessions[0].IsCurrent && sessions[1].IsCurrent,
+		"Only the first login family should be current: %+v", sessions)
+	testutils.Equals(t, testutils.CallFromTestFile, current.Timestamp(), sessions[1].CreatedAt)
+
+	sessions = newSessions(logins, primitive.NilObjectID)
+	testutils.Assert(t, testutils.CallFromTestFile, !sessions[0].IsCurrent && !sessions[1].IsCurrent,
+		"No session should be current: %+v", sessions)
+}
+
+func TestE2ESessions(t *testing.T) {
+	_, adminToken, basicUser, _ := setupUserBasicAndAdmin(t)
+
+	t.Cleanup(func() {
+		tearDownBasicAndAdmin(t)
+		tearDownLogins(t, basicUser.ID)
+	})
+
+	sessionsPath := fmt.Sprintf("detail/%s/sessions", basicUser.ID.Hex())
+	login := func(t *testing.T, userAgent string) successfulLogin {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:   "login",
+			Method: http.MethodPost,
+			Payload: authenticatedUser{
+				User:     User{BaseUser: BaseUser{Email: basicUser.Email}},
+				Password: userBasicPassword,
+			},
+			Headers:            map[string]string{"User-Agent": userAgent},
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+
+		var tokens successfulLogin
+		json.NewDecoder(rr.Body).Decode(&tokens)
+		return tokens
+	}
+	listSessions := func(t *testing.T, token string) []Session {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               sessionsPath,
+			Method:             http.MethodGet,
+			AuthToken:          token,
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+
+		var sessions []Session
+		json.NewDecoder(rr.Body).Decode(&sessions)
+		return sessions
+	}
+	getUser := func(t *testing.T, token string, expectedStatus int) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("detail/%s", basicUser.ID.Hex()),
+			Method:             http.MethodGet,
+			AuthToken:          token,
+			ExpectedHTTPStatus: expectedStatus,
+		})
+	}
+
+	laptop := login(t, "Laptop")
+	phone := login(t, "Phone")
+	tablet := login(t, "Tablet")
+
+	t.Run("ListSessions", func(t *testing.T) {
+		sessions := listSessions(t, laptop.Token)
+		testutils.Equals(t, testutils.CallFromTestFile, 3, len(sessions))
+		for _, session := range sessions {
+			testutils.Assert(t, testutils.CallFromTestFile, session.IsCurrent == (session.UserAgent == "Laptop"),
+				"Only the laptop session should be current: %+v", session)
+		}
+	})
+
+	t.Run("RevokeSession", func(t *testing.T) {
+		var phoneSessionID primitive.ObjectID
+		for _, session := range listSessions(t, phone.Token) {
+			if session.IsCurrent {
+				phoneSessionID = session.ID
+			}
+		}
+
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("%s/%s", sessionsPath, phoneSessionID.Hex()),
+			Method:             http.MethodDelete,
+			AuthToken:          laptop.Token,
+			ExpectedHTTPStatus: http.StatusNoContent,
+		})
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("%s/%s", sessionsPath, phoneSessionID.Hex()),
+			Method:             http.MethodDelete,
+			AuthToken:          laptop.Token,
+			ExpectedHTTPStatus: http.StatusNotFound,
+		})
+
+		getUser(t, phone.Token, http.StatusForbidden)
+		testutils.Equals(t, testutils.CallFromTestFile, 2, len(listSessions(t, laptop.Token)))
+	})
+
+	t.Run("RevokeOtherSessions", func(t *testing.T) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               sessionsPath,
+			Method:             http.MethodDelete,
+			AuthToken:          laptop.Token,
+			ExpectedHTTPStatus: http.StatusNoContent,
+		})
+
+		getUser(t, tablet.Token, http.StatusForbidden)
+		getUser(t, laptop.Token, http.StatusOK)
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(listSessions(t, laptop.Token)))
+	})
+
+	t.Run("AdminRevokesAllSessions", func(t *testing.T) {
+		testutils.Equals(t, testutils.CallFromTestFile, 1, len(listSessions(t, adminToken)))
+
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               sessionsPath,
+			Method:             http.MethodDelete,
+			AuthToken:          adminToken,
+			ExpectedHTTPStatus: http.StatusNoContent,
+		})
+
+		getUser(t, laptop.Token, http.StatusForbidden)
+	})
+}
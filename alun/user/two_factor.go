@@ -0,0 +1,345 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/pkg/crypto"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// Issuer displayed by the authenticator apps
+	twoFactorIssuer = "Al-un"
+	// Accepted clock drift, in TOTP periods on each side
+	twoFactorSkew = 1
+	// Validity of the challenge between the password and the code
+	twoFactorChallengeTTL = 5 * time.Minute
+	// Codes allowed per challenge, which limits brute force
+	twoFactorChallengeMaxAttempts = 5
+	// Invalid codes in a row, over all the challenges of an user, locking the
+	// 2FA of the user for twoFactorLockout
+	twoFactorMaxFailures = 10
+	twoFactorLockout     = 15 * time.Minute
+	recoveryCodeCount    = 10
+	recoveryCodeLength   = 10
+)
+
+// twoFactor is the TOTP configuration of an user. The secret is saved at
+// enrollment and 2FA is enabled once the user sends a first valid code
+type twoFactor struct {
+	Secret         string     `bson:"secret"`
+	IsEnabled      bool       `bson:"isEnabled"`
+	RecoveryCodes  []string   `bson:"recoveryCodes,omitempty"` // hashes of the unused recovery codes
+	LastCounter    int64      `bson:"lastCounter"`             // time step of the last used code, which cannot be reused
+	EnabledAt      *time.Time `bson:"enabledAt,omitempty"`
+	FailedAttempts int        `bson:"failedAttempts"` // invalid codes since the last valid one
+	LockedUntil    *time.Time `bson:"lockedUntil,omitempty"`
+}
+
+// loginChallenge is the first step of a login with 2FA: the password is
+// valid and a code is expected
+type loginChallenge struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Hash      string             `bson:"hash"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	Device    loginDevice        `bson:"device"`
+	Attempts  int                `bson:"attempts"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+}
+
+// twoFactorEnrollment is the secret to add to an authenticator app
+type twoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"` // otpauth URI, usually displayed as a QR code
+}
+
+// twoFactorEnrollRequest confirms the identity of the user starting an
+// enrollment, as an access token alone must not allow to bind an authenticator
+type twoFactorEnrollRequest struct {
+	Password string `json:"password"`
+}
+
+// twoFactorCodeRequest is a TOTP code, or a recovery code when logging in
+type twoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// twoFactorRecovery lists the recovery codes, only returned at enrollment
+type twoFactorRecovery struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// twoFactorChallenge is returned instead of the tokens when 2FA is enabled
+type twoFactorChallenge struct {
+	ChallengeToken string `json:"challengeToken"`
+	ExpiresIn      int64  `json:"expiresIn"` // Challenge validity, in seconds
+}
+
+// twoFactorLoginRequest exchanges a challenge and a code for the tokens
+type twoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
+
+// hasTwoFactor is true if the login of an user requires a code
+func (u User) hasTwoFactor() bool {
+	return u.TwoFactor != nil && u.TwoFactor.IsEnabled
+}
+
+// isLocked is true while no code is accepted after too many invalid codes
+func (tf *twoFactor) isLocked(now time.Time) bool {
+	return tf != nil && tf.LockedUntil != nil && tf.LockedUntil.After(now)
+}
+
+// hashSecretCode hashes a challenge token, a recovery code or a personal
+// access token. All are random enough for a fast unsalted hash
+func hashSecretCode(code string) string {
+	h := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(h[:])
+}
+
+// normalizeRecoveryCode ignores the case and the separators of a typed
+// recovery code
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// generateRecoveryCodes returns the recovery codes, such as "abcde-fghij",
+// and their hashes
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		secret, err := crypto.GenerateTOTPSecret()
+		if err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(secret[:recoveryCodeLength])
+		codes = append(codes, code[:recoveryCodeLength/2]+"-"+code[recoveryCodeLength/2:])
+		hashes = append(hashes, hashSecretCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// newLoginChallenge starts the login of an user with 2FA
+func newLoginChallenge(user User, device loginDevice, now time.Time) (loginChallenge, string, error) {
+	token, err := crypto.GenerateRandomString(32)
+	if err != nil {
+		return loginChallenge{}, "", err
+	}
+
+	return loginChallenge{
+		ID:        primitive.NewObjectID(),
+		Hash:      hashSecretCode(token),
+		UserID:    user.ID,
+		Device:    device,
+		ExpiresAt: now.Add(twoFactorChallengeTTL),
+	}, token, nil
+}
+
+// verifyTwoFactorCode checks a TOTP code or a recovery code of an user. Each
+// code can be used only once. As a new login starts a new challenge, invalid
+// codes are also counted per user to lock its 2FA
+func verifyTwoFactorCode(user User, code string, now time.Time) *core.ServiceMessage {
+	if !user.hasTwoFactor() {
+		return twoFactorNotEnrolled
+	}
+	if user.TwoFactor.isLocked(now) {
+		return twoFactorLocked
+	}
+
+	errMsg := checkTwoFactorCode(user, strings.TrimSpace(code), now)
+	if errMsg == twoFactorCodeInvalid {
+		if lockErrMsg := recordTwoFactorFailure(user.ID, now); lockErrMsg != nil {
+			return lockErrMsg
+		}
+	}
+
+	return errMsg
+}
+
+func checkTwoFactorCode(user User, code string, now time.Time) *core.ServiceMessage {
+	if len(code) == crypto.TOTPDigits {
+		counter, isValid, err := crypto.ValidateTOTP(user.TwoFactor.Secret, code, now, twoFactorSkew)
+		if err != nil {
+			return core.NewServiceErrorMessage(err)
+		}
+		if !isValid {
+			return twoFactorCodeInvalid
+		}
+		return useTwoFactorCounter(user.ID, counter)
+	}
+
+	return useRecoveryCode(user.ID, hashSecretCode(normalizeRecoveryCode(code)))
+}
+
+// challengeLogin starts a login with 2FA, the tokens are issued once a code is
+// sent with the challenge token
+func challengeLogin(user User, device loginDevice) func(http.ResponseWriter) {
+	challenge, token, err := newLoginChallenge(user, device, time.Now())
+	if err != nil {
+		return rejectAuthentication("Error when generating challenge")
+	}
+	if err := createLoginChallenge(challenge); err != nil {
+		return rejectAuthentication("Error when generating challenge")
+	}
+
+	return func(w http.ResponseWriter) {
+		json.NewEncoder(w).Encode(twoFactorChallenge{
+			ChallengeToken: token,
+			ExpiresIn:      int64(twoFactorChallengeTTL / time.Second),
+		})
+	}
+}
+
+// handleTwoFactorLogin exchanges a challenge token and a code for the tokens
+func handleTwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	var loginReq twoFactorLoginRequest
+	json.NewDecoder(r.Body).Decode(&loginReq)
+
+	now := time.Now()
+	challenge, errMsg := useLoginChallenge(hashSecretCode(loginReq.ChallengeToken), now)
+	if errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	user, err := findUserByID(challenge.UserID.Hex())
+	if err != nil {
+		twoFactorChallengeInvalid.Write(w, r)
+		return
+	}
+	if user.IsDisabled {
+		rejectAuthentication("Account is disabled")(w)
+		return
+	}
+
+	if errMsg := verifyTwoFactorCode(user, loginReq.Code, now); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	deleteLoginChallenge(challenge.ID)
+
+	login, refreshToken, err := issueLogin(user, primitive.NilObjectID, challenge.Device)
+	if err != nil {
+		rejectAuthentication("Error when generating JWT")(w)
+		return
+	}
+
+	json.NewEncoder(w).Encode(newSuccessfulLogin(login, refreshToken))
+}
+
+// handleEnrollTwoFactor generates a new secret for an user who sends its
+// current password. 2FA is enabled once the secret is confirmed with a code
+func handleEnrollTwoFactor(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	var enrollReq twoFactorEnrollRequest
+	json.NewDecoder(r.Body).Decode(&enrollReq)
+
+	user, err := findUserByID(core.GetVar(r, "userId"))
+	if err != nil {
+		userNotFound.Write(w, r)
+		return
+	}
+	if user.hasTwoFactor() {
+		twoFactorAlreadyEnabled.Write(w, r)
+		return
+	}
+
+	isValid, err := checkUserPassword(user.ID, enrollReq.Password)
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+	if !isValid {
+		passwordInvalid.Write(w, r)
+		return
+	}
+
+	secret, err := crypto.GenerateTOTPSecret()
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+	if errMsg := enrollTwoFactor(user.ID, &twoFactor{Secret: secret}); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(twoFactorEnrollment{
+		Secret: secret,
+		URI:    crypto.TOTPURI(twoFactorIssuer, user.Email, secret),
+	})
+}
+
+// handleConfirmTwoFactor enables 2FA with a first code and returns the
+// recovery codes, which are not displayed again
+func handleConfirmTwoFactor(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	var codeReq twoFactorCodeRequest
+	json.NewDecoder(r.Body).Decode(&codeReq)
+
+	user, err := findUserByID(core.GetVar(r, "userId"))
+	if err != nil {
+		userNotFound.Write(w, r)
+		return
+	}
+	if user.TwoFactor == nil {
+		twoFactorNotEnrolled.Write(w, r)
+		return
+	}
+	if user.hasTwoFactor() {
+		twoFactorAlreadyEnabled.Write(w, r)
+		return
+	}
+
+	now := time.Now()
+	counter, isValid, err := crypto.ValidateTOTP(user.TwoFactor.Secret, strings.TrimSpace(codeReq.Code), now, twoFactorSkew)
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+	if !isValid {
+		twoFactorCodeInvalid.Write(w, r)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		core.HandleServerError(w, r, err)
+		return
+	}
+	if errMsg := enableTwoFactor(user.ID, user.TwoFactor.Secret, hashes, counter, now); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(twoFactorRecovery{RecoveryCodes: codes})
+}
+
+// handleResetTwoFactor removes the 2FA of an user who lost its device, the
+// next login only requires the password
+func handleResetTwoFactor(w http.ResponseWriter, r *http.Request, claims core.JwtClaims) {
+	userID := core.GetVar(r, "userId")
+	if errMsg := checkManagedUser(claims, userID); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+
+	id, _ := primitive.ObjectIDFromHex(userID)
+	if errMsg := resetTwoFactor(id); errMsg != nil {
+		errMsg.Write(w, r)
+		return
+	}
+	auditAction(claims, id, auditUserTwoFactorReset)
+
+	w.WriteHeader(http.StatusNoContent)
+}
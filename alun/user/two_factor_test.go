@@ -0,0 +1,214 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"github.com/Al-un/alun-api/pkg/crypto"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, recoveryCodeCount, len(codes))
+	testutils.Equals(t, testutils.CallFromTestFile, recoveryCodeCount, len(hashes))
+
+	testutils.Equals(t, testutils.CallFromTestFile, recoveryCodeLength+1, len(codes[0]))
+	testutils.Equals(t, testutils.CallFromTestFile, hashes[0], hashSecretCode(normalizeRecoveryCode(codes[0])))
+	testutils.Equals(t, testutils.CallFromTestFile, hashes[0],
+		hashSecretCode(normalizeRecoveryCode(" "+strings.ToUpper(codes[0]))))
+}
+
+func TestNewLoginChallenge(t *testing.T) {
+	now := time.Now()
+	user := User{}
+	user.TwoFactor = &twoFactor{Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", IsEnabled: true}
+	testutils.Assert(t, testutils.CallFromTestFile, user.hasTwoFactor(), "Enabled 2FA should be required")
+
+	challenge, token, err := newLoginChallenge(user, loginDevice{UserAgent: "Firefox"}, now)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Equals(t, testutils.CallFromTestFile, hashSecretCode(token), challenge.Hash)
+	testutils.Equals(t, testutils.CallFromTestFile, now.Add(twoFactorChallengeTTL), challenge.ExpiresAt)
+	testutils.Equals(t, testutils.CallFromTestFile, "Firefox", challenge.Device.UserAgent)
+
+	testutils.Assert(t, testutils.CallFromTestFile, !user.TwoFactor.isLocked(now), "2FA should not be locked")
+	lockedUntil := now.Add(twoFactorLockout)
+	user.TwoFactor.LockedUntil = &lockedUntil
+	testutils.Assert(t, testutils.CallFromTestFile, user.TwoFactor.isLocked(now), "2FA should be locked")
+	testutils.Assert(t, testutils.CallFromTestFile, !user.TwoFactor.isLocked(lockedUntil), "Lockout should be over")
+}
+
+func TestE2ETwoFactor(t *testing.T) {
+	_, adminToken, basicUser, basicToken := setupUserBasicAndAdmin(t)
+	twoFactorPath := fmt.Sprintf("detail/%s/2fa", basicUser.ID.Hex())
+
+	t.Cleanup(func() {
+		tearDownBasicAndAdmin(t)
+		tearDownLogins(t, basicUser.ID)
+	})
+
+	login := func(t *testing.T) map[string]interface{} {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:   "login",
+			Method: http.MethodPost,
+			Payload: authenticatedUser{
+				User:     User{BaseUser: BaseUser{Email: basicUser.Email}},
+				Password: userBasicPassword,
+			},
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+
+		var resp map[string]interface{}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return resp
+	}
+	loginWithCode := func(t *testing.T, challengeToken interface{}, code string, expectedStatus int) {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:   "login/2fa",
+			Method: http.MethodPost,
+			Payload: twoFactorLoginRequest{
+				ChallengeToken: fmt.Sprintf("%v", challengeToken),
+				Code:           code,
+			},
+			ExpectedHTTPStatus: expectedStatus,
+		})
+
+		if expectedStatus == http.StatusOK {
+			var tokens successfulLogin
+			json.NewDecoder(rr.Body).Decode(&tokens)
+			testutils.Assert(t, testutils.CallFromHelperMethod, tokens.Token != "", "Token should be issued")
+		}
+	}
+
+	var enrollment twoFactorEnrollment
+	var recovery twoFactorRecovery
+
+	t.Run("AdminCannotEnrollOtherUser", func(t *testing.T) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               twoFactorPath,
+			Method:             http.MethodPost,
+			AuthToken:          adminToken,
+			ExpectedHTTPStatus: http.StatusUnauthorized,
+		})
+	})
+
+	t.Run("EnrollRequiresPassword", func(t *testing.T) {
+		for _, enrollReq := range []interface{}{nil, twoFactorEnrollRequest{Password: "pouet"}} {
+			apiTester.TestPath(t, testutils.APITestInfo{
+				Path:               twoFactorPath,
+				Method:             http.MethodPost,
+				AuthToken:          basicToken,
+				Payload:            enrollReq,
+				ExpectedHTTPStatus: http.StatusForbidden,
+			})
+		}
+
+		user, _ := findUserByID(basicUser.ID.Hex())
+		testutils.Assert(t, testutils.CallFromTestFile, user.TwoFactor == nil, "No secret should be saved")
+	})
+
+	t.Run("EnrollAndConfirm", func(t *testing.T) {
+		rr := apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               twoFactorPath,
+			Method:             http.MethodPost,
+			AuthToken:          basicToken,
+			Payload:            twoFactorEnrollRequest{Password: userBasicPassword},
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+		json.NewDecoder(rr.Body).Decode(&enrollment)
+		testutils.Assert(t, testutils.CallFromTestFile, strings.HasPrefix(enrollment.URI, "otpauth://totp/"),
+			"Enrollment should have an otpauth URI: %+v", enrollment)
+
+		// Not enabled until confirmed
+		_, hasChallenge := login(t)["challengeToken"]
+		testutils.Assert(t, testutils.CallFromTestFile, !hasChallenge, "Login should not require a code yet")
+
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               twoFactorPath + "/confirm",
+			Method:             http.MethodPost,
+			AuthToken:          basicToken,
+			Payload:            twoFactorCodeRequest{Code: "000000"},
+			ExpectedHTTPStatus: http.StatusUnauthorized,
+		})
+
+		code, _ := crypto.TOTPCode(enrollment.Secret, crypto.TOTPCounter(time.Now()))
+		rr = apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               twoFactorPath + "/confirm",
+			Method:             http.MethodPost,
+			AuthToken:          basicToken,
+			Payload:            twoFactorCodeRequest{Code: code},
+			ExpectedHTTPStatus: http.StatusOK,
+		})
+		json.NewDecoder(rr.Body).Decode(&recovery)
+		testutils.Equals(t, testutils.CallFromTestFile, recoveryCodeCount, len(recovery.RecoveryCodes))
+
+		user, _ := findUserByID(basicUser.ID.Hex())
+		testutils.Assert(t, testutils.CallFromTestFile, user.hasTwoFactor(), "2FA should be enabled")
+		testutils.Assert(t, testutils.CallFromTestFile, user.TwoFactor.RecoveryCodes[0] != recovery.RecoveryCodes[0],
+			"Recovery codes should be stored hashed")
+	})
+
+	t.Run("LoginWithCode", func(t *testing.T) {
+		challenge := login(t)
+		_, hasToken := challenge["token"]
+		testutils.Assert(t, testutils.CallFromTestFile, !hasToken, "Password alone should not issue a token")
+
+		loginWithCode(t, challenge["challengeToken"], "000000", http.StatusUnauthorized)
+		loginWithCode(t, "pouet", "000000", http.StatusUnauthorized)
+
+		// The confirmation code is already used, the next one is accepted
+		// within the clock drift
+		code, _ := crypto.TOTPCode(enrollment.Secret, crypto.TOTPCounter(time.Now())+1)
+		loginWithCode(t, challenge["challengeToken"], code, http.StatusOK)
+
+		// Codes and challenges are single-use
+		loginWithCode(t, challenge["challengeToken"], code, http.StatusUnauthorized)
+		loginWithCode(t, login(t)["challengeToken"], code, http.StatusUnauthorized)
+	})
+
+	t.Run("LoginWithRecoveryCode", func(t *testing.T) {
+		loginWithCode(t, login(t)["challengeToken"], recovery.RecoveryCodes[0], http.StatusOK)
+		loginWithCode(t, login(t)["challengeToken"], recovery.RecoveryCodes[0], http.StatusUnauthorized)
+	})
+
+	t.Run("ChallengeAttemptsAreLimited", func(t *testing.T) {
+		challenge := login(t)
+		for i := 0; i < twoFactorChallengeMaxAttempts; i++ {
+			loginWithCode(t, challenge["challengeToken"], "000000", http.StatusUnauthorized)
+		}
+		loginWithCode(t, challenge["challengeToken"], recovery.RecoveryCodes[1], http.StatusUnauthorized)
+	})
+
+	t.Run("UserAttemptsAreLimited", func(t *testing.T) {
+		// A valid code resets the count of invalid codes
+		loginWithCode(t, login(t)["challengeToken"], recovery.RecoveryCodes[2], http.StatusOK)
+
+		for i := 0; i < twoFactorMaxFailures; i++ {
+			loginWithCode(t, login(t)["challengeToken"], "000000", http.StatusUnauthorized)
+		}
+		loginWithCode(t, login(t)["challengeToken"], recovery.RecoveryCodes[3], http.StatusTooManyRequests)
+	})
+
+	t.Run("AdminReset", func(t *testing.T) {
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("admin/users/%s/2fa", basicUser.ID.Hex()),
+			Method:             http.MethodDelete,
+			AuthToken:          basicToken,
+			ExpectedHTTPStatus: http.StatusUnauthorized,
+		})
+		apiTester.TestPath(t, testutils.APITestInfo{
+			Path:               fmt.Sprintf("admin/users/%s/2fa", basicUser.ID.Hex()),
+			Method:             http.MethodDelete,
+			AuthToken:          adminToken,
+			ExpectedHTTPStatus: http.StatusNoContent,
+		})
+
+		_, hasToken := login(t)["token"]
+		testutils.Assert(t, testutils.CallFromTestFile, hasToken, "Login should only require the password")
+	})
+}
@@ -8,14 +8,19 @@ package user
 import (
 	"os"
 
+	"github.com/Al-un/alun-api/alun/core"
 	"github.com/Al-un/alun-api/alun/utils"
 	"github.com/Al-un/alun-api/pkg/logger"
 )
 
 var (
 	userLogger    logger.Logger
-	pwdSecretSalt string // pwdSecretSalt is used ONLY as a salt for hashing password
+	pwdSecretSalt string // pwdSecretSalt is used ONLY to verify legacy password hashes
 	alunEmail     utils.AlunEmailSender
+
+	// revocationSecret authenticates the services fetching the revoked tokens.
+	// The internal endpoint is disabled if empty
+	revocationSecret string
 )
 
 const (
@@ -39,6 +44,14 @@ func init() {
 		pwdSecretSalt = defaultPwdSalt
 	}
 
+	revocationSecret = os.Getenv(utils.EnvVarRevocationSecret)
+
+	// --- Init signing keys
+	var err error
+	if jwtKeys, err = loadSigningKeys(); err != nil {
+		userLogger.Fatal(1, "[JWT] Signing keys loading failed: %v", err)
+	}
+
 	// --- Init Email
 	if alunEmail == nil {
 		alunEmail = utils.GetAlunEmail()
@@ -49,4 +62,10 @@ func init() {
 
 	// ---- Init API
 	initAPI()
+
+	// ---- Init directory for other packages
+	core.SetUserDirectory(userDirectory{})
+	core.SetJWTPublicKeys(jwtKeys)
+	core.SetTokenRevocationSource(localRevocationSource{}, core.DefaultRevocationRefreshInterval)
+	core.SetPersonalAccessTokenVerifier(localAccessTokenVerifier{})
 }
@@ -2,12 +2,16 @@ package user
 
 import (
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/Al-un/alun-api/pkg/crypto"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // ----------------------------------------------------------------------------
@@ -21,45 +25,130 @@ func rejectAuthentication(reason string) func(http.ResponseWriter) {
 	}
 }
 
-// hashPassword hashes a password with the "pwdSecretSalt" which is appended to
-// the password as a salt
-func hashPassword(clearPassword string) string {
+// hashPassword hashes a password with Argon2id and a per-user salt, as a PHC
+// string
+func hashPassword(clearPassword string) (string, error) {
+	return crypto.HashPassword(clearPassword)
+}
+
+// hashLegacyPassword is the former SHA-512 hash of a password with the
+// "pwdSecretSalt" appended as a global salt. It is only used to verify the
+// passwords which are not rehashed yet
+func hashLegacyPassword(clearPassword string) string {
 	h := sha512.New()
 	h.Write([]byte(clearPassword))
 	h.Write([]byte(pwdSecretSalt))
-	hashedPassword := string(h.Sum(nil))
 
-	userLogger.Verbose("HashPassword: <%s> + <%s> = <%s>", clearPassword, pwdSecretSalt, hashedPassword)
+	return string(h.Sum(nil))
+}
+
+// dummyPasswordHash is an Argon2id hash with the default parameters. It is
+// verified when no user matches an email so that a login costs the same time
+// whether the account exists or not
+const dummyPasswordHash = "$argon2id$v=19$m=65536,t=3,p=4$L4l63bVPryfV/SGcGJt3pA$4OTIG+jLeU8ld1IeKQsG1qD1cMxjiYzkN7z40V4qvGk"
+
+// verifyPasswordHash verifies the PHC hashes. Tests can replace it to check
+// which hashes are verified
+var verifyPasswordHash = crypto.VerifyPassword
+
+// verifyPassword checks a password against its stored hash. needsRehash is
+// true when the password is valid but its hash is a legacy SHA-512 hash or
+// uses outdated parameters
+func verifyPassword(hashedPassword string, clearPassword string) (isValid bool, needsRehash bool) {
+	if !crypto.IsPHCHash(hashedPassword) {
+		isValid = subtle.ConstantTimeCompare([]byte(hashedPassword), []byte(hashLegacyPassword(clearPassword))) == 1
+		return isValid, isValid
+	}
+
+	isValid, needsRehash, err := verifyPasswordHash(hashedPassword, clearPassword)
+	if err != nil {
+		userLogger.Warn("[User] Password hash cannot be verified: %v", err)
+		return false, false
+	}
+
+	return isValid, needsRehash
+}
 
-	return hashedPassword
+// checkCredentials verifies the password of an user. Without user, the dummy
+// hash is verified so that both cases cost a full hash verification
+func checkCredentials(authUser *authenticatedUser, clearPassword string) (isValid bool, needsRehash bool) {
+	if authUser == nil {
+		verifyPassword(dummyPasswordHash, clearPassword)
+		return false, false
+	}
+
+	return verifyPassword(authUser.Password, clearPassword)
 }
 
-func authenticateCredentials(email string, clearPassword string) func(http.ResponseWriter) {
+func authenticateCredentials(email string, clearPassword string, device loginDevice) func(http.ResponseWriter) {
 	user, err := findUserByEmailPassword(email, clearPassword)
 
+	if err == errUserDisabled {
+		return rejectAuthentication("Account is disabled")
+	}
 	if err != nil {
 		return rejectAuthentication("Invalid credentials")
 	}
+	if user.hasTwoFactor() {
+		return challengeLogin(user, device)
+	}
 
-	login, err := findLoginWithValidToken(user)
-	// TODO: assuming error is when no login is found
-	// Also handled expired token
-	if err != nil || login.Token.ExpiresOn.After(time.Now()) {
-		jwt, err := generateJWT(user)
-		if err != nil {
-			return rejectAuthentication("Error when generating JWT")
-		}
-
-		login = Login{UserID: user.ID, Token: jwt, Timestamp: time.Now()}
-		createLogin(login)
+	// A new login starts a new family of refresh tokens
+	login, refreshToken, err := issueLogin(user, primitive.NilObjectID, device)
+	if err != nil {
+		return rejectAuthentication("Error when generating JWT")
 	}
 
 	return func(w http.ResponseWriter) {
-		json.NewEncoder(w).Encode(successfulLogin{Token: login.Token.Jwt})
+		json.NewEncoder(w).Encode(newSuccessfulLogin(login, refreshToken))
+	}
+}
+
+// issueLogin generates and saves the access token and the refresh token of
+// an user. A nil family ID starts a new family
+func issueLogin(user User, familyID primitive.ObjectID, device loginDevice) (Login, string, error) {
+	jwt, err := generateJWT(user)
+	if err != nil {
+		return Login{}, "", err
+	}
+
+	refreshToken, refreshHash, err := generateRefreshToken()
+	if err != nil {
+		return Login{}, "", err
+	}
+
+	now := time.Now()
+	login := Login{
+		ID:               primitive.NewObjectID(),
+		UserID:           user.ID,
+		Token:            jwt,
+		Timestamp:        now,
+		FamilyID:         familyID,
+		RefreshHash:      refreshHash,
+		RefreshExpiresOn: now.Add(refreshTokenDuration),
+		Device:           device,
+	}
+	if login.FamilyID.IsZero() {
+		login.FamilyID = login.ID
+	}
+
+	login, err = createLogin(login)
+	if err != nil {
+		return Login{}, "", err
+	}
+
+	return login, refreshToken, nil
+}
+
+func newSuccessfulLogin(login Login, refreshToken string) successfulLogin {
+	return successfulLogin{
+		Token:        login.Token.Jwt,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(accessTokenDuration / time.Second),
 	}
 }
 
-func authenticateBasic(authHeader string) func(http.ResponseWriter) {
+func authenticateBasic(authHeader string, device loginDevice) func(http.ResponseWriter) {
 	basicAuth := authHeader[6:]
 
 	// https://golang.org/pkg/encoding/base64/#pkg-variables
@@ -78,7 +167,7 @@ func authenticateBasic(authHeader string) func(http.ResponseWriter) {
 	}
 
 	email, password := basicCredentials[0], basicCredentials[1]
-	userLogger.Verbose("Basic authentication with <%s/%s>", email, password)
+	userLogger.Verbose("Basic authentication with <%s>", email)
 
-	return authenticateCredentials(email, password)
+	return authenticateCredentials(email, password, device)
 }
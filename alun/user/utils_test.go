@@ -0,0 +1,85 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Al-un/alun-api/alun/testutils"
+	"github.com/Al-un/alun-api/pkg/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	hashedPassword, err := hashPassword("pouet")
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+
+	isValid, needsRehash := verifyPassword(hashedPassword, "pouet")
+	testutils.Assert(t, testutils.CallFromTestFile, isValid && !needsRehash,
+		"Argon2id password should be valid without rehash: %v, %v", isValid, needsRehash)
+
+	isValid, _ = verifyPassword(hashedPassword, "plop")
+	testutils.Assert(t, testutils.CallFromTestFile, !isValid, "Wrong password should be invalid")
+
+	legacyPassword := hashLegacyPassword("pouet")
+	isValid, needsRehash = verifyPassword(legacyPassword, "pouet")
+	testutils.Assert(t, testutils.CallFromTestFile, isValid && needsRehash,
+		"Legacy password should be valid and rehashed: %v, %v", isValid, needsRehash)
+
+	isValid, needsRehash = verifyPassword(legacyPassword, "plop")
+	testutils.Assert(t, testutils.CallFromTestFile, !isValid && !needsRehash,
+		"Wrong legacy password should be invalid: %v, %v", isValid, needsRehash)
+}
+
+func TestCheckCredentialsVerifiesHash(t *testing.T) {
+	var verifiedHashes []string
+	defer func(previous func(string, string) (bool, bool, error)) { verifyPasswordHash = previous }(verifyPasswordHash)
+	verifyPasswordHash = func(hash string, password string) (bool, bool, error) {
+		verifiedHashes = append(verifiedHashes, hash)
+		return crypto.VerifyPassword(hash, password)
+	}
+
+	hashedPassword, err := hashPassword("pouet")
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+
+	isValid, _ := checkCredentials(&authenticatedUser{Password: hashedPassword}, "plop")
+	testutils.Assert(t, testutils.CallFromTestFile, !isValid, "Wrong password should be invalid")
+	testutils.Equals(t, testutils.CallFromTestFile, []string{hashedPassword}, verifiedHashes)
+
+	// Unknown users go through the same hash verification
+	isValid, _ = checkCredentials(nil, "pouet")
+	testutils.Assert(t, testutils.CallFromTestFile, !isValid, "Unknown user should be invalid")
+	testutils.Equals(t, testutils.CallFromTestFile, []string{hashedPassword, dummyPasswordHash}, verifiedHashes)
+
+	// The dummy hash costs as much as an user hash
+	params := crypto.DefaultArgon2Params
+	defaultPrefix := fmt.Sprintf("$argon2id$v=19$m=%d,t=%d,p=%d$", params.Memory, params.Iterations, params.Threads)
+	testutils.Assert(t, testutils.CallFromTestFile, strings.HasPrefix(dummyPasswordHash, defaultPrefix),
+		"Dummy hash should use the default Argon2id parameters: %s", dummyPasswordHash)
+}
+
+func TestE2ELegacyPasswordRehash(t *testing.T) {
+	basicUser, _ := setupUsers(t, userBasic, userBasicPassword)
+	defer tearDownBasicAndAdmin(t)
+
+	filter := bson.M{"_id": basicUser.ID}
+	update := bson.M{"$set": bson.M{"password": hashLegacyPassword(userBasicPassword)}}
+	_, err := dbUserCollection.UpdateOne(context.TODO(), filter, update)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+
+	_, err = findUserByEmailPassword(userBasicEmail, "wrongPassword")
+	testutils.Assert(t, testutils.CallFromTestFile, err != nil, "Wrong password should be rejected")
+
+	_, err = findUserByEmailPassword(userBasicEmail, userBasicPassword)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+
+	var authUser authenticatedUser
+	err = dbUserCollection.FindOne(context.TODO(), filter).Decode(&authUser)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+	testutils.Assert(t, testutils.CallFromTestFile, strings.HasPrefix(authUser.Password, "$argon2id$"),
+		"Legacy password should be rehashed with Argon2id")
+
+	_, err = findUserByEmailPassword(userBasicEmail, userBasicPassword)
+	testutils.Ok(t, testutils.CallFromTestFile, err)
+}
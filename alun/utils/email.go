@@ -32,6 +32,14 @@ const (
 	EmailTemplateUserRegistration = "user_registration"
 	// EmailTemplateUserPwdReset when user is requesting a password reset
 	EmailTemplateUserPwdReset = "user_pwd-reset"
+	// EmailTemplateUserEmailConfirm when user confirms a new email
+	EmailTemplateUserEmailConfirm = "user_email-confirm"
+	// EmailTemplateUserEmailNotice when the previous email of an user is changed
+	EmailTemplateUserEmailNotice = "user_email-notice"
+	// EmailTemplateMemoItemAssigned when an item is assigned to an user
+	EmailTemplateMemoItemAssigned = "memo_item-assigned"
+	// EmailTemplateMemoBoardActivity when watched boards are changed
+	EmailTemplateMemoBoardActivity = "memo_board-activity"
 )
 
 var (
@@ -15,10 +15,34 @@ const (
 	EnvVarUserPort    = "ALUN_USER_PORT"
 	EnvVarUserDbURL   = "ALUN_USER_DATABASE_URL"
 	EnvVarUserSaltPwd = "ALUN_SECRET_PWD"
-	EnvVarUserSaltJwt = "ALUN_SECRET_JWT"
+	// Directory of the "<kid>.pem" RSA keys signing the JWT, and the key ID
+	// of the current signing key. The last key ID is the current key if empty
+	EnvVarJwtKeysDir    = "ALUN_JWT_KEYS_DIR"
+	EnvVarJwtSigningKid = "ALUN_JWT_SIGNING_KID"
+	// JWKS endpoint of the user service, for other services in microservice mode
+	EnvVarJwksURL = "ALUN_JWKS_URL"
+	// Internal endpoint of the revoked tokens, for other services in microservice mode
+	EnvVarRevocationURL    = "ALUN_REVOCATION_URL"
+	EnvVarRevocationSecret = "ALUN_SECRET_REVOCATION"
+	// Internal endpoint verifying the personal access tokens, with the revocation secret
+	EnvVarAccessTokenURL = "ALUN_ACCESS_TOKEN_URL"
+	// Internal endpoint finding the contact of users, with the revocation secret
+	EnvVarUserDirectoryURL = "ALUN_USER_DIRECTORY_URL"
 	// === Application: Memo
-	EnvVarMemoPort  = "ALUN_MEMO_PORT"
-	EnvVarMemoDbURL = "ALUN_MEMO_DATABASE_URL"
+	EnvVarMemoPort                 = "ALUN_MEMO_PORT"
+	EnvVarMemoDbURL                = "ALUN_MEMO_DATABASE_URL"
+	EnvVarMemoQuotaBoards          = "ALUN_MEMO_QUOTA_BOARDS"
+	EnvVarMemoQuotaMemosPerBoard   = "ALUN_MEMO_QUOTA_MEMOS_PER_BOARD"
+	EnvVarMemoQuotaItemsPerMemo    = "ALUN_MEMO_QUOTA_ITEMS_PER_MEMO"
+	EnvVarMemoQuotaAttachmentBytes = "ALUN_MEMO_QUOTA_ATTACHMENT_BYTES"
+	EnvVarMemoItemsMaxDepth        = "ALUN_MEMO_ITEMS_MAX_DEPTH"
+	EnvVarMemoWatchWindowSeconds   = "ALUN_MEMO_WATCH_WINDOW_SECONDS"
+	EnvVarMemoMentionPolicy        = "ALUN_MEMO_MENTION_POLICY" // "ignore" (default) or "reject"
+	// === Application: Notification
+	EnvVarNotificationPort   = "ALUN_NOTIFICATION_PORT"
+	EnvVarNotificationDbURL  = "ALUN_NOTIFICATION_DATABASE_URL"
+	EnvVarNotificationURL    = "ALUN_NOTIFICATION_URL" // Internal endpoint, for other services in microservice mode
+	EnvVarNotificationSecret = "ALUN_SECRET_NOTIFICATION"
 	// === Email
 	EnvVarEmailUsername = "ALUN_EMAIL_USERNAME"
 	EnvVarEmailPassword = "ALUN_EMAIL_PASSWORD"
@@ -9,6 +9,7 @@ import (
 
 	"github.com/Al-un/alun-api/alun/core"
 	"github.com/Al-un/alun-api/alun/memo"
+	"github.com/Al-un/alun-api/alun/notification"
 	"github.com/Al-un/alun-api/alun/user"
 	"github.com/Al-un/alun-api/alun/utils"
 	"github.com/Al-un/alun-api/pkg/logger"
@@ -36,6 +37,7 @@ func main() {
 		core.APIMonolithic,
 		user.UserAPI,
 		memo.MemoAPI,
+		notification.NotificationAPI,
 	)
 
 	// Go!
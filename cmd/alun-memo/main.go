@@ -31,6 +31,41 @@ func main() {
 		rootLogger.Fatal(1, "Error when fetching port for %s", utils.EnvVarMemoPort)
 	}
 
+	// Notifications are posted to the notification service
+	if notificationURL := os.Getenv(utils.EnvVarNotificationURL); notificationURL != "" {
+		core.SetNotificationTransport(core.NewHTTPNotificationTransport(
+			notificationURL, os.Getenv(utils.EnvVarNotificationSecret)))
+	}
+
+	// Tokens are verified with the public keys of the user service
+	if jwksURL := os.Getenv(utils.EnvVarJwksURL); jwksURL != "" {
+		core.SetJWTPublicKeys(core.NewJWKSKeyProvider(jwksURL, core.DefaultJWKSRefreshInterval))
+	} else {
+		rootLogger.Warn("[JWT] %s is not defined, all tokens will be rejected", utils.EnvVarJwksURL)
+	}
+
+	// Revoked tokens are fetched from the user service
+	if revocationURL := os.Getenv(utils.EnvVarRevocationURL); revocationURL != "" {
+		core.SetTokenRevocationSource(core.NewHTTPRevocationSource(
+			revocationURL, os.Getenv(utils.EnvVarRevocationSecret)), core.DefaultRevocationRefreshInterval)
+	} else {
+		rootLogger.Warn("[Revocation] %s is not defined, revoked tokens are accepted until they expire", utils.EnvVarRevocationURL)
+	}
+
+	// Personal access tokens are verified by the user service
+	if accessTokenURL := os.Getenv(utils.EnvVarAccessTokenURL); accessTokenURL != "" {
+		core.SetPersonalAccessTokenVerifier(core.NewHTTPAccessTokenVerifier(
+			accessTokenURL, os.Getenv(utils.EnvVarRevocationSecret)))
+	}
+
+	// Contacts and timezones of the users are found by the user service
+	if directoryURL := os.Getenv(utils.EnvVarUserDirectoryURL); directoryURL != "" {
+		core.SetUserDirectory(core.NewHTTPUserDirectory(
+			directoryURL, os.Getenv(utils.EnvVarRevocationSecret)))
+	} else {
+		rootLogger.Warn("[Directory] %s is not defined, mentions, watches and timezones are disabled", utils.EnvVarUserDirectoryURL)
+	}
+
 	r := core.SetupRouter(
 		core.APIMicroservice,
 		memo.MemoAPI,
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Al-un/alun-api/alun/core"
+	"github.com/Al-un/alun-api/alun/notification"
+	"github.com/Al-un/alun-api/alun/utils"
+	"github.com/Al-un/alun-api/pkg/logger"
+	"github.com/joho/godotenv"
+)
+
+var serverPort int
+
+func main() {
+	rootLogger := logger.NewConsoleLogger(logger.LogLevelInfo)
+
+	// Env var loading
+	err := godotenv.Load()
+	if err != nil {
+		// rootLogger.Fatal(1, "Error when load .env:\n%v", err)
+	}
+
+	// Server config
+	serverPort, err = strconv.Atoi(os.Getenv(utils.EnvVarNotificationPort))
+	if err != nil {
+		rootLogger.Fatal(1, "Error when fetching port for %s", utils.EnvVarNotificationPort)
+	}
+
+	// Tokens are verified with the public keys of the user service
+	if jwksURL := os.Getenv(utils.EnvVarJwksURL); jwksURL != "" {
+		core.SetJWTPublicKeys(core.NewJWKSKeyProvider(jwksURL, core.DefaultJWKSRefreshInterval))
+	} else {
+		rootLogger.Warn("[JWT] %s is not defined, all tokens will be rejected", utils.EnvVarJwksURL)
+	}
+
+	// Revoked tokens are fetched from the user service
+	if revocationURL := os.Getenv(utils.EnvVarRevocationURL); revocationURL != "" {
+		core.SetTokenRevocationSource(core.NewHTTPRevocationSource(
+			revocationURL, os.Getenv(utils.EnvVarRevocationSecret)), core.DefaultRevocationRefreshInterval)
+	} else {
+		rootLogger.Warn("[Revocation] %s is not defined, revoked tokens are accepted until they expire", utils.EnvVarRevocationURL)
+	}
+
+	// Personal access tokens are verified by the user service
+	if accessTokenURL := os.Getenv(utils.EnvVarAccessTokenURL); accessTokenURL != "" {
+		core.SetPersonalAccessTokenVerifier(core.NewHTTPAccessTokenVerifier(
+			accessTokenURL, os.Getenv(utils.EnvVarRevocationSecret)))
+	}
+
+	r := core.SetupRouter(
+		core.APIMicroservice,
+		notification.NotificationAPI,
+	)
+
+	// Go!
+	rootLogger.Info("[Notification] Starting notification service on port %d...", serverPort)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", serverPort), r))
+}
@@ -31,6 +31,12 @@ func main() {
 		rootLogger.Fatal(1, "Error when fetching port for %s", utils.EnvVarUserPort)
 	}
 
+	// Notifications are posted to the notification service
+	if notificationURL := os.Getenv(utils.EnvVarNotificationURL); notificationURL != "" {
+		core.SetNotificationTransport(core.NewHTTPNotificationTransport(
+			notificationURL, os.Getenv(utils.EnvVarNotificationSecret)))
+	}
+
 	r := core.SetupRouter(
 		core.APIMicroservice,
 		user.UserAPI,
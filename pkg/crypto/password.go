@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Argon2Params are the cost parameters of an Argon2id hash
+type Argon2Params struct {
+	Memory     uint32 // KiB
+	Iterations uint32
+	Threads    uint8
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// DefaultArgon2Params follows the second recommended option of RFC 9106
+var DefaultArgon2Params = Argon2Params{
+	Memory:     64 * 1024,
+	Iterations: 3,
+	Threads:    4,
+	SaltLength: 16,
+	KeyLength:  32,
+}
+
+const argon2idPrefix = "$argon2id$"
+
+// argon2idFormat and bcryptFormat match the whole PHC strings so that legacy
+// hashes, which are raw bytes, are never mistaken for them
+var (
+	argon2idFormat = regexp.MustCompile(`^\$argon2id\$v=\d+\$m=\d+,t=\d+,p=\d+\$[A-Za-z0-9+/]+\$[A-Za-z0-9+/]+$`)
+	bcryptFormat   = regexp.MustCompile(`^\$2[abxy]?\$\d{2}\$[./A-Za-z0-9]{53}$`)
+)
+
+// ErrHashFormat is returned when a stored hash is not a supported PHC string
+var ErrHashFormat = errors.New("password hash format is not supported")
+
+// HashPassword hashes a password with Argon2id and a random salt. The hash is
+// a PHC string which embeds the parameters and the salt, such as
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+func HashPassword(password string) (string, error) {
+	return HashPasswordWithParams(password, DefaultArgon2Params)
+}
+
+// HashPasswordWithParams hashes a password with custom Argon2id parameters
+func HashPasswordWithParams(password string, params Argon2Params) (string, error) {
+	salt, err := GenerateRandomBytes(int(params.SaltLength))
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Threads, params.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version,
+		params.Memory, params.Iterations, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyPassword checks in constant time a password against an Argon2id or a
+// bcrypt PHC string. needsRehash is true when the password matches a hash
+// which is not an Argon2id hash with the default parameters
+func VerifyPassword(hash string, password string) (isValid bool, needsRehash bool, err error) {
+	switch {
+	case argon2idFormat.MatchString(hash):
+		return verifyArgon2id(hash, password)
+	case bcryptFormat.MatchString(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, false, nil
+		}
+		return err == nil, err == nil, err
+	}
+
+	return false, false, ErrHashFormat
+}
+
+// IsPHCHash tells if a stored hash is a PHC string supported by VerifyPassword
+func IsPHCHash(hash string) bool {
+	return argon2idFormat.MatchString(hash) || bcryptFormat.MatchString(hash)
+}
+
+func verifyArgon2id(hash string, password string) (bool, bool, error) {
+	// "", "argon2id", "v=19", "m=65536,t=3,p=4", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false, ErrHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrHashFormat
+	}
+
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Threads); err != nil {
+		return false, false, ErrHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, ErrHashFormat
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Threads, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, computed) != 1 {
+		return false, false, nil
+	}
+
+	return true, params != DefaultArgon2Params, nil
+}
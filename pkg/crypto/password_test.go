@@ -0,0 +1,84 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testParams keeps the tests fast
+var testParams = Argon2Params{Memory: 1024, Iterations: 1, Threads: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("pouet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$") {
+		t.Errorf("Unexpected PHC string %s", hash)
+	}
+
+	isValid, needsRehash, err := VerifyPassword(hash, "pouet")
+	if err != nil || !isValid || needsRehash {
+		t.Errorf("Password should be valid without rehash: %v, %v, %v", isValid, needsRehash, err)
+	}
+
+	isValid, _, err = VerifyPassword(hash, "Pouet")
+	if err != nil || isValid {
+		t.Errorf("Wrong password should be invalid: %v, %v", isValid, err)
+	}
+}
+
+func TestHashPasswordIsSalted(t *testing.T) {
+	first, _ := HashPasswordWithParams("pouet", testParams)
+	second, _ := HashPasswordWithParams("pouet", testParams)
+
+	if first == second {
+		t.Errorf("Identical passwords should have different hashes: %s", first)
+	}
+}
+
+func TestVerifyPasswordNeedsRehash(t *testing.T) {
+	weak, _ := HashPasswordWithParams("pouet", testParams)
+	isValid, needsRehash, err := VerifyPassword(weak, "pouet")
+	if err != nil || !isValid || !needsRehash {
+		t.Errorf("Argon2id hash with other parameters should be rehashed: %v, %v, %v", isValid, needsRehash, err)
+	}
+
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("pouet"), bcrypt.MinCost)
+	isValid, needsRehash, err = VerifyPassword(string(bcryptHash), "pouet")
+	if err != nil || !isValid || !needsRehash {
+		t.Errorf("bcrypt hash should be valid and rehashed: %v, %v, %v", isValid, needsRehash, err)
+	}
+
+	isValid, _, err = VerifyPassword(string(bcryptHash), "plop")
+	if err != nil || isValid {
+		t.Errorf("Wrong bcrypt password should be invalid: %v, %v", isValid, err)
+	}
+}
+
+func TestVerifyPasswordRejectsUnknownFormat(t *testing.T) {
+	for _, hash := range []string{"", "plainsha512", "$argon2id$v=19$m=1024$salt$key", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5"} {
+		if _, _, err := VerifyPassword(hash, "pouet"); err == nil {
+			t.Errorf("Hash <%s> should not be supported", hash)
+		}
+	}
+}
+
+func TestIsPHCHash(t *testing.T) {
+	argon2idHash, _ := HashPasswordWithParams("pouet", testParams)
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("pouet"), bcrypt.MinCost)
+	for _, hash := range []string{argon2idHash, string(bcryptHash)} {
+		if !IsPHCHash(hash) {
+			t.Errorf("Hash <%s> should be a PHC string", hash)
+		}
+	}
+
+	legacy := "$2" + strings.Repeat("\x9f", 62)
+	for _, hash := range []string{"", legacy, "$2a$10$short", argon2idHash + "$extra"} {
+		if IsPHCHash(hash) {
+			t.Errorf("Hash <%s> should not be a PHC string", hash)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters of RFC 6238 supported by all authenticator apps: HMAC-SHA1,
+// 6 digits and a 30 seconds period
+const (
+	TOTPPeriod     = 30 * time.Second
+	TOTPDigits     = 6
+	totpSecretSize = 20
+)
+
+// totpEncoding is the base32 encoding of the secrets, without padding as
+// expected by the otpauth URI
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random 160 bits secret, base32 encoded
+func GenerateTOTPSecret() (string, error) {
+	b, err := GenerateRandomBytes(totpSecretSize)
+	if err != nil {
+		return "", err
+	}
+
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPCounter is the time step of t
+func TOTPCounter(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// TOTPCode computes the code of a base32 secret at the time step counter
+func TOTPCode(secret string, counter int64) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hotp(key, uint64(counter), TOTPDigits), nil
+}
+
+// ValidateTOTP checks a code against the time steps around t, accepting skew
+// steps of clock drift on each side. The matching counter is returned so that
+// callers can reject a code which is used twice
+func ValidateTOTP(secret string, code string, t time.Time, skew int64) (counter int64, isValid bool, err error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(code) != TOTPDigits {
+		return 0, false, nil
+	}
+
+	current := TOTPCounter(t)
+	for counter = current - skew; counter <= current+skew; counter++ {
+		expected := hotp(key, uint64(counter), TOTPDigits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// TOTPURI builds the otpauth URI of a secret, usually displayed as a QR code,
+// as defined by https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func TOTPURI(issuer string, account string, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	params.Set("period", fmt.Sprintf("%d", int(TOTPPeriod/time.Second)))
+
+	label := url.PathEscape(fmt.Sprintf("%s:%s", issuer, account))
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
+
+// decodeTOTPSecret accepts secrets typed by users, in lower case or with
+// spaces
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	return totpEncoding.DecodeString(strings.TrimRight(secret, "="))
+}
+
+// hotp is the HMAC-based one-time password of RFC 4226
+func hotp(key []byte, counter uint64, digits int) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%modulo)
+}
@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 seed of the RFC 6238 test vectors,
+// "12345678901234567890", base32 encoded
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestHOTPMatchesRFCVectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA1 mode with 8 digits
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	key := []byte("12345678901234567890")
+	for unixTime, expected := range vectors {
+		counter := TOTPCounter(time.Unix(unixTime, 0))
+		if code := hotp(key, uint64(counter), 8); code != expected {
+			t.Errorf("Time %d: expected %s, got %s", unixTime, expected, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	code, err := TOTPCode(rfc6238Secret, TOTPCounter(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "081804" {
+		t.Errorf("Expected code 081804, got %s", code)
+	}
+
+	counter, isValid, err := ValidateTOTP(strings.ToLower(rfc6238Secret), code, now.Add(TOTPPeriod), 1)
+	if err != nil || !isValid || counter != TOTPCounter(now) {
+		t.Errorf("Code of the previous step should be valid: %v, %v, %v", counter, isValid, err)
+	}
+
+	if _, isValid, _ = ValidateTOTP(rfc6238Secret, code, now.Add(2*TOTPPeriod), 1); isValid {
+		t.Errorf("Code older than the skew should be invalid")
+	}
+	if _, isValid, _ = ValidateTOTP(rfc6238Secret, "81804", now, 1); isValid {
+		t.Errorf("Code with missing digits should be invalid")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("160 bits secret should have 32 base32 characters: %s", secret)
+	}
+
+	uri := TOTPURI("Al-un", "john@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Al-un:john@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("Unexpected otpauth URI %s", uri)
+	}
+}
@@ -0,0 +1,352 @@
+// Package quickadd parses a short natural language text, such as
+// "buy milk tomorrow 6pm #groceries", into a task with a due date and labels.
+//
+// Dates are relative to a reference time whose location is the timezone of
+// the user: "tomorrow 6pm" is 6pm in this timezone. Recognised expressions:
+//   - days: "today", "tonight", "tomorrow", weekdays ("friday"), optionally
+//     prefixed by "next", "next week", "next month" and ISO dates such as
+//     "2020-03-15". Short weekdays ("fri") must follow "next" or "on"
+//   - times: "6pm", "6:30am", "18:00", "18h", "noon"
+//   - durations: "in 3 days", "in 2 weeks", "in 1 month", "in 2 hours",
+//     "in 30 minutes"
+//   - labels: any word starting with "#"
+//
+// Dates and times can be introduced by "on", "at", "by" or "due". Only the
+// first date and the first time are used, other occurrences are kept in the
+// text
+package quickadd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// LabelPrefix starts a label word
+	LabelPrefix = "#"
+
+	// TonightHour is the time of "tonight" when no time is provided
+	TonightHour = 20
+	// NoonHour is the time of "noon"
+	NoonHour = 12
+)
+
+// Result is a parsed quick add text.
+//
+// Due is zero if the text has no date nor time. A date without time is due at
+// the end of the day and a time without date is due today, or tomorrow if
+// the time has already passed
+type Result struct {
+	Text    string
+	Due     time.Time
+	HasTime bool
+	Labels  []string
+}
+
+var (
+	isoDateRegexp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	// 6pm, 6:30pm, 18:00, 18h30
+	timeRegexp = regexp.MustCompile(`^(\d{1,2})(?:[:h](\d{2}))?(am|pm|h)?$`)
+
+	weekdays = map[string]time.Weekday{
+		"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday,
+		"wednesday": time.Wednesday, "thursday": time.Thursday, "friday": time.Friday,
+		"saturday": time.Saturday,
+	}
+	// Short weekdays are common words ("sun", "sat", "wed") so they are only
+	// dates after "next" or "on"
+	shortWeekdays = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "tues": time.Tuesday,
+		"wed": time.Wednesday, "thu": time.Thursday, "thurs": time.Thursday,
+		"fri": time.Friday, "sat": time.Saturday,
+	}
+
+	connectors = map[string]bool{"on": true, "at": true, "by": true, "due": true}
+)
+
+// parser keeps the state of a parsing: the date and the time are parsed
+// independently and combined at the end
+type parser struct {
+	now    time.Time
+	tokens []string
+	words  []string
+	labels []string
+
+	hasDate bool
+	date    time.Time // Midnight of the due day
+	tonight bool      // The date is due at TonightHour without explicit time
+
+	hasTime bool
+	hour    int
+	minute  int
+
+	// hasInstant is set by durations which define both the date and the time
+	hasInstant bool
+	instant    time.Time
+}
+
+// Parse extracts the due date and the labels of a text. The location of now
+// is the timezone used to interpret the dates
+func Parse(text string, now time.Time) Result {
+	p := &parser{
+		now:    now,
+		tokens: strings.Fields(text),
+		words:  make([]string, 0),
+		labels: make([]string, 0),
+	}
+
+	for idx := 0; idx < len(p.tokens); {
+		idx += p.consume(idx)
+	}
+
+	return p.result()
+}
+
+// normalize lowers a token and strips its trailing punctuation for matching
+func normalize(token string) string {
+	return strings.TrimRight(strings.ToLower(token), ",.;!?")
+}
+
+// consume parses the tokens starting at idx and returns the number of
+// consumed tokens, at least one
+func (p *parser) consume(idx int) int {
+	token := p.tokens[idx]
+
+	if strings.HasPrefix(token, LabelPrefix) && len(normalize(token)) > len(LabelPrefix) {
+		p.labels = append(p.labels, strings.TrimRight(token[len(LabelPrefix):], ",.;!?"))
+		return 1
+	}
+
+	// A connector is only consumed with the date or the time it introduces
+	offset := 0
+	if connectors[normalize(token)] && idx+1 < len(p.tokens) {
+		offset = 1
+	}
+
+	if count := p.consumeDate(idx + offset); count > 0 {
+		return offset + count
+	}
+	if count := p.consumeTime(idx + offset); count > 0 {
+		return offset + count
+	}
+	if offset == 0 {
+		if count := p.consumeDuration(idx); count > 0 {
+			return count
+		}
+	}
+
+	p.words = append(p.words, token)
+	return 1
+}
+
+// peek returns the normalized token at idx, empty if out of bounds
+func (p *parser) peek(idx int) string {
+	if idx >= len(p.tokens) {
+		return ""
+	}
+	return normalize(p.tokens[idx])
+}
+
+// consumeDate parses a day expression at idx
+func (p *parser) consumeDate(idx int) int {
+	if p.hasDate || p.hasInstant {
+		return 0
+	}
+
+	today := time.Date(p.now.Year(), p.now.Month(), p.now.Day(), 0, 0, 0, 0, p.now.Location())
+	token := p.peek(idx)
+
+	switch {
+	case token == "today":
+		p.setDate(today)
+		return 1
+	case token == "tonight":
+		p.setDate(today)
+		p.tonight = true
+		return 1
+	case token == "tomorrow" || token == "tmrw":
+		p.setDate(today.AddDate(0, 0, 1))
+		return 1
+	case token == "next" && p.peek(idx+1) == "week":
+		daysToMonday := (int(time.Monday) - int(today.Weekday()) + 7) % 7
+		if daysToMonday == 0 {
+			daysToMonday = 7
+		}
+		p.setDate(today.AddDate(0, 0, daysToMonday))
+		return 2
+	case token == "next" && p.peek(idx+1) == "month":
+		p.setDate(time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location()))
+		return 2
+	case token == "next":
+		if weekday, ok := findWeekday(p.peek(idx+1), true); ok {
+			p.setDate(nextWeekday(today, weekday))
+			return 2
+		}
+	case isoDateRegexp.MatchString(token):
+		if date, err := time.ParseInLocation("2006-01-02", token, today.Location()); err == nil {
+			p.setDate(date)
+			return 1
+		}
+	}
+
+	introduced := idx > 0 && p.peek(idx-1) == "on"
+	if weekday, ok := findWeekday(token, introduced); ok {
+		p.setDate(nextWeekday(today, weekday))
+		return 1
+	}
+
+	return 0
+}
+
+// consumeTime parses a time expression at idx. A bare number is only a time
+// when introduced by a connector such as "at 6"
+func (p *parser) consumeTime(idx int) int {
+	if p.hasTime || p.hasInstant {
+		return 0
+	}
+
+	token := p.peek(idx)
+	if token == "noon" {
+		p.setTime(NoonHour, 0)
+		return 1
+	}
+
+	// "6 pm" is handled as "6pm"
+	count := 1
+	if next := p.peek(idx + 1); (next == "am" || next == "pm") && !strings.ContainsAny(token, "apm") {
+		token += next
+		count = 2
+	}
+
+	match := timeRegexp.FindStringSubmatch(token)
+	if match == nil {
+		return 0
+	}
+	hour, _ := strconv.Atoi(match[1])
+	minute, _ := strconv.Atoi(match[2])
+	suffix := match[3]
+
+	introduced := idx > 0 && connectors[p.peek(idx-1)]
+	if suffix == "" && match[2] == "" && !introduced {
+		return 0
+	}
+
+	switch suffix {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0
+		}
+		hour = hour % 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	default:
+		if hour > 23 {
+			return 0
+		}
+	}
+	if minute > 59 {
+		return 0
+	}
+
+	p.setTime(hour, minute)
+	return count
+}
+
+// consumeDuration parses "in <n> <unit>" at idx
+func (p *parser) consumeDuration(idx int) int {
+	if p.hasDate || p.hasTime || p.hasInstant || p.peek(idx) != "in" {
+		return 0
+	}
+
+	amount, err := strconv.Atoi(p.peek(idx + 1))
+	if err != nil || amount < 0 {
+		return 0
+	}
+
+	unit := strings.TrimSuffix(p.peek(idx+2), "s")
+	today := time.Date(p.now.Year(), p.now.Month(), p.now.Day(), 0, 0, 0, 0, p.now.Location())
+	switch unit {
+	case "day":
+		p.setDate(today.AddDate(0, 0, amount))
+	case "week":
+		p.setDate(today.AddDate(0, 0, 7*amount))
+	case "month":
+		p.setDate(today.AddDate(0, amount, 0))
+	case "hour":
+		p.hasInstant, p.instant = true, p.now.Add(time.Duration(amount)*time.Hour)
+	case "minute", "min":
+		p.hasInstant, p.instant = true, p.now.Add(time.Duration(amount)*time.Minute)
+	default:
+		return 0
+	}
+
+	return 3
+}
+
+func (p *parser) setDate(date time.Time) {
+	p.hasDate, p.date = true, date
+}
+
+func (p *parser) setTime(hour int, minute int) {
+	p.hasTime, p.hour, p.minute = true, hour, minute
+}
+
+// result combines the parsed date and time
+func (p *parser) result() Result {
+	result := Result{
+		Text:   strings.Join(p.words, " "),
+		Labels: p.labels,
+	}
+	location := p.now.Location()
+
+	switch {
+	case p.hasInstant:
+		result.Due, result.HasTime = p.instant, true
+	case p.hasDate && !p.hasTime && p.tonight:
+		result.Due = time.Date(p.date.Year(), p.date.Month(), p.date.Day(), TonightHour, 0, 0, 0, location)
+		result.HasTime = true
+	case p.hasDate && p.hasTime:
+		result.Due = time.Date(p.date.Year(), p.date.Month(), p.date.Day(), p.hour, p.minute, 0, 0, location)
+		result.HasTime = true
+	case p.hasDate:
+		result.Due = EndOfDay(p.date)
+	case p.hasTime:
+		result.Due = time.Date(p.now.Year(), p.now.Month(), p.now.Day(), p.hour, p.minute, 0, 0, location)
+		if !result.Due.After(p.now) {
+			result.Due = result.Due.AddDate(0, 0, 1)
+		}
+		result.HasTime = true
+	}
+
+	return result
+}
+
+// findWeekday matches a full weekday name, or a short one if allowed
+func findWeekday(token string, allowShort bool) (time.Weekday, bool) {
+	if weekday, ok := weekdays[token]; ok {
+		return weekday, true
+	}
+	if weekday, ok := shortWeekdays[token]; ok && allowShort {
+		return weekday, true
+	}
+
+	return time.Sunday, false
+}
+
+// nextWeekday returns the next occurrence of a weekday strictly after today
+func nextWeekday(today time.Time, weekday time.Weekday) time.Time {
+	days := (int(weekday) - int(today.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+
+	return today.AddDate(0, 0, days)
+}
+
+// EndOfDay returns the last second of the day of t, in the location of t
+func EndOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 0, t.Location())
+}
@@ -0,0 +1,214 @@
+package quickadd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("Timezone database is not available: %v", err)
+	}
+	// Wednesday 11-Mar-2020 10:00 in Paris
+	now := time.Date(2020, time.March, 11, 10, 0, 0, 0, paris)
+
+	cases := []struct {
+		name     string
+		text     string
+		expected Result
+	}{
+		{
+			name:     "NoDate",
+			text:     "buy milk",
+			expected: Result{Text: "buy milk", Labels: []string{}},
+		},
+		{
+			name: "DateTimeAndLabel",
+			text: "buy milk tomorrow 6pm #groceries",
+			expected: Result{
+				Text:    "buy milk",
+				Due:     time.Date(2020, time.March, 12, 18, 0, 0, 0, paris),
+				HasTime: true,
+				Labels:  []string{"groceries"},
+			},
+		},
+		{
+			name: "DateOnlyIsDueAtEndOfDay",
+			text: "Call mom on friday",
+			expected: Result{
+				Text:   "Call mom",
+				Due:    time.Date(2020, time.March, 13, 23, 59, 59, 0, paris),
+				Labels: []string{},
+			},
+		},
+		{
+			name: "SameWeekdayIsNextWeek",
+			text: "Team meeting next wed at 14:30",
+			expected: Result{
+				Text:    "Team meeting",
+				Due:     time.Date(2020, time.March, 18, 14, 30, 0, 0, paris),
+				HasTime: true,
+				Labels:  []string{},
+			},
+		},
+		{
+			name: "PastTimeIsTomorrow",
+			text: "Stand-up at 9",
+			expected: Result{
+				Text:    "Stand-up",
+				Due:     time.Date(2020, time.March, 12, 9, 0, 0, 0, paris),
+				HasTime: true,
+				Labels:  []string{},
+			},
+		},
+		{
+			name: "FutureTimeIsToday",
+			text: "Lunch noon",
+			expected: Result{
+				Text:    "Lunch",
+				Due:     time.Date(2020, time.March, 11, 12, 0, 0, 0, paris),
+				HasTime: true,
+				Labels:  []string{},
+			},
+		},
+		{
+			name: "Tonight",
+			text: "Watch the game tonight",
+			expected: Result{
+				Text:    "Watch the game",
+				Due:     time.Date(2020, time.March, 11, TonightHour, 0, 0, 0, paris),
+				HasTime: true,
+				Labels:  []string{},
+			},
+		},
+		{
+			name: "TonightWithTime",
+			text: "Dinner tonight 9 pm",
+			expected: Result{
+				Text:    "Dinner",
+				Due:     time.Date(2020, time.March, 11, 21, 0, 0, 0, paris),
+				HasTime: true,
+				Labels:  []string{},
+			},
+		},
+		{
+			name: "IsoDate",
+			text: "Pay taxes by 2020-05-15 #admin #money",
+			expected: Result{
+				Text:   "Pay taxes",
+				Due:    time.Date(2020, time.May, 15, 23, 59, 59, 0, paris),
+				Labels: []string{"admin", "money"},
+			},
+		},
+		{
+			name: "DurationInDays",
+			text: "Renew passport in 3 days",
+			expected: Result{
+				Text:   "Renew passport",
+				Due:    time.Date(2020, time.March, 14, 23, 59, 59, 0, paris),
+				Labels: []string{},
+			},
+		},
+		{
+			name: "DurationInHours",
+			text: "Take the cake out in 2 hours",
+			expected: Result{
+				Text:    "Take the cake out",
+				Due:     time.Date(2020, time.March, 11, 12, 0, 0, 0, paris),
+				HasTime: true,
+				Labels:  []string{},
+			},
+		},
+		{
+			name: "NextWeekIsMonday",
+			text: "Plan holidays next week",
+			expected: Result{
+				Text:   "Plan holidays",
+				Due:    time.Date(2020, time.March, 16, 23, 59, 59, 0, paris),
+				Labels: []string{},
+			},
+		},
+		{
+			name: "OnlyFirstDateIsUsed",
+			text: "Move today meeting to tomorrow",
+			expected: Result{
+				Text:   "Move meeting to tomorrow",
+				Due:    time.Date(2020, time.March, 11, 23, 59, 59, 0, paris),
+				Labels: []string{},
+			},
+		},
+		{
+			name:     "NumbersAndConnectorsStayInText",
+			text:     "Buy 3 apples at the market",
+			expected: Result{Text: "Buy 3 apples at the market", Labels: []string{}},
+		},
+		{
+			name:     "ShortWeekdaysStayInText",
+			text:     "buy sun cream",
+			expected: Result{Text: "buy sun cream", Labels: []string{}},
+		},
+		{
+			name:     "ShortWeekdaysAreWords",
+			text:     "Mon Sat Wed wedding photos",
+			expected: Result{Text: "Mon Sat Wed wedding photos", Labels: []string{}},
+		},
+		{
+			name: "ShortWeekdayAfterOn",
+			text: "Sauna on sat",
+			expected: Result{
+				Text:   "Sauna",
+				Due:    time.Date(2020, time.March, 14, 23, 59, 59, 0, paris),
+				Labels: []string{},
+			},
+		},
+		{
+			name: "FullWeekdayWithoutConnector",
+			text: "Call mom sunday",
+			expected: Result{
+				Text:   "Call mom",
+				Due:    time.Date(2020, time.March, 15, 23, 59, 59, 0, paris),
+				Labels: []string{},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result := Parse(c.text, now)
+
+			if result.Text != c.expected.Text {
+				t.Errorf("Text: expected <%s>, got <%s>", c.expected.Text, result.Text)
+			}
+			if !result.Due.Equal(c.expected.Due) {
+				t.Errorf("Due: expected %v, got %v", c.expected.Due, result.Due)
+			}
+			if result.HasTime != c.expected.HasTime {
+				t.Errorf("HasTime: expected %v, got %v", c.expected.HasTime, result.HasTime)
+			}
+			if !reflect.DeepEqual(result.Labels, c.expected.Labels) {
+				t.Errorf("Labels: expected %v, got %v", c.expected.Labels, result.Labels)
+			}
+		})
+	}
+}
+
+func TestParseUsesTimezone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Timezone database is not available: %v", err)
+	}
+
+	// 23:00 UTC on Tuesday is already Wednesday in Tokyo
+	now := time.Date(2020, time.March, 10, 23, 0, 0, 0, time.UTC)
+	result := Parse("Gym tomorrow 7am", now.In(tokyo))
+
+	expected := time.Date(2020, time.March, 12, 7, 0, 0, 0, tokyo)
+	if !result.Due.Equal(expected) {
+		t.Errorf("Due: expected %v, got %v", expected, result.Due)
+	}
+	if result.Due.UTC().Day() != 11 {
+		t.Errorf("Due should be on the 11th in UTC, got %v", result.Due.UTC())
+	}
+}